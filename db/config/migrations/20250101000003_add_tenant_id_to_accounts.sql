@@ -0,0 +1,15 @@
+-- migrate:up
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+
+-- Account IDs are only unique within a tenant
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_pkey;
+ALTER TABLE accounts ADD PRIMARY KEY (tenant_id, id);
+
+CREATE INDEX IF NOT EXISTS idx_accounts_tenant_created_at ON accounts(tenant_id, created_at DESC);
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_accounts_tenant_created_at;
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_pkey;
+ALTER TABLE accounts ADD PRIMARY KEY (id);
+ALTER TABLE accounts DROP COLUMN IF EXISTS tenant_id;
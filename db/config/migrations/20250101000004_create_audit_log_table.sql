@@ -0,0 +1,30 @@
+-- migrate:up
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    tenant_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    method TEXT NOT NULL,
+    target_id BYTEA NOT NULL,
+    result TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_target_id ON audit_log(target_id);
+
+-- Audit rows are append-only
+CREATE OR REPLACE FUNCTION audit_log_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log rows are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_immutable
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_immutable();
+
+-- migrate:down
+DROP TRIGGER IF EXISTS audit_log_immutable ON audit_log;
+DROP FUNCTION IF EXISTS audit_log_immutable();
+DROP INDEX IF EXISTS idx_audit_log_target_id;
+DROP TABLE IF EXISTS audit_log;
@@ -0,0 +1,6 @@
+-- migrate:up
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+-- migrate:down
+ALTER TABLE accounts DROP COLUMN IF EXISTS metadata;
@@ -0,0 +1,18 @@
+-- migrate:up
+
+-- Account names are unique per tenant. The column type decides case sensitivity:
+-- CITEXT (default) also rejects case variants such as "Alice" and "alice",
+-- TEXT only rejects exact duplicates. Switch with a follow-up migration, e.g.
+--   ALTER TABLE accounts ALTER COLUMN name TYPE TEXT;
+-- the unique index is rebuilt with the new type's comparison rules.
+CREATE EXTENSION IF NOT EXISTS citext;
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS name CITEXT;
+UPDATE accounts SET name = convert_from(id, 'UTF8') WHERE name IS NULL;
+ALTER TABLE accounts ALTER COLUMN name SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_tenant_name ON accounts(tenant_id, name);
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_accounts_tenant_name;
+ALTER TABLE accounts DROP COLUMN IF EXISTS name;
@@ -0,0 +1,8 @@
+-- migrate:up
+
+-- Caps the metadata of an account at 16 KiB of JSON text, the limit the repository
+-- checks requests against; merges are only caught here, as the result is built in SQL.
+ALTER TABLE accounts ADD CONSTRAINT accounts_metadata_size CHECK (octet_length(metadata::text) <= 16384);
+
+-- migrate:down
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_metadata_size;
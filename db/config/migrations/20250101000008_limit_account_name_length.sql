@@ -0,0 +1,8 @@
+-- migrate:up
+
+-- Caps account names and the IDs derived from them at 256 bytes, the limit the repository
+-- checks requests against; rows written around the repository are caught here.
+ALTER TABLE accounts ADD CONSTRAINT accounts_name_length CHECK (octet_length(id) <= 256 AND octet_length(name::text) <= 256);
+
+-- migrate:down
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_name_length;
@@ -0,0 +1,34 @@
+-- migrate:up
+
+-- Group membership events, stored as protojson of configuration.v1.ConfigurationEventProto
+CREATE TABLE IF NOT EXISTS configuration_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    tenant_id TEXT NOT NULL,
+    group_id BYTEA NOT NULL,
+    event JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_configuration_events_group ON configuration_events(tenant_id, group_id, id);
+
+-- Wakes group event subscribers; the payload only names the row, subscribers read the event themselves
+CREATE OR REPLACE FUNCTION notify_configuration_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('configuration_events', json_build_object(
+        'id', NEW.id,
+        'tenant_id', NEW.tenant_id,
+        'group_id', encode(NEW.group_id, 'hex')
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notify_configuration_event
+    AFTER INSERT ON configuration_events
+    FOR EACH ROW EXECUTE FUNCTION notify_configuration_event();
+
+-- migrate:down
+DROP TRIGGER IF EXISTS notify_configuration_event ON configuration_events;
+DROP FUNCTION IF EXISTS notify_configuration_event();
+DROP INDEX IF EXISTS idx_configuration_events_group;
+DROP TABLE IF EXISTS configuration_events;
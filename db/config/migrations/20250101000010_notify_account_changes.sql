@@ -0,0 +1,29 @@
+-- migrate:up
+
+-- Wakes account watchers on every committed change; notifications arrive in commit order
+CREATE OR REPLACE FUNCTION notify_account_change() RETURNS trigger AS $$
+DECLARE
+    account accounts;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        account := OLD;
+    ELSE
+        account := NEW;
+    END IF;
+    PERFORM pg_notify('accounts_changes', json_build_object(
+        'op', TG_OP,
+        'tenant_id', account.tenant_id,
+        'id', encode(account.id, 'hex'),
+        'name', account.name::text
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER accounts_changes
+    AFTER INSERT OR UPDATE OR DELETE ON accounts
+    FOR EACH ROW EXECUTE FUNCTION notify_account_change();
+
+-- migrate:down
+DROP TRIGGER IF EXISTS accounts_changes ON accounts;
+DROP FUNCTION IF EXISTS notify_account_change();
@@ -2,14 +2,21 @@ package api
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+	"github.com/berendjan/golang-bazel-starter/golang/framework/clock"
+	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
 	geninterfaces "github.com/berendjan/golang-bazel-starter/golang/generated/interfaces"
 	commonpb "github.com/berendjan/golang-bazel-starter/proto/common/v1"
 	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
@@ -21,15 +28,38 @@ type ConfigurationApi struct {
 	gw.UnimplementedConfigurationServer
 
 	accountRepo geninterfaces.AccountApiSendable
+
+	// Recent listings served while the database is unavailable, nil unless WithDegradedMode
+	listCache *listCache
+
+	// Expires cached listings
+	clock clock.Clock
+
+	// Report deleting a missing account as success instead of NotFound, see WithIdempotentDeletes
+	idempotentDeletes bool
 }
 
 // Build creates a new Configuration service Api
 func NewConfigurationApi(accountRepo geninterfaces.AccountApiSendable) *ConfigurationApi {
 	return &ConfigurationApi{
 		accountRepo: accountRepo,
+		clock:       clock.Real,
 	}
 }
 
+// WithClock expires cached listings by c instead of the system time
+func (s *ConfigurationApi) WithClock(c clock.Clock) *ConfigurationApi {
+	s.clock = c
+	return s
+}
+
+// WithIdempotentDeletes reports deleting an account that doesn't exist as success, code 200, instead of NotFound
+// A retried delete whose first attempt succeeded then doesn't fail, as HTTP expects of DELETE
+func (s *ConfigurationApi) WithIdempotentDeletes() *ConfigurationApi {
+	s.idempotentDeletes = true
+	return s
+}
+
 // CreateAccount creates a new account
 func (s *ConfigurationApi) CreateAccount(
 	ctx context.Context,
@@ -37,7 +67,11 @@ func (s *ConfigurationApi) CreateAccount(
 ) (*configpb.AccountConfigurationProto, error) {
 	// Validate request
 	if req.GetName() == "" {
-		return nil, status.Error(codes.InvalidArgument, "name is required")
+		return nil, invalidField("name", "name is required")
+	}
+	// The name becomes the account ID, so it is held to the ID's limit before reaching the repository
+	if len(req.GetName()) > ids.MaxAccountIDBytes {
+		return nil, invalidField("name", fmt.Sprintf("name is %d bytes, limit is %d", len(req.GetName()), ids.MaxAccountIDBytes))
 	}
 
 	// Wrap request in MiddleOneRequestProto
@@ -48,7 +82,7 @@ func (s *ConfigurationApi) CreateAccount(
 	// Pass proto message directly to repository
 	account, err := s.accountRepo.SendMiddleOneRequestFromAccountApi(ctx, wrappedReq)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to create account: %v", err)
+		return nil, statusError(err, "failed to create account")
 	}
 
 	log.Printf("Created account: %s", req.GetName())
@@ -60,26 +94,77 @@ func (s *ConfigurationApi) DeleteAccount(
 	ctx context.Context,
 	req *configpb.AccountDeletionRequestProto,
 ) (*commonpb.StatusResponseProto, error) {
-	// The ID from HTTP gateway comes base64-encoded, decode it
-	accountKey := req.GetId()
-
-	// Try to decode from base64 (HTTP gateway sends it encoded)
-	if decoded, err := base64.StdEncoding.DecodeString(accountKey); err == nil {
-		accountKey = string(decoded)
-		// Update the request with decoded ID
-		req.Id = accountKey
+	// Both the gateway and gRPC clients send the base64 string form of the ID, never the raw ID, which could
+	// itself be valid base64. Over REST the path takes PathSegment, or String with "/", "+" and "=" percent-encoded
+	accountID, err := ids.ParseAccountID(req.GetId())
+	if err != nil {
+		return nil, invalidField("id", err.Error())
 	}
 
 	// Pass proto message directly to repository
 	response, err := s.accountRepo.SendAccountDeletionRequestFromAccountApi(ctx, req)
+	if errors.Is(err, db.ErrNotFound) && s.idempotentDeletes {
+		log.Printf("Account already deleted: %s", accountID)
+		return &commonpb.StatusResponseProto{
+			Code:    200,
+			Message: "Account already deleted: " + accountID.String(),
+		}, nil
+	}
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to delete account: %v", err)
+		err = statusError(err, "failed to delete account")
+		if status.Code(err) == codes.NotFound {
+			return nil, notFound(ReasonAccountNotFound, accountID.String(), status.Convert(err).Message())
+		}
+		return nil, err
 	}
 
-	log.Printf("Deleted account: %s", accountKey)
+	log.Printf("Deleted account: %s", accountID)
 	return response, nil
 }
 
+// GetAccount returns an account with its metadata
+func (s *ConfigurationApi) GetAccount(
+	ctx context.Context,
+	req *configpb.GetAccountRequestProto,
+) (*configpb.AccountConfigurationProto, error) {
+	accountID, err := ids.ParseAccountID(req.GetId())
+	if err != nil {
+		return nil, invalidField("id", err.Error())
+	}
+
+	account, err := s.accountRepo.SendGetAccountRequestFromAccountApi(ctx, req)
+	if status.Code(err) == codes.NotFound {
+		return nil, notFound(ReasonAccountNotFound, accountID.String(), status.Convert(err).Message())
+	}
+	if err != nil {
+		return nil, statusError(err, "failed to get account")
+	}
+	return account, nil
+}
+
+// UpdateAccount writes the fields named in the request's update mask
+func (s *ConfigurationApi) UpdateAccount(
+	ctx context.Context,
+	req *configpb.AccountUpdateRequestProto,
+) (*configpb.AccountConfigurationProto, error) {
+	accountID, err := ids.ParseAccountID(req.GetId())
+	if err != nil {
+		return nil, invalidField("id", err.Error())
+	}
+
+	// The repository allow-lists the mask paths and rejects unknown ones with InvalidArgument
+	account, err := s.accountRepo.SendAccountUpdateRequestFromAccountApi(ctx, req)
+	if status.Code(err) == codes.NotFound {
+		return nil, notFound(ReasonAccountNotFound, accountID.String(), status.Convert(err).Message())
+	}
+	if err != nil {
+		return nil, statusError(err, "failed to update account")
+	}
+
+	log.Printf("Updated account: %s", accountID)
+	return account, nil
+}
+
 // ListAccounts lists all accounts
 func (s *ConfigurationApi) ListAccounts(
 	ctx context.Context,
@@ -88,18 +173,229 @@ func (s *ConfigurationApi) ListAccounts(
 	// Pass proto message directly to repository
 	response, err := s.accountRepo.SendListAccountsRequestFromAccountApi(ctx, req)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to list accounts: %v", err)
+		if cached, ok := s.listCache.fallback(ctx, req, err, s.clock.Now()); ok {
+			return cached, nil
+		}
+		return nil, statusError(err, "failed to list accounts")
 	}
 
+	s.listCache.store(ctx, req, response, s.clock.Now())
 	return response, nil
 }
 
+// CountAccounts counts the caller's accounts, honoring the same filters as ListAccounts
+func (s *ConfigurationApi) CountAccounts(
+	ctx context.Context,
+	req *configpb.CountAccountsRequestProto,
+) (*configpb.CountAccountsResponseProto, error) {
+	response, err := s.accountRepo.SendCountAccountsRequestFromAccountApi(ctx, req)
+	if err != nil {
+		return nil, statusError(err, "failed to count accounts")
+	}
+	return response, nil
+}
+
+// maxExportBatchSize caps the batch size an export request may ask for
+const maxExportBatchSize = 10000
+
+// ExportAccounts streams all accounts of the caller's tenant in batches
+// The repository reads them through a cursor; a cancelled stream stops the scan
+func (s *ConfigurationApi) ExportAccounts(
+	req *configpb.ExportAccountsRequestProto,
+	stream grpc.ServerStreamingServer[configpb.ExportAccountsResponseProto],
+) error {
+	if req.GetBatchSize() > maxExportBatchSize {
+		return invalidField("batch_size", fmt.Sprintf("batch_size must be at most %d", maxExportBatchSize))
+	}
+
+	ctx := stream.Context()
+	batches, err := s.accountRepo.SendExportAccountsRequestFromAccountApi(ctx, req)
+	if err != nil {
+		return statusError(err, "failed to export accounts")
+	}
+
+	for batch, err := range batches {
+		if err != nil {
+			return statusError(err, "failed to export accounts")
+		}
+		// Returning stops the iteration, which closes the cursor
+		if err := stream.Send(&configpb.ExportAccountsResponseProto{Accounts: batch}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GroupEventStream streams the events of the group the first message subscribes to, stored ones first
+// Later messages are membership actions, stored as events so every subscriber of the group receives them;
+// the client closing its side ends only the actions, and a failed action ends the stream with its error
+func (s *ConfigurationApi) GroupEventStream(
+	stream grpc.BidiStreamingServer[configpb.GroupEventStreamRequestProto, configpb.ConfigurationEventProto],
+) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	subscribe := first.GetSubscribe()
+	if subscribe == nil {
+		return invalidField("subscribe", "the first message must subscribe to a group")
+	}
+
+	ctx, cancel := context.WithCancelCause(stream.Context())
+	defer cancel(nil)
+
+	events, err := s.accountRepo.SendSubscribeGroupEventsFromAccountApi(ctx, subscribe)
+	if err != nil {
+		return statusError(err, "failed to subscribe to group events")
+	}
+
+	// Actions are received while events are sent; a failed action cancels the subscription
+	go func() {
+		if err := s.receiveGroupActions(ctx, stream); err != nil {
+			cancel(err)
+		}
+	}()
+
+	for event, err := range events {
+		if err != nil {
+			return statusError(err, "failed to stream group events")
+		}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+
+	// The events only end once ctx is done: the client went away or an action failed
+	if err := context.Cause(ctx); err != nil {
+		return statusError(err, "failed to process group action")
+	}
+	return nil
+}
+
+// receiveGroupActions dispatches the membership actions of stream until the client closes its side
+// Actions are authenticated and audited like account mutations; an unauthenticated one ends the stream
+func (s *ConfigurationApi) receiveGroupActions(
+	ctx context.Context,
+	stream grpc.BidiStreamingServer[configpb.GroupEventStreamRequestProto, configpb.ConfigurationEventProto],
+) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch action := req.GetAction().(type) {
+		case *configpb.GroupEventStreamRequestProto_RequestToJoin:
+			err = s.accountRepo.SendRequestToJoinGroupFromAccountApi(ctx, action.RequestToJoin)
+		case *configpb.GroupEventStreamRequestProto_AcceptRequestToJoin:
+			err = s.accountRepo.SendAcceptRequestToJoinGroupFromAccountApi(ctx, action.AcceptRequestToJoin)
+		default:
+			err = invalidField("action", "only the first message may subscribe")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// WatchAccounts streams the changes to the caller's accounts in commit order until the client cancels
+// The response header is sent once the server is watching, so every change committed after it arrives is streamed
+func (s *ConfigurationApi) WatchAccounts(
+	req *configpb.WatchAccountsRequestProto,
+	stream grpc.ServerStreamingServer[configpb.AccountChangeProto],
+) error {
+	changes, err := s.accountRepo.SendWatchAccountsRequestFromAccountApi(stream.Context(), req)
+	if err != nil {
+		return statusError(err, "failed to watch accounts")
+	}
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for change, err := range changes {
+		if err != nil {
+			return statusError(err, "failed to watch accounts")
+		}
+		if err := stream.Send(change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invalidField returns an InvalidArgument error with a google.rpc.BadRequest violation of field
+// Clients map the violation to the matching input; the HTTP gateway renders it in the error body's details
+func invalidField(field, description string) error {
+	st, err := status.New(codes.InvalidArgument, description).WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: field, Description: description},
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to attach field violation for %s: %v", field, err)
+		return status.Error(codes.InvalidArgument, description)
+	}
+	return st.Err()
+}
+
+// ReasonAccountNotFound is the google.rpc.ErrorInfo reason of a NotFound for an account ID
+const ReasonAccountNotFound = "ACCOUNT_NOT_FOUND"
+
+// notFound returns a NotFound error with a google.rpc.ErrorInfo carrying reason and the missing id
+// Clients match on the reason instead of the message; the HTTP gateway renders it in the error body's details
+func notFound(reason, id, description string) error {
+	st, err := status.New(codes.NotFound, description).WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   gw.Configuration_ServiceDesc.ServiceName,
+		Metadata: map[string]string{"id": id},
+	})
+	if err != nil {
+		log.Printf("Failed to attach error info for %s: %v", id, err)
+		return status.Error(codes.NotFound, description)
+	}
+	return st.Err()
+}
+
+// statusError preserves gRPC status errors from downstream handlers, maps duplicates to AlreadyExists,
+// missing rows to NotFound, an exhausted connection pool to ResourceExhausted, an unreachable database to Unavailable,
+// context errors to Canceled or DeadlineExceeded, transaction conflicts to Aborted and wraps anything else as Internal
+func statusError(err error, msg string) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, db.ErrPoolExhausted) {
+		return status.Errorf(codes.ResourceExhausted, "%s: %v", msg, err)
+	}
+	if db.IsUnavailable(err) {
+		return status.Errorf(codes.Unavailable, "%s: %v", msg, err)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	if errors.Is(err, db.ErrDuplicate) {
+		return status.Errorf(codes.AlreadyExists, "%s: %v", msg, err)
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		return status.Errorf(codes.NotFound, "%s: %v", msg, err)
+	}
+	// A conflict with a concurrent transaction that outlasted db.RetryOnSerialization; the client may retry
+	if db.IsSerializationFailure(err) {
+		return status.Errorf(codes.Aborted, "%s: %v", msg, err)
+	}
+	return status.Errorf(codes.Internal, "%s: %v", msg, err)
+}
+
 // RegisterGRPC implements server_builder.GRPCServiceRegistrar
 func (s *ConfigurationApi) RegisterGRPC(Api grpc.ServiceRegistrar) {
 	gw.RegisterConfigurationServer(Api, s)
 }
 
 // RegisterGateway implements server_builder.HTTPGatewayRegistrar
+// The gateway calls the API in-process, so HTTP requests open no connection to the gRPC port
 func (s *ConfigurationApi) RegisterGateway(ctx context.Context, mux *runtime.ServeMux) error {
 	return gw.RegisterConfigurationHandlerServer(ctx, mux, s)
 }
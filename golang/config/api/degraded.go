@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/tenant"
+	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
+)
+
+// WithDegradedMode keeps every ListAccounts response for cacheTTL and serves it while the database is unavailable
+// Writes still fail with Unavailable; pair it with a serverbase readiness check so the server also reports not ready
+func (s *ConfigurationApi) WithDegradedMode(cacheTTL time.Duration) *ConfigurationApi {
+	s.listCache = &listCache{
+		ttl:     cacheTTL,
+		entries: make(map[listCacheKey]cachedList),
+	}
+	return s
+}
+
+// listCache holds recent ListAccounts responses by tenant and request
+type listCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[listCacheKey]cachedList
+}
+
+// listCacheKey identifies a listing; accounts are scoped by tenant, not by user
+type listCacheKey struct {
+	tenantID string
+	request  string
+}
+
+// cachedList is a ListAccounts response served until expires
+type cachedList struct {
+	response *configpb.ListAccountsResponseProto
+	expires  time.Time
+}
+
+// store remembers response for req at now, dropping expired entries; a nil cache stores nothing
+func (c *listCache) store(ctx context.Context, req *configpb.ListAccountsRequestProto, response *configpb.ListAccountsResponseProto, now time.Time) {
+	if c == nil {
+		return
+	}
+	key, ok := newListCacheKey(ctx, req)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedList{
+		response: proto.Clone(response).(*configpb.ListAccountsResponseProto),
+		expires:  now.Add(c.ttl),
+	}
+}
+
+// fallback returns the response stored for req, if unexpired at now, when err means the database is unavailable
+func (c *listCache) fallback(ctx context.Context, req *configpb.ListAccountsRequestProto, err error, now time.Time) (*configpb.ListAccountsResponseProto, bool) {
+	if c == nil || !db.IsUnavailable(err) {
+		return nil, false
+	}
+	key, ok := newListCacheKey(ctx, req)
+	if !ok {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok || now.After(entry.expires) {
+		return nil, false
+	}
+
+	log.Printf("Database unavailable, serving accounts cached until %s: %v", entry.expires.Format(time.RFC3339), err)
+	return proto.Clone(entry.response).(*configpb.ListAccountsResponseProto), true
+}
+
+// newListCacheKey keys req by the caller's tenant and the request's deterministic encoding
+func newListCacheKey(ctx context.Context, req *configpb.ListAccountsRequestProto) (listCacheKey, bool) {
+	request, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return listCacheKey{}, false
+	}
+	return listCacheKey{tenantID: tenant.TenantIDFromContext(ctx), request: string(request)}, true
+}
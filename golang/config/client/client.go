@@ -2,18 +2,54 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
+	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/encoding/gzip" // Register the gzip compressor
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/tenant"
 	commonpb "github.com/berendjan/golang-bazel-starter/proto/common/v1"
 	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
 	gw "github.com/berendjan/golang-bazel-starter/proto/configuration_service/v1/gateway"
 )
 
+// SessionCookieName is the cookie holding the Kratos session
+const SessionCookieName = "ory_kratos_session"
+
+// CredentialSource returns the Cookie header authenticating a call, e.g. "ory_kratos_session=..."
+// It runs before every call, so it can return a refreshed session
+type CredentialSource func(ctx context.Context) (string, error)
+
+// StaticCookie sends the same Cookie header on every call
+func StaticCookie(cookie string) CredentialSource {
+	return func(context.Context) (string, error) {
+		return cookie, nil
+	}
+}
+
+// SessionToken sends token as the Kratos session cookie on every call
+func SessionToken(token string) CredentialSource {
+	return StaticCookie(SessionCookieName + "=" + token)
+}
+
 var (
 	clientInstance *ConfigurationClient
 	clientOnce     sync.Once
@@ -31,7 +67,40 @@ type Config struct {
 	ServerAddress string
 
 	// Insecure determines whether to use insecure connection (default: true)
+	// When false the connection uses TLS configured by the fields below
 	Insecure bool
+
+	// CAFile is the CA certificate used to verify the server (default: system roots)
+	CAFile string
+
+	// CertFile and KeyFile are the client certificate presented for mTLS (default: none)
+	CertFile string
+	KeyFile  string
+
+	// ServerNameOverride is verified against the server certificate and sent as SNI instead of the dial host
+	// Use it when the dial address differs from the certificate name, e.g. through a port-forward
+	ServerNameOverride string
+
+	// InsecureSkipVerify disables server certificate verification
+	// TEST ONLY: this accepts any certificate and must never be used in production
+	InsecureSkipVerify bool
+
+	// TenantID is sent as "x-tenant-id" metadata on every call (default: none)
+	TenantID string
+
+	// Credentials supplies the session cookie sent as "cookie" metadata on every call (default: none)
+	// Use StaticCookie, SessionToken, or a func that fetches the current session
+	Credentials CredentialSource
+
+	// ReconnectMaxDelay caps the exponential backoff between reconnection attempts (default: gRPC default of 120s)
+	ReconnectMaxDelay time.Duration
+
+	// Compression compresses requests with the named compressor, e.g. "gzip" (default: none)
+	// Responses are decompressed automatically with whichever registered compressor the server chose
+	Compression string
+
+	// WaitForConnTimeout makes every call and stream wait up to this long for the connection to be READY (default: 0, no wait)
+	WaitForConnTimeout time.Duration
 }
 
 // DefaultConfig returns default client configuration
@@ -42,6 +111,37 @@ func DefaultConfig() *Config {
 	}
 }
 
+// tlsConfig builds the TLS configuration used when Insecure is false
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerNameOverride,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CAFile != "" {
+		caCert, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file %s: %w", c.CAFile, err)
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to parse CA certificate from %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate from %s and %s: %w", c.CertFile, c.KeyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
 // NewClient creates a new Configuration service client
 func NewClient(ctx context.Context, cfg *Config) (*ConfigurationClient, error) {
 	if cfg == nil {
@@ -51,8 +151,40 @@ func NewClient(ctx context.Context, cfg *Config) (*ConfigurationClient, error) {
 	var opts []grpc.DialOption
 	if cfg.Insecure {
 		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		tlsConfig, err := cfg.tlsConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure TLS: %w", err)
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	if cfg.ReconnectMaxDelay > 0 {
+		backoffConfig := backoff.DefaultConfig
+		backoffConfig.MaxDelay = cfg.ReconnectMaxDelay
+		opts = append(opts, grpc.WithConnectParams(grpc.ConnectParams{Backoff: backoffConfig}))
+	}
+
+	if cfg.Compression != "" {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(cfg.Compression)))
 	}
 
+	var interceptors []grpc.UnaryClientInterceptor
+	var streamInterceptors []grpc.StreamClientInterceptor
+	if cfg.WaitForConnTimeout > 0 {
+		interceptors = append(interceptors, waitForConnInterceptor(cfg.WaitForConnTimeout))
+		streamInterceptors = append(streamInterceptors, waitForConnStreamInterceptor(cfg.WaitForConnTimeout))
+	}
+	if cfg.TenantID != "" {
+		interceptors = append(interceptors, tenantInterceptor(cfg.TenantID))
+		streamInterceptors = append(streamInterceptors, tenantStreamInterceptor(cfg.TenantID))
+	}
+	if cfg.Credentials != nil {
+		interceptors = append(interceptors, credentialsInterceptor(cfg.Credentials))
+		streamInterceptors = append(streamInterceptors, credentialsStreamInterceptor(cfg.Credentials))
+	}
+	opts = append(opts, grpc.WithChainUnaryInterceptor(interceptors...))
+	opts = append(opts, grpc.WithChainStreamInterceptor(streamInterceptors...))
+
 	// Use passthrough resolver for localhost to avoid slow DNS resolution
 	target := cfg.ServerAddress
 	if strings.HasPrefix(target, "localhost") || strings.HasPrefix(target, "127.0.0.1") {
@@ -70,6 +202,94 @@ func NewClient(ctx context.Context, cfg *Config) (*ConfigurationClient, error) {
 	}, nil
 }
 
+// tenantInterceptor attaches the tenant ID to the outgoing metadata of every call
+func tenantInterceptor(tenantID string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, tenant.MetadataKey, tenantID)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// tenantStreamInterceptor attaches the tenant ID to the outgoing metadata of every stream
+func tenantStreamInterceptor(tenantID string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx = metadata.AppendToOutgoingContext(ctx, tenant.MetadataKey, tenantID)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// withCredentials attaches the cookie from creds to the outgoing metadata, as the server's auth middleware expects
+func withCredentials(ctx context.Context, creds CredentialSource) (context.Context, error) {
+	cookie, err := creds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+	if cookie == "" {
+		return ctx, nil
+	}
+	return metadata.AppendToOutgoingContext(ctx, "cookie", cookie), nil
+}
+
+// credentialsInterceptor attaches the session cookie to the outgoing metadata of every call
+func credentialsInterceptor(creds CredentialSource) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := withCredentials(ctx, creds)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// credentialsStreamInterceptor attaches the session cookie to the outgoing metadata of every stream
+func credentialsStreamInterceptor(creds CredentialSource) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := withCredentials(ctx, creds)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// waitForConnInterceptor waits up to timeout for the connection to be READY before every call
+// If the connection is still not ready the call proceeds and reports the transport error itself
+func waitForConnInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		waitForReady(waitCtx, cc)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// waitForConnStreamInterceptor waits up to timeout for the connection to be READY before opening every stream
+// If the connection is still not ready the stream opens anyway and reports the transport error itself
+func waitForConnStreamInterceptor(timeout time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		waitForReady(waitCtx, cc)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// waitForReady blocks until conn is READY or ctx is done, triggering a connection attempt if idle
+func waitForReady(ctx context.Context, conn *grpc.ClientConn) error {
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if state == connectivity.Idle {
+			conn.Connect()
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("connection not ready (state %s): %w", state, ctx.Err())
+		}
+	}
+}
+
 // MustNewClient creates a new client or panics on error
 func MustNewClient(ctx context.Context, cfg *Config) *ConfigurationClient {
 	client, err := NewClient(ctx, cfg)
@@ -100,6 +320,17 @@ func (c *ConfigurationClient) Close() error {
 	return nil
 }
 
+// State returns the connectivity state of the client connection, connectivity.Shutdown once closed
+func (c *ConfigurationClient) State() connectivity.State {
+	return c.conn.GetState()
+}
+
+// WaitForConn blocks until the connection to the server is READY or ctx is done
+// Use it after a server restart to wait for gRPC to reconnect instead of failing with Unavailable
+func (c *ConfigurationClient) WaitForConn(ctx context.Context) error {
+	return waitForReady(ctx, c.conn)
+}
+
 // CreateAccount creates a new account
 func (c *ConfigurationClient) CreateAccount(ctx context.Context, name string) (*configpb.AccountConfigurationProto, error) {
 	req := &configpb.AccountCreationRequestProto{
@@ -114,10 +345,30 @@ func (c *ConfigurationClient) CreateAccount(ctx context.Context, name string) (*
 	return resp, nil
 }
 
+// CreateAccountWithMetadata creates a new account with arbitrary JSON metadata
+func (c *ConfigurationClient) CreateAccountWithMetadata(ctx context.Context, name string, metadata map[string]any) (*configpb.AccountConfigurationProto, error) {
+	metadataStruct, err := structpb.NewStruct(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert metadata: %w", err)
+	}
+
+	req := &configpb.AccountCreationRequestProto{
+		Name:     name,
+		Metadata: metadataStruct,
+	}
+
+	resp, err := c.client.CreateAccount(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account: %w", err)
+	}
+
+	return resp, nil
+}
+
 // DeleteAccount deletes an account by ID
-func (c *ConfigurationClient) DeleteAccount(ctx context.Context, accountID string) (*commonpb.StatusResponseProto, error) {
+func (c *ConfigurationClient) DeleteAccount(ctx context.Context, accountID ids.AccountID) (*commonpb.StatusResponseProto, error) {
 	req := &configpb.AccountDeletionRequestProto{
-		Id: accountID,
+		Id: accountID.String(),
 	}
 
 	resp, err := c.client.DeleteAccount(ctx, req)
@@ -128,6 +379,73 @@ func (c *ConfigurationClient) DeleteAccount(ctx context.Context, accountID strin
 	return resp, nil
 }
 
+// GetAccount returns an account with its metadata
+func (c *ConfigurationClient) GetAccount(ctx context.Context, accountID ids.AccountID) (*configpb.AccountConfigurationProto, error) {
+	req := &configpb.GetAccountRequestProto{
+		Id: accountID.String(),
+	}
+
+	resp, err := c.client.GetAccount(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+
+	return resp, nil
+}
+
+// ErrNoUpdatePaths reports an UpdateAccount call naming no field to write
+var ErrNoUpdatePaths = errors.New("update requires at least one path")
+
+// UpdateAccount writes the fields of an account named in paths ("name", "metadata"), leaving the others untouched
+// At least one path is required: the server reads an empty mask as every field, overwriting the unnamed ones
+// with zero values, so a call without paths fails with ErrNoUpdatePaths before reaching the server
+func (c *ConfigurationClient) UpdateAccount(ctx context.Context, accountID ids.AccountID, name string, metadata map[string]any, paths ...string) (*configpb.AccountConfigurationProto, error) {
+	if len(paths) == 0 {
+		return nil, ErrNoUpdatePaths
+	}
+
+	req := &configpb.AccountUpdateRequestProto{
+		Id:         accountID.String(),
+		Name:       name,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: paths},
+	}
+	if metadata != nil {
+		metadataStruct, err := structpb.NewStruct(metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert metadata: %w", err)
+		}
+		req.Metadata = metadataStruct
+	}
+
+	resp, err := c.client.UpdateAccount(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update account: %w", err)
+	}
+
+	return resp, nil
+}
+
+// AccountIDString returns the ID of account as standard base64, the form DeleteAccountByIDString accepts
+// Account IDs are raw bytes, e.g. binary UUIDs, so use this instead of converting them with string()
+// It matches ids.AccountID.String and the "id" field of accounts in HTTP gateway responses
+func AccountIDString(account *configpb.AccountConfigurationProto) string {
+	return ids.AccountIDFromProto(account.GetAccountId()).String()
+}
+
+// AccountTypeName returns the human name of the type of account for display, e.g. "account"
+func AccountTypeName(account *configpb.AccountConfigurationProto) string {
+	return ids.AccountTypeName(account.GetAccountId().GetType())
+}
+
+// DeleteAccountByIDString deletes the account with the base64 ID id, standard or URL-safe, with or without padding
+func (c *ConfigurationClient) DeleteAccountByIDString(ctx context.Context, id string) (*commonpb.StatusResponseProto, error) {
+	accountID, err := ids.ParseAccountID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete account: %w", err)
+	}
+	return c.DeleteAccount(ctx, accountID)
+}
+
 // ListAccounts lists all accounts
 func (c *ConfigurationClient) ListAccounts(ctx context.Context) ([]*configpb.AccountConfigurationProto, error) {
 	req := &configpb.ListAccountsRequestProto{}
@@ -139,3 +457,152 @@ func (c *ConfigurationClient) ListAccounts(ctx context.Context) ([]*configpb.Acc
 
 	return resp.GetAccounts(), nil
 }
+
+// ListAccountsByDateRange lists accounts created between from and to (inclusive), oldest first
+// A zero from or to leaves that bound open
+func (c *ConfigurationClient) ListAccountsByDateRange(ctx context.Context, from, to time.Time) ([]*configpb.AccountConfigurationProto, error) {
+	req := &configpb.ListAccountsRequestProto{}
+	if !from.IsZero() {
+		req.CreatedAfter = timestamppb.New(from)
+	}
+	if !to.IsZero() {
+		req.CreatedBefore = timestamppb.New(to)
+	}
+
+	resp, err := c.client.ListAccounts(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts by date range: %w", err)
+	}
+
+	return resp.GetAccounts(), nil
+}
+
+// CountAccounts returns the number of accounts without listing them
+func (c *ConfigurationClient) CountAccounts(ctx context.Context) (int64, error) {
+	return c.CountAccountsByDateRange(ctx, time.Time{}, time.Time{})
+}
+
+// CountAccountsByDateRange counts the accounts created between from and to (inclusive)
+// A zero from or to leaves that bound open, as in ListAccountsByDateRange
+func (c *ConfigurationClient) CountAccountsByDateRange(ctx context.Context, from, to time.Time) (int64, error) {
+	req := &configpb.CountAccountsRequestProto{}
+	if !from.IsZero() {
+		req.CreatedAfter = timestamppb.New(from)
+	}
+	if !to.IsZero() {
+		req.CreatedBefore = timestamppb.New(to)
+	}
+
+	resp, err := c.client.CountAccounts(ctx, req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count accounts: %w", err)
+	}
+
+	return resp.GetCount(), nil
+}
+
+// ExportAccounts streams all accounts, oldest first, in batches of batchSize (0 for the server default)
+// Stopping the iteration cancels the stream, which stops the export on the server
+func (c *ConfigurationClient) ExportAccounts(ctx context.Context, batchSize uint32) iter.Seq2[[]*configpb.AccountConfigurationProto, error] {
+	return func(yield func([]*configpb.AccountConfigurationProto, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stream, err := c.client.ExportAccounts(ctx, &configpb.ExportAccountsRequestProto{BatchSize: batchSize})
+		if err != nil {
+			yield(nil, fmt.Errorf("failed to export accounts: %w", err))
+			return
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to export accounts: %w", err))
+				return
+			}
+			if !yield(resp.GetAccounts(), nil) {
+				return
+			}
+		}
+	}
+}
+
+// GroupEventStream opens a GroupEventStream subscribed to groupID
+// Recv returns the group's stored events, then new ones; Send membership actions on the same stream,
+// and CloseSend once done sending, which keeps the events flowing until ctx is cancelled
+func (c *ConfigurationClient) GroupEventStream(ctx context.Context, groupID *commonpb.ConfigurationIdProto) (grpc.BidiStreamingClient[configpb.GroupEventStreamRequestProto, configpb.ConfigurationEventProto], error) {
+	stream, err := c.client.GroupEventStream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open group event stream: %w", err)
+	}
+
+	subscribe := &configpb.GroupEventStreamRequestProto{
+		Action: &configpb.GroupEventStreamRequestProto_Subscribe{
+			Subscribe: &configpb.SubscribeGroupEventsProto{GroupId: groupID},
+		},
+	}
+	if err := stream.Send(subscribe); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to group events: %w", err)
+	}
+	return stream, nil
+}
+
+// AccountChange is a committed change to an account, delivered by WatchAccounts
+// Name is the account's name after the change, or before a delete
+type AccountChange struct {
+	Kind      configpb.AccountChangeProto_Kind
+	AccountID ids.AccountID
+	Name      string
+	Err       error // set on the last change when the watch failed; the other fields are then empty
+}
+
+// WatchAccounts streams the changes to the caller's accounts in commit order until ctx is cancelled
+// It returns once the server is watching, so every change committed after it returns is delivered;
+// the channel is closed when the watch ends, after a change carrying Err if it failed
+func (c *ConfigurationClient) WatchAccounts(ctx context.Context) (<-chan AccountChange, error) {
+	stream, err := c.client.WatchAccounts(ctx, &configpb.WatchAccountsRequestProto{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch accounts: %w", err)
+	}
+
+	// The server sends its header once watching; a stream ending without one failed, and Recv says why
+	header, err := stream.Header()
+	if err == nil && header == nil {
+		_, err = stream.Recv()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch accounts: %w", err)
+	}
+
+	changes := make(chan AccountChange)
+	go func() {
+		defer close(changes)
+		for {
+			resp, err := stream.Recv()
+			change := AccountChange{
+				Kind:      resp.GetKind(),
+				AccountID: ids.AccountIDFromProto(resp.GetAccountId()),
+				Name:      resp.GetName(),
+			}
+			if err != nil {
+				if ctx.Err() != nil || errors.Is(err, io.EOF) {
+					return
+				}
+				change = AccountChange{Err: fmt.Errorf("failed to watch accounts: %w", err)}
+			}
+
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return
+			}
+			if change.Err != nil {
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
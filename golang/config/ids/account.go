@@ -0,0 +1,93 @@
+package ids
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	commonpb "github.com/berendjan/golang-bazel-starter/proto/common/v1"
+)
+
+// AccountType is the ConfigurationIdProto type of accounts
+const AccountType uint32 = 1
+
+// MaxAccountIDBytes caps the length of a raw account ID; IDs are derived from names, so names share the limit
+// The accounts_name_length constraint enforces it in the database
+const MaxAccountIDBytes = 256
+
+// ErrEmptyAccountID is returned when parsing an empty account ID
+var ErrEmptyAccountID = errors.New("account ID is empty")
+
+// AccountID identifies an account; it holds the raw bytes stored in the accounts table
+// Its string form is the standard base64 the HTTP gateway uses for bytes fields, so IDs read from
+// JSON responses and IDs sent over gRPC are interchangeable
+type AccountID []byte
+
+// ParseAccountID parses the string form of an account ID
+// Like the HTTP gateway it accepts standard and URL-safe base64, with or without padding
+func ParseAccountID(s string) (AccountID, error) {
+	if s == "" {
+		return nil, ErrEmptyAccountID
+	}
+
+	encoding := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		encoding = base64.URLEncoding
+	}
+	if len(s)%4 != 0 {
+		encoding = encoding.WithPadding(base64.NoPadding)
+	}
+
+	id, err := encoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse account ID %q: %w", s, err)
+	}
+	if len(id) == 0 {
+		return nil, ErrEmptyAccountID
+	}
+	if len(id) > MaxAccountIDBytes {
+		return nil, fmt.Errorf("account ID is %d bytes, limit is %d", len(id), MaxAccountIDBytes)
+	}
+	return AccountID(id), nil
+}
+
+// AccountIDFromProto returns the account ID of p, nil if p is nil
+func AccountIDFromProto(p *commonpb.ConfigurationIdProto) AccountID {
+	return AccountID(bytes.Clone(p.GetId()))
+}
+
+// String returns the standard base64 form of the ID, as the HTTP gateway renders it
+func (id AccountID) String() string {
+	return base64.StdEncoding.EncodeToString(id)
+}
+
+// Bytes returns a copy of the raw ID
+func (id AccountID) Bytes() []byte {
+	return bytes.Clone([]byte(id))
+}
+
+// IsZero reports whether the ID is empty
+func (id AccountID) IsZero() bool {
+	return len(id) == 0
+}
+
+// Equal reports whether id and other are the same ID
+func (id AccountID) Equal(other AccountID) bool {
+	return bytes.Equal(id, other)
+}
+
+// PathSegment returns the unpadded URL-safe base64 form of the ID, usable in REST paths without escaping
+// ParseAccountID accepts it, e.g. for DELETE /v1/accounts/{id}
+func (id AccountID) PathSegment() string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// Proto returns the ID as a ConfigurationIdProto of AccountType
+func (id AccountID) Proto() *commonpb.ConfigurationIdProto {
+	return &commonpb.ConfigurationIdProto{
+		Id:   id.Bytes(),
+		Type: AccountType,
+	}
+}
@@ -0,0 +1,107 @@
+package ids_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+)
+
+func TestAccountIDRoundTripsThroughString(t *testing.T) {
+	for _, raw := range [][]byte{
+		[]byte("test account"),
+		[]byte("test"), // Valid base64 itself; must not be decoded twice
+		{0x00, 0xff, 0xfe, 0x10, 0x80},
+		bytes.Repeat([]byte{0xfb}, 16), // Encodes to "+" and "/" in standard base64
+	} {
+		id := ids.AccountID(raw)
+		parsed, err := ids.ParseAccountID(id.String())
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", id.String(), err)
+		}
+		if !parsed.Equal(id) || !bytes.Equal(parsed.Bytes(), raw) {
+			t.Fatalf("Expected %x after round trip, got %x", raw, parsed.Bytes())
+		}
+	}
+}
+
+func TestAccountIDRoundTripsThroughBytesAndProto(t *testing.T) {
+	raw := []byte{0x01, 0x02, 0x03, 0xff}
+	id := ids.AccountID(raw)
+
+	b := id.Bytes()
+	b[0] = 0x42
+	if id[0] != 0x01 {
+		t.Fatal("Expected Bytes to return a copy")
+	}
+
+	p := id.Proto()
+	if p.GetType() != ids.AccountType {
+		t.Fatalf("Expected type %d, got %d", ids.AccountType, p.GetType())
+	}
+	if got := ids.AccountIDFromProto(p); !got.Equal(id) {
+		t.Fatalf("Expected %x from proto, got %x", raw, got.Bytes())
+	}
+	if got := ids.AccountIDFromProto(nil); !got.IsZero() {
+		t.Fatalf("Expected a zero ID from a nil proto, got %x", got.Bytes())
+	}
+}
+
+func TestParseAccountIDAcceptsGatewayEncodings(t *testing.T) {
+	raw := bytes.Repeat([]byte{0xfb}, 4)
+	for _, s := range []string{
+		base64.StdEncoding.EncodeToString(raw),
+		base64.URLEncoding.EncodeToString(raw),
+		base64.RawStdEncoding.EncodeToString(raw),
+		base64.RawURLEncoding.EncodeToString(raw),
+	} {
+		id, err := ids.ParseAccountID(s)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", s, err)
+		}
+		if !bytes.Equal(id, raw) {
+			t.Fatalf("Expected %x from %q, got %x", raw, s, []byte(id))
+		}
+	}
+}
+
+func TestParseAccountIDRejectsInvalidInput(t *testing.T) {
+	if _, err := ids.ParseAccountID(""); !errors.Is(err, ids.ErrEmptyAccountID) {
+		t.Fatalf("Expected ErrEmptyAccountID, got %v", err)
+	}
+	if _, err := ids.ParseAccountID("not base64!"); err == nil {
+		t.Fatal("Expected an error for invalid base64")
+	}
+
+	longest := ids.AccountID(bytes.Repeat([]byte("a"), ids.MaxAccountIDBytes))
+	if _, err := ids.ParseAccountID(longest.String()); err != nil {
+		t.Fatalf("Expected an ID of %d bytes to parse, got %v", ids.MaxAccountIDBytes, err)
+	}
+	if _, err := ids.ParseAccountID(ids.AccountID(append(longest, 'a')).String()); err == nil {
+		t.Fatalf("Expected an ID over %d bytes to be rejected", ids.MaxAccountIDBytes)
+	}
+}
+
+func TestAccountIDPathSegmentIsPathSafe(t *testing.T) {
+	for _, raw := range [][]byte{
+		[]byte("team/alpha??"), // Printable with a slash; also encodes to a "/" in standard base64
+		[]byte("ops??>~"),      // Encodes to a "+" in standard base64
+		{0x00, 0xff, 0xfe, 0x2f, 0x3f},
+		bytes.Repeat([]byte{0xfb}, 16),
+	} {
+		segment := ids.AccountID(raw).PathSegment()
+		if strings.ContainsAny(segment, "/+=%") {
+			t.Fatalf("Expected a path-safe segment for %x, got %q", raw, segment)
+		}
+		parsed, err := ids.ParseAccountID(segment)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", segment, err)
+		}
+		if !bytes.Equal(parsed, raw) {
+			t.Fatalf("Expected %x from %q, got %x", raw, segment, []byte(parsed))
+		}
+	}
+}
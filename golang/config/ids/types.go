@@ -0,0 +1,55 @@
+package ids
+
+import (
+	"fmt"
+	"sync"
+)
+
+// typeRegistry maps the ConfigurationIdProto type codes of accounts to their human names and back
+var typeRegistry = struct {
+	mu    sync.RWMutex
+	names map[uint32]string
+	codes map[string]uint32
+}{
+	names: map[uint32]string{AccountType: "account"},
+	codes: map[string]uint32{"account": AccountType},
+}
+
+// RegisterAccountType adds an account type code with its human name, e.g. when a service introduces a new kind of account
+// Registering a code or name twice is an error, so two types can't claim the same code
+func RegisterAccountType(code uint32, name string) error {
+	if name == "" {
+		return fmt.Errorf("account type %d has no name", code)
+	}
+
+	typeRegistry.mu.Lock()
+	defer typeRegistry.mu.Unlock()
+	if existing, ok := typeRegistry.names[code]; ok {
+		return fmt.Errorf("account type %d is already registered as %q", code, existing)
+	}
+	if existing, ok := typeRegistry.codes[name]; ok {
+		return fmt.Errorf("account type %q is already registered as %d", name, existing)
+	}
+	typeRegistry.names[code] = name
+	typeRegistry.codes[name] = code
+	return nil
+}
+
+// AccountTypeName returns the human name of an account type code, e.g. "account" for AccountType
+// Unregistered codes are named "unknown(<code>)" so they still show up in logs
+func AccountTypeName(code uint32) string {
+	typeRegistry.mu.RLock()
+	defer typeRegistry.mu.RUnlock()
+	if name, ok := typeRegistry.names[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", code)
+}
+
+// AccountTypeCode returns the account type code registered under name, false if there is none
+func AccountTypeCode(name string) (uint32, bool) {
+	typeRegistry.mu.RLock()
+	defer typeRegistry.mu.RUnlock()
+	code, ok := typeRegistry.codes[name]
+	return code, ok
+}
@@ -0,0 +1,46 @@
+package ids_test
+
+import (
+	"testing"
+
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+)
+
+func TestAccountTypeRoundTripsThroughName(t *testing.T) {
+	name := ids.AccountTypeName(ids.AccountType)
+	if name != "account" {
+		t.Fatalf("Expected AccountType to be named account, got %q", name)
+	}
+	if code, ok := ids.AccountTypeCode(name); !ok || code != ids.AccountType {
+		t.Fatalf("Expected %q to map back to %d, got %d (registered %t)", name, ids.AccountType, code, ok)
+	}
+
+	// The registry is global, so a repeated run finds the type registered already
+	if _, ok := ids.AccountTypeCode("service-account"); !ok {
+		if err := ids.RegisterAccountType(42, "service-account"); err != nil {
+			t.Fatalf("Failed to register account type: %v", err)
+		}
+	}
+	if code, ok := ids.AccountTypeCode(ids.AccountTypeName(42)); !ok || code != 42 {
+		t.Fatalf("Expected the registered type to round trip, got %d (registered %t)", code, ok)
+	}
+}
+
+func TestAccountTypeRegistryRejectsUnknownAndDuplicates(t *testing.T) {
+	if name := ids.AccountTypeName(7); name != "unknown(7)" {
+		t.Fatalf("Expected an unknown code to be named unknown(7), got %q", name)
+	}
+	if _, ok := ids.AccountTypeCode("unknown(7)"); ok {
+		t.Fatal("Expected the name of an unknown code not to be registered")
+	}
+
+	if err := ids.RegisterAccountType(ids.AccountType, "user"); err == nil {
+		t.Fatal("Expected registering a taken code to fail")
+	}
+	if err := ids.RegisterAccountType(43, "account"); err == nil {
+		t.Fatal("Expected registering a taken name to fail")
+	}
+	if err := ids.RegisterAccountType(44, ""); err == nil {
+		t.Fatal("Expected registering an empty name to fail")
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
+)
+
+// AuditEntry is a single row of the audit trail
+type AuditEntry struct {
+	TenantID string
+	UserID   string
+	Method   string
+	TargetID []byte
+	Result   string
+}
+
+// AuditDbRepository appends entries to the immutable audit_log table
+type AuditDbRepository struct {
+	pool *db.DBPool
+}
+
+// NewAuditRepository creates a new AuditDbRepository
+func NewAuditRepository(pool *db.DBPool) *AuditDbRepository {
+	return &AuditDbRepository{
+		pool: pool,
+	}
+}
+
+// Record appends an entry to the audit trail
+func (r *AuditDbRepository) Record(ctx context.Context, entry AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (tenant_id, user_id, method, target_id, result)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := r.pool.Querier(ctx).Exec(ctx, query, entry.TenantID, entry.UserID, entry.Method, entry.TargetID, entry.Result)
+	if err != nil {
+		log.Printf("Failed to record audit entry in database: %v", err)
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// RunInTx runs fn in a transaction shared by every repository of the config database called with its context
+func (r *AuditDbRepository) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return r.pool.RunInTx(ctx, fn)
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
+)
+
+// eventsChannel is the notification channel the configuration_events insert trigger notifies
+const eventsChannel = "configuration_events"
+
+// eventNotification is the payload of a configuration_events notification, naming the inserted row
+type eventNotification struct {
+	ID       int64  `json:"id"`
+	TenantID string `json:"tenant_id"`
+	GroupID  string `json:"group_id"` // hex encoded
+}
+
+// HandleRequestToJoinGroup stores the request as a PendingMemberEvent of its group
+func (r *AccountDbRepository) HandleRequestToJoinGroup(ctx context.Context, req *configpb.RequestToJoinGroupProto) error {
+	if len(req.GetAccountId().GetId()) == 0 {
+		return status.Error(codes.InvalidArgument, "account_id is required")
+	}
+
+	return r.storeEvent(ctx, req.GetGroupId().GetId(), &configpb.ConfigurationEventProto{
+		Event: &configpb.ConfigurationEventProto_PendingMemberEvent{
+			PendingMemberEvent: &configpb.PendingMemberEventProto{
+				AccountId:       req.GetAccountId(),
+				GroupId:         req.GetGroupId(),
+				InviterId:       req.GetInviterId(),
+				InviteId:        req.GetInviteId(),
+				X25519PublicKey: req.GetX25519PublicKey(),
+			},
+		},
+	})
+}
+
+// HandleAcceptRequestToJoinGroup stores the acceptance as a PendingMemberAcceptedEvent for the invitee
+func (r *AccountDbRepository) HandleAcceptRequestToJoinGroup(ctx context.Context, req *configpb.AcceptRequestToJoinGroupProto) error {
+	if len(req.GetInviteeId().GetId()) == 0 {
+		return status.Error(codes.InvalidArgument, "invitee_id is required")
+	}
+
+	return r.storeEvent(ctx, req.GetGroupId().GetId(), &configpb.ConfigurationEventProto{
+		Event: &configpb.ConfigurationEventProto_PendingMemberAcceptedEvent{
+			PendingMemberAcceptedEvent: &configpb.PendingMemberAcceptedEventProto{
+				AccountId:         req.GetInviteeId(),
+				GroupId:           req.GetGroupId(),
+				EncryptedGroupKey: req.GetEncryptedGroupKey(),
+			},
+		},
+	})
+}
+
+// storeEvent appends event to the caller's events of groupID; the insert trigger notifies subscribers
+func (r *AccountDbRepository) storeEvent(ctx context.Context, groupID []byte, event *configpb.ConfigurationEventProto) error {
+	if len(groupID) == 0 {
+		return status.Error(codes.InvalidArgument, "group_id is required")
+	}
+
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := protojson.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal configuration event: %w", err)
+	}
+
+	query := `INSERT INTO configuration_events (tenant_id, group_id, event) VALUES ($1, $2, $3)`
+	if _, err := r.pool.Querier(ctx).Exec(ctx, query, tenantID, groupID, data); err != nil {
+		log.Printf("Failed to store configuration event in database: %v", err)
+		return fmt.Errorf("failed to store configuration event: %w", err)
+	}
+	return nil
+}
+
+// HandleSubscribeGroupEvents returns the events of the requested group, oldest first
+// Iterating yields the stored events, then waits for new ones until ctx is done or the consumer stops;
+// the subscription shares the pool's listening connection of the events channel while it is iterated
+func (r *AccountDbRepository) HandleSubscribeGroupEvents(ctx context.Context, req *configpb.SubscribeGroupEventsProto) (iter.Seq2[*configpb.ConfigurationEventProto, error], error) {
+	groupID := req.GetGroupId().GetId()
+	if len(groupID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "group_id is required")
+	}
+
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groupHex := hex.EncodeToString(groupID)
+
+	return func(yield func(*configpb.ConfigurationEventProto, error) bool) {
+		// Listen before reading the stored events, so no event inserted in between is missed
+		listener, err := r.pool.Listen(ctx, eventsChannel)
+		if err != nil {
+			yield(nil, fmt.Errorf("failed to subscribe to group events: %w", err))
+			return
+		}
+		defer listener.Close()
+
+		var last int64
+		for {
+			events, next, err := r.eventsSince(ctx, tenantID, groupID, last)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			last = next
+			for _, event := range events {
+				if !yield(event, nil) {
+					return
+				}
+			}
+
+			// Wait for an event of this group newer than the ones yielded
+			for {
+				payload, err := listener.Wait(ctx)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					yield(nil, fmt.Errorf("failed to receive group events: %w", err))
+					return
+				}
+
+				var n eventNotification
+				if err := json.Unmarshal([]byte(payload), &n); err != nil {
+					log.Printf("Ignoring malformed configuration event notification %q: %v", payload, err)
+					continue
+				}
+				if n.TenantID == tenantID && n.GroupID == groupHex && n.ID > last {
+					break
+				}
+			}
+		}
+	}, nil
+}
+
+// eventsSince returns the events of the tenant's group stored after the event with ID after, oldest first,
+// and the ID of the newest one returned (after if there are none)
+func (r *AccountDbRepository) eventsSince(ctx context.Context, tenantID string, groupID []byte, after int64) ([]*configpb.ConfigurationEventProto, int64, error) {
+	query := `SELECT id, event FROM configuration_events WHERE tenant_id = $1 AND group_id = $2 AND id > $3 ORDER BY id`
+
+	rows, err := r.pool.Querier(ctx).Query(ctx, query, tenantID, groupID, after)
+	if err != nil {
+		log.Printf("Failed to read configuration events from database: %v", err)
+		return nil, after, fmt.Errorf("failed to read configuration events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*configpb.ConfigurationEventProto
+	for rows.Next() {
+		var id int64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, after, fmt.Errorf("failed to scan configuration event: %w", err)
+		}
+
+		event := &configpb.ConfigurationEventProto{}
+		if err := protojson.Unmarshal(data, event); err != nil {
+			return nil, after, fmt.Errorf("failed to unmarshal configuration event %d: %w", id, err)
+		}
+		events = append(events, event)
+		after = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, after, fmt.Errorf("failed to read configuration events: %w", err)
+	}
+	return events, after, nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
+	commonpb "github.com/berendjan/golang-bazel-starter/proto/common/v1"
+	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
+)
+
+// MaxMetadataBytes caps the JSON encoding of an account's metadata
+// Requests are checked against their compact JSON; the accounts_metadata_size constraint checks the stored
+// JSON text, which also bounds the result of MergeMetadata
+const MaxMetadataBytes = 16 << 10
+
+// metadataSizeConstraint is the check constraint on accounts enforcing MaxMetadataBytes
+const metadataSizeConstraint = "accounts_metadata_size"
+
+// errMetadataTooLarge reports stored metadata rejected by metadataSizeConstraint
+var errMetadataTooLarge = status.Errorf(codes.InvalidArgument, "metadata exceeds the limit of %d bytes", MaxMetadataBytes)
+
+// validateMetadata rejects metadata whose JSON encoding exceeds MaxMetadataBytes with InvalidArgument
+func validateMetadata(metadata *structpb.Struct) error {
+	if metadata == nil {
+		return nil
+	}
+	data, err := protojson.Marshal(metadata)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid metadata: %v", err)
+	}
+	if len(data) > MaxMetadataBytes {
+		return status.Errorf(codes.InvalidArgument, "metadata is %d bytes, limit is %d", len(data), MaxMetadataBytes)
+	}
+	return nil
+}
+
+// HandleGetAccountRequest returns the account with the requested ID, NotFound if the caller's tenant has none
+func (r *AccountDbRepository) HandleGetAccountRequest(ctx context.Context, req *configpb.GetAccountRequestProto) (*configpb.AccountConfigurationProto, error) {
+	accountID, err := ids.ParseAccountID(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, COALESCE(name, ''), type, metadata FROM accounts WHERE tenant_id = $1 AND id = $2`
+
+	var id []byte
+	var name string
+	var accType uint32
+	var metadata *structpb.Struct
+	err = r.pool.Querier(ctx).QueryRow(ctx, query, tenantID, accountID.Bytes()).Scan(&id, &name, &accType, db.ScanJSON(&metadata))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "Account not found: "+accountID.String())
+	}
+	if err != nil {
+		log.Printf("Failed to get account from database: %v", err)
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+
+	return &configpb.AccountConfigurationProto{
+		AccountId: &commonpb.ConfigurationIdProto{
+			Id:   id,
+			Type: accType,
+		},
+		Metadata: metadata,
+		Name:     name,
+	}, nil
+}
+
+// GetMetadata returns the metadata of an account of the caller's tenant, NotFound if it doesn't exist
+func (r *AccountDbRepository) GetMetadata(ctx context.Context, accountID ids.AccountID) (*structpb.Struct, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT metadata FROM accounts WHERE tenant_id = $1 AND id = $2`
+
+	var metadata *structpb.Struct
+	err = r.pool.Querier(ctx).QueryRow(ctx, query, tenantID, accountID.Bytes()).Scan(db.ScanJSON(&metadata))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "Account not found: "+accountID.String())
+	}
+	if err != nil {
+		log.Printf("Failed to get account metadata from database: %v", err)
+		return nil, fmt.Errorf("failed to get account metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+// SetMetadata replaces the metadata of an account and returns it; nil stores an empty object
+func (r *AccountDbRepository) SetMetadata(ctx context.Context, accountID ids.AccountID, metadata *structpb.Struct) (*structpb.Struct, error) {
+	return r.writeMetadata(ctx, accountID, "COALESCE($3::jsonb, '{}'::jsonb)", metadata)
+}
+
+// MergeMetadata sets the top-level keys of metadata on the account's metadata, keeping its other keys
+// Returns the merged metadata; nested objects are replaced as a whole and a null value is stored, not deleted
+func (r *AccountDbRepository) MergeMetadata(ctx context.Context, accountID ids.AccountID, metadata *structpb.Struct) (*structpb.Struct, error) {
+	return r.writeMetadata(ctx, accountID, "metadata || COALESCE($3::jsonb, '{}'::jsonb)", metadata)
+}
+
+// writeMetadata sets the metadata of an account to expr, in which $3 is metadata, and returns the result
+func (r *AccountDbRepository) writeMetadata(ctx context.Context, accountID ids.AccountID, expr string, metadata *structpb.Struct) (*structpb.Struct, error) {
+	if err := validateMetadata(metadata); err != nil {
+		return nil, err
+	}
+
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE accounts SET metadata = ` + expr + `, updated_at = $4 WHERE tenant_id = $1 AND id = $2 RETURNING metadata`
+
+	var result *structpb.Struct
+	err = r.pool.Querier(ctx).QueryRow(ctx, query, tenantID, accountID.Bytes(), metadata, r.clock.Now()).Scan(db.ScanJSON(&result))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "Account not found: "+accountID.String())
+	}
+	if db.IsCheckViolation(err, metadataSizeConstraint) {
+		return nil, errMetadataTooLarge
+	}
+	if err != nil {
+		log.Printf("Failed to write account metadata to database: %v", err)
+		return nil, fmt.Errorf("failed to write account metadata: %w", err)
+	}
+
+	log.Printf("Wrote metadata of account %s", accountID)
+	return result, nil
+}
@@ -2,23 +2,45 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"log"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+	"github.com/berendjan/golang-bazel-starter/golang/framework/clock"
 	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
 	geninterfaces "github.com/berendjan/golang-bazel-starter/golang/generated/interfaces"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/tenant"
 	commonpb "github.com/berendjan/golang-bazel-starter/proto/common/v1"
 	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
 )
 
 const (
 	DbName string = "config"
+
+	// SchemaVersion is the newest migration in db/config/migrations, the schema this package queries
+	SchemaVersion uint64 = 20250101000010
+)
+
+// Open bounds for creation time filters, within the range of a postgres timestamptz
+var (
+	minCreatedAt = time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	maxCreatedAt = time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
 )
 
 // AccountDbRepository implements the AccountRepository interface
 type AccountDbRepository struct {
-	pool *db.DBPool
+	pool  *db.DBPool
+	clock clock.Clock // stamps created_at and updated_at
 }
 
 // Compile-time check that AccountDbRepository implements AccountRepositoryInterface
@@ -32,10 +54,26 @@ type AccountRepositoryProvider[T geninterfaces.AccountRepositoryInterface] inter
 // NewAccountRepository creates a new AccountRepository implementation
 func NewAccountRepository(pool *db.DBPool) *AccountDbRepository {
 	return &AccountDbRepository{
-		pool: pool,
+		pool:  pool,
+		clock: clock.Real,
 	}
 }
 
+// WithClock stamps created_at and updated_at from c instead of the system time
+func (r *AccountDbRepository) WithClock(c clock.Clock) *AccountDbRepository {
+	r.clock = c
+	return r
+}
+
+// tenantFromContext returns the caller's tenant or a PermissionDenied error if none is set
+func tenantFromContext(ctx context.Context) (string, error) {
+	tenantID := tenant.TenantIDFromContext(ctx)
+	if tenantID == "" {
+		return "", status.Error(codes.PermissionDenied, "tenant is required")
+	}
+	return tenantID, nil
+}
+
 // HandleMiddleOneRequest creates a new account and returns the account configuration
 func (r *AccountDbRepository) HandleMiddleOneRequest(ctx context.Context, req *configpb.MiddleOneRequestProto) (*configpb.AccountConfigurationProto, error) {
 	return r.handleAccountCreation(ctx, req.GetRequest())
@@ -43,23 +81,43 @@ func (r *AccountDbRepository) HandleMiddleOneRequest(ctx context.Context, req *c
 
 // handleAccountCreation is the internal implementation
 func (r *AccountDbRepository) handleAccountCreation(ctx context.Context, req *configpb.AccountCreationRequestProto) (*configpb.AccountConfigurationProto, error) {
-	if req.GetName() == "" {
-		return nil, fmt.Errorf("name is required")
+	if err := validateName(req.GetName()); err != nil {
+		return nil, err
+	}
+	if err := validateMetadata(req.GetMetadata()); err != nil {
+		return nil, err
+	}
+
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// Generate account ID from name
-	accountID := []byte(req.GetName())
-	accountType := uint32(1) // Default account type
+	accountID := ids.AccountID(req.GetName())
 
+	// Accounts created without metadata store an empty object
+	// Names are unique per tenant, a conflict is reported as db.ErrDuplicate
 	query := `
-		INSERT INTO accounts (id, type)
-		VALUES ($1, $2)
-		RETURNING id, type
+		INSERT INTO accounts (tenant_id, id, name, type, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, COALESCE($5::jsonb, '{}'::jsonb), $6, $6)
+		RETURNING id, name, type, metadata
 	`
 
 	var id []byte
+	var name string
 	var accType uint32
-	err := r.pool.QueryRow(ctx, query, accountID, accountType).Scan(&id, &accType)
+	var metadata *structpb.Struct
+	err = r.pool.Querier(ctx).QueryRow(ctx, query, tenantID, accountID.Bytes(), req.GetName(), ids.AccountType, req.GetMetadata(), r.clock.Now()).Scan(&id, &name, &accType, db.ScanJSON(&metadata))
+	if db.IsUniqueViolation(err) {
+		return nil, fmt.Errorf("account %q already exists: %w", req.GetName(), db.ErrDuplicate)
+	}
+	if db.IsCheckViolation(err, metadataSizeConstraint) {
+		return nil, errMetadataTooLarge
+	}
+	if db.IsCheckViolation(err, nameLengthConstraint) {
+		return nil, errNameTooLong
+	}
 	if err != nil {
 		log.Printf("Failed to create account in database: %v", err)
 		return nil, fmt.Errorf("failed to create account: %w", err)
@@ -70,60 +128,418 @@ func (r *AccountDbRepository) handleAccountCreation(ctx context.Context, req *co
 			Id:   id,
 			Type: accType,
 		},
+		Metadata: metadata,
+		Name:     name,
 	}
 
-	log.Printf("Created account with id %s", string(accountID))
+	log.Printf("Created %s with id %s", ids.AccountTypeName(accType), accountID)
 	return account, nil
 }
 
 // HandleAccountDeletionRequest deletes an account by ID and returns status response
+// A missing account fails with an error wrapping db.ErrNotFound
 func (r *AccountDbRepository) HandleAccountDeletionRequest(ctx context.Context, req *configpb.AccountDeletionRequestProto) (*commonpb.StatusResponseProto, error) {
-	accountKey := req.GetId()
+	accountID, err := ids.ParseAccountID(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 
-	// Try to decode from base64 (HTTP gateway sends it encoded)
-	// Removed base64 decoding logic - moved from API layer
+	name, err := r.deleteAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
 
-	query := `DELETE FROM accounts WHERE id = $1`
-	result, err := r.pool.Exec(ctx, query, []byte(accountKey))
+	return &commonpb.StatusResponseProto{
+		Code:    200,
+		Message: deletedAccountMessage(accountID, name),
+	}, nil
+}
+
+// deletedAccountMessage names the deleted account in the response, e.g. "Account deleted: acme (YWNtZQ==)"
+func deletedAccountMessage(accountID ids.AccountID, name string) string {
+	if name == "" {
+		return "Account deleted: " + accountID.String()
+	}
+	return fmt.Sprintf("Account deleted: %s (%s)", name, accountID)
+}
+
+// accountUpdateField is an update_mask path and the column it writes
+type accountUpdateField struct {
+	path   string
+	column string
+	// expr is the SQL expression of the written value, with %s for its placeholder
+	expr  string
+	value func(req *configpb.AccountUpdateRequestProto) any
+}
+
+// accountUpdateFields allow-lists the update_mask paths, in the order an empty mask writes them
+var accountUpdateFields = []accountUpdateField{
+	{path: "name", column: "name", expr: "%s", value: func(req *configpb.AccountUpdateRequestProto) any { return req.GetName() }},
+	{path: "metadata", column: "metadata", expr: "COALESCE(%s::jsonb, '{}'::jsonb)", value: func(req *configpb.AccountUpdateRequestProto) any { return req.GetMetadata() }},
+}
+
+// HandleAccountUpdateRequest writes the fields of req named in its update mask and returns the updated account
+// An empty mask writes every field; unknown paths are rejected with InvalidArgument and a missing account with NotFound
+func (r *AccountDbRepository) HandleAccountUpdateRequest(ctx context.Context, req *configpb.AccountUpdateRequestProto) (*configpb.AccountConfigurationProto, error) {
+	accountID, err := ids.ParseAccountID(req.GetId())
 	if err != nil {
-		log.Printf("Failed to delete account from database: %v", err)
-		return nil, fmt.Errorf("failed to delete account: %w", err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return &commonpb.StatusResponseProto{
-			Code:    404,
-			Message: "Account not found: " + accountKey,
-		}, fmt.Errorf("account not found: %s", accountKey)
+	fields, err := accountUpdateMask(req)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Printf("Deleted account: %s", accountKey)
+	// Column names only come from the allow-list, values are always parameters
+	args := []any{tenantID, accountID.Bytes()}
+	set := make([]string, 0, len(fields)+1)
+	for _, field := range fields {
+		args = append(args, field.value(req))
+		set = append(set, field.column+" = "+fmt.Sprintf(field.expr, fmt.Sprintf("$%d", len(args))))
+	}
+	args = append(args, r.clock.Now())
+	set = append(set, fmt.Sprintf("updated_at = $%d", len(args)))
 
-	return &commonpb.StatusResponseProto{
-		Code:    200,
-		Message: "Account deleted successfully",
+	query := `UPDATE accounts SET ` + strings.Join(set, ", ") + ` WHERE tenant_id = $1 AND id = $2 RETURNING id, COALESCE(name, ''), type, metadata`
+
+	var id []byte
+	var name string
+	var accType uint32
+	var metadata *structpb.Struct
+	err = r.pool.Querier(ctx).QueryRow(ctx, query, args...).Scan(&id, &name, &accType, db.ScanJSON(&metadata))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "Account not found: "+accountID.String())
+	}
+	if db.IsUniqueViolation(err) {
+		return nil, fmt.Errorf("account %q already exists: %w", req.GetName(), db.ErrDuplicate)
+	}
+	if db.IsCheckViolation(err, metadataSizeConstraint) {
+		return nil, errMetadataTooLarge
+	}
+	if db.IsCheckViolation(err, nameLengthConstraint) {
+		return nil, errNameTooLong
+	}
+	if err != nil {
+		log.Printf("Failed to update account in database: %v", err)
+		return nil, fmt.Errorf("failed to update account: %w", err)
+	}
+
+	log.Printf("Updated %s %s", ids.AccountTypeName(accType), accountID)
+	return &configpb.AccountConfigurationProto{
+		AccountId: &commonpb.ConfigurationIdProto{
+			Id:   id,
+			Type: accType,
+		},
+		Metadata: metadata,
+		Name:     name,
 	}, nil
 }
 
-// HandleListAccountsRequest retrieves all accounts ordered by creation time
+// nameLengthConstraint is the check constraint on accounts enforcing ids.MaxAccountIDBytes on names and IDs
+const nameLengthConstraint = "accounts_name_length"
+
+// errNameTooLong reports a name or ID rejected by nameLengthConstraint
+var errNameTooLong = status.Errorf(codes.InvalidArgument, "name exceeds the limit of %d bytes", ids.MaxAccountIDBytes)
+
+// validateName rejects an empty name or one longer than ids.MaxAccountIDBytes with InvalidArgument
+// Checked before writing, so an over-long name never reaches the database
+func validateName(name string) error {
+	if name == "" {
+		return status.Error(codes.InvalidArgument, "name is required")
+	}
+	if len(name) > ids.MaxAccountIDBytes {
+		return status.Errorf(codes.InvalidArgument, "name is %d bytes, limit is %d", len(name), ids.MaxAccountIDBytes)
+	}
+	return nil
+}
+
+// accountUpdateMask returns the allow-listed fields named in the update mask of req, each once
+// An empty mask names every field
+func accountUpdateMask(req *configpb.AccountUpdateRequestProto) ([]accountUpdateField, error) {
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		return accountUpdateFields, validateAccountUpdate(req, accountUpdateFields)
+	}
+
+	var fields []accountUpdateField
+	for _, path := range paths {
+		i := slices.IndexFunc(accountUpdateFields, func(f accountUpdateField) bool { return f.path == path })
+		if i < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown update_mask path %q", path)
+		}
+		if !slices.ContainsFunc(fields, func(f accountUpdateField) bool { return f.path == path }) {
+			fields = append(fields, accountUpdateFields[i])
+		}
+	}
+	return fields, validateAccountUpdate(req, fields)
+}
+
+// validateAccountUpdate rejects an update writing an empty or over-long name or oversized metadata
+func validateAccountUpdate(req *configpb.AccountUpdateRequestProto, fields []accountUpdateField) error {
+	for _, field := range fields {
+		switch field.path {
+		case "name":
+			if err := validateName(req.GetName()); err != nil {
+				return err
+			}
+		case "metadata":
+			if err := validateMetadata(req.GetMetadata()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteAccountQuery deletes an account of a tenant and returns its name
+const deleteAccountQuery = `DELETE FROM accounts WHERE tenant_id = $1 AND id = $2 RETURNING COALESCE(name, '')`
+
+// DeleteAccount deletes an account of the caller's tenant and returns the number of rows deleted
+// Deleting a missing account is not an error; callers decide whether zero rows means NotFound
+func (r *AccountDbRepository) DeleteAccount(ctx context.Context, accountID ids.AccountID) (int64, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `DELETE FROM accounts WHERE tenant_id = $1 AND id = $2`
+	rows, err := r.pool.ExecExpectRows(ctx, 1, query, tenantID, accountID.Bytes())
+	if errors.Is(err, db.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		log.Printf("Failed to delete account from database: %v", err)
+		return 0, fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	log.Printf("Deleted account: %s", accountID)
+	return rows, nil
+}
+
+// deleteAccount deletes an account of the caller's tenant and returns its name
+// A missing account fails with an error wrapping db.ErrNotFound
+func (r *AccountDbRepository) deleteAccount(ctx context.Context, accountID ids.AccountID) (string, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var name string
+	err = r.pool.ExecReturning(ctx, []any{&name}, deleteAccountQuery, tenantID, accountID.Bytes())
+	if errors.Is(err, db.ErrNotFound) {
+		return "", fmt.Errorf("account %s: %w", accountID, err)
+	}
+	if err != nil {
+		log.Printf("Failed to delete account from database: %v", err)
+		return "", fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	log.Printf("Deleted account: %s (%s)", name, accountID)
+	return name, nil
+}
+
+// HandleListAccountsRequest retrieves the caller's accounts, filtered by creation time if requested
 func (r *AccountDbRepository) HandleListAccountsRequest(ctx context.Context, req *configpb.ListAccountsRequestProto) (*configpb.ListAccountsResponseProto, error) {
-	query := `SELECT id, type, created_at, updated_at FROM accounts ORDER BY created_at DESC`
+	var accounts []*configpb.AccountConfigurationProto
+	var err error
+	if req.GetCreatedAfter() != nil || req.GetCreatedBefore() != nil {
+		from, to := minCreatedAt, maxCreatedAt
+		if req.GetCreatedAfter() != nil {
+			from = req.GetCreatedAfter().AsTime()
+		}
+		if req.GetCreatedBefore() != nil {
+			to = req.GetCreatedBefore().AsTime()
+		}
+		accounts, err = r.ListAccountsByDateRange(ctx, from, to)
+	} else {
+		accounts, err = r.listAccounts(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &configpb.ListAccountsResponseProto{
+		Accounts: accounts,
+	}, nil
+}
 
-	rows, err := r.pool.Query(ctx, query)
+// listAccounts retrieves all accounts of the caller's tenant, newest first
+func (r *AccountDbRepository) listAccounts(ctx context.Context) ([]*configpb.AccountConfigurationProto, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, COALESCE(name, ''), type, created_at, updated_at, metadata FROM accounts WHERE tenant_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.pool.Querier(ctx).Query(ctx, query, tenantID)
 	if err != nil {
 		log.Printf("Failed to list accounts from database: %v", err)
 		return nil, fmt.Errorf("failed to list accounts: %w", err)
 	}
 	defer rows.Close()
 
+	accounts, err := scanAccounts(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Listed %d accounts", len(accounts))
+	return accounts, nil
+}
+
+// ListAccountsByDateRange retrieves the caller's accounts created between from and to (inclusive), oldest first
+func (r *AccountDbRepository) ListAccountsByDateRange(ctx context.Context, from, to time.Time) ([]*configpb.AccountConfigurationProto, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, COALESCE(name, ''), type, created_at, updated_at, metadata FROM accounts WHERE tenant_id = $1 AND created_at BETWEEN $2 AND $3 ORDER BY created_at`
+
+	rows, err := r.pool.Querier(ctx).Query(ctx, query, tenantID, from, to)
+	if err != nil {
+		log.Printf("Failed to list accounts by date range from database: %v", err)
+		return nil, fmt.Errorf("failed to list accounts by date range: %w", err)
+	}
+	defer rows.Close()
+
+	accounts, err := scanAccounts(ctx, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Listed %d accounts created between %s and %s", len(accounts), from.Format(time.RFC3339Nano), to.Format(time.RFC3339Nano))
+	return accounts, nil
+}
+
+// HandleCountAccountsRequest counts the caller's accounts, limited to the created_after and created_before bounds when set
+func (r *AccountDbRepository) HandleCountAccountsRequest(ctx context.Context, req *configpb.CountAccountsRequestProto) (*configpb.CountAccountsResponseProto, error) {
+	from, to := minCreatedAt, maxCreatedAt
+	if req.GetCreatedAfter() != nil {
+		from = req.GetCreatedAfter().AsTime()
+	}
+	if req.GetCreatedBefore() != nil {
+		to = req.GetCreatedBefore().AsTime()
+	}
+
+	count, err := r.countAccounts(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &configpb.CountAccountsResponseProto{Count: count}, nil
+}
+
+// CountAccounts returns the number of accounts of the caller's tenant without reading them
+func (r *AccountDbRepository) CountAccounts(ctx context.Context) (int64, error) {
+	return r.countAccounts(ctx, minCreatedAt, maxCreatedAt)
+}
+
+// countAccounts returns the number of the caller's accounts created between from and to (inclusive)
+func (r *AccountDbRepository) countAccounts(ctx context.Context, from, to time.Time) (int64, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `SELECT COUNT(*) FROM accounts WHERE tenant_id = $1 AND created_at BETWEEN $2 AND $3`
+
+	var count int64
+	if err := r.pool.Querier(ctx).QueryRow(ctx, query, tenantID, from, to).Scan(&count); err != nil {
+		log.Printf("Failed to count accounts in database: %v", err)
+		return 0, fmt.Errorf("failed to count accounts: %w", err)
+	}
+	return count, nil
+}
+
+// DefaultExportBatchSize is the number of accounts ExportAccounts fetches per batch unless the request sets one
+const DefaultExportBatchSize = 500
+
+// errExportStopped ends the export transaction when the consumer stops iterating
+var errExportStopped = errors.New("export stopped by consumer")
+
+// HandleExportAccountsRequest returns the caller's accounts as batches, oldest first
+// Nothing is read until the batches are iterated; iterating runs a server-side cursor in a transaction,
+// so only one batch is held in memory, and stopping the iteration or cancelling ctx closes the cursor
+func (r *AccountDbRepository) HandleExportAccountsRequest(ctx context.Context, req *configpb.ExportAccountsRequestProto) (iter.Seq2[[]*configpb.AccountConfigurationProto, error], error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	batchSize := int(req.GetBatchSize())
+	if batchSize == 0 {
+		batchSize = DefaultExportBatchSize
+	}
+
+	return func(yield func([]*configpb.AccountConfigurationProto, error) bool) {
+		exported := 0
+		err := r.pool.RunInTx(ctx, func(ctx context.Context) error {
+			tx := r.pool.Querier(ctx)
+
+			query := `DECLARE export_accounts NO SCROLL CURSOR FOR
+				SELECT id, COALESCE(name, ''), type, created_at, updated_at, metadata FROM accounts WHERE tenant_id = $1 ORDER BY created_at, id`
+			if _, err := tx.Exec(ctx, query, tenantID); err != nil {
+				return fmt.Errorf("failed to declare export cursor: %w", err)
+			}
+
+			for {
+				rows, err := tx.Query(ctx, fmt.Sprintf("FETCH FORWARD %d FROM export_accounts", batchSize))
+				if err != nil {
+					return fmt.Errorf("failed to fetch accounts: %w", err)
+				}
+				batch, err := scanAccounts(ctx, rows)
+				rows.Close()
+				if err != nil {
+					return err
+				}
+				if len(batch) == 0 {
+					return nil
+				}
+
+				exported += len(batch)
+				if !yield(batch, nil) {
+					return errExportStopped
+				}
+			}
+		})
+
+		switch {
+		case errors.Is(err, errExportStopped):
+			log.Printf("Stopped exporting accounts after %d rows: consumer stopped", exported)
+		case err != nil:
+			log.Printf("Stopped exporting accounts after %d rows: %v", exported, err)
+			yield(nil, fmt.Errorf("failed to export accounts: %w", err))
+		default:
+			log.Printf("Exported %d accounts", exported)
+		}
+	}, nil
+}
+
+// scanAccounts reads all account rows selected as (id, name, type, created_at, updated_at, metadata)
+// It stops with the context error as soon as ctx is done, e.g. when the client disconnects mid-scan
+func scanAccounts(ctx context.Context, rows pgx.Rows) ([]*configpb.AccountConfigurationProto, error) {
 	var accounts []*configpb.AccountConfigurationProto
 	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			rows.Close()
+			log.Printf("Stopped scanning accounts after %d rows: %v", len(accounts), err)
+			return nil, fmt.Errorf("failed to scan accounts: %w", err)
+		}
+
 		var id []byte
+		var name string
 		var accountType uint32
 		var createdAt, updatedAt time.Time
+		var metadata *structpb.Struct
 
-		if err := rows.Scan(&id, &accountType, &createdAt, &updatedAt); err != nil {
+		if err := rows.Scan(&id, &name, &accountType, &createdAt, &updatedAt, db.ScanJSON(&metadata)); err != nil {
 			log.Printf("Failed to scan account row: %v", err)
 			return nil, fmt.Errorf("failed to scan account: %w", err)
 		}
@@ -133,6 +549,8 @@ func (r *AccountDbRepository) HandleListAccountsRequest(ctx context.Context, req
 				Id:   id,
 				Type: accountType,
 			},
+			Metadata: metadata,
+			Name:     name,
 		}
 		accounts = append(accounts, account)
 	}
@@ -142,8 +560,5 @@ func (r *AccountDbRepository) HandleListAccountsRequest(ctx context.Context, req
 		return nil, fmt.Errorf("failed to iterate accounts: %w", err)
 	}
 
-	log.Printf("Listed %d accounts", len(accounts))
-	return &configpb.ListAccountsResponseProto{
-		Accounts: accounts,
-	}, nil
+	return accounts, nil
 }
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"log"
+	"sync/atomic"
+
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
+)
+
+// accountChangesChannel is the notification channel the accounts change trigger notifies
+const accountChangesChannel = "accounts_changes"
+
+// accountChangeKinds maps the trigger operation of a notification to the change it reports
+var accountChangeKinds = map[string]configpb.AccountChangeProto_Kind{
+	"INSERT": configpb.AccountChangeProto_KIND_CREATED,
+	"UPDATE": configpb.AccountChangeProto_KIND_UPDATED,
+	"DELETE": configpb.AccountChangeProto_KIND_DELETED,
+}
+
+// accountChangeNotification is the payload of an accounts_changes notification
+type accountChangeNotification struct {
+	Op       string `json:"op"` // INSERT, UPDATE or DELETE
+	TenantID string `json:"tenant_id"`
+	ID       string `json:"id"` // hex encoded
+	Name     string `json:"name"`
+}
+
+// proto returns the change the notification reports
+func (n accountChangeNotification) proto() (*configpb.AccountChangeProto, error) {
+	kind, ok := accountChangeKinds[n.Op]
+	if !ok {
+		return nil, fmt.Errorf("unknown account change %q", n.Op)
+	}
+	id, err := hex.DecodeString(n.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode account ID %q: %w", n.ID, err)
+	}
+	return &configpb.AccountChangeProto{
+		Kind:      kind,
+		AccountId: ids.AccountID(id).Proto(),
+		Name:      n.Name,
+	}, nil
+}
+
+// HandleWatchAccountsRequest listens for changes to the caller's accounts and returns them in commit order
+// Every change committed after it returns is yielded; iterating waits for them until ctx is done or the consumer stops.
+// The listener is closed once iteration stops, or once ctx is done if the changes are never iterated
+func (r *AccountDbRepository) HandleWatchAccountsRequest(ctx context.Context, req *configpb.WatchAccountsRequestProto) (iter.Seq2[*configpb.AccountChangeProto, error], error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Listen before returning, so callers know which changes they will see
+	listener, err := r.pool.Listen(ctx, accountChangesChannel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch accounts: %w", err)
+	}
+
+	// The iteration owns the listener once it starts; until then ctx ending closes it
+	var claimed atomic.Bool
+	stop := context.AfterFunc(ctx, func() {
+		if claimed.CompareAndSwap(false, true) {
+			listener.Close()
+		}
+	})
+
+	return func(yield func(*configpb.AccountChangeProto, error) bool) {
+		if !claimed.CompareAndSwap(false, true) {
+			return
+		}
+		stop()
+		defer listener.Close()
+
+		for {
+			payload, err := listener.Wait(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to receive account changes: %w", err))
+				return
+			}
+
+			var n accountChangeNotification
+			if err := json.Unmarshal([]byte(payload), &n); err != nil {
+				log.Printf("Ignoring malformed account change notification %q: %v", payload, err)
+				continue
+			}
+			if n.TenantID != tenantID {
+				continue
+			}
+			change, err := n.proto()
+			if err != nil {
+				log.Printf("Ignoring account change notification %q: %v", payload, err)
+				continue
+			}
+			if !yield(change, nil) {
+				return
+			}
+		}
+	}, nil
+}
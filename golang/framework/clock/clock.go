@@ -0,0 +1,57 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time; repositories and caches take one so tests can control time
+type Clock interface {
+	Now() time.Time
+}
+
+// Real is the Clock reading the system time
+var Real Clock = realClock{}
+
+// realClock reads time.Now
+type realClock struct{}
+
+// Now implements Clock
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a Clock that only moves when a test sets or advances it; it is safe for concurrent use
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Compile-time check that Fake implements Clock
+var _ Clock = (*Fake)(nil)
+
+// NewFake creates a fake clock reading now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now implements Clock
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance moves the clock forward by d
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Set moves the clock to now, which may be in the past
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
@@ -0,0 +1,33 @@
+package clock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/clock"
+)
+
+func TestFakeOnlyMovesWhenTold(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+
+	if got := fake.Now(); !got.Equal(start) {
+		t.Fatalf("Expected %s, got %s", start, got)
+	}
+	fake.Advance(90 * time.Second)
+	if got, want := fake.Now(), start.Add(90*time.Second); !got.Equal(want) {
+		t.Fatalf("Expected %s after Advance, got %s", want, got)
+	}
+	fake.Set(start.Add(-time.Hour))
+	if got, want := fake.Now(), start.Add(-time.Hour); !got.Equal(want) {
+		t.Fatalf("Expected %s after Set, got %s", want, got)
+	}
+}
+
+func TestRealFollowsSystemTime(t *testing.T) {
+	before := time.Now()
+	got := clock.Real.Now()
+	if got.Before(before) || got.After(time.Now()) {
+		t.Fatalf("Expected the real clock to read the system time, got %s", got)
+	}
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrPoolExhausted reports that no connection became free within the acquire timeout
+// It is distinct from the caller's own deadline, which surfaces as context.DeadlineExceeded
+var ErrPoolExhausted = errors.New("connection pool exhausted")
+
+// AcquireTimeout acquires a connection, waiting at most d for one to become free
+// Fails with ErrPoolExhausted if all MaxConns connections stay in use for d; the caller must release the connection
+func (pool *DBPool) AcquireTimeout(ctx context.Context, d time.Duration) (*pgxpool.Conn, error) {
+	acquireCtx, cancel := context.WithTimeoutCause(ctx, d, ErrPoolExhausted)
+	defer cancel()
+
+	conn, err := pool.Acquire(acquireCtx)
+	if err == nil {
+		return conn, nil
+	}
+
+	// Only our own timeout with every connection in use means saturation; a slow dial is a plain error
+	stat := pool.Stat()
+	if ctx.Err() == nil && errors.Is(context.Cause(acquireCtx), ErrPoolExhausted) && stat.AcquiredConns() >= stat.MaxConns() {
+		return nil, fmt.Errorf("no connection to %s freed up within %s (%d of %d in use): %w",
+			pool.database, d, stat.AcquiredConns(), stat.MaxConns(), ErrPoolExhausted)
+	}
+	return nil, fmt.Errorf("failed to acquire connection: %w", err)
+}
+
+// acquiringQuerier runs every statement on a connection acquired with AcquireTimeout
+type acquiringQuerier struct {
+	pool    *DBPool
+	timeout time.Duration
+}
+
+var _ Querier = acquiringQuerier{}
+
+// Exec implements Querier, releasing the connection once the statement completes
+func (q acquiringQuerier) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	conn, err := q.pool.AcquireTimeout(ctx, q.timeout)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer conn.Release()
+	return conn.Exec(ctx, sql, args...)
+}
+
+// Query implements Querier, releasing the connection once the rows are closed
+func (q acquiringQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	conn, err := q.pool.AcquireTimeout(ctx, q.timeout)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return &releasingRows{Rows: rows, conn: conn}, nil
+}
+
+// QueryRow implements Querier, releasing the connection once the row is scanned
+func (q acquiringQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	conn, err := q.pool.AcquireTimeout(ctx, q.timeout)
+	if err != nil {
+		return errRow{err: err}
+	}
+	return releasingRow{row: conn.QueryRow(ctx, sql, args...), conn: conn}
+}
+
+// releasingRows releases its connection once the rows are closed or exhausted
+type releasingRows struct {
+	pgx.Rows
+	conn    *pgxpool.Conn
+	release sync.Once
+}
+
+// Next advances to the next row, closing the rows after the last one
+func (r *releasingRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+// Close closes the rows and releases the connection
+func (r *releasingRows) Close() {
+	r.Rows.Close()
+	r.release.Do(r.conn.Release)
+}
+
+// releasingRow releases its connection once scanned
+type releasingRow struct {
+	row  pgx.Row
+	conn *pgxpool.Conn
+}
+
+// Scan implements pgx.Row, releasing the connection
+func (r releasingRow) Scan(dest ...any) error {
+	defer r.conn.Release()
+	return r.row.Scan(dest...)
+}
+
+// errRow is a row whose Scan fails with err
+type errRow struct {
+	err error
+}
+
+// Scan implements pgx.Row
+func (r errRow) Scan(...any) error {
+	return r.err
+}
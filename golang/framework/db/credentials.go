@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Credentials are the secrets a single new connection authenticates with
+type Credentials struct {
+	// Password replaces the password of the connection string; empty keeps it
+	Password string
+	// Certificate replaces the client certificate of the connection string; nil keeps it
+	Certificate *tls.Certificate
+}
+
+// CredentialSource supplies the credentials for every new connection
+// Implementations are called concurrently and should read the current secret, e.g. from a file a secret agent rotates
+type CredentialSource interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// FileCredentials reads credentials from files on every call, so rewritten files are picked up by the next connection
+// Empty paths are skipped; CertFile and KeyFile are only read together
+type FileCredentials struct {
+	PasswordFile string
+	CertFile     string
+	KeyFile      string
+}
+
+// Compile-time check that FileCredentials implements CredentialSource
+var _ CredentialSource = FileCredentials{}
+
+// Credentials reads the password and the client certificate from disk
+func (f FileCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	var creds Credentials
+
+	if f.PasswordFile != "" {
+		password, err := os.ReadFile(f.PasswordFile)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to read password file: %w", err)
+		}
+		// Secret agents commonly write a trailing newline
+		creds.Password = strings.TrimRight(string(password), "\r\n")
+	}
+
+	if f.CertFile != "" && f.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(f.CertFile, f.KeyFile)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		creds.Certificate = &cert
+	}
+
+	return creds, nil
+}
+
+// ReloadCredentials returns a BeforeConnect hook that applies the current credentials of src to every new connection
+// Pools keep existing connections, so rotated credentials take effect as connections cycle (see MaxConnLifetime)
+func ReloadCredentials(src CredentialSource) func(ctx context.Context, cfg *pgx.ConnConfig) error {
+	return func(ctx context.Context, cfg *pgx.ConnConfig) error {
+		creds, err := src.Credentials(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load database credentials: %w", err)
+		}
+
+		if creds.Password != "" {
+			cfg.Password = creds.Password
+		}
+
+		if creds.Certificate != nil {
+			// pgxpool passes a copy of its config, with cloned TLS configs, so they can be changed in place
+			if cfg.TLSConfig != nil {
+				cfg.TLSConfig.Certificates = []tls.Certificate{*creds.Certificate}
+			}
+			for _, fallback := range cfg.Fallbacks {
+				if fallback.TLSConfig != nil {
+					fallback.TLSConfig.Certificates = []tls.Certificate{*creds.Certificate}
+				}
+			}
+		}
+
+		return nil
+	}
+}
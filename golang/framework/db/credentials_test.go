@@ -0,0 +1,127 @@
+package db_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
+)
+
+// rotatingCredentials is a fake CredentialSource whose secret a test rotates, as a secret agent would
+type rotatingCredentials struct {
+	mu    sync.Mutex
+	creds db.Credentials
+	err   error
+}
+
+func (r *rotatingCredentials) rotate(creds db.Credentials) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.creds = creds
+}
+
+func (r *rotatingCredentials) Credentials(ctx context.Context) (db.Credentials, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.creds, r.err
+}
+
+// connect runs hook on a fresh copy of the parsed connection string, as pgxpool does for every new connection
+func connect(t *testing.T, connStr string, hook func(ctx context.Context, cfg *pgx.ConnConfig) error) *pgx.ConnConfig {
+	t.Helper()
+	parsed, err := pgx.ParseConfig(connStr)
+	if err != nil {
+		t.Fatalf("Failed to parse connection string: %v", err)
+	}
+	cfg := parsed.Copy()
+	if err := hook(context.Background(), cfg); err != nil {
+		t.Fatalf("BeforeConnect hook failed: %v", err)
+	}
+	return cfg
+}
+
+func TestReloadCredentialsUsesRotatedPassword(t *testing.T) {
+	source := &rotatingCredentials{creds: db.Credentials{Password: "first"}}
+	hook := db.ReloadCredentials(source)
+	connStr := "host=localhost sslmode=disable password=static"
+
+	first := connect(t, connStr, hook)
+	if first.Password != "first" {
+		t.Fatalf("Expected the source's password to replace the static one, got %q", first.Password)
+	}
+
+	source.rotate(db.Credentials{Password: "second"})
+	second := connect(t, connStr, hook)
+	if second.Password != "second" {
+		t.Fatalf("Expected a new connection to use the rotated password, got %q", second.Password)
+	}
+	if first.Password != "first" {
+		t.Fatalf("Expected an existing connection to keep its password, got %q", first.Password)
+	}
+
+	// An empty password keeps the one from the connection string
+	source.rotate(db.Credentials{})
+	if cfg := connect(t, connStr, hook); cfg.Password != "static" {
+		t.Fatalf("Expected the static password without a rotated one, got %q", cfg.Password)
+	}
+}
+
+func TestReloadCredentialsUsesRotatedCertificate(t *testing.T) {
+	cert := tls.Certificate{Certificate: [][]byte{[]byte("rotated")}}
+	hook := db.ReloadCredentials(&rotatingCredentials{creds: db.Credentials{Certificate: &cert}})
+
+	for _, sslMode := range []string{"require", "prefer"} {
+		cfg := connect(t, "host=localhost sslmode="+sslMode, hook)
+		if cfg.TLSConfig == nil || len(cfg.TLSConfig.Certificates) != 1 || string(cfg.TLSConfig.Certificates[0].Certificate[0]) != "rotated" {
+			t.Fatalf("Expected the rotated certificate with sslmode=%s, got %+v", sslMode, cfg.TLSConfig)
+		}
+		for _, fallback := range cfg.Fallbacks {
+			if fallback.TLSConfig != nil && len(fallback.TLSConfig.Certificates) != 1 {
+				t.Fatalf("Expected TLS fallbacks to use the rotated certificate with sslmode=%s", sslMode)
+			}
+		}
+	}
+}
+
+func TestReloadCredentialsFailsConnectionOnSourceError(t *testing.T) {
+	sourceErr := errors.New("secret unavailable")
+	hook := db.ReloadCredentials(&rotatingCredentials{err: sourceErr})
+
+	cfg, err := pgx.ParseConfig("host=localhost sslmode=disable")
+	if err != nil {
+		t.Fatalf("Failed to parse connection string: %v", err)
+	}
+	if err := hook(context.Background(), cfg); !errors.Is(err, sourceErr) {
+		t.Fatalf("Expected the source error, got: %v", err)
+	}
+}
+
+func TestFileCredentialsRereadsPasswordFile(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, []byte("first\n"), 0600); err != nil {
+		t.Fatalf("Failed to write password file: %v", err)
+	}
+	hook := db.ReloadCredentials(db.FileCredentials{PasswordFile: passwordFile})
+
+	if cfg := connect(t, "host=localhost sslmode=disable", hook); cfg.Password != "first" {
+		t.Fatalf("Expected the password from the file without its newline, got %q", cfg.Password)
+	}
+
+	if err := os.WriteFile(passwordFile, []byte("second"), 0600); err != nil {
+		t.Fatalf("Failed to rewrite password file: %v", err)
+	}
+	if cfg := connect(t, "host=localhost sslmode=disable", hook); cfg.Password != "second" {
+		t.Fatalf("Expected the rewritten password, got %q", cfg.Password)
+	}
+
+	if _, err := (db.FileCredentials{PasswordFile: filepath.Join(t.TempDir(), "missing")}).Credentials(context.Background()); err == nil {
+		t.Fatal("Expected a missing password file to fail")
+	}
+}
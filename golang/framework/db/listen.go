@@ -0,0 +1,256 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// closeListenTimeout bounds closing a shared listening connection once its last Listener is closed
+const closeListenTimeout = 5 * time.Second
+
+// maxQueuedNotifications bounds the notifications a Listener holds for a reader that fell behind
+const maxQueuedNotifications = 1024
+
+// ErrListenerBehind is returned by Wait once a Listener dropped notifications because its reader fell
+// more than maxQueuedNotifications behind
+var ErrListenerBehind = errors.New("listener fell behind")
+
+// errPoolClosed ends the Listeners of a closed pool
+var errPoolClosed = errors.New("database pool closed")
+
+// listenHub shares one listening connection per notification channel between the Listeners of a pool
+type listenHub struct {
+	mu       sync.Mutex
+	channels map[string]*sharedListen
+}
+
+// sharedListen is a connection outside the pool subscribed with LISTEN to one channel
+// It fans every notification out to its Listeners and is closed with the last of them
+type sharedListen struct {
+	channel string
+	conn    *pgx.Conn
+	cancel  context.CancelFunc
+	done    chan struct{} // closed once the connection is closed
+
+	mu        sync.Mutex
+	listeners map[*Listener]struct{}
+}
+
+// Listener receives the notifications of one channel through the connection it shares with the pool's other Listeners of it
+// Listeners hold no pooled connection, so any number of them leave MaxConns to queries
+type Listener struct {
+	hub    *listenHub
+	shared *sharedListen
+
+	mu    sync.Mutex
+	queue []string
+	err   error
+	ready chan struct{} // signalled whenever queue or err changes
+}
+
+// Listen subscribes to channel, opening the channel's shared listening connection if no Listener has it open
+// Notifications sent after Listen returns are queued until Wait reads them; the caller must Close the listener
+func (pool *DBPool) Listen(ctx context.Context, channel string) (*Listener, error) {
+	hub := &pool.listening
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	shared, ok := hub.channels[channel]
+	if !ok {
+		var err error
+		shared, err = pool.connectListen(ctx, channel)
+		if err != nil {
+			return nil, err
+		}
+		if hub.channels == nil {
+			hub.channels = make(map[string]*sharedListen)
+		}
+		hub.channels[channel] = shared
+	}
+
+	l := &Listener{hub: hub, shared: shared, ready: make(chan struct{}, 1)}
+	shared.mu.Lock()
+	shared.listeners[l] = struct{}{}
+	shared.mu.Unlock()
+	return l, nil
+}
+
+// connectListen opens a connection outside the pool, set up as the pool's own, and subscribes it to channel
+func (pool *DBPool) connectListen(ctx context.Context, channel string) (*sharedListen, error) {
+	cfg := pool.Config()
+	if cfg.BeforeConnect != nil {
+		if err := cfg.BeforeConnect(ctx, cfg.ConnConfig); err != nil {
+			return nil, fmt.Errorf("failed to prepare listener connection: %w", err)
+		}
+	}
+	conn, err := pgx.ConnectConfig(ctx, cfg.ConnConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open listener connection: %w", err)
+	}
+	if cfg.AfterConnect != nil {
+		if err := cfg.AfterConnect(ctx, conn); err != nil {
+			conn.Close(ctx)
+			return nil, fmt.Errorf("failed to set up listener connection: %w", err)
+		}
+	}
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("failed to listen on %s: %w", channel, err)
+	}
+
+	listenCtx, cancel := context.WithCancel(context.Background())
+	shared := &sharedListen{
+		channel:   channel,
+		conn:      conn,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		listeners: make(map[*Listener]struct{}),
+	}
+	go shared.run(listenCtx, &pool.listening)
+	return shared, nil
+}
+
+// run delivers the notifications of the connection to every Listener until ctx is cancelled, then closes the connection
+// A failed connection ends every Listener with its error; the next Listen opens a new one
+func (s *sharedListen) run(ctx context.Context, hub *listenHub) {
+	defer close(s.done)
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), closeListenTimeout)
+		defer cancel()
+		s.conn.Close(closeCtx)
+	}()
+
+	for {
+		notification, err := s.conn.WaitForNotification(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Listener connection on %s failed: %v", s.channel, err)
+			hub.remove(s)
+			s.fail(err)
+			return
+		}
+
+		s.mu.Lock()
+		for l := range s.listeners {
+			l.deliver(notification.Payload)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// fail ends every Listener of s with err once its queued notifications are read
+func (s *sharedListen) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for l := range s.listeners {
+		l.fail(err)
+	}
+}
+
+// remove stops handing s to new Listeners
+func (hub *listenHub) remove(s *sharedListen) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.channels[s.channel] == s {
+		delete(hub.channels, s.channel)
+	}
+}
+
+// closeAll closes every shared listening connection, ending their Listeners
+func (hub *listenHub) closeAll() {
+	hub.mu.Lock()
+	channels := hub.channels
+	hub.channels = nil
+	hub.mu.Unlock()
+
+	for _, s := range channels {
+		s.fail(errPoolClosed)
+		s.cancel()
+		<-s.done
+	}
+}
+
+// deliver queues payload for Wait, or ends the Listener with ErrListenerBehind if its queue is full
+func (l *Listener) deliver(payload string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	if len(l.queue) >= maxQueuedNotifications {
+		l.err = ErrListenerBehind
+	} else {
+		l.queue = append(l.queue, payload)
+	}
+	l.signal()
+}
+
+// fail ends the Listener with err unless it already ended
+func (l *Listener) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil {
+		l.err = err
+		l.signal()
+	}
+}
+
+// signal wakes a waiting Wait; l.mu must be held
+func (l *Listener) signal() {
+	select {
+	case l.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Wait blocks until a notification arrives or ctx is done, returning the notification's payload
+func (l *Listener) Wait(ctx context.Context) (string, error) {
+	for {
+		l.mu.Lock()
+		if len(l.queue) > 0 {
+			payload := l.queue[0]
+			l.queue = l.queue[1:]
+			l.mu.Unlock()
+			return payload, nil
+		}
+		err := l.err
+		l.mu.Unlock()
+		if err != nil {
+			return "", fmt.Errorf("failed to wait for notification on %s: %w", l.shared.channel, err)
+		}
+
+		select {
+		case <-l.ready:
+		case <-ctx.Done():
+			return "", fmt.Errorf("failed to wait for notification on %s: %w", l.shared.channel, ctx.Err())
+		}
+	}
+}
+
+// Close unsubscribes the Listener, closing the shared connection if it was the channel's last Listener
+func (l *Listener) Close() {
+	l.hub.mu.Lock()
+	s := l.shared
+	s.mu.Lock()
+	delete(s.listeners, l)
+	last := len(s.listeners) == 0
+	s.mu.Unlock()
+	if last && l.hub.channels[s.channel] == s {
+		delete(l.hub.channels, s.channel)
+	}
+	l.hub.mu.Unlock()
+
+	if last {
+		s.cancel()
+		<-s.done
+	}
+}
@@ -2,11 +2,36 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/puddle/v2"
+)
+
+// AuthMode selects how the client authenticates to PostgreSQL
+type AuthMode int
+
+const (
+	// CertAuth authenticates with the client certificate in SSLCert and SSLKey; Password is ignored
+	CertAuth AuthMode = iota
+	// PasswordAuth authenticates with Password (SCRAM or md5, as the server asks); SSLCert and SSLKey are ignored
+	PasswordAuth
 )
 
 // Config holds database configuration
@@ -18,68 +43,240 @@ type Config struct {
 	Database string
 	SSLMode  string
 
+	// AuthMode selects the connection string shape; the zero value is CertAuth
+	AuthMode AuthMode
+
+	// ApplicationName tags the connections in pg_stat_activity; empty leaves it unset
+	ApplicationName string
+
 	// SSL Certificate paths
 	SSLCert     string // Path to client certificate
 	SSLKey      string // Path to client private key
 	SSLRootCert string // Path to CA certificate
 
 	// Connection pool settings
+	// MaxConns caps the pool; zero sizes it from ConcurrencyHint, see PoolSize
 	MaxConns          int32
 	MinConns          int32
 	MaxConnLifetime   time.Duration
 	MaxConnIdleTime   time.Duration
 	HealthCheckPeriod time.Duration
+
+	// CountQueries counts every statement the pool runs, read with DBPool.QueryCount; tests use it to catch N+1 queries
+	CountQueries bool
+
+	// ConcurrencyHint is the number of requests expected to query at once; zero uses GOMAXPROCS
+	// It only sizes the pool while MaxConns is zero
+	ConcurrencyHint int
+
+	// AcquireTimeout bounds the wait for a free connection in Querier and RunInTx; past it they fail with ErrPoolExhausted
+	// It only covers waiting for the pool, not running the statement, so exhaustion is told apart from slow queries
+	// Zero waits as long as the caller's context allows
+	AcquireTimeout time.Duration
+
+	// BeforeConnect runs before every new connection, e.g. to apply rotated credentials with ReloadCredentials
+	BeforeConnect func(ctx context.Context, cfg *pgx.ConnConfig) error
+
+	// AfterConnect runs on every new connection, e.g. to register custom types with RegisterTypes
+	AfterConnect func(ctx context.Context, conn *pgx.Conn) error
 }
 
+// defaultHost is the in-cluster service of the database, only resolvable inside the cluster
+const defaultHost = "app-postgres-rw.app-namespace.svc.cluster.local"
+
+// Environment variables read by ConfigFromEnv
+const (
+	HostEnv     = "DB_HOST"
+	PortEnv     = "DB_PORT"
+	UserEnv     = "DB_USER"
+	PasswordEnv = "DB_PASSWORD" // Switches to PasswordAuth
+	SSLModeEnv  = "DB_SSLMODE"
+	MaxConnsEnv = "DB_MAX_CONNS" // Overrides the computed pool size
+)
+
 // DefaultConfig returns default database configuration
+// MaxConns is left zero, so the pool is sized for the process by PoolSize
 func DefaultConfig(dbName string) *Config {
 	return &Config{
-		Host:              "app-postgres-rw.app-namespace.svc.cluster.local",
+		Host:              defaultHost,
 		Port:              5432,
 		User:              "grpcserver",
 		Password:          "", // Not used with certificate authentication
 		Database:          dbName,
 		SSLMode:           "verify-full",
+		AuthMode:          CertAuth,
+		ApplicationName:   filepath.Base(os.Args[0]),
 		SSLCert:           "/mnt/client-certs/tls.crt",
 		SSLKey:            "/mnt/client-certs/tls.key",
 		SSLRootCert:       "/mnt/postgres-ca/ca.crt",
-		MaxConns:          25,
 		MinConns:          5,
 		MaxConnLifetime:   time.Hour,
 		MaxConnIdleTime:   30 * time.Minute,
 		HealthCheckPeriod: 1 * time.Minute,
+		AcquireTimeout:    5 * time.Second,
+	}
+}
+
+// ConfigFromEnv returns DefaultConfig overridden by the DB_* environment variables that are set
+// Outside the cluster set DB_HOST, and DB_PASSWORD to authenticate without the mounted client certificate
+func ConfigFromEnv(dbName string) (*Config, error) {
+	cfg := DefaultConfig(dbName)
+	if host := os.Getenv(HostEnv); host != "" {
+		cfg.Host = host
+	}
+	if port := os.Getenv(PortEnv); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s %q: %w", PortEnv, port, err)
+		}
+		cfg.Port = p
+	}
+	if user := os.Getenv(UserEnv); user != "" {
+		cfg.User = user
+	}
+	if password := os.Getenv(PasswordEnv); password != "" {
+		cfg.Password = password
+		cfg.AuthMode = PasswordAuth
+	}
+	if maxConns := os.Getenv(MaxConnsEnv); maxConns != "" {
+		n, err := strconv.ParseInt(maxConns, 10, 32)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("failed to parse %s %q: must be a positive number", MaxConnsEnv, maxConns)
+		}
+		cfg.MaxConns = int32(n)
+	}
+	if sslMode := os.Getenv(SSLModeEnv); sslMode != "" {
+		cfg.SSLMode = sslMode
+		// pgx reads the CA file even without TLS, and it is only mounted in the cluster
+		if sslMode == "disable" {
+			cfg.SSLRootCert = ""
+		}
+	}
+	return cfg, nil
+}
+
+// Bounds of the pool size computed by PoolSizeFor
+const (
+	MinPoolSize int32 = 4
+	MaxPoolSize int32 = 100
+)
+
+// connsPerUnit is the number of connections PoolSizeFor allots each unit of concurrency,
+// so one query can run while another of the same request waits on the network
+const connsPerUnit = 2
+
+// PoolSizeFor returns the pool size for concurrency concurrent requests: two connections each,
+// clamped to MinPoolSize so a one-CPU dev pod keeps a spare, and to MaxPoolSize so large pods
+// don't exhaust the server's max_connections
+func PoolSizeFor(concurrency int) int32 {
+	if concurrency >= int(MaxPoolSize/connsPerUnit) {
+		return MaxPoolSize
+	}
+	return max(MinPoolSize, int32(concurrency)*connsPerUnit)
+}
+
+// PoolSize returns the number of connections the pool opens at most: MaxConns if set, else
+// PoolSizeFor(ConcurrencyHint), falling back to GOMAXPROCS, the CPUs the process may use (its CPU limit in a container)
+func (c *Config) PoolSize() int32 {
+	if c.MaxConns > 0 {
+		return c.MaxConns
 	}
+	if c.ConcurrencyHint > 0 {
+		return PoolSizeFor(c.ConcurrencyHint)
+	}
+	return PoolSizeFor(runtime.GOMAXPROCS(0))
 }
 
 type DBPool struct {
 	*pgxpool.Pool
-	database string
+	database       string
+	acquireTimeout time.Duration
+	queries        *atomic.Int64 // nil unless Config.CountQueries
+	listening      listenHub     // the shared connections of Listen, outside the pool
+}
+
+// connParam is a keyword and value of a connection string
+type connParam struct {
+	key, value string
+}
+
+// connParams returns the connection parameters following host, port, user and dbname, in connection string order
+func (c *Config) connParams() []connParam {
+	var params []connParam
+	if c.SSLMode != "" {
+		params = append(params, connParam{"sslmode", c.SSLMode})
+	}
+
+	switch c.AuthMode {
+	case PasswordAuth:
+		if c.Password != "" {
+			params = append(params, connParam{"password", c.Password})
+		}
+	default:
+		if c.SSLCert != "" {
+			params = append(params, connParam{"sslcert", c.SSLCert})
+		}
+		if c.SSLKey != "" {
+			params = append(params, connParam{"sslkey", c.SSLKey})
+		}
+	}
+
+	// The CA verifies the server in either mode
+	if c.SSLRootCert != "" {
+		params = append(params, connParam{"sslrootcert", c.SSLRootCert})
+	}
+
+	if c.ApplicationName != "" {
+		params = append(params, connParam{"application_name", c.ApplicationName})
+	}
+
+	return params
 }
 
-// ConnectionString builds a PostgreSQL connection string from the config
+// ConnectionString builds a PostgreSQL keyword/value connection string from the config
+// Values are quoted per libpq rules, so passwords and paths may contain spaces, quotes and backslashes
 func (c *Config) ConnectionString() string {
 	connStr := fmt.Sprintf(
-		"host=%s port=%d user=%s dbname=%s sslmode=%s",
-		c.Host, c.Port, c.User, c.Database, c.SSLMode,
+		"host=%s port=%d user=%s dbname=%s",
+		quoteConnValue(c.Host), c.Port, quoteConnValue(c.User), quoteConnValue(c.Database),
 	)
 
-	// Add password if provided (for non-certificate auth)
-	if c.Password != "" {
-		connStr += fmt.Sprintf(" password=%s", c.Password)
+	for _, param := range c.connParams() {
+		connStr += " " + param.key + "=" + quoteConnValue(param.value)
 	}
 
-	// Add SSL certificate paths if provided (for certificate auth)
-	if c.SSLCert != "" {
-		connStr += fmt.Sprintf(" sslcert=%s", c.SSLCert)
-	}
-	if c.SSLKey != "" {
-		connStr += fmt.Sprintf(" sslkey=%s", c.SSLKey)
+	return connStr
+}
+
+// URL builds a postgres:// connection URL from the config, percent-encoding every value
+func (c *Config) URL() string {
+	u := &url.URL{
+		Scheme: "postgres",
+		User:   url.User(c.User),
+		Host:   net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		Path:   "/" + c.Database,
 	}
-	if c.SSLRootCert != "" {
-		connStr += fmt.Sprintf(" sslrootcert=%s", c.SSLRootCert)
+
+	// The password goes in the user info, the other parameters in the query
+	query := url.Values{}
+	for _, param := range c.connParams() {
+		if param.key == "password" {
+			u.User = url.UserPassword(c.User, param.value)
+			continue
+		}
+		query.Set(param.key, param.value)
 	}
+	u.RawQuery = query.Encode()
 
-	return connStr
+	return u.String()
+}
+
+// quoteConnValue quotes a connection string value if it is empty or contains spaces, quotes or backslashes
+func quoteConnValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " '\\") {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
 }
 
 // NewPool creates a new PostgreSQL connection pool
@@ -87,15 +284,23 @@ func NewPool(ctx context.Context, cfg *Config) (*DBPool, error) {
 	// Build pool config
 	poolConfig, err := pgxpool.ParseConfig(cfg.ConnectionString())
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse connection string: %w", err)
+		return nil, fmt.Errorf("failed to parse connection string: %w", localHint(cfg, err))
 	}
 
 	// Configure connection pool
-	poolConfig.MaxConns = cfg.MaxConns
-	poolConfig.MinConns = cfg.MinConns
+	poolConfig.MaxConns = cfg.PoolSize()
+	// A small computed pool may be below the configured minimum
+	poolConfig.MinConns = min(cfg.MinConns, poolConfig.MaxConns)
 	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
 	poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
 	poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	poolConfig.BeforeConnect = cfg.BeforeConnect
+	poolConfig.AfterConnect = cfg.AfterConnect
+	counter := queryCounter{}
+	if cfg.CountQueries {
+		counter.total = new(atomic.Int64)
+	}
+	poolConfig.ConnConfig.Tracer = counter
 
 	// Create pool
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
@@ -106,11 +311,39 @@ func NewPool(ctx context.Context, cfg *Config) (*DBPool, error) {
 	// Verify connection
 	if err := pool.Ping(ctx); err != nil {
 		pool.Close()
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		return nil, fmt.Errorf("failed to ping database: %w", localHint(cfg, err))
+	}
+
+	log.Printf("Connected to PostgreSQL at %s:%d (database: %s, max %d connections)", cfg.Host, cfg.Port, cfg.Database, poolConfig.MaxConns)
+	return &DBPool{Pool: pool, database: cfg.Database, acquireTimeout: cfg.AcquireTimeout, queries: counter.total}, nil
+}
+
+// localHint adds what to do to err if it failed on an in-cluster default, e.g. when run locally
+// Failures to resolve any host point at DB_HOST
+func localHint(cfg *Config, err error) error {
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && cfg.Host == defaultHost:
+		return fmt.Errorf("cannot resolve database host %s, set %s, the default targets the in-cluster service: %w", cfg.Host, HostEnv, err)
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("cannot resolve database host %s, check %s: %w", cfg.Host, HostEnv, err)
+	case errors.Is(err, fs.ErrNotExist) && cfg.SSLRootCert == DefaultConfig(cfg.Database).SSLRootCert:
+		return fmt.Errorf("missing certificates only mounted in the cluster, set %s, %s and %s=disable to connect locally: %w", HostEnv, PasswordEnv, SSLModeEnv, err)
 	}
+	return err
+}
 
-	log.Printf("Connected to PostgreSQL at %s:%d (database: %s)", cfg.Host, cfg.Port, cfg.Database)
-	return &DBPool{pool, cfg.Database}, nil
+// RegisterTypes returns an AfterConnect hook that loads and registers the named database types
+// Use it for types pgx doesn't know, such as enums and composites; array types are named with a "_" prefix
+func RegisterTypes(typeNames ...string) func(ctx context.Context, conn *pgx.Conn) error {
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		types, err := conn.LoadTypes(ctx, typeNames)
+		if err != nil {
+			return fmt.Errorf("failed to load types %v: %w", typeNames, err)
+		}
+		conn.TypeMap().RegisterTypes(types)
+		return nil
+	}
 }
 
 // MustNewPool creates a new connection pool or panics on error
@@ -122,6 +355,62 @@ func MustNewPool(ctx context.Context, cfg *Config) *DBPool {
 	return pool
 }
 
+// MaxQueryJSONRows caps the number of rows QueryJSON returns before it fails
+const MaxQueryJSONRows = 10000
+
+// QueryJSON runs a read-only query and returns its rows as a JSON array of objects keyed by column name
+// Fails if the query returns more than MaxQueryJSONRows rows
+func (pool *DBPool) QueryJSON(ctx context.Context, sql string, args ...any) ([]byte, error) {
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin read-only transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run query: %w", err)
+	}
+	defer rows.Close()
+
+	fields := rows.FieldDescriptions()
+	result := []map[string]any{}
+	for rows.Next() {
+		if len(result) == MaxQueryJSONRows {
+			return nil, fmt.Errorf("query returned more than %d rows", MaxQueryJSONRows)
+		}
+
+		values, err := rows.Values()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read row: %w", err)
+		}
+
+		row := make(map[string]any, len(fields))
+		for i, field := range fields {
+			row[field.Name] = jsonValue(values[i])
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal rows: %w", err)
+	}
+	return data, nil
+}
+
+// jsonValue converts pgx values without a useful JSON encoding
+// UUIDs become their string form; bytea stays base64 and time.Time RFC 3339, as encoding/json does by default
+func jsonValue(value any) any {
+	if uuid, ok := value.([16]byte); ok {
+		return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+	}
+	return value
+}
+
 // Close gracefully closes the database connection pool
 func (pool *DBPool) Close() {
 	if pool == nil || pool.Pool == nil {
@@ -129,6 +418,60 @@ func (pool *DBPool) Close() {
 		return
 	}
 	log.Printf("Closing database connection pool (database: %s, stats before: %+v)", pool.database, pool.Pool.Stat())
+	pool.listening.closeAll()
 	pool.Pool.Close()
 	log.Printf("Database connection pool closed (database: %s)", pool.database)
 }
+
+// jsonScanner decodes a JSON or JSONB column into dst
+type jsonScanner[T any] struct {
+	dst *T
+}
+
+// Scan implements sql.Scanner, leaving dst unchanged for NULL
+func (s jsonScanner[T]) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), s.dst)
+	case []byte:
+		return json.Unmarshal(v, s.dst)
+	default:
+		return fmt.Errorf("failed to scan JSON: unsupported source type %T", src)
+	}
+}
+
+// ScanJSON returns a Scan destination decoding a JSON or JSONB column into dst
+// dst can be anything encoding/json decodes into, e.g. a struct, a map or a *structpb.Struct; NULL leaves it unchanged
+func ScanJSON[T any](dst *T) sql.Scanner {
+	return jsonScanner[T]{dst: dst}
+}
+
+// uniqueViolation is the PostgreSQL SQLSTATE for a unique constraint violation
+const uniqueViolation = "23505"
+
+// checkViolation is the PostgreSQL SQLSTATE for a check constraint violation
+const checkViolation = "23514"
+
+// IsCheckViolation reports whether err is a PostgreSQL check constraint violation of the named constraint
+func IsCheckViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == checkViolation && pgErr.ConstraintName == constraint
+}
+
+// ErrDuplicate reports a write rejected because the row already exists
+var ErrDuplicate = errors.New("duplicate key")
+
+// IsUniqueViolation reports whether err is a PostgreSQL unique constraint violation
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// IsUnavailable reports whether err means the database can't be reached: the pool is closed or no connection could be made
+// Statement errors from a reachable database are not unavailability
+func IsUnavailable(err error) bool {
+	var connectErr *pgconn.ConnectError
+	return errors.Is(err, puddle.ErrClosedPool) || errors.As(err, &connectErr)
+}
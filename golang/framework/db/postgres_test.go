@@ -0,0 +1,258 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/puddle/v2"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
+)
+
+func TestConnectionStringCertAuth(t *testing.T) {
+	cfg := db.DefaultConfig("config")
+	cfg.Password = "ignored"
+
+	want := "host=app-postgres-rw.app-namespace.svc.cluster.local port=5432 user=grpcserver dbname=config sslmode=verify-full" +
+		" sslcert=/mnt/client-certs/tls.crt sslkey=/mnt/client-certs/tls.key sslrootcert=/mnt/postgres-ca/ca.crt" +
+		" application_name=" + filepath.Base(os.Args[0])
+	if got := cfg.ConnectionString(); got != want {
+		t.Fatalf("Unexpected cert auth connection string:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestConnectionStringPasswordAuth(t *testing.T) {
+	// Switching the mode is enough, the certificate paths of the default config can stay
+	cfg := db.DefaultConfig("config")
+	cfg.AuthMode = db.PasswordAuth
+	cfg.Host = "localhost"
+	cfg.User = "postgres"
+	cfg.Password = "secret"
+	cfg.ApplicationName = ""
+
+	want := "host=localhost port=5432 user=postgres dbname=config sslmode=verify-full password=secret sslrootcert=/mnt/postgres-ca/ca.crt"
+	if got := cfg.ConnectionString(); got != want {
+		t.Fatalf("Unexpected password auth connection string:\n got: %s\nwant: %s", got, want)
+	}
+
+	cfg.SSLMode = "disable"
+	cfg.SSLRootCert = ""
+	want = "host=localhost port=5432 user=postgres dbname=config sslmode=disable password=secret"
+	if got := cfg.ConnectionString(); got != want {
+		t.Fatalf("Unexpected password auth connection string without TLS:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestConnectionStringApplicationName(t *testing.T) {
+	// The default config tags connections with the binary name
+	if got := db.DefaultConfig("config").ApplicationName; got != filepath.Base(os.Args[0]) {
+		t.Fatalf("Expected the binary name as default application name, got %q", got)
+	}
+
+	for _, name := range []string{"grpcserver", "config service", `it's \ quoted`} {
+		cfg := &db.Config{Host: "localhost", Port: 5432, User: "postgres", Database: "config", SSLMode: "disable", ApplicationName: name}
+		parsed, err := pgx.ParseConfig(cfg.ConnectionString())
+		if err != nil {
+			t.Fatalf("Failed to parse connection string %q: %v", cfg.ConnectionString(), err)
+		}
+		if got := parsed.RuntimeParams["application_name"]; got != name {
+			t.Fatalf("Expected application_name %q, got %q from %q", name, got, cfg.ConnectionString())
+		}
+	}
+}
+
+func TestConnectionStringAndURLEscapeValues(t *testing.T) {
+	cfg := &db.Config{
+		Host:            "localhost",
+		Port:            5432,
+		User:            "app user",
+		Password:        `p@ss word's \ 100%/x?`,
+		Database:        "config db",
+		SSLMode:         "disable",
+		AuthMode:        db.PasswordAuth,
+		ApplicationName: "config service",
+	}
+
+	for name, connString := range map[string]string{"connection string": cfg.ConnectionString(), "URL": cfg.URL()} {
+		parsed, err := pgx.ParseConfig(connString)
+		if err != nil {
+			t.Fatalf("Failed to parse %s %q: %v", name, connString, err)
+		}
+		if parsed.User != cfg.User || parsed.Password != cfg.Password || parsed.Database != cfg.Database {
+			t.Fatalf("Expected %s %q to round trip user %q, password %q and dbname %q, got %q, %q and %q",
+				name, connString, cfg.User, cfg.Password, cfg.Database, parsed.User, parsed.Password, parsed.Database)
+		}
+		if parsed.Host != cfg.Host || parsed.Port != uint16(cfg.Port) {
+			t.Fatalf("Expected %s %q to address %s:%d, got %s:%d", name, connString, cfg.Host, cfg.Port, parsed.Host, parsed.Port)
+		}
+		if got := parsed.RuntimeParams["application_name"]; got != cfg.ApplicationName {
+			t.Fatalf("Expected %s %q to keep application_name %q, got %q", name, connString, cfg.ApplicationName, got)
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv(db.HostEnv, "localhost")
+	t.Setenv(db.PortEnv, "5433")
+	t.Setenv(db.PasswordEnv, "secret")
+	t.Setenv(db.SSLModeEnv, "disable")
+
+	cfg, err := db.ConfigFromEnv("config")
+	if err != nil {
+		t.Fatalf("Failed to read config from env: %v", err)
+	}
+	if cfg.Host != "localhost" || cfg.Port != 5433 || cfg.SSLMode != "disable" {
+		t.Fatalf("Expected host, port and sslmode from env, got %s:%d sslmode=%s", cfg.Host, cfg.Port, cfg.SSLMode)
+	}
+	if cfg.AuthMode != db.PasswordAuth || cfg.Password != "secret" {
+		t.Fatalf("Expected a password from env to switch to password auth, got mode %d", cfg.AuthMode)
+	}
+	// Unset variables keep their defaults
+	if want := db.DefaultConfig("config").User; cfg.User != want {
+		t.Fatalf("Expected default user %s, got %s", want, cfg.User)
+	}
+
+	// Without TLS the CA, only mounted in the cluster, isn't read
+	if cfg.SSLRootCert != "" {
+		t.Fatalf("Expected sslmode=disable to drop the CA, got %s", cfg.SSLRootCert)
+	}
+
+	t.Setenv(db.PortEnv, "postgres")
+	if _, err := db.ConfigFromEnv("config"); err == nil {
+		t.Fatal("Expected an invalid port to fail")
+	}
+}
+
+func TestConfigFromEnvOverridesPoolSize(t *testing.T) {
+	t.Setenv(db.MaxConnsEnv, "12")
+
+	cfg, err := db.ConfigFromEnv("config")
+	if err != nil {
+		t.Fatalf("Failed to read config from env: %v", err)
+	}
+	cfg.ConcurrencyHint = 40
+	if got := cfg.PoolSize(); got != 12 {
+		t.Fatalf("Expected %s to override the computed pool size, got %d", db.MaxConnsEnv, got)
+	}
+
+	t.Setenv(db.MaxConnsEnv, "0")
+	if _, err := db.ConfigFromEnv("config"); err == nil {
+		t.Fatal("Expected a non-positive pool size to fail")
+	}
+}
+
+func TestPoolSize(t *testing.T) {
+	cfg := db.DefaultConfig("config")
+
+	// Without a hint the pool follows the CPUs the process may use
+	if got, want := cfg.PoolSize(), db.PoolSizeFor(runtime.GOMAXPROCS(0)); got != want {
+		t.Fatalf("Expected the default pool size %d for GOMAXPROCS %d, got %d", want, runtime.GOMAXPROCS(0), got)
+	}
+
+	for _, tc := range []struct {
+		hint int
+		want int32
+	}{
+		{hint: 1, want: db.MinPoolSize},
+		{hint: 8, want: 16},
+		{hint: 20, want: 40},
+		{hint: 1000, want: db.MaxPoolSize},
+	} {
+		cfg.ConcurrencyHint = tc.hint
+		if got := cfg.PoolSize(); got != tc.want {
+			t.Fatalf("Expected pool size %d for concurrency hint %d, got %d", tc.want, tc.hint, got)
+		}
+	}
+
+	// An explicit MaxConns wins over the hint
+	cfg.MaxConns = 3
+	if got := cfg.PoolSize(); got != 3 {
+		t.Fatalf("Expected the explicit MaxConns 3, got %d", got)
+	}
+}
+
+func TestNewPoolExplainsUnresolvableHost(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The .invalid domain never resolves
+	cfg := db.DefaultConfig("config")
+	cfg.Host = "postgres.invalid"
+	cfg.AuthMode = db.PasswordAuth
+	cfg.SSLMode = "disable"
+	cfg.SSLRootCert = ""
+	cfg.MinConns = 0
+
+	_, err := db.NewPool(ctx, cfg)
+	if err == nil {
+		t.Fatal("Expected connecting to an unresolvable host to fail")
+	}
+	want := "cannot resolve database host postgres.invalid, check " + db.HostEnv
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("Expected the error to contain %q, got: %v", want, err)
+	}
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		t.Fatalf("Expected the DNS error to stay wrapped, got: %v", err)
+	}
+}
+
+func TestNewPoolExplainsMissingClusterCertificates(t *testing.T) {
+	cfg := db.DefaultConfig("config")
+	if _, err := os.Stat(cfg.SSLRootCert); err == nil {
+		t.Skipf("Running in the cluster, %s exists", cfg.SSLRootCert)
+	}
+
+	_, err := db.NewPool(context.Background(), cfg)
+	if err == nil {
+		t.Fatal("Expected connecting without the mounted certificates to fail")
+	}
+	if !strings.Contains(err.Error(), "set "+db.HostEnv+", "+db.PasswordEnv+" and "+db.SSLModeEnv+"=disable") {
+		t.Fatalf("Expected the error to explain how to connect locally, got: %v", err)
+	}
+}
+
+func TestIsUnavailable(t *testing.T) {
+	if !db.IsUnavailable(fmt.Errorf("failed to list accounts: %w", puddle.ErrClosedPool)) {
+		t.Fatal("Expected a closed pool to be unavailable")
+	}
+
+	// Nothing listens on port 1, so the dial fails
+	_, err := pgx.Connect(context.Background(), "host=127.0.0.1 port=1 user=postgres dbname=config sslmode=disable connect_timeout=1")
+	if err == nil {
+		t.Fatal("Expected connecting to port 1 to fail")
+	}
+	if !db.IsUnavailable(fmt.Errorf("failed to create account: %w", err)) {
+		t.Fatalf("Expected a failed connect to be unavailable: %v", err)
+	}
+
+	if db.IsUnavailable(&pgconn.PgError{Code: "23505"}) {
+		t.Fatal("Expected a statement error not to be unavailable")
+	}
+	if db.IsUnavailable(nil) {
+		t.Fatal("Expected nil not to be unavailable")
+	}
+}
+
+func TestIsCheckViolation(t *testing.T) {
+	err := fmt.Errorf("failed to update account: %w", &pgconn.PgError{Code: "23514", ConstraintName: "accounts_metadata_size"})
+	if !db.IsCheckViolation(err, "accounts_metadata_size") {
+		t.Fatal("Expected a violation of accounts_metadata_size")
+	}
+	if db.IsCheckViolation(err, "other_constraint") {
+		t.Fatal("Expected the violation to only match its own constraint")
+	}
+	if db.IsCheckViolation(&pgconn.PgError{Code: "23505", ConstraintName: "accounts_metadata_size"}, "accounts_metadata_size") {
+		t.Fatal("Expected a unique violation not to be a check violation")
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc"
+)
+
+// queryCountContextKey is the context key for the request-scoped query counter
+type queryCountContextKey struct{}
+
+// WithQueryCounter returns a new context counting every statement pools run with it or a context derived from it
+// Each round trip counts once, including BEGIN and COMMIT; tests assert on the count to catch N+1 queries
+func WithQueryCounter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryCountContextKey{}, new(atomic.Int64))
+}
+
+// QueriesFromContext returns the number of statements run so far with ctx, or 0 without WithQueryCounter
+func QueriesFromContext(ctx context.Context) int64 {
+	if counter, ok := ctx.Value(queryCountContextKey{}).(*atomic.Int64); ok {
+		return counter.Load()
+	}
+	return 0
+}
+
+// QueryCountUnaryInterceptor gives every unary call its own query counter
+// Once the handler returns, report, if not nil, gets the call's method and number of statements
+func QueryCountUnaryInterceptor(report func(method string, queries int64)) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx = WithQueryCounter(ctx)
+		resp, err := handler(ctx, req)
+		if report != nil {
+			report(info.FullMethod, QueriesFromContext(ctx))
+		}
+		return resp, err
+	}
+}
+
+// queryCounter is the pgx tracer incrementing the counter of the statement's context,
+// and the pool's total if it counts queries
+type queryCounter struct {
+	total *atomic.Int64 // nil unless Config.CountQueries
+}
+
+// Compile-time check that queryCounter implements pgx.QueryTracer
+var _ pgx.QueryTracer = queryCounter{}
+
+// TraceQueryStart implements pgx.QueryTracer
+func (q queryCounter) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	if q.total != nil {
+		q.total.Add(1)
+	}
+	if counter, ok := ctx.Value(queryCountContextKey{}).(*atomic.Int64); ok {
+		counter.Add(1)
+	}
+	return ctx
+}
+
+// TraceQueryEnd implements pgx.QueryTracer
+func (queryCounter) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+// QueryCount returns the number of statements the pool ran since it was created or last reset
+// Always 0 unless the pool was created with Config.CountQueries
+func (pool *DBPool) QueryCount() int64 {
+	if pool.queries == nil {
+		return 0
+	}
+	return pool.queries.Load()
+}
+
+// ResetQueryCount restarts QueryCount from 0, e.g. after a test's setup so only the calls under test count
+func (pool *DBPool) ResetQueryCount() {
+	if pool.queries != nil {
+		pool.queries.Store(0)
+	}
+}
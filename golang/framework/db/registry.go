@@ -0,0 +1,74 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrPoolNotRegistered is returned by Registry.Get for a name without a pool
+var ErrPoolNotRegistered = errors.New("database pool not registered")
+
+// Registry stores connection pools by database name so code can look up the pool it needs
+// It is safe for concurrent use
+type Registry struct {
+	mu    sync.RWMutex
+	pools map[string]*DBPool
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{pools: make(map[string]*DBPool)}
+}
+
+// Register stores pool under name; a name can only be registered once
+func (r *Registry) Register(name string, pool *DBPool) error {
+	if pool == nil {
+		return fmt.Errorf("failed to register database pool %q: pool is nil", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.pools[name]; exists {
+		return fmt.Errorf("failed to register database pool %q: already registered", name)
+	}
+	r.pools[name] = pool
+	return nil
+}
+
+// Get returns the pool registered under name, or ErrPoolNotRegistered listing the registered names
+func (r *Registry) Get(name string) (*DBPool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pool, ok := r.pools[name]
+	if !ok {
+		names := slices.Sorted(maps.Keys(r.pools))
+		return nil, fmt.Errorf("%w: %q (registered: %s)", ErrPoolNotRegistered, name, strings.Join(names, ", "))
+	}
+	return pool, nil
+}
+
+// MustGet returns the pool registered under name or panics
+func (r *Registry) MustGet(name string) *DBPool {
+	pool, err := r.Get(name)
+	if err != nil {
+		panic(err)
+	}
+	return pool
+}
+
+// Close closes and forgets all registered pools
+func (r *Registry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, pool := range r.pools {
+		pool.Close()
+		delete(r.pools, name)
+	}
+}
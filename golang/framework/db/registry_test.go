@@ -0,0 +1,83 @@
+package db_test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
+)
+
+func TestRegistryReturnsPoolsByName(t *testing.T) {
+	registry := db.NewRegistry()
+	configPool, analyticsPool := &db.DBPool{}, &db.DBPool{}
+
+	if err := registry.Register("config", configPool); err != nil {
+		t.Fatalf("Failed to register config pool: %v", err)
+	}
+	if err := registry.Register("analytics", analyticsPool); err != nil {
+		t.Fatalf("Failed to register analytics pool: %v", err)
+	}
+
+	if pool, err := registry.Get("config"); err != nil || pool != configPool {
+		t.Fatalf("Expected the config pool, got %p, %v", pool, err)
+	}
+	if pool := registry.MustGet("analytics"); pool != analyticsPool {
+		t.Fatalf("Expected the analytics pool, got %p", pool)
+	}
+
+	if err := registry.Register("config", &db.DBPool{}); err == nil {
+		t.Fatal("Expected registering a name twice to fail")
+	}
+	if err := registry.Register("audit", nil); err == nil {
+		t.Fatal("Expected registering a nil pool to fail")
+	}
+}
+
+func TestRegistryUnknownName(t *testing.T) {
+	registry := db.NewRegistry()
+	if err := registry.Register("config", &db.DBPool{}); err != nil {
+		t.Fatalf("Failed to register config pool: %v", err)
+	}
+
+	_, err := registry.Get("analytics")
+	if !errors.Is(err, db.ErrPoolNotRegistered) {
+		t.Fatalf("Expected ErrPoolNotRegistered, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), `"analytics"`) || !strings.Contains(err.Error(), "config") {
+		t.Fatalf("Expected the error to name the unknown and registered pools, got: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected MustGet to panic for an unknown name")
+		}
+	}()
+	registry.MustGet("analytics")
+}
+
+func TestRegistryConcurrentUse(t *testing.T) {
+	registry := db.NewRegistry()
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Go(func() {
+			if err := registry.Register(name, &db.DBPool{}); err != nil {
+				t.Errorf("Failed to register %s: %v", name, err)
+			}
+			for range 100 {
+				registry.Get(name)
+				registry.Get("missing")
+			}
+		})
+	}
+	wg.Wait()
+
+	for _, name := range names {
+		if _, err := registry.Get(name); err != nil {
+			t.Fatalf("Expected %s to be registered: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PostgreSQL SQLSTATEs of transactions aborted by a conflict with a concurrent one, which succeed when run again
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// MaxSerializationAttempts is the number of times RetryOnSerialization runs its function before giving up
+const MaxSerializationAttempts = 5
+
+// serializationBackoff is the delay before the first retry, doubled for every further one
+// A random part of up to the same amount again keeps conflicting retries from colliding in lockstep
+var serializationBackoff = 10 * time.Millisecond
+
+// IsSerializationFailure reports whether err is a serialization failure or deadlock, aborted only because of a concurrent transaction
+func IsSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == serializationFailure || pgErr.Code == deadlockDetected)
+}
+
+// RetryOnSerialization runs fn, a whole transaction such as a RunInTx call, again while it fails with IsSerializationFailure
+// It backs off between attempts and gives up after MaxSerializationAttempts, returning the last error; other errors return at once
+// Inside a transaction carried by ctx fn runs once: the failure aborted the outer transaction, which has to be retried as a whole
+func RetryOnSerialization(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	delay := serializationBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !IsSerializationFailure(err) {
+			return err
+		}
+		if attempt == MaxSerializationAttempts {
+			return fmt.Errorf("failed after %d attempts: %w", attempt, err)
+		}
+
+		wait := delay + rand.N(delay)
+		log.Printf("Retrying transaction in %s after attempt %d: %v", wait, attempt, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("stopped retrying after %d attempts: %w", attempt, errors.Join(ctx.Err(), err))
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,66 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
+)
+
+func TestRetryOnSerializationRetriesConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	// A serialization failure and a deadlock, then success
+	failures := []error{&pgconn.PgError{Code: "40001"}, &pgconn.PgError{Code: "40P01"}}
+	attempts := 0
+	err := db.RetryOnSerialization(ctx, func(ctx context.Context) error {
+		attempts++
+		if attempts <= len(failures) {
+			return failures[attempts-1]
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Fatalf("Expected success on the third attempt, got %d attempts: %v", attempts, err)
+	}
+
+	// Other errors are not retried
+	attempts = 0
+	unique := &pgconn.PgError{Code: "23505"}
+	err = db.RetryOnSerialization(ctx, func(ctx context.Context) error {
+		attempts++
+		return unique
+	})
+	if !errors.Is(err, unique) || attempts != 1 {
+		t.Fatalf("Expected the unique violation after 1 attempt, got %d attempts: %v", attempts, err)
+	}
+}
+
+func TestRetryOnSerializationGivesUp(t *testing.T) {
+	attempts := 0
+	err := db.RetryOnSerialization(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return &pgconn.PgError{Code: "40001"}
+	})
+	if !db.IsSerializationFailure(err) {
+		t.Fatalf("Expected the last serialization failure, got %v", err)
+	}
+	if attempts != db.MaxSerializationAttempts {
+		t.Fatalf("Expected %d attempts, got %d", db.MaxSerializationAttempts, attempts)
+	}
+
+	// A cancelled context stops the backoff
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts = 0
+	err = db.RetryOnSerialization(ctx, func(ctx context.Context) error {
+		attempts++
+		return &pgconn.PgError{Code: "40001"}
+	})
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Fatalf("Expected to stop after 1 attempt with the context error, got %d attempts: %v", attempts, err)
+	}
+}
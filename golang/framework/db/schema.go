@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrSchemaVersionMismatch is returned by AssertSchemaVersion when the database is migrated to another version
+var ErrSchemaVersionMismatch = errors.New("database schema version mismatch")
+
+// SchemaVersion returns the newest migration version recorded in dbmate's schema_migrations table, zero if none is
+func (pool *DBPool) SchemaVersion(ctx context.Context) (uint64, error) {
+	rows, err := pool.Querier(ctx).Query(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	// Versions are stored as text, so compare them as numbers rather than let the database sort strings
+	var latest uint64
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return 0, fmt.Errorf("failed to scan schema version: %w", err)
+		}
+		v, err := strconv.ParseUint(version, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse schema version %q: %w", version, err)
+		}
+		latest = max(latest, v)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	return latest, nil
+}
+
+// AssertSchemaVersion fails with ErrSchemaVersionMismatch unless the newest applied migration is expected
+// Servers call it at startup, so a binary never serves a database migrated for an older or newer release
+func (pool *DBPool) AssertSchemaVersion(ctx context.Context, expected uint64) error {
+	version, err := pool.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version != expected {
+		return fmt.Errorf("%w: database is at %d, expected %d", ErrSchemaVersionMismatch, version, expected)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Querier runs statements on a pool or inside a transaction; both *DBPool and pgx.Tx implement it
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Compile-time checks that pools and transactions implement Querier
+var (
+	_ Querier = (*DBPool)(nil)
+	_ Querier = (pgx.Tx)(nil)
+)
+
+// txContextKey is the context key for the request-scoped transaction
+type txContextKey struct{}
+
+// WithTx returns a new context carrying tx, so repositories called with it run their statements in tx
+func WithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx, if any
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(pgx.Tx)
+	return tx, ok && tx != nil
+}
+
+// Querier returns the transaction carried by ctx, falling back to the pool outside a transaction
+// The transaction must belong to this pool's database
+// With Config.AcquireTimeout set, statements on the pool fail with ErrPoolExhausted when no connection frees up in time
+func (pool *DBPool) Querier(ctx context.Context) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	if pool.acquireTimeout > 0 {
+		return acquiringQuerier{pool: pool, timeout: pool.acquireTimeout}
+	}
+	return pool
+}
+
+// ErrNotFound reports a write that affected fewer rows than the caller expected, e.g. deleting a missing row
+var ErrNotFound = errors.New("not found")
+
+// ExecExpectRows runs a statement with Querier and returns the number of rows it affected
+// Fewer than minRows affected rows fail with ErrNotFound; the statement's effects stay unless it runs in RunInTx
+func (pool *DBPool) ExecExpectRows(ctx context.Context, minRows int64, sql string, args ...any) (int64, error) {
+	tag, err := pool.Querier(ctx).Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+	rows := tag.RowsAffected()
+	if rows < minRows {
+		return rows, fmt.Errorf("%w: affected %d rows, expected at least %d", ErrNotFound, rows, minRows)
+	}
+	return rows, nil
+}
+
+// ExecReturning runs a statement with a RETURNING clause with Querier and scans the row it returns into dest
+// A statement affecting no row fails with ErrNotFound, e.g. a DELETE ... RETURNING of a missing row
+func (pool *DBPool) ExecReturning(ctx context.Context, dest []any, sql string, args ...any) error {
+	err := pool.Querier(ctx).QueryRow(ctx, sql, args...).Scan(dest...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: affected no rows", ErrNotFound)
+	}
+	return err
+}
+
+// RunInTx runs fn with a context carrying a transaction, committing it if fn succeeds and rolling it back otherwise
+// If ctx already carries a transaction fn joins it, leaving commit or rollback to the outermost RunInTx
+func (pool *DBPool) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := pool.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Rolling back after a commit is a no-op; this also covers a panicking fn
+	defer tx.Rollback(ctx)
+
+	if err := fn(WithTx(ctx, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// begin starts a transaction, acquiring its connection with AcquireTimeout when Config.AcquireTimeout is set
+func (pool *DBPool) begin(ctx context.Context) (pgx.Tx, error) {
+	if pool.acquireTimeout <= 0 {
+		return pool.Begin(ctx)
+	}
+
+	conn, err := pool.AcquireTimeout(ctx, pool.acquireTimeout)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return releasingTx{Tx: tx, conn: conn}, nil
+}
+
+// releasingTx releases its connection once committed or rolled back
+type releasingTx struct {
+	pgx.Tx
+	conn *pgxpool.Conn
+}
+
+// Commit commits the transaction and releases the connection
+func (tx releasingTx) Commit(ctx context.Context) error {
+	defer tx.conn.Release()
+	return tx.Tx.Commit(ctx)
+}
+
+// Rollback rolls the transaction back and releases the connection, unless it was already closed
+func (tx releasingTx) Rollback(ctx context.Context) error {
+	err := tx.Tx.Rollback(ctx)
+	if !errors.Is(err, pgx.ErrTxClosed) {
+		tx.conn.Release()
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package pagetoken
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Cursor is the position after the last item of a page, for listings ordered by creation time and ID
+type Cursor struct {
+	CreatedAt time.Time
+	ID        []byte
+}
+
+// IsZero reports whether the cursor is the start of a listing, as decoded from an empty token
+func (c Cursor) IsZero() bool {
+	return c.CreatedAt.IsZero() && len(c.ID) == 0
+}
+
+const (
+	// version is the first byte of every token, changed whenever the layout changes
+	version = 1
+
+	// headerSize is the version byte and the creation time in Unix nanoseconds
+	headerSize = 1 + 8
+
+	// macSize is the length of the truncated HMAC-SHA256 ending every token
+	macSize = 16
+)
+
+// Codec encodes cursors as opaque page tokens signed with a key, so clients can't forge or alter them
+type Codec struct {
+	key []byte
+}
+
+// NewCodec creates a codec signing tokens with key, which must stay secret and be shared by all replicas
+func NewCodec(key []byte) *Codec {
+	return &Codec{key: bytes.Clone(key)}
+}
+
+// Encode returns the page token of cursor, empty for the zero cursor
+func (c *Codec) Encode(cursor Cursor) string {
+	if cursor.IsZero() {
+		return ""
+	}
+
+	payload := make([]byte, headerSize, headerSize+len(cursor.ID)+macSize)
+	payload[0] = version
+	binary.BigEndian.PutUint64(payload[1:headerSize], uint64(cursor.CreatedAt.UnixNano()))
+	payload = append(payload, cursor.ID...)
+	return base64.RawURLEncoding.EncodeToString(append(payload, c.mac(payload)...))
+}
+
+// Decode returns the cursor of a token from Encode, the zero cursor for an empty token
+// Tokens that are malformed, altered or signed with another key fail with codes.InvalidArgument
+func (c *Codec) Decode(token string) (Cursor, error) {
+	if token == "" {
+		return Cursor{}, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) < headerSize+macSize {
+		return Cursor{}, invalidToken()
+	}
+	payload, mac := data[:len(data)-macSize], data[len(data)-macSize:]
+	if !hmac.Equal(mac, c.mac(payload)) || payload[0] != version {
+		return Cursor{}, invalidToken()
+	}
+
+	return Cursor{
+		CreatedAt: time.Unix(0, int64(binary.BigEndian.Uint64(payload[1:headerSize]))).UTC(),
+		ID:        bytes.Clone(payload[headerSize:]),
+	}, nil
+}
+
+// mac returns the truncated HMAC-SHA256 of payload
+func (c *Codec) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, c.key)
+	h.Write(payload)
+	return h.Sum(nil)[:macSize]
+}
+
+// invalidToken returns the error of a token that doesn't decode
+func invalidToken() error {
+	return status.Error(codes.InvalidArgument, "invalid page token")
+}
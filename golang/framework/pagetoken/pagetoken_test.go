@@ -0,0 +1,70 @@
+package pagetoken_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/pagetoken"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestTokensRoundTrip(t *testing.T) {
+	codec := pagetoken.NewCodec(testKey)
+	cursor := pagetoken.Cursor{
+		CreatedAt: time.Date(2025, time.June, 1, 12, 30, 0, 123456000, time.UTC),
+		ID:        []byte("account/with 'quotes'"),
+	}
+
+	token := codec.Encode(cursor)
+	got, err := codec.Decode(token)
+	if err != nil {
+		t.Fatalf("Failed to decode token %q: %v", token, err)
+	}
+	if !got.CreatedAt.Equal(cursor.CreatedAt) || !bytes.Equal(got.ID, cursor.ID) {
+		t.Fatalf("Expected %+v, got %+v", cursor, got)
+	}
+
+	// An empty token starts the listing
+	if token := codec.Encode(pagetoken.Cursor{}); token != "" {
+		t.Fatalf("Expected an empty token for the zero cursor, got %q", token)
+	}
+	if got, err := codec.Decode(""); err != nil || !got.IsZero() {
+		t.Fatalf("Expected the zero cursor for an empty token, got %+v, %v", got, err)
+	}
+}
+
+func TestDecodeRejectsInvalidTokens(t *testing.T) {
+	codec := pagetoken.NewCodec(testKey)
+	token := codec.Encode(pagetoken.Cursor{CreatedAt: time.Unix(1700000000, 0), ID: []byte("account")})
+
+	// Altering any byte, e.g. moving the cursor to another ID, breaks the signature
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		t.Fatalf("Expected a base64url token, got %q: %v", token, err)
+	}
+	var tampered []string
+	for i := range data {
+		altered := bytes.Clone(data)
+		altered[i] ^= 0x01
+		tampered = append(tampered, base64.RawURLEncoding.EncodeToString(altered))
+	}
+
+	invalid := append(tampered,
+		"garbage!",
+		"AAAA",
+		token[:len(token)-2],
+		token+"AA",
+		pagetoken.NewCodec([]byte("another key")).Encode(pagetoken.Cursor{ID: []byte("account")}),
+	)
+	for _, token := range invalid {
+		if _, err := codec.Decode(token); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expected InvalidArgument for token %q, got: %v", token, err)
+		}
+	}
+}
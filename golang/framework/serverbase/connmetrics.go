@@ -0,0 +1,108 @@
+package serverbase
+
+import (
+	"context"
+	"log"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/stats"
+)
+
+const (
+	// meterName is the instrumentation scope of the connection metrics
+	meterName = "github.com/berendjan/golang-bazel-starter/golang/framework/serverbase"
+
+	// OpenConnectionsMetric is the number of open connections to the gRPC server
+	OpenConnectionsMetric = "rpc.server.open_connections"
+
+	// ReceivedBytesMetric counts the bytes of the messages received by the gRPC server, as sent on the wire
+	ReceivedBytesMetric = "rpc.server.received_bytes"
+
+	// SentBytesMetric counts the bytes of the messages sent by the gRPC server, as sent on the wire
+	SentBytesMetric = "rpc.server.sent_bytes"
+)
+
+// ConnMetrics exports gRPC server connections and bytes as the OpenConnectionsMetric, ReceivedBytesMetric
+// and SentBytesMetric instruments; install it with WithStatsHandler
+type ConnMetrics struct {
+	meterProvider metric.MeterProvider
+}
+
+// NewConnMetrics creates connection metrics recorded with the global meter provider
+func NewConnMetrics() *ConnMetrics {
+	return &ConnMetrics{}
+}
+
+// WithMeterProvider records the metrics with mp instead of the global meter provider
+func (c *ConnMetrics) WithMeterProvider(mp metric.MeterProvider) *ConnMetrics {
+	c.meterProvider = mp
+	return c
+}
+
+// StatsHandler returns the gRPC stats handler recording the metrics
+func (c *ConnMetrics) StatsHandler() stats.Handler {
+	mp := c.meterProvider
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(meterName)
+
+	// Instruments that fail to be created are no-ops, connections are still served
+	open, err := meter.Int64UpDownCounter(OpenConnectionsMetric,
+		metric.WithDescription("Open connections to the gRPC server"),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		log.Printf("Failed to create %s counter: %v", OpenConnectionsMetric, err)
+	}
+	received, err := meter.Int64Counter(ReceivedBytesMetric,
+		metric.WithDescription("Bytes of the messages received by the gRPC server"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		log.Printf("Failed to create %s counter: %v", ReceivedBytesMetric, err)
+	}
+	sent, err := meter.Int64Counter(SentBytesMetric,
+		metric.WithDescription("Bytes of the messages sent by the gRPC server"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		log.Printf("Failed to create %s counter: %v", SentBytesMetric, err)
+	}
+
+	return &connMetricsHandler{open: open, received: received, sent: sent}
+}
+
+// connMetricsHandler is the stats handler returned by ConnMetrics.StatsHandler
+type connMetricsHandler struct {
+	open     metric.Int64UpDownCounter
+	received metric.Int64Counter
+	sent     metric.Int64Counter
+}
+
+func (h *connMetricsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *connMetricsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	switch s := s.(type) {
+	case *stats.InPayload:
+		h.received.Add(ctx, int64(s.WireLength))
+	case *stats.OutPayload:
+		h.sent.Add(ctx, int64(s.WireLength))
+	}
+}
+
+func (h *connMetricsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *connMetricsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {
+	switch s.(type) {
+	case *stats.ConnBegin:
+		h.open.Add(ctx, 1)
+	case *stats.ConnEnd:
+		h.open.Add(ctx, -1)
+	}
+}
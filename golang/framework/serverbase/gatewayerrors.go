@@ -0,0 +1,35 @@
+package serverbase
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// gatewayErrorHandler writes errors like runtime.DefaultHTTPErrorHandler, after setting the HTTP headers
+// the status details translate to, e.g. Retry-After for the RetryInfo of a ResourceExhausted status
+func gatewayErrorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if st, ok := status.FromError(err); ok {
+		for _, detail := range st.Details() {
+			setDetailHeaders(w.Header(), detail)
+		}
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
+
+// setDetailHeaders sets the HTTP headers of a known status detail; other details are only rendered in the body
+func setDetailHeaders(header http.Header, detail any) {
+	switch d := detail.(type) {
+	case *errdetails.RetryInfo:
+		if delay := d.GetRetryDelay(); delay.IsValid() && delay.AsDuration() >= 0 {
+			// Retry-After takes whole seconds, rounded up so clients never retry early
+			seconds := (delay.AsDuration() + time.Second - 1) / time.Second
+			header.Set("Retry-After", strconv.FormatInt(int64(seconds), 10))
+		}
+	}
+}
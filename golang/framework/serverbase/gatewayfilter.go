@@ -0,0 +1,85 @@
+package serverbase
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// WithGatewayMethodFilter keeps the given gRPC methods off the HTTP gateway, e.g. "/pkg.v1.Service/Method"
+// Their HTTP routes answer 404 while the methods stay reachable over gRPC; must be called before RegisterGateways
+func (sb *ServerBuilder) WithGatewayMethodFilter(deny ...string) *ServerBuilder {
+	sb.gatewayDeny = append(sb.gatewayDeny, deny...)
+	return sb
+}
+
+// maskDeniedMethods shadows the HTTP routes of denied methods with a 404 handler
+// ServeMux matches the most recently registered handler first, so this must run after the gateway registers
+func (sb *ServerBuilder) maskDeniedMethods(mux *runtime.ServeMux) error {
+	for _, method := range sb.gatewayDeny {
+		rule, err := httpRule(method)
+		if err != nil {
+			return err
+		}
+		for _, binding := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+			verb, path := httpRoute(binding)
+			if verb == "" {
+				continue
+			}
+			if err := mux.HandlePath(verb, path, notFoundHandler(mux)); err != nil {
+				return fmt.Errorf("failed to mask %s %s of %s: %w", verb, path, method, err)
+			}
+		}
+	}
+	return nil
+}
+
+// httpRule returns the google.api.http rule of a gRPC method from the registered proto descriptors
+func httpRule(method string) (*annotations.HttpRule, error) {
+	name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(method, "/"), "/", "."))
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find gateway method %s: %w", method, err)
+	}
+	methodDesc, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("failed to find gateway method %s: %s is not a method", method, name)
+	}
+	rule, _ := proto.GetExtension(methodDesc.Options(), annotations.E_Http).(*annotations.HttpRule)
+	return rule, nil
+}
+
+// httpRoute returns the verb and path template of a single HTTP binding
+func httpRoute(rule *annotations.HttpRule) (string, string) {
+	switch pattern := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return http.MethodGet, pattern.Get
+	case *annotations.HttpRule_Put:
+		return http.MethodPut, pattern.Put
+	case *annotations.HttpRule_Post:
+		return http.MethodPost, pattern.Post
+	case *annotations.HttpRule_Delete:
+		return http.MethodDelete, pattern.Delete
+	case *annotations.HttpRule_Patch:
+		return http.MethodPatch, pattern.Patch
+	case *annotations.HttpRule_Custom:
+		return pattern.Custom.GetKind(), pattern.Custom.GetPath()
+	}
+	return "", ""
+}
+
+// notFoundHandler answers like the gateway does for a route that does not exist
+func notFoundHandler(mux *runtime.ServeMux) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		_, outbound := runtime.MarshalerForRequest(mux, r)
+		runtime.HTTPError(r.Context(), mux, outbound, w, r, status.Error(codes.NotFound, http.StatusText(http.StatusNotFound)))
+	}
+}
@@ -0,0 +1,54 @@
+package serverbase
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+)
+
+// WithGRPCWeb serves the gRPC server on grpcPort as gRPC-Web on httpPort, next to the HTTP gateway
+// Browsers on other origins are only allowed from allowedOrigins ("*" allows any); methods denied
+// with WithGatewayMethodFilter are not served as gRPC-Web either
+func (sb *ServerBuilder) WithGRPCWeb(httpPort, grpcPort int, allowedOrigins ...string) *ServerBuilder {
+	sb.grpcWeb[httpPort] = grpcWebTarget{grpcPort: grpcPort, allowedOrigins: allowedOrigins}
+
+	// gRPC-Web needs an HTTP server even when no gateway is registered on the port
+	if _, exists := sb.httpServers[httpPort]; !exists {
+		sb.httpServers[httpPort] = newServeMux()
+	}
+	return sb
+}
+
+// grpcWebTarget is the gRPC server served as gRPC-Web on an HTTP port
+type grpcWebTarget struct {
+	grpcPort       int
+	allowedOrigins []string
+}
+
+// httpHandler returns the handler for an HTTP port, dispatching gRPC-Web requests to the gRPC server
+// Must be called after all services are registered
+func (sb *ServerBuilder) httpHandler(httpPort int) http.Handler {
+	mux := sb.httpServers[httpPort]
+	target, ok := sb.grpcWeb[httpPort]
+	if !ok {
+		return mux
+	}
+
+	wrapped := grpcweb.WrapServer(sb.grpcServers[target.grpcPort], grpcweb.WithOriginFunc(func(origin string) bool {
+		return slices.Contains(target.allowedOrigins, "*") || slices.Contains(target.allowedOrigins, origin)
+	}))
+	notFound := notFoundHandler(mux)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !wrapped.IsGrpcWebRequest(r) && !wrapped.IsAcceptableGrpcCorsRequest(r) {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		if slices.Contains(sb.gatewayDeny, r.URL.Path) {
+			notFound(w, r, nil)
+			return
+		}
+		wrapped.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,50 @@
+package serverbase
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// HealthzPath is the gateway route answering with the status of the gRPC health service
+const HealthzPath = "/healthz"
+
+// WithHealthz serves GET /healthz on the HTTP gateway of httpPort, answered by the in-process health service
+// It responds 200 when the service is SERVING and 503 otherwise; "?service=name" checks a single service
+// Does nothing when no gateway is registered on the port
+func (sb *ServerBuilder) WithHealthz(httpPort int, checker healthpb.HealthServer) *ServerBuilder {
+	mux, exists := sb.httpServers[httpPort]
+	if !exists {
+		return sb
+	}
+	if err := mux.HandlePath(http.MethodGet, HealthzPath, healthzHandler(checker)); err != nil {
+		log.Printf("Failed to register %s on HTTP port %d: %v", HealthzPath, httpPort, err)
+	}
+	return sb
+}
+
+// healthzHandler calls the health service's Check and maps the serving status to an HTTP status
+func healthzHandler(checker healthpb.HealthServer) runtime.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		resp, err := checker.Check(r.Context(), &healthpb.HealthCheckRequest{Service: r.URL.Query().Get("service")})
+
+		servingStatus := resp.GetStatus().String()
+		if err != nil {
+			// An unknown service is reported as NotFound by the health service
+			servingStatus = status.Code(err).String()
+		}
+
+		code := http.StatusOK
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]string{"status": servingStatus})
+	}
+}
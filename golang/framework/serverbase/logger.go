@@ -0,0 +1,103 @@
+package serverbase
+
+import (
+	"context"
+	"log/slog"
+
+	"google.golang.org/grpc"
+)
+
+// loggerKey is the context key of the logger set with ContextWithLogger
+type loggerKey struct{}
+
+// ContextWithLogger returns a context whose slog records a ContextHandler sends to logger
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger set on ctx with ContextWithLogger, if any
+func LoggerFromContext(ctx context.Context) (*slog.Logger, bool) {
+	logger, ok := ctx.Value(loggerKey{}).(*slog.Logger)
+	return logger, ok && logger != nil
+}
+
+// WithLogger carries logger in the context of every gRPC call the server handles
+// Records logged with that context, e.g. by the logging middleware and the messenger, reach logger while the default
+// slog handler is a ContextHandler; calls through the in-process HTTP gateway skip the gRPC interceptors and so the logger
+func (s *ServerBase) WithLogger(logger *slog.Logger) *ServerBase {
+	s.logger = logger
+	return s
+}
+
+// loggerUnaryInterceptor sets logger on the context of unary calls
+func loggerUnaryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(ContextWithLogger(ctx, logger), req)
+	}
+}
+
+// loggerStreamInterceptor sets logger on the context of streams
+func loggerStreamInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, loggerStream{ServerStream: ss, ctx: ContextWithLogger(ss.Context(), logger)})
+	}
+}
+
+// loggerStream is a ServerStream with the context set by loggerStreamInterceptor
+type loggerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s loggerStream) Context() context.Context {
+	return s.ctx
+}
+
+// ContextHandler is a slog.Handler sending each record to the handler of the logger its context carries
+// Records without one, or logged without a context, go to the fallback handler
+type ContextHandler struct {
+	fallback slog.Handler
+	derive   []func(slog.Handler) slog.Handler // WithAttrs and WithGroup calls, replayed on the chosen handler
+}
+
+// NewContextHandler creates a ContextHandler falling back to fallback
+// If fallback writes through the log package, restore its output after slog.SetDefault so records don't loop
+func NewContextHandler(fallback slog.Handler) *ContextHandler {
+	return &ContextHandler{fallback: fallback}
+}
+
+// handler returns the handler for records logged with ctx
+func (h *ContextHandler) handler(ctx context.Context) slog.Handler {
+	target := h.fallback
+	if logger, ok := LoggerFromContext(ctx); ok {
+		target = logger.Handler()
+	}
+	for _, derive := range h.derive {
+		target = derive(target)
+	}
+	return target
+}
+
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler(ctx).Enabled(ctx, level)
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.handler(ctx).Handle(ctx, record)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+// with returns a copy of h that also applies derive
+func (h *ContextHandler) with(derive func(slog.Handler) slog.Handler) *ContextHandler {
+	return &ContextHandler{
+		fallback: h.fallback,
+		derive:   append(append([]func(slog.Handler) slog.Handler(nil), h.derive...), derive),
+	}
+}
@@ -0,0 +1,62 @@
+package serverbase
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// WithMetadataLimits rejects requests whose incoming metadata exceeds maxBytes in total or has more than maxKeys keys
+// The limit interceptors run before any interceptors added after this call; a limit of 0 disables that check
+func (s *ServerBase) WithMetadataLimits(maxBytes, maxKeys int) *ServerBase {
+	s.WithUnaryInterceptor(MetadataLimitUnaryInterceptor(maxBytes, maxKeys))
+	s.WithStreamInterceptor(MetadataLimitStreamInterceptor(maxBytes, maxKeys))
+	return s
+}
+
+// MetadataLimitUnaryInterceptor rejects unary calls with oversized metadata with ResourceExhausted
+func MetadataLimitUnaryInterceptor(maxBytes, maxKeys int) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := checkMetadataLimits(ctx, maxBytes, maxKeys); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// MetadataLimitStreamInterceptor rejects streams with oversized metadata with ResourceExhausted
+func MetadataLimitStreamInterceptor(maxBytes, maxKeys int) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkMetadataLimits(ss.Context(), maxBytes, maxKeys); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// checkMetadataLimits counts every key, including transport keys like ":authority" and "user-agent"
+// The size is the sum of the lengths of all keys and values
+func checkMetadataLimits(ctx context.Context, maxBytes, maxKeys int) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	if maxKeys > 0 && len(md) > maxKeys {
+		return status.Errorf(codes.ResourceExhausted, "metadata has %d keys, limit is %d", len(md), maxKeys)
+	}
+
+	size := 0
+	for key, values := range md {
+		for _, value := range values {
+			size += len(key) + len(value)
+		}
+	}
+	if maxBytes > 0 && size > maxBytes {
+		return status.Errorf(codes.ResourceExhausted, "metadata is %d bytes, limit is %d", size, maxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package serverbase
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+)
+
+// MetricsPath is the path the metrics port serves its handler on
+const MetricsPath = "/metrics"
+
+// WithMetricsPort serves handler, e.g. a Prometheus exporter's, at MetricsPath on a separate plaintext port
+// The port is bound with the gRPC and HTTP ports, so Launch fails if it is taken
+func (s *ServerBase) WithMetricsPort(port int, handler http.Handler) *ServerBase {
+	s.metricsPort = port
+	s.metricsHandler = handler
+	return s
+}
+
+// startMetricsServer serves the metrics handler on its bound listener
+func (s *ServerBase) startMetricsServer(lis net.Listener) {
+	defer s.wg.Done()
+
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, s.metricsHandler)
+	server := &http.Server{
+		Addr:    lis.Addr().String(),
+		Handler: mux,
+	}
+	log.Printf("Metrics server listening on %s", lis.Addr())
+
+	// Setup shutdown listener
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-s.shutdownCtx.Done()
+		log.Printf("Shutting down metrics server on port %d", boundPort(lis))
+		s.stopServer(ListenerMetrics, boundPort(lis), func() {
+			if err := server.Shutdown(context.Background()); err != nil {
+				log.Printf("Metrics server shutdown error: %v", err)
+			}
+		}, func() { server.Close() })
+	}()
+
+	if err := server.Serve(lis); err != nil && err != http.ErrServerClosed {
+		log.Printf("Metrics server stopped: %v", err)
+	}
+	s.waitForStop(stopped)
+}
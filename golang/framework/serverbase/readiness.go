@@ -0,0 +1,89 @@
+package serverbase
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// ReadyPath is the health port route answering with the status of the gRPC health service
+// Unlike /health, which only reports that the process is up, it fails while a readiness check fails
+const ReadyPath = "/ready"
+
+// readinessCheck is a dependency the server needs to serve requests, e.g. its database
+type readinessCheck struct {
+	interval time.Duration
+	check    func(ctx context.Context) error
+}
+
+// WithReadinessCheck runs check every interval once launched, reporting the server NOT_SERVING while it fails
+// The status is served by the gRPC health service, /healthz on the gateway and /ready on the health port
+// Each run gets at most interval to complete, e.g. WithReadinessCheck(5*time.Second, pool.Ping)
+func (s *ServerBase) WithReadinessCheck(interval time.Duration, check func(ctx context.Context) error) *ServerBase {
+	s.readinessChecks = append(s.readinessChecks, readinessCheck{interval: interval, check: check})
+	return s
+}
+
+// startReadinessChecks runs every readiness check until shutdown, keeping the overall serving status up to date
+func (s *ServerBase) startReadinessChecks() {
+	var mu sync.Mutex
+	failing := make([]bool, len(s.readinessChecks))
+
+	// report records the result of check i, flipping the serving status when the first check fails or the last recovers
+	report := func(i int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if failing[i] == (err != nil) {
+			return
+		}
+		failing[i] = err != nil
+		if err != nil {
+			log.Printf("Readiness check %d failed: %v", i, err)
+		} else {
+			log.Printf("Readiness check %d recovered", i)
+		}
+
+		servingStatus := healthpb.HealthCheckResponse_SERVING
+		for _, f := range failing {
+			if f {
+				servingStatus = healthpb.HealthCheckResponse_NOT_SERVING
+			}
+		}
+		s.health.SetServingStatus("", servingStatus)
+	}
+
+	for i, rc := range s.readinessChecks {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+
+			ticker := time.NewTicker(rc.interval)
+			defer ticker.Stop()
+			for {
+				ctx, cancel := context.WithTimeout(s.shutdownCtx, rc.interval)
+				err := rc.check(ctx)
+				cancel()
+				if s.shutdownCtx.Err() != nil {
+					return
+				}
+				report(i, err)
+
+				select {
+				case <-ticker.C:
+				case <-s.shutdownCtx.Done():
+					return
+				}
+			}
+		}()
+	}
+}
+
+// readyHandler answers ReadyPath on the health port like /healthz on the gateway
+func (s *ServerBase) readyHandler(w http.ResponseWriter, r *http.Request) {
+	healthzHandler(s.health)(w, r, nil)
+}
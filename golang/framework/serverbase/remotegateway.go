@@ -0,0 +1,70 @@
+package serverbase
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// EndpointGatewayFunc registers an HTTP gateway proxying to the gRPC server at endpoint
+// e.g. the generated Register<Service>HandlerFromEndpoint
+type EndpointGatewayFunc func(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) error
+
+// RemoteGateway is a ServerInterface serving only the HTTP gateway, proxying every call to a separate gRPC server
+// so a gateway deployment and its backend scale independently; Launch then starts no gRPC server and ignores its gRPC port
+// Unlike the in-process gateway, calls go through the upstream's interceptors and streaming methods are supported
+type RemoteGateway struct {
+	upstream   string
+	tlsConfig  *tls.Config
+	registrars []EndpointGatewayFunc
+}
+
+// NewRemoteGateway creates a gateway proxying the services of registrars to the gRPC server at upstream, e.g. "grpcserver:25000"
+func NewRemoteGateway(upstream string, registrars ...EndpointGatewayFunc) *RemoteGateway {
+	return &RemoteGateway{upstream: upstream, registrars: registrars}
+}
+
+// WithTLS dials the upstream with cfg instead of plaintext
+// Set RootCAs to verify an upstream with a private CA, and Certificates when the upstream requires mTLS
+func (g *RemoteGateway) WithTLS(cfg *tls.Config) *RemoteGateway {
+	g.tlsConfig = cfg
+	return g
+}
+
+// Register implements ServerInterface, registering the gateways on httpPort
+// The upstream is dialed lazily, so it may start after the gateway
+func (g *RemoteGateway) Register(sb *ServerBuilder, _, httpPort int) error {
+	creds := insecure.NewCredentials()
+	if g.tlsConfig != nil {
+		creds = credentials.NewTLS(g.tlsConfig)
+	}
+
+	log.Printf("Proxying HTTP port %d to gRPC server %s", httpPort, g.upstream)
+	for _, register := range g.registrars {
+		sb.RegisterRemoteGateway(httpPort, g.upstream, register, grpc.WithTransportCredentials(creds))
+	}
+	return nil
+}
+
+// RegisterRemoteGateway adds an HTTP gateway on httpPort proxying to the gRPC server at endpoint
+// Like RegisterGateway it is registered on the ServeMux by RegisterGateways, whose context closes the connection
+func (sb *ServerBuilder) RegisterRemoteGateway(httpPort int, endpoint string, register EndpointGatewayFunc, opts ...grpc.DialOption) *ServerBuilder {
+	return sb.RegisterGateway(httpPort, endpointGateway{endpoint: endpoint, register: register, opts: opts})
+}
+
+// endpointGateway is the HTTPGatewayRegistrar of a gateway proxying to a remote gRPC server
+type endpointGateway struct {
+	endpoint string
+	register EndpointGatewayFunc
+	opts     []grpc.DialOption
+}
+
+// RegisterGateway implements HTTPGatewayRegistrar
+func (e endpointGateway) RegisterGateway(ctx context.Context, mux *runtime.ServeMux) error {
+	return e.register(ctx, mux, e.endpoint, e.opts)
+}
@@ -6,16 +6,22 @@ import (
 	"crypto/x509"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"sync"
 	"syscall"
+	"time"
 
-	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/stats"
 )
 
 type ServerBase struct {
@@ -24,7 +30,57 @@ type ServerBase struct {
 	cancel      context.CancelFunc
 	wg          sync.WaitGroup
 	tlsConfig   *tls.Config
-	healthPort  int // separate non-TLS health port (0 = disabled)
+	portTLS     map[int]*tls.Config // map of port -> TLS config overriding tlsConfig
+	bindHost    string              // host every server listens on ("" = all interfaces)
+	healthPort  int                 // separate health port, plaintext unless healthTLS (0 = disabled)
+	healthTLS   bool                // serve the health port with its TLS config
+	health      *health.Server      // gRPC health service, also answering /healthz on the gateway
+	gatewayDeny []string            // gRPC methods kept off the HTTP gateway
+	noSignals   bool                // leave SIGINT and SIGTERM to the caller
+	grpcOnly    bool                // serve gRPC without the gateway when a gateway fails to register
+
+	// Separate plaintext port serving metricsHandler at MetricsPath (0 = disabled)
+	metricsPort    int
+	metricsHandler http.Handler
+
+	// Graceful stop bound (0 = none) and how each server stopped
+	shutdownTimeout time.Duration
+	stops           []ListenerStop // guarded by mu
+
+	// Resources closed once Launch returns, e.g. database pools, in reverse registration order
+	closers   []func()
+	closeOnce sync.Once
+
+	// gRPC-Web on the HTTP port passed to Launch
+	grpcWeb        bool
+	grpcWebOrigins []string
+
+	// Services registered on the ports passed to Launch after those from Register
+	additionalServices []GRPCServiceRegistrar
+
+	// Interceptors installed on the gRPC server passed to Launch, in registration order
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+	statsHandlers      []stats.Handler
+
+	// Middleware wrapping the HTTP servers' handlers, outermost first
+	httpMiddleware []func(http.Handler) http.Handler
+
+	// Logger carried in the context of every gRPC call, set before the other interceptors run (nil = none)
+	logger *slog.Logger
+
+	// Dependency checks flipping the health service to NOT_SERVING while they fail
+	readinessChecks []readinessCheck
+
+	// Bound addresses, known once all listeners are bound
+	mu        sync.Mutex
+	grpcPort  int              // requested gRPC port passed to Launch
+	httpPort  int              // requested HTTP port passed to Launch
+	grpcAddrs map[int]net.Addr // map of requested grpcPort -> bound address
+	httpAddrs map[int]net.Addr // map of requested httpPort -> bound address
+	ready     chan struct{}    // closed once all listeners are bound or launch failed
+	readyOnce sync.Once
+	launchErr error
 }
 
 func NewServerBase() *ServerBase {
@@ -32,6 +88,11 @@ func NewServerBase() *ServerBase {
 	return &ServerBase{
 		shutdownCtx: ctx,
 		cancel:      cancel,
+		portTLS:     make(map[int]*tls.Config),
+		health:      health.NewServer(),
+		grpcAddrs:   make(map[int]net.Addr),
+		httpAddrs:   make(map[int]net.Addr),
+		ready:       make(chan struct{}),
 	}
 }
 
@@ -77,11 +138,114 @@ func (s *ServerBase) WithClientCA(caFile string) *ServerBase {
 	return s
 }
 
-// WithHealthPort configures a separate non-TLS HTTP port for health checks
+// WithPortTLS configures TLS for a single gRPC or HTTP port, overriding the config from WithTLS
+// Pass a config without ClientCAs for public TLS, or one requiring client certificates for mTLS
+func (s *ServerBase) WithPortTLS(port int, cfg *tls.Config) *ServerBase {
+	s.portTLS[port] = cfg
+	log.Printf("TLS configured for port %d", port)
+	return s
+}
+
+// WithHealthPort configures a separate HTTP port for health checks, plaintext unless WithHealthTLS
 // This is useful when mTLS is enabled but Kubernetes probes can't provide client certs
 func (s *ServerBase) WithHealthPort(port int) *ServerBase {
 	s.healthPort = port
-	log.Printf("Health port enabled on :%d (no TLS)", port)
+	log.Printf("Health port enabled on :%d", port)
+	return s
+}
+
+// WithHealthTLS serves the health port over TLS, e.g. for a service mesh requiring TLS everywhere
+// It uses the health port's WithPortTLS config, or else the WithTLS config; probes then need client certs under mTLS
+func (s *ServerBase) WithHealthTLS() *ServerBase {
+	s.healthTLS = true
+	return s
+}
+
+// healthTLSConfig returns the TLS config of the health port, nil while it serves plaintext
+func (s *ServerBase) healthTLSConfig() *tls.Config {
+	if !s.healthTLS {
+		return nil
+	}
+	if cfg, ok := s.portTLS[s.healthPort]; ok {
+		return cfg
+	}
+	return s.tlsConfig
+}
+
+// WithGatewayMethodFilter keeps the given gRPC methods off the HTTP gateway, e.g. "/pkg.v1.Service/Method"
+// Their HTTP routes answer 404 while the methods stay reachable over gRPC
+func (s *ServerBase) WithGatewayMethodFilter(deny ...string) *ServerBase {
+	s.gatewayDeny = append(s.gatewayDeny, deny...)
+	return s
+}
+
+// WithGRPCOnlyFallback keeps serving gRPC when a gateway fails to register instead of failing Launch
+// The HTTP port then only answers health checks; errors from Register still fail Launch
+func (s *ServerBase) WithGRPCOnlyFallback() *ServerBase {
+	s.grpcOnly = true
+	return s
+}
+
+// WithGRPCWeb serves the gRPC server as gRPC-Web on the HTTP port, so browsers can call it directly
+// Cross-origin browsers are only allowed from allowedOrigins ("*" allows any); the HTTP gateway keeps working
+func (s *ServerBase) WithGRPCWeb(allowedOrigins ...string) *ServerBase {
+	s.grpcWeb = true
+	s.grpcWebOrigins = append(s.grpcWebOrigins, allowedOrigins...)
+	log.Printf("gRPC-Web enabled on the HTTP port")
+	return s
+}
+
+// WithBindAddress makes every server, including the health port, listen on host only, e.g. "127.0.0.1"
+// The default listens on all interfaces
+func (s *ServerBase) WithBindAddress(host string) *ServerBase {
+	s.bindHost = host
+	log.Printf("Binding servers to %s", host)
+	return s
+}
+
+// listenAddr returns the address to listen on for port, on the host set with WithBindAddress
+func (s *ServerBase) listenAddr(port int) string {
+	return net.JoinHostPort(s.bindHost, strconv.Itoa(port))
+}
+
+// WithoutSignalHandler leaves SIGINT and SIGTERM to the caller, e.g. when embedded in another process or in tests
+// The servers then only stop through Shutdown or the context passed to LaunchContext
+func (s *ServerBase) WithoutSignalHandler() *ServerBase {
+	s.noSignals = true
+	return s
+}
+
+// RegisterAdditionalService adds services to the gRPC port passed to Launch, next to the ones from Register
+// Services that also implement HTTPGatewayRegistrar are served on the HTTP gateway as well
+func (s *ServerBase) RegisterAdditionalService(services ...GRPCServiceRegistrar) *ServerBase {
+	s.additionalServices = append(s.additionalServices, services...)
+	return s
+}
+
+// WithUnaryInterceptor adds unary interceptors to the gRPC server, run in the order they are added
+// They run before any interceptors added by Register
+func (s *ServerBase) WithUnaryInterceptor(interceptors ...grpc.UnaryServerInterceptor) *ServerBase {
+	s.unaryInterceptors = append(s.unaryInterceptors, interceptors...)
+	return s
+}
+
+// WithStreamInterceptor adds stream interceptors to the gRPC server, run in the order they are added
+// They run before any interceptors added by Register
+func (s *ServerBase) WithStreamInterceptor(interceptors ...grpc.StreamServerInterceptor) *ServerBase {
+	s.streamInterceptors = append(s.streamInterceptors, interceptors...)
+	return s
+}
+
+// WithStatsHandler adds gRPC stats handlers to the gRPC server, e.g. the one from ConnMetrics
+func (s *ServerBase) WithStatsHandler(handlers ...stats.Handler) *ServerBase {
+	s.statsHandlers = append(s.statsHandlers, handlers...)
+	return s
+}
+
+// WithHTTPMiddleware wraps the handlers of the HTTP servers, the first added outermost
+// The in-process gateway skips the gRPC interceptors, so request-scoped state they set must also be set here
+func (s *ServerBase) WithHTTPMiddleware(middleware ...func(http.Handler) http.Handler) *ServerBase {
+	s.httpMiddleware = append(s.httpMiddleware, middleware...)
 	return s
 }
 
@@ -91,126 +255,468 @@ func (s *ServerBase) LaunchWithDefaultPorts() error {
 	return s.Launch(grpcPort, httpPort)
 }
 
+// Launch registers all services and blocks until shutdown
+// Pass port 0 to bind a free port; GRPCAddr and HTTPAddr return the bound addresses
+// When Register creates no gRPC server, e.g. with a RemoteGateway, only the HTTP gateway is served
+// Returns without serving anything if Register fails, or if a gateway fails to register without WithGRPCOnlyFallback
+// Either way, closers added with RegisterCloser run before it returns
 func (s *ServerBase) Launch(grpcPort, httpPort int) error {
+	defer s.runClosers()
+
+	s.mu.Lock()
+	s.grpcPort = grpcPort
+	s.httpPort = httpPort
+	s.mu.Unlock()
+
+	if err := s.validatePorts(grpcPort, httpPort); err != nil {
+		s.markReady(err)
+		log.Printf("Failed to launch: %v", err)
+		return err
+	}
+
+	if s.healthPort > 0 && s.healthTLS && s.healthTLSConfig() == nil {
+		err := fmt.Errorf("health port %d has TLS enabled but no TLS config", s.healthPort)
+		s.markReady(err)
+		log.Printf("Failed to launch: %v", err)
+		return err
+	}
 
 	// Create server builder
 	sb := NewServerBuilder()
 
+	// Apply the global TLS config and any per-port overrides
+	sb.WithTLS(s.tlsConfig)
+	for port, cfg := range s.portTLS {
+		sb.WithPortTLS(port, cfg)
+	}
+
+	// Keep denied methods off the HTTP gateway
+	sb.WithGatewayMethodFilter(s.gatewayDeny...)
+
+	// Install interceptors added with WithUnaryInterceptor and WithStreamInterceptor, and handlers added with WithStatsHandler
+	// The logger set with WithLogger goes on the context first, so every other interceptor logs to it
+	unaryInterceptors, streamInterceptors := s.unaryInterceptors, s.streamInterceptors
+	if s.logger != nil {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{loggerUnaryInterceptor(s.logger)}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{loggerStreamInterceptor(s.logger)}, streamInterceptors...)
+	}
+	if len(unaryInterceptors) > 0 {
+		sb.WithGRPCOptions(grpcPort, grpc.ChainUnaryInterceptor(unaryInterceptors...))
+	}
+	if len(streamInterceptors) > 0 {
+		sb.WithGRPCOptions(grpcPort, grpc.ChainStreamInterceptor(streamInterceptors...))
+	}
+	for _, h := range s.statsHandlers {
+		sb.WithStatsHandler(grpcPort, h)
+	}
+
 	// Register services with both gRPC and HTTP gateway on specified ports
-	s.Register(sb, grpcPort, httpPort)
+	if err := s.Register(sb, grpcPort, httpPort); err != nil {
+		log.Printf("Failed to register services: %v", err)
+		err = fmt.Errorf("failed to register services: %w", err)
+		s.markReady(err)
+		return err
+	}
+	for _, service := range s.additionalServices {
+		sb.RegisterAdditionalService(grpcPort, httpPort, service)
+	}
+
+	// Register the collected HTTP gateways; a failure leaves nothing half registered or served
+	// Gateways proxying to a remote gRPC server close their connection on shutdown
+	if err := sb.RegisterGateways(s.shutdownCtx); err != nil {
+		if !s.grpcOnly {
+			s.markReady(err)
+			log.Printf("Failed to register gateways: %v", err)
+			return err
+		}
+		log.Printf("Serving gRPC only, failed to register gateways: %v", err)
+	}
+
+	// A gateway-only server, e.g. a RemoteGateway, has no gRPC server to extend
+	if grpcServer := sb.GRPCServer(grpcPort); grpcServer != nil {
+		// Add reflection for debugging with grpcurl
+		reflection.Register(grpcServer)
+
+		// Add the standard gRPC health service
+		healthpb.RegisterHealthServer(grpcServer, s.health)
+
+		// Serve the launch gRPC server as gRPC-Web next to the gateway
+		if s.grpcWeb {
+			sb.WithGRPCWeb(httpPort, grpcPort, s.grpcWebOrigins...)
+		}
+	}
 
-	// Add reflection for debugging with grpcurl
-	reflection.Register(sb.GRPCServer(grpcPort))
+	// The health service also answers /healthz on the gateway
+	sb.WithHealthz(httpPort, s.health)
 
 	// Run all servers
 	if err := s.runServer(sb); err != nil {
-		log.Fatalf("Failed to run servers: %v", err)
+		s.markReady(err)
+		log.Printf("Failed to launch: %v", err)
 		return err
 	}
 
 	return nil
 }
 
+// validatePorts checks the gRPC, HTTP, health and metrics ports are valid and distinct, so a clash fails Launch up front
+// instead of as a bind error in a serving goroutine; port 0 binds a free port and never clashes
+func (s *ServerBase) validatePorts(grpcPort, httpPort int) error {
+	ports := []struct {
+		name string
+		port int
+	}{{"gRPC", grpcPort}, {"HTTP", httpPort}, {"health", s.healthPort}, {"metrics", s.metricsPort}}
+
+	used := make(map[int]string, len(ports))
+	for _, p := range ports {
+		if p.port < 0 || p.port > 65535 {
+			return fmt.Errorf("invalid %s port %d", p.name, p.port)
+		}
+		if p.port == 0 {
+			continue
+		}
+		if other, ok := used[p.port]; ok {
+			return fmt.Errorf("%s and %s ports are both %d; each needs its own port", other, p.name, p.port)
+		}
+		used[p.port] = p.name
+	}
+	return nil
+}
+
+// HealthServer returns the gRPC health service of the server passed to Launch
+// Use SetServingStatus on it to report the server, or single services, as not serving
+func (s *ServerBase) HealthServer() *health.Server {
+	return s.health
+}
+
+// LaunchContext is Launch, shutting all servers down once ctx is done
+func (s *ServerBase) LaunchContext(ctx context.Context, grpcPort, httpPort int) error {
+	stop := context.AfterFunc(ctx, s.Shutdown)
+	defer stop()
+	return s.Launch(grpcPort, httpPort)
+}
+
+// Ready returns a channel closed once all servers are listening, or the launch failed
+// Listening sockets queue connections until served, so calls made once it is closed succeed; LaunchErr tells a failed launch apart
+func (s *ServerBase) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// LaunchErr returns why the launch failed, nil while launching or once all servers are listening
+func (s *ServerBase) LaunchErr() error {
+	select {
+	case <-s.ready:
+		return s.launchErr
+	default:
+		return nil
+	}
+}
+
+// WaitUntilReady blocks until all servers are listening, the launch failed, or ctx is done
+func (s *ServerBase) WaitUntilReady(ctx context.Context) error {
+	select {
+	case <-s.Ready():
+		return s.launchErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GRPCAddr returns the bound address of the gRPC server passed to Launch
+// Returns nil until the server is listening
+func (s *ServerBase) GRPCAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.grpcAddrs[s.grpcPort]
+}
+
+// HTTPAddr returns the bound address of the HTTP gateway passed to Launch
+// Returns nil until the server is listening
+func (s *ServerBase) HTTPAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.httpAddrs[s.httpPort]
+}
+
+// EffectiveConfig describes the ports and TLS settings a ServerBase runs with, for diagnostics
+type EffectiveConfig struct {
+	GRPCPort        int           // bound gRPC port, or the requested port until Launch binds it
+	HTTPPort        int           // bound HTTP port, or the requested port until Launch binds it
+	HealthPort      int           // health port, 0 when disabled
+	HealthTLS       bool          // health port served over TLS with WithHealthTLS
+	MetricsPort     int           // metrics port, 0 when disabled
+	BindAddress     string        // host every server listens on with WithBindAddress, "" for all interfaces
+	GRPCWeb         bool          // gRPC-Web served on the HTTP port
+	TLS             bool          // TLS configured with WithTLS
+	MTLS            bool          // client certificates required by the WithTLS config
+	PortTLS         map[int]bool  // ports with a WithPortTLS override -> whether that override requires client certificates
+	ShutdownTimeout time.Duration // graceful stop bound set with WithShutdownTimeout, 0 for none
+}
+
+// String formats the config as a single key=value line
+func (c EffectiveConfig) String() string {
+	return fmt.Sprintf("grpcPort=%d httpPort=%d healthPort=%d healthTLS=%t metricsPort=%d bindAddress=%q grpcWeb=%t tls=%t mtls=%t portTLS=%v shutdownTimeout=%s",
+		c.GRPCPort, c.HTTPPort, c.HealthPort, c.HealthTLS, c.MetricsPort, c.BindAddress, c.GRPCWeb, c.TLS, c.MTLS, c.PortTLS, c.ShutdownTimeout)
+}
+
+// EffectiveConfig returns the configuration the server launched with, using bound ports once known
+func (s *ServerBase) EffectiveConfig() EffectiveConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg := EffectiveConfig{
+		GRPCPort:        s.grpcPort,
+		HTTPPort:        s.httpPort,
+		HealthPort:      s.healthPort,
+		HealthTLS:       s.healthTLSConfig() != nil,
+		MetricsPort:     s.metricsPort,
+		BindAddress:     s.bindHost,
+		GRPCWeb:         s.grpcWeb,
+		TLS:             s.tlsConfig != nil,
+		MTLS:            requiresClientCert(s.tlsConfig),
+		PortTLS:         make(map[int]bool, len(s.portTLS)),
+		ShutdownTimeout: s.shutdownTimeout,
+	}
+	if addr, ok := s.grpcAddrs[s.grpcPort].(*net.TCPAddr); ok {
+		cfg.GRPCPort = addr.Port
+	}
+	if addr, ok := s.httpAddrs[s.httpPort].(*net.TCPAddr); ok {
+		cfg.HTTPPort = addr.Port
+	}
+	for port, tlsConfig := range s.portTLS {
+		cfg.PortTLS[port] = requiresClientCert(tlsConfig)
+	}
+	return cfg
+}
+
+// requiresClientCert reports whether cfg makes clients present a verified certificate
+func requiresClientCert(cfg *tls.Config) bool {
+	return cfg != nil && cfg.ClientAuth == tls.RequireAndVerifyClientCert
+}
+
+// markReady closes Ready and unblocks WaitUntilReady, reporting err if the launch failed
+func (s *ServerBase) markReady(err error) {
+	s.readyOnce.Do(func() {
+		s.launchErr = err
+		close(s.ready)
+	})
+}
+
 // Run starts all configured servers and blocks until shutdown
 func (s *ServerBase) runServer(sb *ServerBuilder) error {
 	if len(sb.grpcServers) == 0 && len(sb.httpServers) == 0 {
 		return fmt.Errorf("no services registered")
 	}
 
-	// Setup graceful shutdown
-	s.setupGracefulShutdown()
+	// Bind all listeners up front so their addresses are known before serving
+	bound, err := s.bindListeners(sb)
+	if err != nil {
+		return err
+	}
 
-	// Start health server if configured (non-TLS)
-	if s.healthPort > 0 {
+	// Setup graceful shutdown on SIGINT and SIGTERM unless the caller handles signals
+	// Done before reporting ready so a signal sent once ready always reaches the handler
+	if !s.noSignals {
+		s.setupGracefulShutdown()
+	}
+
+	s.markReady(nil)
+	log.Printf("Effective server config: %s", s.EffectiveConfig())
+
+	// Keep the serving status in line with the server's dependencies
+	s.startReadinessChecks()
+
+	// Start health server if configured, plaintext unless WithHealthTLS
+	if bound.health != nil {
 		s.wg.Add(1)
-		go s.startHealthServer()
+		go s.startHealthServer(bound.health)
 	}
 
 	// Start all gRPC servers
 	log.Printf("Starting %d gRPC server(s) and %d HTTP server(s)...", len(sb.grpcServers), len(sb.httpServers))
 	for grpcPort, grpcServer := range sb.grpcServers {
 		s.wg.Add(1)
-		go s.startGRPCServer(grpcPort, grpcServer)
+		go s.startGRPCServer(grpcPort, grpcServer, bound.grpc[grpcPort], sb.TLSConfig(grpcPort) != nil)
 	}
 
 	// Start all HTTP servers
-	for httpPort, httpMux := range sb.httpServers {
+	for httpPort := range sb.httpServers {
+		s.wg.Add(1)
+		go s.startHTTPServer(httpPort, s.wrapHTTPHandler(sb.httpHandler(httpPort)), bound.http[httpPort], sb.TLSConfig(httpPort))
+	}
+
+	// Start the metrics server if configured
+	if bound.metrics != nil {
 		s.wg.Add(1)
-		go s.startHTTPServer(httpPort, httpMux)
+		go s.startMetricsServer(bound.metrics)
 	}
 
 	// Wait for all servers to complete
 	s.wg.Wait()
+	log.Printf("Shutdown report: %s", s.ShutdownReport())
 	return nil
 }
 
-// startGRPCServer starts a single gRPC server instance
-func (s *ServerBase) startGRPCServer(grpcPort int, grpcServer *grpc.Server) {
-	defer s.wg.Done()
+// wrapHTTPHandler applies the middleware added with WithHTTPMiddleware to handler
+func (s *ServerBase) wrapHTTPHandler(handler http.Handler) http.Handler {
+	for _, middleware := range slices.Backward(s.httpMiddleware) {
+		handler = middleware(handler)
+	}
+	return handler
+}
 
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
-	if err != nil {
-		log.Fatalf("Failed to listen on gRPC port %d: %v", grpcPort, err)
+// listeners are the listeners bound by bindListeners
+type listeners struct {
+	grpc    map[int]net.Listener // by requested gRPC port
+	http    map[int]net.Listener // by requested HTTP port
+	health  net.Listener         // nil without WithHealthPort
+	metrics net.Listener         // nil without WithMetricsPort
+}
+
+// closeAll closes every bound listener
+func (l *listeners) closeAll() {
+	for _, lis := range l.grpc {
+		lis.Close()
+	}
+	for _, lis := range l.http {
+		lis.Close()
+	}
+	if l.health != nil {
+		l.health.Close()
+	}
+	if l.metrics != nil {
+		l.metrics.Close()
 	}
+}
 
-	// Wrap listener with TLS if configured
-	if s.tlsConfig != nil {
-		lis = tls.NewListener(lis, s.tlsConfig)
-		log.Printf("gRPC server listening on port %d (TLS)", grpcPort)
+// bindListeners binds a listener for every gRPC and HTTP server and the health and metrics ports, and records the bound addresses
+// Any listeners already bound are closed if one of them fails
+func (s *ServerBase) bindListeners(sb *ServerBuilder) (*listeners, error) {
+	bound := &listeners{
+		grpc: make(map[int]net.Listener),
+		http: make(map[int]net.Listener),
+	}
+
+	for grpcPort := range sb.grpcServers {
+		lis, err := net.Listen("tcp", s.listenAddr(grpcPort))
+		if err != nil {
+			bound.closeAll()
+			return nil, fmt.Errorf("failed to listen on gRPC port %d: %w", grpcPort, err)
+		}
+		bound.grpc[grpcPort] = lis
+	}
+
+	for httpPort := range sb.httpServers {
+		lis, err := net.Listen("tcp", s.listenAddr(httpPort))
+		if err != nil {
+			bound.closeAll()
+			return nil, fmt.Errorf("failed to listen on HTTP port %d: %w", httpPort, err)
+		}
+		bound.http[httpPort] = lis
+	}
+
+	if s.healthPort > 0 {
+		lis, err := net.Listen("tcp", s.listenAddr(s.healthPort))
+		if err != nil {
+			bound.closeAll()
+			return nil, fmt.Errorf("failed to listen on health port %d: %w", s.healthPort, err)
+		}
+		bound.health = lis
+	}
+
+	if s.metricsPort > 0 {
+		lis, err := net.Listen("tcp", s.listenAddr(s.metricsPort))
+		if err != nil {
+			bound.closeAll()
+			return nil, fmt.Errorf("failed to listen on metrics port %d: %w", s.metricsPort, err)
+		}
+		bound.metrics = lis
+	}
+
+	s.mu.Lock()
+	for grpcPort, lis := range bound.grpc {
+		s.grpcAddrs[grpcPort] = lis.Addr()
+	}
+	for httpPort, lis := range bound.http {
+		s.httpAddrs[httpPort] = lis.Addr()
+	}
+	s.mu.Unlock()
+
+	return bound, nil
+}
+
+// startGRPCServer starts a single gRPC server instance on a bound listener
+func (s *ServerBase) startGRPCServer(grpcPort int, grpcServer *grpc.Server, lis net.Listener, useTLS bool) {
+	defer s.wg.Done()
+
+	// TLS is handled by the server's transport credentials
+	if useTLS {
+		log.Printf("gRPC server listening on %s (TLS)", lis.Addr())
 	} else {
-		log.Printf("gRPC server listening on port %d", grpcPort)
+		log.Printf("gRPC server listening on %s", lis.Addr())
 	}
 
 	// Setup shutdown listener
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-s.shutdownCtx.Done()
 		log.Printf("Shutting down gRPC server on port %d", grpcPort)
-		grpcServer.GracefulStop()
+		s.stopServer(ListenerGRPC, boundPort(lis), grpcServer.GracefulStop, grpcServer.Stop)
 	}()
 
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Printf("gRPC server on port %d stopped: %v", grpcPort, err)
 	}
+	s.waitForStop(stopped)
+}
+
+// waitForStop waits for a server's shutdown listener once shutdown began, so the report is complete when Launch returns
+// A server that stopped on its own leaves its listener waiting for a shutdown that may never come
+func (s *ServerBase) waitForStop(stopped <-chan struct{}) {
+	if s.shutdownCtx.Err() != nil {
+		<-stopped
+	}
 }
 
-// startHTTPServer starts a single HTTP gateway server instance
-func (s *ServerBase) startHTTPServer(httpPort int, httpMux *runtime.ServeMux) {
+// startHTTPServer starts a single HTTP gateway server instance on a bound listener
+func (s *ServerBase) startHTTPServer(httpPort int, handler http.Handler, lis net.Listener, tlsConfig *tls.Config) {
 	defer s.wg.Done()
 
 	httpServer := &http.Server{
-		Addr:    fmt.Sprintf(":%d", httpPort),
-		Handler: httpMux,
-	}
-
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", httpPort))
-	if err != nil {
-		log.Fatalf("Failed to listen on HTTP port %d: %v", httpPort, err)
+		Addr:    lis.Addr().String(),
+		Handler: handler,
 	}
 
 	// Wrap listener with TLS if configured
-	if s.tlsConfig != nil {
-		lis = tls.NewListener(lis, s.tlsConfig)
-		log.Printf("HTTPS server listening on port %d (TLS)", httpPort)
+	if tlsConfig != nil {
+		log.Printf("HTTPS server listening on %s (TLS)", lis.Addr())
+		lis = tls.NewListener(lis, tlsConfig)
 	} else {
-		log.Printf("HTTP server listening on port %d", httpPort)
+		log.Printf("HTTP server listening on %s", lis.Addr())
 	}
 
 	// Setup shutdown listener
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-s.shutdownCtx.Done()
 		log.Printf("Shutting down HTTP server on port %d", httpPort)
-		if err := httpServer.Shutdown(context.Background()); err != nil {
-			log.Printf("HTTP server on port %d shutdown error: %v", httpPort, err)
-		}
+		s.stopServer(ListenerHTTP, boundPort(lis), func() {
+			if err := httpServer.Shutdown(context.Background()); err != nil {
+				log.Printf("HTTP server on port %d shutdown error: %v", httpPort, err)
+			}
+		}, func() { httpServer.Close() })
 	}()
 
 	if err := httpServer.Serve(lis); err != nil && err != http.ErrServerClosed {
 		log.Printf("HTTP server on port %d stopped: %v", httpPort, err)
 	}
+	s.waitForStop(stopped)
 }
 
-// startHealthServer starts a simple HTTP server for health checks (no TLS)
-func (s *ServerBase) startHealthServer() {
+// startHealthServer serves health checks on its bound listener, over TLS with WithHealthTLS
+func (s *ServerBase) startHealthServer(lis net.Listener) {
 	defer s.wg.Done()
 
 	mux := http.NewServeMux()
@@ -219,36 +725,57 @@ func (s *ServerBase) startHealthServer() {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"ok"}`))
 	})
+	mux.HandleFunc(ReadyPath, s.readyHandler)
 
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", s.healthPort),
-		Handler: mux,
+		Addr:      lis.Addr().String(),
+		Handler:   mux,
+		TLSConfig: s.healthTLSConfig(),
 	}
 
-	log.Printf("Health server listening on port %d (no TLS)", s.healthPort)
+	mode := "plaintext"
+	if server.TLSConfig != nil {
+		mode = "TLS"
+	}
+	log.Printf("Health server listening on %s (%s)", lis.Addr(), mode)
 
 	// Setup shutdown listener
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-s.shutdownCtx.Done()
 		log.Printf("Shutting down health server on port %d", s.healthPort)
-		if err := server.Shutdown(context.Background()); err != nil {
-			log.Printf("Health server shutdown error: %v", err)
-		}
+		s.stopServer(ListenerHealth, s.healthPort, func() {
+			if err := server.Shutdown(context.Background()); err != nil {
+				log.Printf("Health server shutdown error: %v", err)
+			}
+		}, func() { server.Close() })
 	}()
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	// The certificates come from TLSConfig, so no files are passed
+	serve := func() error { return server.Serve(lis) }
+	if server.TLSConfig != nil {
+		serve = func() error { return server.ServeTLS(lis, "", "") }
+	}
+	if err := serve(); err != nil && err != http.ErrServerClosed {
 		log.Printf("Health server stopped: %v", err)
 	}
+	s.waitForStop(stopped)
 }
 
 // setupGracefulShutdown sets up signal handling for graceful shutdown
+// The handler is removed once the servers shut down, so later signals reach the process again
 func (s *ServerBase) setupGracefulShutdown() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-		<-sigCh
-		log.Println("Received shutdown signal, shutting down all servers...")
-		s.cancel()
+		defer signal.Stop(sigCh)
+		select {
+		case <-sigCh:
+			log.Println("Received shutdown signal, shutting down all servers...")
+			s.cancel()
+		case <-s.shutdownCtx.Done():
+		}
 	}()
 }
 
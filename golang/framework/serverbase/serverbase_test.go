@@ -0,0 +1,1454 @@
+package serverbase_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/serverbase"
+)
+
+// testPKI holds a CA with a server certificate for localhost and a client certificate
+type testPKI struct {
+	caPool     *x509.CertPool
+	serverCert tls.Certificate
+	clientCert tls.Certificate
+	caFile     string
+	certFile   string
+	keyFile    string
+}
+
+// newTestPKI generates a CA and the certificates it signs, writing the CA and server certificate to a temp dir
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+
+	issue := func(serial int64, template *x509.Certificate) ([]byte, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		template.SerialNumber = big.NewInt(serial)
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("Failed to create certificate: %v", err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatalf("Failed to marshal key: %v", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+
+	serverCertPEM, serverKeyPEM := issue(2, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	clientCertPEM, clientKeyPEM := issue(3, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "test-client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	pki := &testPKI{
+		caPool:   x509.NewCertPool(),
+		caFile:   filepath.Join(t.TempDir(), "ca.crt"),
+		certFile: filepath.Join(t.TempDir(), "tls.crt"),
+		keyFile:  filepath.Join(t.TempDir(), "tls.key"),
+	}
+	pki.caPool.AddCert(caCert)
+	if pki.serverCert, err = tls.X509KeyPair(serverCertPEM, serverKeyPEM); err != nil {
+		t.Fatalf("Failed to load server certificate: %v", err)
+	}
+	if pki.clientCert, err = tls.X509KeyPair(clientCertPEM, clientKeyPEM); err != nil {
+		t.Fatalf("Failed to load client certificate: %v", err)
+	}
+	if err := os.WriteFile(pki.caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0600); err != nil {
+		t.Fatalf("Failed to write CA certificate: %v", err)
+	}
+	if err := os.WriteFile(pki.certFile, serverCertPEM, 0600); err != nil {
+		t.Fatalf("Failed to write server certificate: %v", err)
+	}
+	if err := os.WriteFile(pki.keyFile, serverKeyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write server key: %v", err)
+	}
+	return pki
+}
+
+// registrarFunc adapts a function to serverbase.GRPCServiceRegistrar
+type registrarFunc func(s grpc.ServiceRegistrar)
+
+func (f registrarFunc) RegisterGRPC(s grpc.ServiceRegistrar) {
+	f(s)
+}
+
+// twoPortServer serves the health service on the launch port and on an extra internal port
+type twoPortServer struct {
+	internalPort int
+}
+
+func (s *twoPortServer) Register(sb *serverbase.ServerBuilder, grpcPort, httpPort int) error {
+	// Launch registers health and reflection on the launch port itself
+	sb.RegisterGRPCService(grpcPort, registrarFunc(func(grpc.ServiceRegistrar) {}))
+	sb.RegisterGRPCService(s.internalPort, registrarFunc(func(r grpc.ServiceRegistrar) {
+		healthpb.RegisterHealthServer(r, health.NewServer())
+	}))
+	return nil
+}
+
+// pingService is a hand-written gRPC service whose Ping method answers with the service name
+type pingService struct {
+	name string
+}
+
+func (p pingService) RegisterGRPC(s grpc.ServiceRegistrar) {
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: p.name,
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Ping",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				in := &emptypb.Empty{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req any) (any, error) {
+					return wrapperspb.String(p.name), nil
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + p.name + "/Ping"}, handler)
+			},
+		}},
+	}, p)
+}
+
+// gatewayServer registers an empty gRPC service and HTTP gateway on the launch ports
+type gatewayServer struct{}
+
+func (gatewayServer) RegisterGRPC(grpc.ServiceRegistrar) {}
+
+func (gatewayServer) RegisterGateway(context.Context, *runtime.ServeMux) error {
+	return nil
+}
+
+func (s gatewayServer) Register(sb *serverbase.ServerBuilder, grpcPort, httpPort int) error {
+	sb.RegisterService(grpcPort, httpPort, s)
+	return nil
+}
+
+// routeGateway serves a fixed GET route on the gateway, or fails to register with err
+type routeGateway struct {
+	path string
+	err  error
+}
+
+func (g routeGateway) RegisterGRPC(grpc.ServiceRegistrar) {}
+
+func (g routeGateway) RegisterGateway(_ context.Context, mux *runtime.ServeMux) error {
+	if err := mux.HandlePath(http.MethodGet, g.path, func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		w.WriteHeader(http.StatusOK)
+	}); err != nil {
+		return err
+	}
+	return g.err
+}
+
+// freePort returns a TCP port that was free a moment ago
+func freePort(t *testing.T) int {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+// checkHealth calls the health service at addr with the given client TLS config
+func checkHealth(t *testing.T, addr string, cfg *tls.Config) error {
+	t.Helper()
+	conn, err := grpc.NewClient("passthrough:///"+addr, grpc.WithTransportCredentials(credentials.NewTLS(cfg)))
+	if err != nil {
+		t.Fatalf("Failed to create client for %s: %v", addr, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestPortTLSOverridesGlobalTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	internalPort := freePort(t)
+
+	// The launch port falls back to public TLS, the internal port requires client certificates
+	server := serverbase.NewServerBase().
+		WithTLS(pki.certFile, pki.keyFile).
+		WithPortTLS(internalPort, &tls.Config{
+			Certificates: []tls.Certificate{pki.serverCert},
+			ClientCAs:    pki.caPool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			MinVersion:   tls.VersionTLS12,
+		})
+	server.ServerInterface = &twoPortServer{internalPort: internalPort}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	publicAddr := net.JoinHostPort("localhost", strconv.Itoa(server.GRPCAddr().(*net.TCPAddr).Port))
+	internalAddr := net.JoinHostPort("localhost", strconv.Itoa(internalPort))
+	withoutClientCert := &tls.Config{RootCAs: pki.caPool, MinVersion: tls.VersionTLS12}
+	withClientCert := &tls.Config{RootCAs: pki.caPool, Certificates: []tls.Certificate{pki.clientCert}, MinVersion: tls.VersionTLS12}
+
+	if err := checkHealth(t, publicAddr, withoutClientCert); err != nil {
+		t.Fatalf("Expected public port to accept clients without a certificate: %v", err)
+	}
+	if err := checkHealth(t, internalAddr, withoutClientCert); err == nil {
+		t.Fatal("Expected internal port to reject clients without a certificate")
+	}
+	if err := checkHealth(t, internalAddr, withClientCert); err != nil {
+		t.Fatalf("Expected internal port to accept clients with a certificate: %v", err)
+	}
+}
+
+func TestEffectiveConfigReportsBuilderOptions(t *testing.T) {
+	pki := newTestPKI(t)
+	internalPort := freePort(t)
+	healthPort := freePort(t)
+
+	server := serverbase.NewServerBase().
+		WithTLS(pki.certFile, pki.keyFile).
+		WithClientCA(pki.caFile).
+		WithHealthPort(healthPort).
+		WithBindAddress("127.0.0.1").
+		WithShutdownTimeout(3*time.Second).
+		WithPortTLS(internalPort, &tls.Config{
+			Certificates: []tls.Certificate{pki.serverCert},
+			MinVersion:   tls.VersionTLS12,
+		})
+	server.ServerInterface = &twoPortServer{internalPort: internalPort}
+
+	cfg := server.EffectiveConfig()
+	if cfg.HealthPort != healthPort || !cfg.TLS || !cfg.MTLS {
+		t.Fatalf("Unexpected config before launch: %s", cfg)
+	}
+	if cfg.BindAddress != "127.0.0.1" || cfg.ShutdownTimeout != 3*time.Second {
+		t.Fatalf("Expected the bind address and shutdown timeout, got: %s", cfg)
+	}
+	if s := cfg.String(); !strings.Contains(s, `bindAddress="127.0.0.1"`) || !strings.Contains(s, "shutdownTimeout=3s") {
+		t.Fatalf("Expected the bind address and shutdown timeout in the config line, got: %s", s)
+	}
+	if mtls, ok := cfg.PortTLS[internalPort]; !ok || mtls {
+		t.Fatalf("Expected a public TLS override on port %d, got %v", internalPort, cfg.PortTLS)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	// Once launched the bound port replaces the requested port 0
+	cfg = server.EffectiveConfig()
+	if want := server.GRPCAddr().(*net.TCPAddr).Port; cfg.GRPCPort != want {
+		t.Fatalf("Expected gRPC port %d, got %d", want, cfg.GRPCPort)
+	}
+	if cfg.HTTPPort != 0 {
+		t.Fatalf("Expected no HTTP port without a gateway, got %d", cfg.HTTPPort)
+	}
+}
+
+func TestMetadataLimitsRejectOversizedMetadata(t *testing.T) {
+	server := serverbase.NewServerBase().WithMetadataLimits(4096, 16)
+	server.ServerInterface = &twoPortServer{internalPort: freePort(t)}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	conn, err := grpc.NewClient("passthrough:///"+server.GRPCAddr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	check := func(md metadata.MD) error {
+		_, err := client.Check(metadata.NewOutgoingContext(ctx, md), &healthpb.HealthCheckRequest{})
+		return err
+	}
+
+	if err := check(metadata.Pairs("cookie", "session=abc")); err != nil {
+		t.Fatalf("Expected a normal request to pass: %v", err)
+	}
+
+	if err := check(metadata.Pairs("cookie", strings.Repeat("a", 8192))); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Expected oversized metadata to be rejected with ResourceExhausted, got: %v", err)
+	}
+
+	manyKeys := metadata.MD{}
+	for i := range 32 {
+		manyKeys.Set("x-key-"+strconv.Itoa(i), "v")
+	}
+	if err := check(manyKeys); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Expected too many metadata keys to be rejected with ResourceExhausted, got: %v", err)
+	}
+}
+
+func TestRegisterAdditionalServiceSharesThePort(t *testing.T) {
+	server := serverbase.NewServerBase().
+		RegisterAdditionalService(pingService{name: "serverbase.test.First"}, pingService{name: "serverbase.test.Second"})
+	server.ServerInterface = &twoPortServer{internalPort: freePort(t)}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	conn, err := grpc.NewClient("passthrough:///"+server.GRPCAddr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer conn.Close()
+
+	// Both services answer on the launch port next to the ones Launch registers itself
+	for _, name := range []string{"serverbase.test.First", "serverbase.test.Second"} {
+		reply := &wrapperspb.StringValue{}
+		if err := conn.Invoke(ctx, "/"+name+"/Ping", &emptypb.Empty{}, reply); err != nil {
+			t.Fatalf("Failed to call %s: %v", name, err)
+		}
+		if reply.GetValue() != name {
+			t.Fatalf("Expected %s to answer with its name, got %q", name, reply.GetValue())
+		}
+	}
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Expected the health service to keep working: %v", err)
+	}
+}
+
+// grpcWebFrame encodes a gRPC-Web frame: a flag byte, a big-endian length and the payload
+func grpcWebFrame(flag byte, payload []byte) []byte {
+	frame := make([]byte, 5, 5+len(payload))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+func TestGRPCWebServesFramedRequests(t *testing.T) {
+	server := serverbase.NewServerBase().WithGRPCWeb()
+	server.ServerInterface = &twoPortServer{internalPort: freePort(t)}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	reqBody, err := proto.Marshal(&healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	url := "http://" + server.HTTPAddr().String() + "/grpc.health.v1.Health/Check"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(grpcWebFrame(0x00, reqBody)))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	req.Header.Set("X-Grpc-Web", "1")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send gRPC-Web request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/grpc-web") {
+		t.Fatalf("Expected a gRPC-Web content type, got %q", ct)
+	}
+
+	// The body holds a data frame followed by a trailer frame carrying the status
+	var healthResp healthpb.HealthCheckResponse
+	var trailers string
+	body := bufio.NewReader(resp.Body)
+	for {
+		header := make([]byte, 5)
+		if _, err := io.ReadFull(body, header); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Failed to read frame header: %v", err)
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(header[1:]))
+		if _, err := io.ReadFull(body, payload); err != nil {
+			t.Fatalf("Failed to read frame payload: %v", err)
+		}
+		if header[0]&0x80 != 0 {
+			trailers = string(payload)
+			continue
+		}
+		if err := proto.Unmarshal(payload, &healthResp); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+	}
+
+	if healthResp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("Expected SERVING, got %s", healthResp.GetStatus())
+	}
+	if !strings.Contains(strings.ToLower(trailers), "grpc-status: 0") {
+		t.Fatalf("Expected an OK grpc-status trailer, got %q", trailers)
+	}
+}
+
+func TestHealthzReflectsServingStatus(t *testing.T) {
+	server := serverbase.NewServerBase()
+	server.ServerInterface = gatewayServer{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	healthz := func(query string) (int, string) {
+		t.Helper()
+		resp, err := http.Get("http://" + server.HTTPAddr().String() + serverbase.HealthzPath + query)
+		if err != nil {
+			t.Fatalf("Failed to call %s: %v", serverbase.HealthzPath, err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	if code, body := healthz(""); code != http.StatusOK || !strings.Contains(body, `"SERVING"`) {
+		t.Fatalf("Expected 200 SERVING, got %d %s", code, body)
+	}
+
+	server.HealthServer().SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	if code, body := healthz(""); code != http.StatusServiceUnavailable || !strings.Contains(body, `"NOT_SERVING"`) {
+		t.Fatalf("Expected 503 NOT_SERVING, got %d %s", code, body)
+	}
+
+	server.HealthServer().SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	if code, body := healthz(""); code != http.StatusOK {
+		t.Fatalf("Expected 200 after serving again, got %d %s", code, body)
+	}
+
+	// Single services are checked by name; unknown ones are unavailable
+	server.HealthServer().SetServingStatus("pkg.v1.Service", healthpb.HealthCheckResponse_NOT_SERVING)
+	if code, body := healthz("?service=pkg.v1.Service"); code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 for a service that is not serving, got %d %s", code, body)
+	}
+	if code, body := healthz("?service=pkg.v1.Unknown"); code != http.StatusServiceUnavailable || !strings.Contains(body, "NotFound") {
+		t.Fatalf("Expected 503 NotFound for an unknown service, got %d %s", code, body)
+	}
+}
+
+func TestReadinessCheckFlipsServingStatus(t *testing.T) {
+	var dbDown atomic.Bool
+	healthPort := freePort(t)
+	server := serverbase.NewServerBase().
+		WithHealthPort(healthPort).
+		WithReadinessCheck(10*time.Millisecond, func(context.Context) error {
+			if dbDown.Load() {
+				return errors.New("database unreachable")
+			}
+			return nil
+		})
+	server.ServerInterface = gatewayServer{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	// awaitStatus polls url until it answers with code, since checks and the health port run in the background
+	awaitStatus := func(url string, code int) {
+		t.Helper()
+		var got int
+		for ctx.Err() == nil {
+			if resp, err := http.Get(url); err == nil {
+				resp.Body.Close()
+				if got = resp.StatusCode; got == code {
+					return
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Expected %d from %s, last got %d", code, url, got)
+	}
+	healthz := "http://" + server.HTTPAddr().String() + serverbase.HealthzPath
+	ready := fmt.Sprintf("http://localhost:%d%s", healthPort, serverbase.ReadyPath)
+	live := fmt.Sprintf("http://localhost:%d/health", healthPort)
+
+	awaitStatus(healthz, http.StatusOK)
+	awaitStatus(ready, http.StatusOK)
+
+	// A failing check reports not ready everywhere, while the process stays live
+	dbDown.Store(true)
+	awaitStatus(healthz, http.StatusServiceUnavailable)
+	awaitStatus(ready, http.StatusServiceUnavailable)
+	awaitStatus(live, http.StatusOK)
+
+	dbDown.Store(false)
+	awaitStatus(healthz, http.StatusOK)
+	awaitStatus(ready, http.StatusOK)
+}
+
+func TestHealthPortServesTLSWhenEnabled(t *testing.T) {
+	pki := newTestPKI(t)
+	healthPort := freePort(t)
+	server := serverbase.NewServerBase().
+		WithTLS(pki.certFile, pki.keyFile).
+		WithHealthPort(healthPort).
+		WithHealthTLS()
+	server.ServerInterface = gatewayServer{}
+
+	if cfg := server.EffectiveConfig(); !cfg.HealthTLS {
+		t.Fatalf("Expected the health port to report TLS: %s", cfg)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	// The health server starts in the background, so poll until it answers over TLS
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: pki.caPool, MinVersion: tls.VersionTLS12},
+	}}
+	live := fmt.Sprintf("https://localhost:%d/health", healthPort)
+	var lastErr error
+	for ctx.Err() == nil {
+		resp, err := client.Get(live)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected %d from %s, got %d", http.StatusOK, live, resp.StatusCode)
+			}
+			break
+		}
+		lastErr = err
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("Health port did not answer over TLS: %v", lastErr)
+	}
+
+	// Plaintext requests get the TLS server's 400 instead of a health answer
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/health", healthPort))
+	if err != nil {
+		t.Fatalf("Failed to call the health port without TLS: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Fatal("Expected the health port to refuse plaintext requests")
+	}
+}
+
+func TestHealthTLSWithoutTLSConfigFailsLaunch(t *testing.T) {
+	server := serverbase.NewServerBase().
+		WithHealthPort(freePort(t)).
+		WithHealthTLS()
+	server.ServerInterface = gatewayServer{}
+
+	if err := server.Launch(0, 0); err == nil {
+		t.Fatal("Expected Launch to fail without a TLS config for the health port")
+	}
+}
+
+// connMetric returns the int64 value of the connection metric name, summed over its data points
+func connMetric(t *testing.T, reader sdkmetric.Reader, name string) int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+	var total int64
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != name {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("Expected %s to be an int64 sum, got %T", name, m.Data)
+			}
+			for _, dp := range sum.DataPoints {
+				total += dp.Value
+			}
+		}
+	}
+	return total
+}
+
+func TestMetricsPortServesTheHandler(t *testing.T) {
+	metricsPort := freePort(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "rpc_server_open_connections 1")
+	})
+	server := serverbase.NewServerBase().WithMetricsPort(metricsPort, handler)
+	server.ServerInterface = gatewayServer{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	// The metrics port is bound before the server reports ready
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", metricsPort, serverbase.MetricsPath))
+	if err != nil {
+		t.Fatalf("Failed to scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read metrics: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || string(body) != "rpc_server_open_connections 1" {
+		t.Fatalf("Expected the metrics handler's response, got %d: %q", resp.StatusCode, body)
+	}
+}
+
+func TestConnMetricsCountConnectionsAndBytes(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	metrics := serverbase.NewConnMetrics().WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	server := serverbase.NewServerBase().WithStatsHandler(metrics.StatsHandler())
+	server.ServerInterface = gatewayServer{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	// awaitOpen polls the open connection gauge until it is want, since connections end in the background
+	awaitOpen := func(want int64) {
+		t.Helper()
+		var got int64
+		for ctx.Err() == nil {
+			if got = connMetric(t, reader, serverbase.OpenConnectionsMetric); got == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Expected %d open connections, last got %d", want, got)
+	}
+
+	// Each client opens its own connection with its first call
+	var conns []*grpc.ClientConn
+	for range 2 {
+		conn, err := grpc.NewClient("passthrough:///"+server.GRPCAddr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		defer conn.Close()
+		if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("Failed to check health: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	awaitOpen(2)
+
+	if got := connMetric(t, reader, serverbase.ReceivedBytesMetric); got <= 0 {
+		t.Fatalf("Expected received bytes to be counted, got %d", got)
+	}
+	if got := connMetric(t, reader, serverbase.SentBytesMetric); got <= 0 {
+		t.Fatalf("Expected sent bytes to be counted, got %d", got)
+	}
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	awaitOpen(0)
+}
+
+func TestRegisterGatewaysIsAllOrNothing(t *testing.T) {
+	const httpPort = 26000
+	sb := serverbase.NewServerBuilder().
+		RegisterService(25000, httpPort, routeGateway{path: "/first"}).
+		RegisterService(25000, httpPort, routeGateway{path: "/second", err: errors.New("second gateway is broken")})
+
+	err := sb.RegisterGateways(context.Background())
+	if err == nil {
+		t.Fatal("Expected the failing gateway to fail registration")
+	}
+	if !strings.Contains(err.Error(), "second gateway is broken") || !strings.Contains(err.Error(), "HTTP port 26000") {
+		t.Fatalf("Expected the error to name the failing gateway and port, got: %v", err)
+	}
+
+	// The route of the gateway that did register is not served either
+	for _, path := range []string{"/first", "/second"} {
+		rec := httptest.NewRecorder()
+		sb.HTTPMux(httpPort).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("Expected %s to answer 404 after a failed registration, got %d", path, rec.Code)
+		}
+	}
+
+	// Launch reports the error instead of serving a partial gateway
+	server := serverbase.NewServerBase().
+		RegisterAdditionalService(routeGateway{path: "/first"}, routeGateway{path: "/second", err: errors.New("second gateway is broken")})
+	server.ServerInterface = gatewayServer{}
+	if err := server.Launch(0, 0); err == nil || !strings.Contains(err.Error(), "second gateway is broken") {
+		t.Fatalf("Expected Launch to fail with the gateway error, got: %v", err)
+	}
+	if err := server.WaitUntilReady(context.Background()); err == nil {
+		t.Fatal("Expected WaitUntilReady to report the failed launch")
+	}
+	if server.HTTPAddr() != nil {
+		t.Fatalf("Expected no HTTP server after a failed launch, got %s", server.HTTPAddr())
+	}
+}
+
+// rateLimitedGateway rejects every request to its route with ResourceExhausted, asking to retry after delay
+type rateLimitedGateway struct {
+	path  string
+	delay time.Duration
+}
+
+func (g rateLimitedGateway) RegisterGRPC(grpc.ServiceRegistrar) {}
+
+func (g rateLimitedGateway) RegisterGateway(_ context.Context, mux *runtime.ServeMux) error {
+	return mux.HandlePath(http.MethodGet, g.path, func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		st, err := status.New(codes.ResourceExhausted, "rate limit exceeded").WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(g.delay),
+		})
+		if err != nil {
+			panic(err)
+		}
+		_, outbound := runtime.MarshalerForRequest(mux, r)
+		runtime.HTTPError(r.Context(), mux, outbound, w, r, st.Err())
+	})
+}
+
+func TestGatewayErrorsSetRetryAfterFromRetryInfo(t *testing.T) {
+	const httpPort = 26001
+	sb := serverbase.NewServerBuilder().
+		RegisterService(25001, httpPort, rateLimitedGateway{path: "/limited", delay: 1500 * time.Millisecond})
+	if err := sb.RegisterGateways(context.Background()); err != nil {
+		t.Fatalf("Failed to register gateways: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	sb.HTTPMux(httpPort).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/limited", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 for a rate-limited request, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// The delay is rounded up to whole seconds
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("Expected Retry-After 2, got %q", got)
+	}
+	// The detail is still rendered in the body
+	if !strings.Contains(rec.Body.String(), "google.rpc.RetryInfo") {
+		t.Fatalf("Expected the body to carry the RetryInfo detail, got: %s", rec.Body.String())
+	}
+
+	// Errors without a RetryInfo detail carry no Retry-After
+	rec = httptest.NewRecorder()
+	sb.HTTPMux(httpPort).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404 for an unknown route, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "" {
+		t.Fatalf("Expected no Retry-After for an error without RetryInfo, got %q", got)
+	}
+}
+
+func TestReadyIsClosedOnceServing(t *testing.T) {
+	server := serverbase.NewServerBase().WithoutSignalHandler()
+	server.ServerInterface = gatewayServer{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	select {
+	case <-server.Ready():
+	case <-time.After(10 * time.Second):
+		t.Fatal("Server did not become ready")
+	}
+	if err := server.LaunchErr(); err != nil {
+		t.Fatalf("Expected a successful launch, got: %v", err)
+	}
+
+	// No retries or waiting: both ports answer right away
+	conn, err := grpc.NewClient(server.GRPCAddr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Expected the health check to succeed once ready: %v", err)
+	}
+
+	resp, err := http.Get("http://" + server.HTTPAddr().String() + serverbase.HealthzPath)
+	if err != nil {
+		t.Fatalf("Expected the gateway to answer once ready: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected %s to answer 200, got %d", serverbase.HealthzPath, resp.StatusCode)
+	}
+}
+
+func TestRegisterCloserRunsOnceAfterShutdown(t *testing.T) {
+	var closed []string
+	server := serverbase.NewServerBase().WithoutSignalHandler().
+		RegisterCloser(func() { closed = append(closed, "pool") }).
+		RegisterCloser(func() { closed = append(closed, "cache") })
+	server.ServerInterface = gatewayServer{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	if err := server.WaitUntilReady(context.Background()); err != nil {
+		t.Fatalf("Failed to launch: %v", err)
+	}
+
+	// Closers wait for the servers to stop; Launch has not returned, so reading closed is safe
+	if len(closed) != 0 {
+		t.Fatalf("Expected no closer to run while serving, got %v", closed)
+	}
+
+	server.Shutdown()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Launch did not return after Shutdown")
+	}
+	server.Shutdown()
+
+	if want := []string{"cache", "pool"}; !slices.Equal(closed, want) {
+		t.Fatalf("Expected closers to run once in reverse registration order %v, got %v", want, closed)
+	}
+}
+
+// externalIPv4 returns an IPv4 address of a non-loopback interface, skipping the test if there is none
+func externalIPv4(t *testing.T) net.IP {
+	t.Helper()
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		t.Fatalf("Failed to list interface addresses: %v", err)
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP
+		}
+	}
+	t.Skip("No non-loopback IPv4 interface to dial")
+	return nil
+}
+
+func TestBindAddressListensOnLoopbackOnly(t *testing.T) {
+	external := externalIPv4(t)
+	healthPort := freePort(t)
+	server := serverbase.NewServerBase().WithoutSignalHandler().
+		WithBindAddress("127.0.0.1").
+		WithHealthPort(healthPort)
+	server.ServerInterface = gatewayServer{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+	if err := server.WaitUntilReady(context.Background()); err != nil {
+		t.Fatalf("Failed to launch: %v", err)
+	}
+
+	// The health server binds once started, shortly after the others
+	loopbackHealth := net.JoinHostPort("127.0.0.1", strconv.Itoa(healthPort))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.DialTimeout("tcp", loopbackHealth, time.Second)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Health server did not listen on %s: %v", loopbackHealth, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for name, port := range map[string]int{
+		"gRPC":   server.GRPCAddr().(*net.TCPAddr).Port,
+		"HTTP":   server.HTTPAddr().(*net.TCPAddr).Port,
+		"health": healthPort,
+	} {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), time.Second)
+		if err != nil {
+			t.Fatalf("Expected the %s port to accept loopback connections: %v", name, err)
+		}
+		conn.Close()
+
+		externalAddr := net.JoinHostPort(external.String(), strconv.Itoa(port))
+		if conn, err := net.DialTimeout("tcp", externalAddr, time.Second); err == nil {
+			conn.Close()
+			t.Fatalf("Expected the %s port to refuse connections on %s", name, externalAddr)
+		}
+	}
+}
+
+// failingServer fails to register with err
+type failingServer struct {
+	err error
+}
+
+func (s failingServer) Register(sb *serverbase.ServerBuilder, grpcPort, httpPort int) error {
+	return s.err
+}
+
+func TestLaunchReturnsRegisterError(t *testing.T) {
+	registerErr := errors.New("service is misconfigured")
+	server := serverbase.NewServerBase()
+	server.ServerInterface = failingServer{err: registerErr}
+
+	if err := server.Launch(0, 0); !errors.Is(err, registerErr) {
+		t.Fatalf("Expected Launch to return the Register error, got: %v", err)
+	}
+	if err := server.WaitUntilReady(context.Background()); !errors.Is(err, registerErr) {
+		t.Fatalf("Expected WaitUntilReady to report the Register error, got: %v", err)
+	}
+	<-server.Ready()
+	if err := server.LaunchErr(); !errors.Is(err, registerErr) {
+		t.Fatalf("Expected LaunchErr to report the Register error, got: %v", err)
+	}
+	if server.GRPCAddr() != nil || server.HTTPAddr() != nil {
+		t.Fatal("Expected no servers after a failed Register")
+	}
+}
+
+func TestGRPCOnlyFallbackServesGRPCWhenGatewayFails(t *testing.T) {
+	server := serverbase.NewServerBase().
+		WithGRPCOnlyFallback().
+		RegisterAdditionalService(routeGateway{path: "/broken", err: errors.New("gateway is broken")})
+	server.ServerInterface = gatewayServer{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Expected the server to start without its gateway, got: %v", err)
+	}
+
+	conn, err := grpc.NewClient("passthrough:///"+server.GRPCAddr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Expected gRPC to be served, got: %v", err)
+	}
+
+	// The failed gateway is not served, health checks still are
+	for path, want := range map[string]int{"/broken": http.StatusNotFound, serverbase.HealthzPath: http.StatusOK} {
+		resp, err := http.Get("http://" + server.HTTPAddr().String() + path)
+		if err != nil {
+			t.Fatalf("Failed to call %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("Expected %d from %s, got %d", want, path, resp.StatusCode)
+		}
+	}
+}
+
+func TestWithoutSignalHandlerLeavesSIGTERMToTheCaller(t *testing.T) {
+	// The test owns SIGTERM, so the signal never terminates the test process
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	server := serverbase.NewServerBase().WithoutSignalHandler()
+	server.ServerInterface = &twoPortServer{internalPort: freePort(t)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.LaunchContext(ctx, 0, 0)
+	}()
+
+	readyCtx, readyCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer readyCancel()
+	if err := server.WaitUntilReady(readyCtx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("Failed to send SIGTERM: %v", err)
+	}
+	select {
+	case <-sigCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the caller to receive SIGTERM")
+	}
+
+	// The server ignored the signal and keeps serving
+	select {
+	case err := <-done:
+		t.Fatalf("Expected the server to keep running after SIGTERM, Launch returned: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	conn, err := grpc.NewClient("passthrough:///"+server.GRPCAddr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(readyCtx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Expected the server to keep serving after SIGTERM: %v", err)
+	}
+
+	// Cancelling the launch context is what stops it
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the server to stop once the launch context was cancelled")
+	}
+}
+
+func TestSignalHandlerShutsDownOnSIGTERM(t *testing.T) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	server := serverbase.NewServerBase()
+	server.ServerInterface = &twoPortServer{internalPort: freePort(t)}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Launch(0, 0)
+	}()
+	defer server.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("Failed to send SIGTERM: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the default signal handler to shut the server down")
+	}
+}
+
+// blockingGateway serves a GET route that holds the request open until release is closed
+type blockingGateway struct {
+	path    string
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (g blockingGateway) RegisterGRPC(grpc.ServiceRegistrar) {}
+
+func (g blockingGateway) RegisterGateway(_ context.Context, mux *runtime.ServeMux) error {
+	return mux.HandlePath(http.MethodGet, g.path, func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		close(g.entered)
+		<-g.release
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (g blockingGateway) Register(sb *serverbase.ServerBuilder, grpcPort, httpPort int) error {
+	sb.RegisterService(grpcPort, httpPort, g)
+	return nil
+}
+
+func TestShutdownReportListsEveryListener(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	healthPort := freePort(t)
+	gateway := blockingGateway{path: "/v1/slow", entered: make(chan struct{}), release: make(chan struct{})}
+	defer close(gateway.release)
+
+	server := serverbase.NewServerBase().WithHealthPort(healthPort).WithShutdownTimeout(timeout)
+	server.ServerInterface = gateway
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	// An in-flight request keeps the HTTP server from stopping gracefully
+	go http.Get("http://" + server.HTTPAddr().String() + gateway.path)
+	select {
+	case <-gateway.entered:
+	case <-ctx.Done():
+		t.Fatal("Expected the slow request to reach the handler")
+	}
+
+	server.Shutdown()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("Expected the shutdown timeout to stop the server")
+	}
+
+	report := server.ShutdownReport()
+	grpcPort := server.GRPCAddr().(*net.TCPAddr).Port
+	httpPort := server.HTTPAddr().(*net.TCPAddr).Port
+	want := []struct {
+		kind   string
+		port   int
+		forced bool
+	}{
+		{serverbase.ListenerGRPC, grpcPort, false},
+		{serverbase.ListenerHTTP, httpPort, true},
+		{serverbase.ListenerHealth, healthPort, false},
+	}
+	if len(report.Listeners) != len(want) {
+		t.Fatalf("Expected %d listeners in the report, got: %s", len(want), report)
+	}
+	for i, w := range want {
+		stop := report.Listeners[i]
+		if stop.Kind != w.kind || stop.Port != w.port || stop.Forced != w.forced {
+			t.Fatalf("Expected %s :%d (forced %t) at %d, got %+v", w.kind, w.port, w.forced, i, stop)
+		}
+	}
+
+	if !report.Forced() {
+		t.Fatal("Expected the report to flag the forced stop")
+	}
+	if report.Duration() < timeout {
+		t.Fatalf("Expected the forced stop to take at least the %s timeout, took %s", timeout, report.Duration())
+	}
+	if s := report.String(); !strings.Contains(s, fmt.Sprintf("HTTP :%d in", httpPort)) || !strings.Contains(s, "(forced)") {
+		t.Fatalf("Unexpected report string: %s", s)
+	}
+}
+
+func TestContextHandlerRoutesRecordsToTheContextLogger(t *testing.T) {
+	var fallback, scoped bytes.Buffer
+	logger := slog.New(serverbase.NewContextHandler(slog.NewTextHandler(&fallback, nil))).With("component", "test")
+	ctx := serverbase.ContextWithLogger(context.Background(), slog.New(slog.NewTextHandler(&scoped, nil)))
+
+	logger.InfoContext(ctx, "scoped record")
+	logger.InfoContext(context.Background(), "unscoped record")
+
+	if got := scoped.String(); !strings.Contains(got, "scoped record") || !strings.Contains(got, "component=test") || strings.Contains(got, "unscoped") {
+		t.Errorf("Expected only the scoped record with its attributes in the context logger, got: %s", got)
+	}
+	if got := fallback.String(); !strings.Contains(got, "unscoped record") || strings.Contains(got, "msg=\"scoped record\"") {
+		t.Errorf("Expected only the unscoped record in the fallback handler, got: %s", got)
+	}
+}
+
+func TestLaunchRejectsCollidingPorts(t *testing.T) {
+	port := freePort(t)
+	tests := []struct {
+		name       string
+		healthPort int
+		grpcPort   int
+		httpPort   int
+		want       string
+	}{
+		{"gRPC and HTTP", 0, port, port, fmt.Sprintf("gRPC and HTTP ports are both %d", port)},
+		{"HTTP and health", port, 0, port, fmt.Sprintf("HTTP and health ports are both %d", port)},
+		{"out of range", 0, 70000, 0, "invalid gRPC port 70000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serverbase.NewServerBase().WithHealthPort(tt.healthPort)
+			server.ServerInterface = gatewayServer{}
+
+			err := server.Launch(tt.grpcPort, tt.httpPort)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Expected Launch to fail with %q, got: %v", tt.want, err)
+			}
+			if err := server.LaunchErr(); err == nil {
+				t.Fatal("Expected LaunchErr to report the port error")
+			}
+			if server.GRPCAddr() != nil || server.HTTPAddr() != nil {
+				t.Fatal("Expected no servers after a port error")
+			}
+		})
+	}
+}
+
+func TestLaunchFailsWhenHealthPortIsTaken(t *testing.T) {
+	taken, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer taken.Close()
+	healthPort := taken.Addr().(*net.TCPAddr).Port
+
+	server := serverbase.NewServerBase().WithHealthPort(healthPort)
+	server.ServerInterface = gatewayServer{}
+
+	// The health port is bound with the other ports, so the server never reports ready without it
+	err = server.Launch(0, 0)
+	if want := fmt.Sprintf("failed to listen on health port %d", healthPort); err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("Expected Launch to fail with %q, got: %v", want, err)
+	}
+	if err := server.LaunchErr(); err == nil {
+		t.Fatal("Expected LaunchErr to report the health port error")
+	}
+	if server.GRPCAddr() != nil || server.HTTPAddr() != nil {
+		t.Fatal("Expected no servers after a health port error")
+	}
+}
+
+func TestHTTPMiddlewareWrapsGatewayInOrder(t *testing.T) {
+	var order []string
+	middleware := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	server := serverbase.NewServerBase().WithHTTPMiddleware(middleware("outer"), middleware("inner"))
+	server.ServerInterface = gatewayServer{}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	resp, err := http.Get("http://" + server.HTTPAddr().String() + serverbase.HealthzPath)
+	if err != nil {
+		t.Fatalf("Failed to call the gateway: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if !slices.Equal(order, []string{"outer", "inner"}) {
+		t.Fatalf("Expected the middleware to run outer first, got %v", order)
+	}
+}
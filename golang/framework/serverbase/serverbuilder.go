@@ -2,10 +2,19 @@ package serverbase
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
+	"slices"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/encoding/gzip" // Register the gzip compressor
+	"google.golang.org/grpc/stats"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
@@ -15,6 +24,8 @@ type GRPCServiceRegistrar interface {
 }
 
 // HTTPGatewayRegistrar registers an HTTP gateway handler with a ServeMux
+// Registering with the generated Register<Service>HandlerServer calls the service in-process, without a
+// connection to the gRPC port; such calls skip the gRPC server's interceptors and don't support streaming
 type HTTPGatewayRegistrar interface {
 	RegisterGateway(ctx context.Context, mux *runtime.ServeMux) error
 }
@@ -27,9 +38,15 @@ type ServiceRegistrar interface {
 
 // ServerBuilder builds and manages multiple gRPC and HTTP servers
 type ServerBuilder struct {
-	grpcServers map[int]*grpc.Server        // map of grpcPort -> grpc.Server
-	httpServers map[int]*runtime.ServeMux   // map of httpPort -> ServeMux
-	grpcOpts    map[int][]grpc.ServerOption // map of grpcPort -> server options
+	grpcServers map[int]*grpc.Server           // map of grpcPort -> grpc.Server
+	httpServers map[int]*runtime.ServeMux      // map of httpPort -> ServeMux
+	grpcOpts    map[int][]grpc.ServerOption    // map of grpcPort -> server options
+	compression string                         // compressor for responses on all gRPC servers ("" = none)
+	tlsConfig   *tls.Config                    // TLS for ports without their own config (nil = plaintext)
+	portTLS     map[int]*tls.Config            // map of port -> TLS config overriding tlsConfig
+	gatewayDeny []string                       // gRPC methods whose HTTP routes answer 404
+	gateways    map[int][]HTTPGatewayRegistrar // map of httpPort -> gateways registered by RegisterGateways
+	grpcWeb     map[int]grpcWebTarget          // map of httpPort -> gRPC server served as gRPC-Web
 }
 
 // New creates a new ServerBuilder
@@ -38,12 +55,19 @@ func NewServerBuilder() *ServerBuilder {
 		grpcServers: make(map[int]*grpc.Server),
 		httpServers: make(map[int]*runtime.ServeMux),
 		grpcOpts:    make(map[int][]grpc.ServerOption),
+		portTLS:     make(map[int]*tls.Config),
+		grpcWeb:     make(map[int]grpcWebTarget),
+		gateways:    make(map[int][]HTTPGatewayRegistrar),
 	}
 }
 
 // newServeMux creates a new ServeMux with JSON marshaler configured to use proto field names (snake_case)
+// Routes are matched on the escaped path, so a percent-encoded "/" stays inside its path parameter;
+// errors carry the HTTP headers their status details translate to
 func newServeMux() *runtime.ServeMux {
 	return runtime.NewServeMux(
+		runtime.WithUnescapingMode(runtime.UnescapingModeAllExceptReserved),
+		runtime.WithErrorHandler(gatewayErrorHandler),
 		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
 			MarshalOptions: protojson.MarshalOptions{
 				UseProtoNames: true, // Use snake_case field names from proto
@@ -61,36 +85,103 @@ func (sb *ServerBuilder) WithGRPCOptions(grpcPort int, opts ...grpc.ServerOption
 	return sb
 }
 
-// RegisterService registers a service on specified ports
-// Creates gRPC and HTTP servers on the given ports if they don't exist
-func (sb *ServerBuilder) RegisterService(grpcPort, httpPort int, service ServiceRegistrar) *ServerBuilder {
-	log.Printf("RegisterService called with grpcPort=%d httpPort=%d service=%T", grpcPort, httpPort, service)
+// WithStatsHandler installs a gRPC stats handler on a specific port, e.g. the one from ConnMetrics
+// Must be called before registering services on that port
+func (sb *ServerBuilder) WithStatsHandler(grpcPort int, h stats.Handler) *ServerBuilder {
+	return sb.WithGRPCOptions(grpcPort, grpc.StatsHandler(h))
+}
 
-	// Get or create gRPC server for this port
-	grpcServer, exists := sb.grpcServers[grpcPort]
-	if !exists {
-		opts := sb.grpcOpts[grpcPort] // Get port-specific options
-		grpcServer = grpc.NewServer(opts...)
-		sb.grpcServers[grpcPort] = grpcServer
+// WithTLS sets the TLS config for all gRPC and HTTP ports without their own config
+// Must be called before registering services
+func (sb *ServerBuilder) WithTLS(cfg *tls.Config) *ServerBuilder {
+	sb.tlsConfig = cfg
+	return sb
+}
+
+// WithPortTLS sets the TLS config for a single gRPC or HTTP port, overriding the config from WithTLS
+// Must be called before registering services on that port
+func (sb *ServerBuilder) WithPortTLS(port int, cfg *tls.Config) *ServerBuilder {
+	sb.portTLS[port] = cfg
+	return sb
+}
+
+// TLSConfig returns the TLS config for a port, falling back to the config from WithTLS
+// Returns nil if the port serves plaintext
+func (sb *ServerBuilder) TLSConfig(port int) *tls.Config {
+	if cfg, ok := sb.portTLS[port]; ok {
+		return cfg
 	}
+	return sb.tlsConfig
+}
 
-	// Get or create HTTP ServeMux for this port
-	httpMux, exists := sb.httpServers[httpPort]
-	if !exists {
-		httpMux = newServeMux()
-		sb.httpServers[httpPort] = httpMux
+// WithDefaultCompression compresses responses on all gRPC servers with the named compressor, e.g. "gzip"
+// Only applies to calls whose client accepts the compressor; must be called before registering services
+func (sb *ServerBuilder) WithDefaultCompression(name string) *ServerBuilder {
+	if encoding.GetCompressor(name) == nil {
+		log.Printf("Compression disabled: compressor %q is not registered", name)
+		return sb
 	}
+	sb.compression = name
+	return sb
+}
 
-	// Register gRPC service
-	service.RegisterGRPC(grpcServer)
+// serverOptions returns the options for a new gRPC server on a specific port
+func (sb *ServerBuilder) serverOptions(grpcPort int) []grpc.ServerOption {
+	opts := slices.Clone(sb.grpcOpts[grpcPort])
+	// Use gRPC transport credentials so handlers can inspect the peer certificate
+	if cfg := sb.TLSConfig(grpcPort); cfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg)))
+	}
+	if sb.compression != "" {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(compressionInterceptor(sb.compression)),
+			grpc.ChainStreamInterceptor(compressionStreamInterceptor(sb.compression)),
+		)
+	}
+	return opts
+}
 
-	// Register HTTP gateway
-	ctx := context.Background()
-	if err := service.RegisterGateway(ctx, httpMux); err != nil {
-		log.Fatalf("Failed to register gateway: %v", err)
+// compressionInterceptor sets the response compressor when the client advertises support for it
+func compressionInterceptor(name string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		setSendCompressor(ctx, name, info.FullMethod)
+		return handler(ctx, req)
 	}
+}
 
-	return sb
+// compressionStreamInterceptor is compressionInterceptor for streaming RPCs, compressing every sent message
+func compressionStreamInterceptor(name string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		setSendCompressor(ss.Context(), name, info.FullMethod)
+		return handler(srv, ss)
+	}
+}
+
+// setSendCompressor compresses the responses of the call in ctx with name if its client supports it
+func setSendCompressor(ctx context.Context, name, method string) {
+	if supported, err := grpc.ClientSupportedCompressors(ctx); err == nil && slices.Contains(supported, name) {
+		if err := grpc.SetSendCompressor(ctx, name); err != nil {
+			log.Printf("Failed to set %s compressor for %s: %v", name, method, err)
+		}
+	}
+}
+
+// RegisterService registers a service on specified ports
+// Creates gRPC and HTTP servers on the given ports if they don't exist; the gateway is registered by RegisterGateways
+func (sb *ServerBuilder) RegisterService(grpcPort, httpPort int, service ServiceRegistrar) *ServerBuilder {
+	log.Printf("RegisterService called with grpcPort=%d httpPort=%d service=%T", grpcPort, httpPort, service)
+
+	sb.RegisterGRPCService(grpcPort, service)
+	return sb.RegisterGateway(httpPort, service)
+}
+
+// RegisterAdditionalService registers another service on ports that may already serve others
+// Services that also implement HTTPGatewayRegistrar get their HTTP gateway too, others are gRPC only
+func (sb *ServerBuilder) RegisterAdditionalService(grpcPort, httpPort int, service GRPCServiceRegistrar) *ServerBuilder {
+	if withGateway, ok := service.(ServiceRegistrar); ok {
+		return sb.RegisterService(grpcPort, httpPort, withGateway)
+	}
+	return sb.RegisterGRPCService(grpcPort, service)
 }
 
 // RegisterGRPCService registers only a gRPC service on specified port
@@ -98,7 +189,7 @@ func (sb *ServerBuilder) RegisterGRPCService(grpcPort int, service GRPCServiceRe
 	// Get or create gRPC server for this port
 	grpcServer, exists := sb.grpcServers[grpcPort]
 	if !exists {
-		opts := sb.grpcOpts[grpcPort] // Get port-specific options
+		opts := sb.serverOptions(grpcPort) // Get port-specific options
 		grpcServer = grpc.NewServer(opts...)
 		sb.grpcServers[grpcPort] = grpcServer
 	}
@@ -107,22 +198,53 @@ func (sb *ServerBuilder) RegisterGRPCService(grpcPort int, service GRPCServiceRe
 	return sb
 }
 
-// RegisterGateway registers only an HTTP gateway service on specified port
+// RegisterGateway adds an HTTP gateway service on specified port
+// The gateway is collected and registered on the ServeMux by RegisterGateways
 func (sb *ServerBuilder) RegisterGateway(httpPort int, service HTTPGatewayRegistrar) *ServerBuilder {
 	// Get or create HTTP ServeMux for this port
-	httpMux, exists := sb.httpServers[httpPort]
-	if !exists {
-		httpMux = newServeMux()
-		sb.httpServers[httpPort] = httpMux
+	if _, exists := sb.httpServers[httpPort]; !exists {
+		sb.httpServers[httpPort] = newServeMux()
 	}
 
-	ctx := context.Background()
-	if err := service.RegisterGateway(ctx, httpMux); err != nil {
-		log.Fatalf("Failed to register gateway: %v", err)
-	}
+	sb.gateways[httpPort] = append(sb.gateways[httpPort], service)
 	return sb
 }
 
+// RegisterGateways registers all collected gateways on their ServeMux, then masks methods denied by WithGatewayMethodFilter
+// Registration is all or nothing: if any gateway fails, every port gets an empty ServeMux and the joined errors are returned
+func (sb *ServerBuilder) RegisterGateways(ctx context.Context) error {
+	ports := slices.Sorted(maps.Keys(sb.gateways))
+
+	var errs []error
+	for _, httpPort := range ports {
+		mux := sb.httpServers[httpPort]
+		for _, service := range sb.gateways[httpPort] {
+			if err := service.RegisterGateway(ctx, mux); err != nil {
+				errs = append(errs, fmt.Errorf("failed to register gateway %T on HTTP port %d: %w", service, httpPort, err))
+			}
+		}
+		if err := sb.maskDeniedMethods(mux); err != nil {
+			errs = append(errs, fmt.Errorf("failed to register gateway on HTTP port %d: %w", httpPort, err))
+		}
+	}
+	clear(sb.gateways)
+
+	if len(errs) > 0 {
+		// Drop the partially registered gateways so nothing is served half-configured
+		for _, httpPort := range ports {
+			sb.httpServers[httpPort] = newServeMux()
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// HTTPMux returns the HTTP ServeMux for a specific port
+// Returns nil if no gateway exists on that port
+func (sb *ServerBuilder) HTTPMux(httpPort int) *runtime.ServeMux {
+	return sb.httpServers[httpPort]
+}
+
 // GRPCServer returns the underlying gRPC server for a specific port
 // Useful for registering additional services like reflection
 // Returns nil if no server exists on that port
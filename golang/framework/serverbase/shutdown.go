@@ -0,0 +1,132 @@
+package serverbase
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Listener kinds reported in a ShutdownReport
+const (
+	ListenerGRPC    = "gRPC"
+	ListenerHTTP    = "HTTP"
+	ListenerHealth  = "health"
+	ListenerMetrics = "metrics"
+)
+
+// listenerOrder sorts a ShutdownReport by kind, then port
+var listenerOrder = map[string]int{ListenerGRPC: 0, ListenerHTTP: 1, ListenerHealth: 2, ListenerMetrics: 3}
+
+// ListenerStop records how one server stopped during shutdown
+type ListenerStop struct {
+	Kind     string        // ListenerGRPC, ListenerHTTP, ListenerHealth or ListenerMetrics
+	Port     int           // bound port, so also the actual port of servers launched on port 0
+	Duration time.Duration // from the shutdown request until the server stopped
+	Forced   bool          // the graceful stop outlasted the shutdown timeout and open connections were closed
+}
+
+// ShutdownReport lists how long every started server took to stop
+// Use it to size terminationGracePeriodSeconds above the slowest graceful stop
+type ShutdownReport struct {
+	Listeners []ListenerStop
+}
+
+// Duration returns how long the slowest server took to stop; servers stop concurrently
+func (r ShutdownReport) Duration() time.Duration {
+	var longest time.Duration
+	for _, stop := range r.Listeners {
+		longest = max(longest, stop.Duration)
+	}
+	return longest
+}
+
+// Forced reports whether any server was stopped forcefully
+func (r ShutdownReport) Forced() bool {
+	return slices.ContainsFunc(r.Listeners, func(stop ListenerStop) bool { return stop.Forced })
+}
+
+// String formats the report for logging, e.g. "gRPC :25000 in 1.2ms, HTTP :26000 in 5s (forced); total 5s"
+func (r ShutdownReport) String() string {
+	stops := make([]string, len(r.Listeners))
+	for i, stop := range r.Listeners {
+		stops[i] = fmt.Sprintf("%s :%d in %s", stop.Kind, stop.Port, stop.Duration)
+		if stop.Forced {
+			stops[i] += " (forced)"
+		}
+	}
+	return fmt.Sprintf("%s; total %s", strings.Join(stops, ", "), r.Duration())
+}
+
+// WithShutdownTimeout bounds each server's graceful stop; once it passes, open connections are closed
+// The default of 0 waits for in-flight requests however long they take
+func (s *ServerBase) WithShutdownTimeout(timeout time.Duration) *ServerBase {
+	s.shutdownTimeout = timeout
+	return s
+}
+
+// RegisterCloser adds fn to run once Launch returns, after all servers have stopped, e.g. a database pool's Close
+// Closers run exactly once, the last registered first, so resources close after the servers using them drained
+func (s *ServerBase) RegisterCloser(fn func()) *ServerBase {
+	s.closers = append(s.closers, fn)
+	return s
+}
+
+// runClosers runs the closers added with RegisterCloser, the first time it is called
+func (s *ServerBase) runClosers() {
+	s.closeOnce.Do(func() {
+		for _, fn := range slices.Backward(s.closers) {
+			fn()
+		}
+	})
+}
+
+// ShutdownReport returns how the servers stopped, complete once Launch has returned
+func (s *ServerBase) ShutdownReport() ShutdownReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ShutdownReport{Listeners: slices.Clone(s.stops)}
+}
+
+// stopServer runs graceful and, if it outlasts the shutdown timeout, force, then records the stop
+// graceful must return once force has run
+func (s *ServerBase) stopServer(kind string, port int, graceful func(), force func()) {
+	start := time.Now()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		graceful()
+	}()
+
+	forced := false
+	if s.shutdownTimeout > 0 {
+		timer := time.NewTimer(s.shutdownTimeout)
+		defer timer.Stop()
+		select {
+		case <-stopped:
+		case <-timer.C:
+			forced = true
+			force()
+			<-stopped
+		}
+	} else {
+		<-stopped
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stops = append(s.stops, ListenerStop{Kind: kind, Port: port, Duration: time.Since(start), Forced: forced})
+	slices.SortFunc(s.stops, func(a, b ListenerStop) int {
+		return cmp.Or(cmp.Compare(listenerOrder[a.Kind], listenerOrder[b.Kind]), cmp.Compare(a.Port, b.Port))
+	})
+}
+
+// boundPort returns the port lis is bound to, or 0 for non-TCP listeners
+func boundPort(lis net.Listener) int {
+	if addr, ok := lis.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
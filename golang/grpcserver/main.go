@@ -2,16 +2,32 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/berendjan/golang-bazel-starter/golang/config/api"
 	"github.com/berendjan/golang-bazel-starter/golang/config/repository"
 	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
 	"github.com/berendjan/golang-bazel-starter/golang/framework/serverbase"
 	"github.com/berendjan/golang-bazel-starter/golang/grpcserver/messenger"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/audit"
 	"github.com/berendjan/golang-bazel-starter/golang/middleware/auth"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/dedup"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/logging"
 	"github.com/berendjan/golang-bazel-starter/golang/middleware/middleone"
 	"github.com/berendjan/golang-bazel-starter/golang/middleware/middletwo"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/tenant"
+	gw "github.com/berendjan/golang-bazel-starter/proto/configuration_service/v1/gateway"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 )
 
 type GrpcServer struct {
@@ -21,18 +37,52 @@ type GrpcServer struct {
 }
 
 func (g *GrpcServer) Register(sb *serverbase.ServerBuilder, grpcPort, httpPort int) error {
+	// Compress responses for clients that accept gzip, e.g. large account listings
+	sb.WithDefaultCompression("gzip")
+
 	// Register the AccountApi first (creates mux with proper marshaler options)
 	sb.RegisterService(grpcPort, httpPort, g.accountApi)
 	return nil
 }
 
-func NewGrpcServer(messenger *messenger.GrpcMessenger) *GrpcServer {
+// Limits on incoming metadata; legitimate callers send a tenant, a session cookie and transport keys
+const (
+	maxMetadataBytes = 8 << 10
+	maxMetadataKeys  = 64
+)
+
+// Identical requests from the same caller within dedupWindow are counted as duplicates, e.g. client retries
+// Deletes are idempotent, so a duplicate delete gets the first delete's response instead of NotFound;
+// any other request of the tenant in between, e.g. re-creating the account, makes the duplicate run again
+const (
+	dedupWindow         = 2 * time.Second
+	deleteAccountMethod = "/configuration_service.v1.Configuration/DeleteAccount"
+)
+
+// NewGrpcServer creates the gRPC server, recording its metrics with meterProvider
+func NewGrpcServer(messenger *messenger.GrpcMessenger, meterProvider metric.MeterProvider) *GrpcServer {
 	// Create API with messenger as the sendable interface
 	accountApi := api.NewConfigurationApi(messenger)
 
-	// Create gRPC server
+	// Create gRPC server that logs every RPC, rejects oversized metadata, resolves the caller's tenant
+	// and detects duplicate requests before any handler runs, recording duplicates and connection metrics with meterProvider
+	// Streams get the same logging, limits and tenant; duplicate detection only applies to unary calls
+	// The in-process HTTP gateway skips the interceptors, so it resolves the tenant itself
 	grpcServer := &GrpcServer{
-		ServerBase: serverbase.NewServerBase(),
+		ServerBase: serverbase.NewServerBase().WithUnaryInterceptor(
+			logging.UnaryServerInterceptor(),
+			serverbase.MetadataLimitUnaryInterceptor(maxMetadataBytes, maxMetadataKeys),
+			tenant.UnaryServerInterceptor(),
+			dedup.NewDetector(dedupWindow).
+				WithShortCircuit(deleteAccountMethod).
+				WithMeterProvider(meterProvider).
+				UnaryServerInterceptor(),
+		).WithStreamInterceptor(
+			logging.StreamServerInterceptor(),
+			serverbase.MetadataLimitStreamInterceptor(maxMetadataBytes, maxMetadataKeys),
+			tenant.StreamServerInterceptor(),
+		).WithStatsHandler(serverbase.NewConnMetrics().WithMeterProvider(meterProvider).StatsHandler()).
+			WithHTTPMiddleware(tenant.HTTPMiddleware),
 		accountApi: accountApi,
 		messenger:  messenger,
 	}
@@ -41,43 +91,164 @@ func NewGrpcServer(messenger *messenger.GrpcMessenger) *GrpcServer {
 	return grpcServer
 }
 
-func createMessenger() *messenger.GrpcMessenger {
-	// Initialize database pool
-	pool := db.MustNewPool(context.Background(), db.DefaultConfig(repository.DbName))
+// dbReadinessInterval is how often a degraded server pings its database to report readiness
+const dbReadinessInterval = time.Second
+
+// WithDegradedMode keeps serving account listings cached for cacheTTL while pool is unreachable
+// The server reports NOT_SERVING while pool fails its ping; writes fail with Unavailable either way
+func (g *GrpcServer) WithDegradedMode(cacheTTL time.Duration, pool *db.DBPool) *GrpcServer {
+	g.accountApi.WithDegradedMode(cacheTTL)
+	g.ServerBase.WithReadinessCheck(dbReadinessInterval, pool.Ping)
+	return g
+}
+
+// WithIdempotentDeletes reports deleting a missing account as success instead of NotFound
+func (g *GrpcServer) WithIdempotentDeletes() *GrpcServer {
+	g.accountApi.WithIdempotentDeletes()
+	return g
+}
+
+// assertSchemaEnv makes the server refuse to start unless the database is at repository.SchemaVersion, e.g. "true"
+const assertSchemaEnv = "DB_ASSERT_SCHEMA_VERSION"
+
+// createMessenger returns the messenger and the database pools it queries, which the caller closes
+func createMessenger() (*messenger.GrpcMessenger, *db.Registry) {
+	// Initialize database pools, registered by database name
+	// Outside the cluster the DB_* variables of db.ConfigFromEnv point at a local database
+	pools := db.NewRegistry()
+	dbConfig, err := db.ConfigFromEnv(repository.DbName)
+	if err != nil {
+		log.Fatalf("Failed to configure database: %v", err)
+	}
+	if err := pools.Register(repository.DbName, db.MustNewPool(context.Background(), dbConfig)); err != nil {
+		log.Fatalf("Failed to register database pool: %v", err)
+	}
+	pool := pools.MustGet(repository.DbName)
+
+	// Fail fast on a database migrated for another release instead of failing queries later
+	if value := os.Getenv(assertSchemaEnv); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("Failed to parse %s=%q: %v", assertSchemaEnv, value, err)
+		}
+		if enabled {
+			if err := pool.AssertSchemaVersion(context.Background(), repository.SchemaVersion); err != nil {
+				log.Fatalf("Failed to verify database schema: %v", err)
+			}
+		}
+	}
 
-	// Create repository
+	// Create repositories
 	accountRepo := repository.NewAccountRepository(pool)
+	auditRepo := repository.NewAuditRepository(pool)
 
-	// Create auth middleware (Kratos public API)
-	authMiddleware := auth.NewAuthMiddleware("http://kratos.app-namespace.svc.cluster.local:4433")
+	// Create auth middleware (Kratos public API), riding out brief Kratos outages
+	authMiddleware := auth.NewAuthMiddleware("http://kratos.app-namespace.svc.cluster.local:4433").
+		WithValidationRetries(2, 100*time.Millisecond)
 
 	// Create middleware chain
 	middlewareOne := middleone.NewMiddleOne(authMiddleware)
 	middlewareTwo := &middletwo.MiddleTwo{}
+	auditMiddleware := audit.NewAuditMiddleware(auditRepo)
 
 	// Create messenger with all dependencies
 	grpcMessenger := messenger.NewGrpcMessenger(
 		accountRepo,
 		middlewareOne,
 		middlewareTwo,
+		auditMiddleware,
 	)
-	return grpcMessenger
+	return grpcMessenger, pools
+}
+
+// newMeterProvider creates a meter provider whose metrics are served by promhttp.Handler
+func newMeterProvider() (*sdkmetric.MeterProvider, error) {
+	exporter, err := prometheus.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Prometheus exporter: %w", err)
+	}
+	return sdkmetric.NewMeterProvider(sdkmetric.WithReader(exporter)), nil
+}
+
+// gatewayUpstreamEnv runs the server as a gateway-only pod proxying to the gRPC server at its address, e.g. "grpcserver:25000"
+const gatewayUpstreamEnv = "GATEWAY_UPSTREAM"
+
+// newRemoteGateway creates a server serving only the HTTP gateway, dialing upstream with mTLS
+// The server certificate doubles as the client certificate, the CA verifies the upstream
+func newRemoteGateway(upstream, certFile, keyFile, caFile string) (*serverbase.ServerBase, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate from %s and %s: %w", certFile, keyFile, err)
+	}
+	caCert, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file %s: %w", caFile, err)
+	}
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("failed to parse CA certificate from %s", caFile)
+	}
+
+	server := serverbase.NewServerBase()
+	server.ServerInterface = serverbase.NewRemoteGateway(upstream, gw.RegisterConfigurationHandlerFromEndpoint).
+		WithTLS(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+			RootCAs:      caCertPool,
+			MinVersion:   tls.VersionTLS12,
+		})
+	return server, nil
 }
 
 func main() {
+	// Emit all logs as JSON
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+
 	// TLS certificate and key files
 	certFile := "/mnt/server-certs/tls.crt"
 	keyFile := "/mnt/server-certs/tls.key"
 	caFile := "/mnt/server-certs/ca.crt"
 
+	// A gateway pod fronts a separate gRPC deployment, so both scale independently
+	if upstream := os.Getenv(gatewayUpstreamEnv); upstream != "" {
+		gateway, err := newRemoteGateway(upstream, certFile, keyFile, caFile)
+		if err != nil {
+			log.Fatalf("Failed to configure gateway: %v", err)
+		}
+		gateway.WithTLS(certFile, keyFile).
+			WithClientCA(caFile).
+			WithHealthPort(27000)
+		log.Printf("Starting HTTP gateway for gRPC server %s", upstream)
+
+		if err := gateway.LaunchWithDefaultPorts(); err != nil {
+			log.Fatalf("Failed to launch gateway: %v", err)
+		}
+		return
+	}
+
+	meterProvider, err := newMeterProvider()
+	if err != nil {
+		log.Fatalf("Failed to configure metrics: %v", err)
+	}
+
 	// Create and launch gRPC server with mTLS
-	// Health port 27000 is non-TLS for Kubernetes probes
-	grpcServer := NewGrpcServer(createMessenger()).
+	// Health port 27000 is non-TLS for Kubernetes probes, metrics port 28000 is non-TLS for Prometheus scrapes
+	// The database pools close once the servers have drained
+	grpcMessenger, pools := createMessenger()
+	grpcServer := NewGrpcServer(grpcMessenger, meterProvider).
 		WithTLS(certFile, keyFile).
 		WithClientCA(caFile).
-		WithHealthPort(27000)
+		WithHealthPort(27000).
+		WithMetricsPort(28000, promhttp.Handler()).
+		RegisterCloser(pools.Close).
+		RegisterCloser(func() {
+			if err := meterProvider.Shutdown(context.Background()); err != nil {
+				log.Printf("Failed to shut down meter provider: %v", err)
+			}
+		})
 	log.Println("Starting gRPC server with messenger")
 
 	// Launch server
-	grpcServer.LaunchWithDefaultPorts()
+	if err := grpcServer.LaunchWithDefaultPorts(); err != nil {
+		log.Fatalf("Failed to launch gRPC server: %v", err)
+	}
 }
@@ -0,0 +1,144 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+	"github.com/berendjan/golang-bazel-starter/golang/config/repository"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/auth"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/tenant"
+
+	geninterfaces "github.com/berendjan/golang-bazel-starter/golang/generated/interfaces"
+	commonpb "github.com/berendjan/golang-bazel-starter/proto/common/v1"
+	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
+)
+
+const (
+	// resultSuccess is recorded for mutations that completed without error
+	resultSuccess = "success"
+)
+
+// AuditMiddleware records an audit entry after every successful mutating request
+type AuditMiddleware struct {
+	auditRepo *repository.AuditDbRepository
+}
+
+// Compile-time check that AuditMiddleware implements AuditMiddlewareInterface
+var _ geninterfaces.AuditMiddlewareInterface = (*AuditMiddleware)(nil)
+
+// NewAuditMiddleware creates a new AuditMiddleware
+func NewAuditMiddleware(auditRepo *repository.AuditDbRepository) *AuditMiddleware {
+	return &AuditMiddleware{
+		auditRepo: auditRepo,
+	}
+}
+
+// HandleMiddleOneRequest forwards the account creation and audits it on success
+// Both writes share a transaction, so an account is never created without its audit entry
+func (m *AuditMiddleware) HandleMiddleOneRequest(ctx context.Context, req *configpb.MiddleOneRequestProto, next geninterfaces.AuditMiddlewareSendable) (*configpb.AccountConfigurationProto, error) {
+	var result *configpb.AccountConfigurationProto
+	err := m.auditRepo.RunInTx(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = next.SendMiddleOneRequestFromAuditMiddleware(ctx, req)
+		if err != nil {
+			return err
+		}
+		return m.record(ctx, "CreateAccount", ids.AccountIDFromProto(result.GetAccountId()))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// HandleAccountDeletionRequest forwards the account deletion and audits it on success
+// Both writes share a transaction, so an account is never deleted without its audit entry
+func (m *AuditMiddleware) HandleAccountDeletionRequest(ctx context.Context, req *configpb.AccountDeletionRequestProto, next geninterfaces.AuditMiddlewareSendable) (*commonpb.StatusResponseProto, error) {
+	var result *commonpb.StatusResponseProto
+	err := m.auditRepo.RunInTx(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = next.SendAccountDeletionRequestFromAuditMiddleware(ctx, req)
+		if err != nil {
+			return err
+		}
+		accountID, err := ids.ParseAccountID(req.GetId())
+		if err != nil {
+			return err
+		}
+		return m.record(ctx, "DeleteAccount", accountID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// HandleAccountUpdateRequest forwards the account update and audits it on success
+// Both writes share a transaction, so an account is never updated without its audit entry
+func (m *AuditMiddleware) HandleAccountUpdateRequest(ctx context.Context, req *configpb.AccountUpdateRequestProto, next geninterfaces.AuditMiddlewareSendable) (*configpb.AccountConfigurationProto, error) {
+	var result *configpb.AccountConfigurationProto
+	err := m.auditRepo.RunInTx(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = next.SendAccountUpdateRequestFromAuditMiddleware(ctx, req)
+		if err != nil {
+			return err
+		}
+		return m.record(ctx, "UpdateAccount", ids.AccountIDFromProto(result.GetAccountId()))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// HandleRequestToJoinGroup forwards the join request and audits it against the group on success
+// Both writes share a transaction, so a join request is never stored without its audit entry
+func (m *AuditMiddleware) HandleRequestToJoinGroup(ctx context.Context, req *configpb.RequestToJoinGroupProto, next geninterfaces.AuditMiddlewareSendable) error {
+	return m.auditRepo.RunInTx(ctx, func(ctx context.Context) error {
+		if err := next.SendRequestToJoinGroupFromAuditMiddleware(ctx, req); err != nil {
+			return err
+		}
+		return m.record(ctx, "RequestToJoinGroup", ids.AccountIDFromProto(req.GetGroupId()))
+	})
+}
+
+// HandleAcceptRequestToJoinGroup forwards the acceptance and audits it against the group on success
+// Both writes share a transaction, so an acceptance is never stored without its audit entry
+func (m *AuditMiddleware) HandleAcceptRequestToJoinGroup(ctx context.Context, req *configpb.AcceptRequestToJoinGroupProto, next geninterfaces.AuditMiddlewareSendable) error {
+	return m.auditRepo.RunInTx(ctx, func(ctx context.Context) error {
+		if err := next.SendAcceptRequestToJoinGroupFromAuditMiddleware(ctx, req); err != nil {
+			return err
+		}
+		return m.record(ctx, "AcceptRequestToJoinGroup", ids.AccountIDFromProto(req.GetGroupId()))
+	})
+}
+
+// ErrNoUser is returned for a mutation whose context carries no authenticated user
+// Its transaction rolls back: a mutation is never stored without the user who made it
+var ErrNoUser = errors.New("no authenticated user to audit")
+
+// record writes the audit entry for a successful mutation by the user in the context
+func (m *AuditMiddleware) record(ctx context.Context, method string, targetID ids.AccountID) error {
+	userID := auth.UserIDFromContext(ctx)
+	if userID == "" {
+		log.Printf("Audit: refusing to record %s of %s without a user", method, targetID)
+		return fmt.Errorf("failed to audit %s of %s: %w", method, targetID, ErrNoUser)
+	}
+
+	entry := repository.AuditEntry{
+		TenantID: tenant.TenantIDFromContext(ctx),
+		UserID:   userID,
+		Method:   method,
+		TargetID: targetID.Bytes(),
+		Result:   resultSuccess,
+	}
+
+	if err := m.auditRepo.Record(ctx, entry); err != nil {
+		log.Printf("Audit: failed to record %s of %s by user %q: %v", method, targetID, entry.UserID, err)
+		return err
+	}
+	return nil
+}
@@ -3,12 +3,13 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"runtime"
 	"strings"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -27,32 +28,34 @@ type KratosIdentity struct {
 	ID string `json:"id"`
 }
 
+// Validator resolves the authenticated user of a request
+// AuthMiddleware validates the Kratos session; tests inject a validator that skips Kratos
+type Validator interface {
+	ExtractUserID(ctx context.Context) (string, error)
+}
+
+// Compile-time check that AuthMiddleware implements Validator
+var _ Validator = (*AuthMiddleware)(nil)
+
 // AuthMiddleware validates Kratos sessions and extracts user IDs
 type AuthMiddleware struct {
 	kratosURL  string
 	httpClient *http.Client
+	retries    int           // extra Kratos calls after a transient failure
+	backoff    time.Duration // wait before the first retry, doubled for every further retry
 }
 
-// isRunningInTest checks if the code is being called from a Go test
-// by inspecting the call stack for test-related function names
-func isRunningInTest() bool {
-	var pcs [32]uintptr
-	n := runtime.Callers(0, pcs[:])
-	frames := runtime.CallersFrames(pcs[:n])
-
-	for {
-		frame, more := frames.Next()
-		// Check if the function name contains "testing" package calls
-		// or if it starts with "Test" (test function names)
-		if strings.Contains(frame.Function, "testing.") ||
-			strings.Contains(frame.File, "_test.go") {
-			return true
-		}
-		if !more {
-			break
-		}
-	}
-	return false
+// transientError is a Kratos failure worth retrying, e.g. a 5xx response or a refused connection
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
 }
 
 // NewAuthMiddleware creates a new auth middleware
@@ -64,14 +67,18 @@ func NewAuthMiddleware(kratosURL string) *AuthMiddleware {
 	}
 }
 
+// WithValidationRetries retries session validation up to n more times when Kratos fails transiently
+// (5xx responses and connection errors), waiting backoff before the first retry and doubling it after each
+// A 401 or any other client error is never retried
+func (m *AuthMiddleware) WithValidationRetries(n int, backoff time.Duration) *AuthMiddleware {
+	m.retries = n
+	m.backoff = backoff
+	return m
+}
+
 // ExtractUserID extracts and validates the user ID from the request context
 // Returns the user ID or an error if authentication fails
 func (m *AuthMiddleware) ExtractUserID(ctx context.Context) (string, error) {
-	// Skip auth validation in tests
-	if isRunningInTest() {
-		return "test-user", nil
-	}
-
 	// Get cookies from gRPC metadata (forwarded by grpc-gateway)
 	cookie, err := m.extractCookie(ctx)
 	if err != nil {
@@ -120,8 +127,30 @@ func (m *AuthMiddleware) extractCookie(ctx context.Context) (string, error) {
 	return strings.Join(cookies, "; "), nil
 }
 
-// validateSession calls Kratos to validate the session
+// validateSession calls Kratos to validate the session, retrying transient failures within the retry budget
 func (m *AuthMiddleware) validateSession(ctx context.Context, cookie string) (*KratosSession, error) {
+	backoff := m.backoff
+	for attempt := 1; ; attempt++ {
+		session, err := m.whoami(ctx, cookie)
+
+		var transient *transientError
+		if err == nil || !errors.As(err, &transient) || attempt > m.retries {
+			return session, err
+		}
+
+		log.Printf("Auth: Kratos call %d of %d failed, retrying in %s: %v", attempt, m.retries+1, backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to call Kratos: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// whoami makes a single call to Kratos /sessions/whoami
+// Failures worth retrying are returned as *transientError
+func (m *AuthMiddleware) whoami(ctx context.Context, cookie string) (*KratosSession, error) {
 	url := fmt.Sprintf("%s/sessions/whoami", m.kratosURL)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -134,7 +163,11 @@ func (m *AuthMiddleware) validateSession(ctx context.Context, cookie string) (*K
 
 	resp, err := m.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call Kratos: %w", err)
+		err = fmt.Errorf("failed to call Kratos: %w", err)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &transientError{err: err}
 	}
 	defer resp.Body.Close()
 
@@ -144,7 +177,11 @@ func (m *AuthMiddleware) validateSession(ctx context.Context, cookie string) (*K
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Kratos returned status %d: %s", resp.StatusCode, string(body))
+		err := fmt.Errorf("Kratos returned status %d: %s", resp.StatusCode, string(body))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return nil, &transientError{err: err}
+		}
+		return nil, err
 	}
 
 	var session KratosSession
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// kratosMock answers /sessions/whoami with the given status codes in turn, then with an active session
+func kratosMock(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		if call <= len(statuses) {
+			w.WriteHeader(statuses[call-1])
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "session-1", "active": true, "identity": {"id": "user-1"}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestValidateSessionRetriesTransientFailures(t *testing.T) {
+	server, calls := kratosMock(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	m := NewAuthMiddleware(server.URL).WithValidationRetries(3, time.Millisecond)
+
+	session, err := m.validateSession(context.Background(), "ory_kratos_session=abc")
+	if err != nil {
+		t.Fatalf("Expected the session to resolve after retries, got: %v", err)
+	}
+	if session.Identity.ID != "user-1" {
+		t.Fatalf("Unexpected session: %+v", session)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("Expected 3 Kratos calls, got %d", got)
+	}
+}
+
+func TestValidateSessionDoesNotRetryUnauthorized(t *testing.T) {
+	server, calls := kratosMock(t, http.StatusUnauthorized)
+	m := NewAuthMiddleware(server.URL).WithValidationRetries(3, time.Millisecond)
+
+	if _, err := m.validateSession(context.Background(), "ory_kratos_session=abc"); err == nil {
+		t.Fatal("Expected a 401 to fail validation")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("Expected a single Kratos call for a 401, got %d", got)
+	}
+}
+
+func TestValidateSessionStopsWhenRetriesAreExhausted(t *testing.T) {
+	server, calls := kratosMock(t, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	m := NewAuthMiddleware(server.URL).WithValidationRetries(1, time.Millisecond)
+
+	if _, err := m.validateSession(context.Background(), "ory_kratos_session=abc"); err == nil {
+		t.Fatal("Expected validation to fail once retries are exhausted")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("Expected 2 Kratos calls with one retry, got %d", got)
+	}
+
+	// Without a retry budget a refused connection fails right away
+	server.Close()
+	if _, err := NewAuthMiddleware(server.URL).validateSession(context.Background(), "ory_kratos_session=abc"); err == nil {
+		t.Fatal("Expected an unreachable Kratos to fail validation")
+	}
+}
+
+func TestExtractUserIDRequiresSessionCookie(t *testing.T) {
+	server, calls := kratosMock(t)
+	m := NewAuthMiddleware(server.URL)
+
+	// Running under go test no longer bypasses authentication
+	_, err := m.ExtractUserID(metadata.NewIncomingContext(context.Background(), metadata.MD{}))
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Expected Unauthenticated without a session cookie, got: %v", err)
+	}
+	if got := calls.Load(); got != 0 {
+		t.Fatalf("Expected no Kratos calls without a cookie, got %d", got)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("cookie", "ory_kratos_session=abc"))
+	userID, err := m.ExtractUserID(ctx)
+	if err != nil {
+		t.Fatalf("Expected the session to resolve, got: %v", err)
+	}
+	if userID != "user-1" {
+		t.Fatalf("Expected user-1, got %q", userID)
+	}
+}
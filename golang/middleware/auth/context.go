@@ -1,6 +1,9 @@
 package auth
 
-import "context"
+import (
+	"context"
+	"sync"
+)
 
 // contextKey is a custom type for context keys to avoid collisions
 type contextKey string
@@ -8,10 +11,35 @@ type contextKey string
 const (
 	// userIDKey is the context key for storing the user ID
 	userIDKey contextKey = "user_id"
+
+	// userIDRecorderKey is the context key for storing the user ID recorder
+	userIDRecorderKey contextKey = "user_id_recorder"
 )
 
+// userIDRecorder captures the user ID set further down the handler chain
+type userIDRecorder struct {
+	mu     sync.Mutex
+	userID string
+}
+
+// WithUserIDRecorder returns a context in which WithUserID also reports the user ID back to the caller
+// Interceptors use this to learn which user the handlers they wrap authenticated
+func WithUserIDRecorder(ctx context.Context) (context.Context, func() string) {
+	recorder := &userIDRecorder{}
+	return context.WithValue(ctx, userIDRecorderKey, recorder), func() string {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		return recorder.userID
+	}
+}
+
 // WithUserID returns a new context with the user ID set
 func WithUserID(ctx context.Context, userID string) context.Context {
+	if recorder, ok := ctx.Value(userIDRecorderKey).(*userIDRecorder); ok {
+		recorder.mu.Lock()
+		recorder.userID = userID
+		recorder.mu.Unlock()
+	}
 	return context.WithValue(ctx, userIDKey, userID)
 }
 
@@ -0,0 +1,206 @@
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/clock"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/tenant"
+)
+
+const (
+	// meterName is the instrumentation scope of the duplicate request counter
+	meterName = "github.com/berendjan/golang-bazel-starter/golang/middleware/dedup"
+
+	// DuplicateRequestsMetric counts requests repeating an earlier request within the window
+	DuplicateRequestsMetric = "rpc.server.duplicate_requests"
+)
+
+// Detector counts exact duplicate requests within a window, exposing them as the DuplicateRequestsMetric counter
+// Exact duplicates of methods registered with WithShortCircuit get the first request's response instead of running again,
+// unless the tenant sent another request in between, e.g. re-creating a deleted account
+type Detector struct {
+	window        time.Duration
+	shortCircuit  map[string]bool
+	meterProvider metric.MeterProvider
+	clock         clock.Clock
+
+	mu        sync.Mutex
+	seen      map[[sha256.Size]byte]*request
+	latest    map[string]*request // the request of each tenant whose handler ran last
+	nextSweep time.Time
+}
+
+// request is the first request seen with a key; done is closed once its handler returned
+type request struct {
+	expires time.Time
+	done    chan struct{}
+	resp    any
+	err     error
+}
+
+// NewDetector creates a detector treating identical requests less than window apart as duplicates
+func NewDetector(window time.Duration) *Detector {
+	return &Detector{
+		window:       window,
+		shortCircuit: make(map[string]bool),
+		clock:        clock.Real,
+		seen:         make(map[[sha256.Size]byte]*request),
+		latest:       make(map[string]*request),
+	}
+}
+
+// WithClock measures the window by c instead of the system time
+func (d *Detector) WithClock(c clock.Clock) *Detector {
+	d.clock = c
+	return d
+}
+
+// WithShortCircuit replays the response of the first request to exact duplicates of the given gRPC methods
+// Only register idempotent mutations; a duplicate of a failed request, or one following another request
+// of the same tenant, runs normally. The detector can't tell reads from writes, so any request counts
+func (d *Detector) WithShortCircuit(methods ...string) *Detector {
+	for _, method := range methods {
+		d.shortCircuit[method] = true
+	}
+	return d
+}
+
+// WithMeterProvider records the duplicate counter with mp instead of the global meter provider
+func (d *Detector) WithMeterProvider(mp metric.MeterProvider) *Detector {
+	d.meterProvider = mp
+	return d
+}
+
+// UnaryServerInterceptor counts and optionally short-circuits duplicate requests
+// Must run after tenant.UnaryServerInterceptor: the caller is identified by tenant and session cookie,
+// since users are only authenticated later by the handlers
+func (d *Detector) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	mp := d.meterProvider
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	duplicates, err := mp.Meter(meterName).Int64Counter(DuplicateRequestsMetric,
+		metric.WithDescription("Requests repeating an identical request from the same caller within the deduplication window"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		// The returned counter is a no-op, requests are still served
+		log.Printf("Failed to create %s counter: %v", DuplicateRequestsMetric, err)
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		tenantID := tenant.TenantIDFromContext(ctx)
+		key, ok := requestKey(ctx, tenantID, info.FullMethod, req)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		first, isNew, replayable := d.observe(key, tenantID, d.shortCircuit[info.FullMethod])
+		if isNew {
+			defer close(first.done)
+			first.resp, first.err = handler(ctx, req)
+			return first.resp, first.err
+		}
+
+		var replay proto.Message
+		if replayable {
+			select {
+			case <-first.done:
+			case <-ctx.Done():
+				return nil, status.FromContextError(ctx.Err()).Err()
+			}
+			if resp, ok := first.resp.(proto.Message); ok && first.err == nil {
+				replay = resp
+			}
+		}
+
+		duplicates.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("rpc.method", info.FullMethod),
+			attribute.Bool("short_circuited", replay != nil),
+		))
+		if replay != nil {
+			return proto.Clone(replay), nil
+		}
+		return handler(ctx, req)
+	}
+}
+
+// observe returns the unexpired request with key, or records a new one, reporting whether it is new
+// A duplicate is replayable if shortCircuit is set and no other request of the tenant ran since the first;
+// otherwise its handler runs, so it becomes the tenant's latest request
+func (d *Detector) observe(key [sha256.Size]byte, tenantID string, shortCircuit bool) (*request, bool, bool) {
+	now := d.clock.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Drop expired requests at most once per window, so the map holds about one window of requests
+	if now.After(d.nextSweep) {
+		for k, r := range d.seen {
+			if now.After(r.expires) {
+				delete(d.seen, k)
+			}
+		}
+		for t, r := range d.latest {
+			if now.After(r.expires) {
+				delete(d.latest, t)
+			}
+		}
+		d.nextSweep = now.Add(d.window)
+	}
+
+	if r, ok := d.seen[key]; ok && now.Before(r.expires) {
+		if shortCircuit && d.latest[tenantID] == r {
+			return r, false, true
+		}
+		d.latest[tenantID] = &request{expires: now.Add(d.window)}
+		return r, false, false
+	}
+	r := &request{
+		expires: now.Add(d.window),
+		done:    make(chan struct{}),
+	}
+	d.seen[key] = r
+	d.latest[tenantID] = r
+	return r, true, false
+}
+
+// requestKey hashes the method, the caller of tenantID and the request; requests that aren't protos are never duplicates
+func requestKey(ctx context.Context, tenantID, method string, req any) ([sha256.Size]byte, bool) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return [sha256.Size]byte{}, false
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return [sha256.Size]byte{}, false
+	}
+
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(tenantID))
+	h.Write([]byte{0})
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, cookie := range md.Get("cookie") {
+		h.Write([]byte(cookie))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key, true
+}
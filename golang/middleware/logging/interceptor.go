@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/auth"
+)
+
+// UnaryServerInterceptor logs one structured line per RPC through slog.Default()
+// The authenticated user is included as user_id when a handler sets it with auth.WithUserID
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, userID := auth.WithUserIDRecorder(ctx)
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		logRPC(ctx, info.FullMethod, start, userID(), err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor logs one structured line per stream through slog.Default() once it ends
+// Its fields are those of UnaryServerInterceptor, with the duration covering the whole stream
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, userID := auth.WithUserIDRecorder(ss.Context())
+		start := time.Now()
+
+		err := handler(srv, contextStream{ServerStream: ss, ctx: ctx})
+
+		logRPC(ctx, info.FullMethod, start, userID(), err)
+		return err
+	}
+}
+
+// logRPC logs the outcome of a call to method that started at start
+func logRPC(ctx context.Context, method string, start time.Time, userID string, err error) {
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", status.Code(err).String()),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if userID != "" {
+		attrs = append(attrs, slog.String("user_id", userID))
+	}
+
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	slog.Default().LogAttrs(ctx, level, "rpc", attrs...)
+}
+
+// contextStream is a ServerStream with a replaced context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -7,44 +7,78 @@ import (
 	"github.com/berendjan/golang-bazel-starter/golang/middleware/auth"
 
 	geninterfaces "github.com/berendjan/golang-bazel-starter/golang/generated/interfaces"
+	commonpb "github.com/berendjan/golang-bazel-starter/proto/common/v1"
 	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
 )
 
 type MiddleOne struct {
-	auth *auth.AuthMiddleware
+	auth auth.Validator
 }
 
 // Compile-time check that MiddleOne implements MiddlewareOneInterface
 var _ geninterfaces.MiddlewareOneInterface = (*MiddleOne)(nil)
 
-// NewMiddleOne creates a new MiddleOne middleware
-func NewMiddleOne(authMiddleware *auth.AuthMiddleware) *MiddleOne {
+// NewMiddleOne creates a new MiddleOne middleware authenticating requests with validator
+// Production passes the Kratos-backed *auth.AuthMiddleware
+func NewMiddleOne(validator auth.Validator) *MiddleOne {
 	return &MiddleOne{
-		auth: authMiddleware,
+		auth: validator,
 	}
 }
 
 // HandleMiddleOneRequest authenticates the user and forwards to the next handler
 func (m *MiddleOne) HandleMiddleOneRequest(ctx context.Context, req *configpb.MiddleOneRequestProto, next geninterfaces.MiddlewareOneSendable) (*configpb.AccountConfigurationProto, error) {
-	// Extract and validate user ID from cookie
-	userID, err := m.auth.ExtractUserID(ctx)
+	ctx, err := m.authenticate(ctx)
 	if err != nil {
-		log.Printf("MiddleOne: Authentication failed: %v", err)
 		return nil, err
 	}
 
-	// Add user ID to context for downstream handlers
-	ctx = auth.WithUserID(ctx, userID)
+	// Forward to next handler with authenticated context; the messenger logs the route
+	return next.SendMiddleOneRequestFromMiddlewareOne(ctx, req)
+}
 
-	log.Printf("MiddleOne: Processing request for user %s: %+v", userID, req)
+// HandleAccountDeletionRequest authenticates the user and forwards to the next handler
+func (m *MiddleOne) HandleAccountDeletionRequest(ctx context.Context, req *configpb.AccountDeletionRequestProto, next geninterfaces.MiddlewareOneSendable) (*commonpb.StatusResponseProto, error) {
+	ctx, err := m.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return next.SendAccountDeletionRequestFromMiddlewareOne(ctx, req)
+}
 
-	// Forward to next handler with authenticated context
-	result, err := next.SendMiddleOneRequestFromMiddlewareOne(ctx, req)
+// HandleAccountUpdateRequest authenticates the user and forwards to the next handler
+func (m *MiddleOne) HandleAccountUpdateRequest(ctx context.Context, req *configpb.AccountUpdateRequestProto, next geninterfaces.MiddlewareOneSendable) (*configpb.AccountConfigurationProto, error) {
+	ctx, err := m.authenticate(ctx)
 	if err != nil {
-		log.Printf("MiddleOne: Request failed for user %s: %v", userID, err)
 		return nil, err
 	}
+	return next.SendAccountUpdateRequestFromMiddlewareOne(ctx, req)
+}
 
-	log.Printf("MiddleOne: Request successful for user %s: %+v", userID, result)
-	return result, nil
+// HandleRequestToJoinGroup authenticates the user and forwards to the next handler
+func (m *MiddleOne) HandleRequestToJoinGroup(ctx context.Context, req *configpb.RequestToJoinGroupProto, next geninterfaces.MiddlewareOneSendable) error {
+	ctx, err := m.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	return next.SendRequestToJoinGroupFromMiddlewareOne(ctx, req)
+}
+
+// HandleAcceptRequestToJoinGroup authenticates the user and forwards to the next handler
+func (m *MiddleOne) HandleAcceptRequestToJoinGroup(ctx context.Context, req *configpb.AcceptRequestToJoinGroupProto, next geninterfaces.MiddlewareOneSendable) error {
+	ctx, err := m.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	return next.SendAcceptRequestToJoinGroupFromMiddlewareOne(ctx, req)
+}
+
+// authenticate extracts and validates the user ID from the cookie, adding it to the context for downstream handlers
+func (m *MiddleOne) authenticate(ctx context.Context) (context.Context, error) {
+	userID, err := m.auth.ExtractUserID(ctx)
+	if err != nil {
+		log.Printf("MiddleOne: Authentication failed: %v", err)
+		return nil, err
+	}
+	return auth.WithUserID(ctx, userID), nil
 }
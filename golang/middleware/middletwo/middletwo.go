@@ -2,7 +2,7 @@ package middletwo
 
 import (
 	"context"
-	"log"
+	"iter"
 
 	geninterfaces "github.com/berendjan/golang-bazel-starter/golang/generated/interfaces"
 	commonpb "github.com/berendjan/golang-bazel-starter/proto/common/v1"
@@ -19,41 +19,58 @@ func NewMiddleTwo() *MiddleTwo {
 	return &MiddleTwo{}
 }
 
-// HandleAccountDeletionRequest logs the message and forwards to the repository
+// HandleAccountDeletionRequest forwards to the next handler; the messenger logs the route
 func (m *MiddleTwo) HandleAccountDeletionRequest(ctx context.Context, req *configpb.AccountDeletionRequestProto, next geninterfaces.MiddlewareTwoSendable) (*commonpb.StatusResponseProto, error) {
-	log.Printf("MiddleTwo: Processing account deletion request: %+v", req)
-
-	// Forward to next handler
-	result, err := next.SendAccountDeletionRequestFromMiddlewareTwo(ctx, req)
+	return next.SendAccountDeletionRequestFromMiddlewareTwo(ctx, req)
+}
 
-	if err != nil {
-		log.Printf("MiddleTwo: Account deletion failed: %v", err)
-		return nil, err
-	}
+// HandleAccountUpdateRequest forwards to the next handler; the messenger logs the route
+func (m *MiddleTwo) HandleAccountUpdateRequest(ctx context.Context, req *configpb.AccountUpdateRequestProto, next geninterfaces.MiddlewareTwoSendable) (*configpb.AccountConfigurationProto, error) {
+	return next.SendAccountUpdateRequestFromMiddlewareTwo(ctx, req)
+}
 
-	log.Printf("MiddleTwo: Account deletion successful: %+v", result)
-	return result, nil
+// HandleGetAccountRequest forwards to the repository; the messenger logs the route
+func (m *MiddleTwo) HandleGetAccountRequest(ctx context.Context, req *configpb.GetAccountRequestProto, next geninterfaces.MiddlewareTwoSendable) (*configpb.AccountConfigurationProto, error) {
+	return next.SendGetAccountRequestFromMiddlewareTwo(ctx, req)
 }
 
-// HandleListAccountsRequest logs the message and forwards to the repository
+// HandleListAccountsRequest forwards to the repository; the messenger logs the route
 func (m *MiddleTwo) HandleListAccountsRequest(ctx context.Context, req *configpb.ListAccountsRequestProto, next geninterfaces.MiddlewareTwoSendable) (*configpb.ListAccountsResponseProto, error) {
-	log.Printf("MiddleTwo: Processing list accounts request: %+v", req)
+	return next.SendListAccountsRequestFromMiddlewareTwo(ctx, req)
+}
+
+// HandleCountAccountsRequest forwards to the repository; the messenger logs the route
+func (m *MiddleTwo) HandleCountAccountsRequest(ctx context.Context, req *configpb.CountAccountsRequestProto, next geninterfaces.MiddlewareTwoSendable) (*configpb.CountAccountsResponseProto, error) {
+	return next.SendCountAccountsRequestFromMiddlewareTwo(ctx, req)
+}
 
-	// Forward to next handler
-	result, err := next.SendListAccountsRequestFromMiddlewareTwo(ctx, req)
+// HandleExportAccountsRequest forwards to the repository; the messenger logs the route
+func (m *MiddleTwo) HandleExportAccountsRequest(ctx context.Context, req *configpb.ExportAccountsRequestProto, next geninterfaces.MiddlewareTwoSendable) (iter.Seq2[[]*configpb.AccountConfigurationProto, error], error) {
+	return next.SendExportAccountsRequestFromMiddlewareTwo(ctx, req)
+}
+
+// HandleSubscribeGroupEvents forwards to the repository; the messenger logs the route
+func (m *MiddleTwo) HandleSubscribeGroupEvents(ctx context.Context, req *configpb.SubscribeGroupEventsProto, next geninterfaces.MiddlewareTwoSendable) (iter.Seq2[*configpb.ConfigurationEventProto, error], error) {
+	return next.SendSubscribeGroupEventsFromMiddlewareTwo(ctx, req)
+}
 
-	if err != nil {
-		log.Printf("MiddleTwo: List accounts failed: %v", err)
-		return nil, err
-	}
+// HandleRequestToJoinGroup forwards to the next handler; the messenger logs the route
+func (m *MiddleTwo) HandleRequestToJoinGroup(ctx context.Context, req *configpb.RequestToJoinGroupProto, next geninterfaces.MiddlewareTwoSendable) error {
+	return next.SendRequestToJoinGroupFromMiddlewareTwo(ctx, req)
+}
+
+// HandleAcceptRequestToJoinGroup forwards to the next handler; the messenger logs the route
+func (m *MiddleTwo) HandleAcceptRequestToJoinGroup(ctx context.Context, req *configpb.AcceptRequestToJoinGroupProto, next geninterfaces.MiddlewareTwoSendable) error {
+	return next.SendAcceptRequestToJoinGroupFromMiddlewareTwo(ctx, req)
+}
 
-	log.Printf("MiddleTwo: List accounts successful: %d accounts found", len(result.GetAccounts()))
-	return result, nil
+// HandleWatchAccountsRequest forwards to the repository; the messenger logs the route
+func (m *MiddleTwo) HandleWatchAccountsRequest(ctx context.Context, req *configpb.WatchAccountsRequestProto, next geninterfaces.MiddlewareTwoSendable) (iter.Seq2[*configpb.AccountChangeProto, error], error) {
+	return next.SendWatchAccountsRequestFromMiddlewareTwo(ctx, req)
 }
 
-// HandleMiddleOneRequest logs and passes through (not the last receiver)
+// HandleMiddleOneRequest passes through (not the last receiver)
 func (m *MiddleTwo) HandleMiddleOneRequest(ctx context.Context, message *configpb.MiddleOneRequestProto, next geninterfaces.MiddlewareTwoSendable) error {
-	log.Printf("MiddleTwo: Processing MiddleOne request in chain: %+v", message)
 	// This is not the last receiver, so just return nil to continue the chain
 	return nil
 }
@@ -0,0 +1,29 @@
+package tenant
+
+import (
+	"context"
+)
+
+// contextKey is a custom type for context keys to avoid collisions
+type contextKey string
+
+const (
+	// tenantIDKey is the context key for storing the tenant ID
+	tenantIDKey contextKey = "tenant_id"
+
+	// MetadataKey is the gRPC metadata key carrying the tenant ID
+	// HTTP clients send it as the "Grpc-Metadata-X-Tenant-Id" header, which grpc-gateway forwards as this key
+	MetadataKey = "x-tenant-id"
+)
+
+// WithTenantID returns a new context with the tenant ID set
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDKey, tenantID)
+}
+
+// TenantIDFromContext extracts the tenant ID set by the interceptors or HTTPMiddleware
+// Unverified metadata is never consulted; returns empty string if not found
+func TenantIDFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantIDKey).(string)
+	return tenantID
+}
@@ -0,0 +1,126 @@
+package tenant
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor resolves the caller's tenant and stores it in the request context
+// The tenant is the CN of the verified mTLS client certificate, or without one the "x-tenant-id" metadata;
+// metadata naming another tenant than the certificate is rejected with PermissionDenied
+// Requests without a tenant are passed through; repositories reject them where a tenant is required
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		tenantID, err := resolveTenantID(tenantIDFromMetadata(ctx), tenantIDFromPeerCertificate(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if tenantID != "" {
+			ctx = WithTenantID(ctx, tenantID)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor resolves the caller's tenant of streams like UnaryServerInterceptor
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		tenantID, err := resolveTenantID(tenantIDFromMetadata(ctx), tenantIDFromPeerCertificate(ctx))
+		if err != nil {
+			return err
+		}
+		if tenantID != "" {
+			ss = tenantStream{ServerStream: ss, ctx: WithTenantID(ctx, tenantID)}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// tenantStream is a ServerStream whose context carries the resolved tenant
+type tenantStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s tenantStream) Context() context.Context {
+	return s.ctx
+}
+
+// HTTPMiddleware resolves the tenant of requests to the in-process HTTP gateway like UnaryServerInterceptor
+// The gateway calls the API directly, skipping the gRPC interceptors, and passes on the request context
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var certTenantID string
+		if r.TLS != nil {
+			certTenantID = tenantIDFromVerifiedChains(r.TLS.VerifiedChains)
+		}
+		tenantID, err := resolveTenantID(r.Header.Get(httpHeader), certTenantID)
+		if err != nil {
+			http.Error(w, status.Convert(err).Message(), http.StatusForbidden)
+			return
+		}
+		if tenantID != "" {
+			r = r.WithContext(WithTenantID(r.Context(), tenantID))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// httpHeader is the HTTP header grpc-gateway forwards as MetadataKey
+const httpHeader = "Grpc-Metadata-" + MetadataKey
+
+// resolveTenantID returns the caller's tenant given the one it asked for and the one its certificate verifies
+// A verified certificate decides the tenant; asking for another one is denied
+func resolveTenantID(requested, verified string) (string, error) {
+	if verified == "" {
+		return requested, nil
+	}
+	if requested != "" && requested != verified {
+		return "", status.Errorf(codes.PermissionDenied, "tenant %q does not match the client certificate", requested)
+	}
+	return verified, nil
+}
+
+// tenantIDFromMetadata extracts the tenant ID the caller asked for from the incoming gRPC metadata
+func tenantIDFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(MetadataKey); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// tenantIDFromPeerCertificate returns the CN of the verified mTLS client certificate
+func tenantIDFromPeerCertificate(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ""
+	}
+	return tenantIDFromVerifiedChains(tlsInfo.State.VerifiedChains)
+}
+
+// tenantIDFromVerifiedChains returns the CN of the leaf certificate of the first verified chain with one
+func tenantIDFromVerifiedChains(chains [][]*x509.Certificate) string {
+	for _, chain := range chains {
+		if len(chain) > 0 && chain[0].Subject.CommonName != "" {
+			return chain[0].Subject.CommonName
+		}
+	}
+	return ""
+}
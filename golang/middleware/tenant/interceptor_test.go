@@ -0,0 +1,126 @@
+package tenant
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// verifiedChains is a verified chain whose client certificate has the given CN
+func verifiedChains(cn string) [][]*x509.Certificate {
+	return [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}}
+}
+
+// callContext is the context of a gRPC call asking for requested, over mTLS when certCN is set
+func callContext(requested, certCN string) context.Context {
+	ctx := context.Background()
+	if requested != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(MetadataKey, requested))
+	}
+	if certCN != "" {
+		ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: verifiedChains(certCN)},
+		}})
+	}
+	return ctx
+}
+
+func TestUnaryServerInterceptorResolvesTenant(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested string
+		certCN    string
+		want      string
+		wantCode  codes.Code
+	}{
+		{name: "metadata without certificate", requested: "tenant-a", want: "tenant-a"},
+		{name: "certificate without metadata", certCN: "tenant-a", want: "tenant-a"},
+		{name: "metadata matching certificate", requested: "tenant-a", certCN: "tenant-a", want: "tenant-a"},
+		{name: "metadata naming another tenant", requested: "tenant-b", certCN: "tenant-a", wantCode: codes.PermissionDenied},
+		{name: "neither", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := func(ctx context.Context, req any) (any, error) {
+				got = TenantIDFromContext(ctx)
+				return nil, nil
+			}
+
+			_, err := UnaryServerInterceptor()(callContext(tt.requested, tt.certCN), nil, &grpc.UnaryServerInfo{}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("Expected code %v, got: %v", tt.wantCode, err)
+			}
+			if got != tt.want {
+				t.Fatalf("Expected tenant %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareRejectsTenantOtherThanCertificate(t *testing.T) {
+	var got string
+	handler := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = TenantIDFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/accounts", nil)
+	req.Header.Set("Grpc-Metadata-X-Tenant-Id", "tenant-b")
+	req.TLS = &tls.ConnectionState{VerifiedChains: verifiedChains("tenant-a")}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403 for another tenant than the certificate's, got %d", rec.Code)
+	}
+
+	req.Header.Set("Grpc-Metadata-X-Tenant-Id", "tenant-a")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || got != "tenant-a" {
+		t.Fatalf("Expected the certificate's tenant to be served, got status %d and tenant %q", rec.Code, got)
+	}
+}
+
+func TestTenantIDFromContextIgnoresMetadata(t *testing.T) {
+	if got := TenantIDFromContext(callContext("tenant-a", "")); got != "" {
+		t.Fatalf("Expected no tenant without the interceptor, got %q", got)
+	}
+}
+
+// serverStream is a grpc.ServerStream with only a context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptorResolvesTenant(t *testing.T) {
+	var got string
+	handler := func(srv any, ss grpc.ServerStream) error {
+		got = TenantIDFromContext(ss.Context())
+		return nil
+	}
+	interceptor := StreamServerInterceptor()
+
+	if err := interceptor(nil, serverStream{ctx: callContext("tenant-a", "")}, &grpc.StreamServerInfo{}, handler); err != nil || got != "tenant-a" {
+		t.Fatalf("Expected the stream to see tenant-a, got %q and %v", got, err)
+	}
+	err := interceptor(nil, serverStream{ctx: callContext("tenant-b", "tenant-a")}, &grpc.StreamServerInfo{}, handler)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expected PermissionDenied for another tenant than the certificate's, got: %v", err)
+	}
+}
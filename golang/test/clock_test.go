@@ -0,0 +1,193 @@
+package test_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/puddle/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/berendjan/golang-bazel-starter/golang/config/api"
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+	"github.com/berendjan/golang-bazel-starter/golang/config/repository"
+	"github.com/berendjan/golang-bazel-starter/golang/framework/clock"
+	geninterfaces "github.com/berendjan/golang-bazel-starter/golang/generated/interfaces"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/dedup"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/tenant"
+	"github.com/berendjan/golang-bazel-starter/golang/test"
+	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
+)
+
+// clockStart is where the fake clocks of these tests start, whole microseconds as postgres stores them
+var clockStart = time.Date(2030, time.March, 1, 9, 30, 0, 0, time.UTC)
+
+func TestRepositoryStampsTimesFromClock(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	pool := tc.GetDBPool(test.ConfigDb)
+	fake := clock.NewFake(clockStart)
+	repo := repository.NewAccountRepository(pool).WithClock(fake)
+	tenantCtx := tenant.WithTenantID(ctx, testTenant)
+
+	if _, err := repo.HandleMiddleOneRequest(tenantCtx, &configpb.MiddleOneRequestProto{
+		Request: &configpb.AccountCreationRequestProto{Name: "clocked-account"},
+	}); err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	fake.Advance(time.Hour)
+	accountID := ids.AccountID("clocked-account")
+	if _, err := repo.MergeMetadata(tenantCtx, accountID, nil); err != nil {
+		t.Fatalf("Failed to update account: %v", err)
+	}
+
+	var createdAt, updatedAt time.Time
+	err = pool.QueryRow(ctx, "SELECT created_at, updated_at FROM accounts WHERE tenant_id = $1 AND id = $2", testTenant, accountID.Bytes()).
+		Scan(&createdAt, &updatedAt)
+	if err != nil {
+		t.Fatalf("Failed to read timestamps: %v", err)
+	}
+	if !createdAt.Equal(clockStart) {
+		t.Fatalf("Expected created_at %s, got %s", clockStart, createdAt)
+	}
+	if want := clockStart.Add(time.Hour); !updatedAt.Equal(want) {
+		t.Fatalf("Expected updated_at %s, got %s", want, updatedAt)
+	}
+
+	// The date range filter sees the clock's times, not the wall clock's
+	accounts, err := repo.ListAccountsByDateRange(tenantCtx, clockStart.Add(-time.Minute), clockStart.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	if len(accounts) != 1 {
+		t.Fatalf("Expected the account in the clock's range, got %d accounts", len(accounts))
+	}
+}
+
+// flakyListSender answers ListAccounts with response, or with an unavailable database error while down
+type flakyListSender struct {
+	geninterfaces.AccountApiSendable
+	down     bool
+	response *configpb.ListAccountsResponseProto
+}
+
+func (f *flakyListSender) SendListAccountsRequestFromAccountApi(context.Context, *configpb.ListAccountsRequestProto) (*configpb.ListAccountsResponseProto, error) {
+	if f.down {
+		return nil, fmt.Errorf("failed to list accounts: %w", puddle.ErrClosedPool)
+	}
+	return f.response, nil
+}
+
+func TestDegradedListCacheExpiresOnClock(t *testing.T) {
+	ctx := tenant.WithTenantID(context.Background(), testTenant)
+
+	const ttl = time.Minute
+	fake := clock.NewFake(clockStart)
+	sender := &flakyListSender{response: &configpb.ListAccountsResponseProto{
+		Accounts: []*configpb.AccountConfigurationProto{{}},
+	}}
+	server := api.NewConfigurationApi(sender).WithDegradedMode(ttl).WithClock(fake)
+
+	if _, err := server.ListAccounts(ctx, &configpb.ListAccountsRequestProto{}); err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+
+	// Just before the TTL ends the cached listing is served
+	sender.down = true
+	fake.Advance(ttl - time.Second)
+	resp, err := server.ListAccounts(ctx, &configpb.ListAccountsRequestProto{})
+	if err != nil {
+		t.Fatalf("Expected the cached listing within the TTL, got: %v", err)
+	}
+	if len(resp.GetAccounts()) != 1 {
+		t.Fatalf("Expected the cached account, got %d accounts", len(resp.GetAccounts()))
+	}
+
+	// Once the TTL has passed the database error surfaces
+	fake.Advance(2 * time.Second)
+	if _, err := server.ListAccounts(ctx, &configpb.ListAccountsRequestProto{}); err == nil {
+		t.Fatal("Expected an error once the cached listing expired")
+	}
+}
+
+func TestDuplicateWindowFollowsClock(t *testing.T) {
+	const method = "/configuration_service.v1.Configuration/CreateAccount"
+	const window = time.Minute
+	fake := clock.NewFake(clockStart)
+	interceptor := dedup.NewDetector(window).WithShortCircuit(method).WithClock(fake).UnaryServerInterceptor()
+
+	calls := 0
+	handler := func(context.Context, any) (any, error) {
+		calls++
+		return wrapperspb.String("created"), nil
+	}
+	call := func() {
+		t.Helper()
+		if _, err := interceptor(context.Background(), wrapperspb.String("account"), &grpc.UnaryServerInfo{FullMethod: method}, handler); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	call()
+	fake.Advance(window - time.Second)
+	call()
+	if calls != 1 {
+		t.Fatalf("Expected the duplicate within the window to be short-circuited, handler ran %d times", calls)
+	}
+
+	fake.Advance(2 * time.Second)
+	call()
+	if calls != 2 {
+		t.Fatalf("Expected the request after the window to run, handler ran %d times", calls)
+	}
+}
+
+func TestDuplicateAfterAnotherRequestRunsAgain(t *testing.T) {
+	const deleteMethod = "/configuration_service.v1.Configuration/DeleteAccount"
+	const createMethod = "/configuration_service.v1.Configuration/CreateAccount"
+	fake := clock.NewFake(clockStart)
+	interceptor := dedup.NewDetector(time.Minute).WithShortCircuit(deleteMethod).WithClock(fake).UnaryServerInterceptor()
+
+	calls := map[string]int{}
+	call := func(method string) {
+		t.Helper()
+		handler := func(context.Context, any) (any, error) {
+			calls[method]++
+			return wrapperspb.String("done"), nil
+		}
+		if _, err := interceptor(context.Background(), wrapperspb.String("account"), &grpc.UnaryServerInfo{FullMethod: method}, handler); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// An immediate retry is replayed
+	call(deleteMethod)
+	call(deleteMethod)
+	if calls[deleteMethod] != 1 {
+		t.Fatalf("Expected the retried delete to be short-circuited, handler ran %d times", calls[deleteMethod])
+	}
+
+	// Delete, re-create and delete: twice in a row, so the re-create is itself a duplicate that runs
+	for range 2 {
+		call(createMethod)
+		call(deleteMethod)
+	}
+	if calls[deleteMethod] != 3 {
+		t.Fatalf("Expected every delete after a create to run, handler ran %d times", calls[deleteMethod])
+	}
+}
@@ -0,0 +1,1303 @@
+package test_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	configClient "github.com/berendjan/golang-bazel-starter/golang/config/client"
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+	"github.com/berendjan/golang-bazel-starter/golang/config/repository"
+	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/tenant"
+	"github.com/berendjan/golang-bazel-starter/golang/test"
+	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
+)
+
+func TestPoolConnectionsCarryApplicationName(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	var applicationName string
+	err = tc.GetDBPool(test.ConfigDb).QueryRow(ctx,
+		"SELECT application_name FROM pg_stat_activity WHERE pid = pg_backend_pid()",
+	).Scan(&applicationName)
+	if err != nil {
+		t.Fatalf("Failed to query pg_stat_activity: %v", err)
+	}
+	if applicationName != test.ApplicationName {
+		t.Fatalf("Expected application_name %q in pg_stat_activity, got %q", test.ApplicationName, applicationName)
+	}
+}
+
+func TestPoolConnectsWithSpecialCharacterPassword(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Roles are shared by the container's databases, so drop a leftover of an earlier run first
+	const role = "special password user"
+	const password = `s3cret pass'word\!`
+	admin := tc.GetDBPool(test.ConfigDb)
+	for _, stmt := range []string{
+		"SELECT format('DROP ROLE IF EXISTS %I', $1::text)",
+		"SELECT format('CREATE ROLE %I LOGIN PASSWORD %L', $1::text, $2::text)",
+	} {
+		var ddl string
+		if err := admin.QueryRow(ctx, stmt, role, password).Scan(&ddl); err != nil {
+			t.Fatalf("Failed to format role statement: %v", err)
+		}
+		if _, err := admin.Exec(ctx, ddl); err != nil {
+			t.Fatalf("Failed to run %q: %v", ddl, err)
+		}
+	}
+	defer admin.Exec(ctx, `DROP ROLE IF EXISTS "`+role+`"`)
+
+	cfg := tc.GetDBConfig(test.ConfigDb)
+	cfg.AuthMode = db.PasswordAuth
+	cfg.User = role
+	cfg.Password = password
+
+	pool, err := db.NewPool(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Failed to connect with connection string %q: %v", cfg.ConnectionString(), err)
+	}
+	defer pool.Close()
+
+	var user string
+	if err := pool.QueryRow(ctx, "SELECT current_user").Scan(&user); err != nil {
+		t.Fatalf("Failed to query current user: %v", err)
+	}
+	if user != role {
+		t.Fatalf("Expected to connect as %q, got %q", role, user)
+	}
+
+	// The URL form reaches the same server
+	conn, err := pgx.Connect(ctx, cfg.URL())
+	if err != nil {
+		t.Fatalf("Failed to connect with the URL: %v", err)
+	}
+	conn.Close(ctx)
+}
+
+func TestPoolAfterConnectRegistersTypes(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Enums are unknown to pgx until registered, so arrays of them can't be scanned without the hook
+	if _, err := tc.GetDBPool(test.ConfigDb).Exec(ctx, "CREATE TYPE account_status AS ENUM ('active', 'suspended')"); err != nil {
+		t.Fatalf("Failed to create enum type: %v", err)
+	}
+
+	var connects atomic.Int32
+	registerTypes := db.RegisterTypes("account_status", "_account_status")
+	cfg := tc.GetDBConfig(test.ConfigDb)
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		connects.Add(1)
+		return registerTypes(ctx, conn)
+	}
+
+	pool, err := db.NewPool(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Failed to create pool with AfterConnect hook: %v", err)
+	}
+	defer pool.Close()
+
+	var metadata map[string]any
+	var statuses []string
+	err = pool.QueryRow(ctx,
+		`SELECT '{"plan": "pro", "seats": 3}'::jsonb, ARRAY['active', 'suspended']::account_status[]`,
+	).Scan(&metadata, &statuses)
+	if err != nil {
+		t.Fatalf("Failed to scan JSONB and enum array: %v", err)
+	}
+
+	if connects.Load() == 0 {
+		t.Fatal("Expected AfterConnect to run for new connections")
+	}
+	if metadata["plan"] != "pro" || metadata["seats"] != float64(3) {
+		t.Fatalf("Unexpected JSONB metadata: %v", metadata)
+	}
+	if len(statuses) != 2 || statuses[0] != "active" || statuses[1] != "suspended" {
+		t.Fatalf("Unexpected enum array: %v", statuses)
+	}
+}
+
+func TestRunInTxCommitsOrRollsBack(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	pool := tc.GetDBPool(test.ConfigDb)
+	if _, err := pool.Exec(ctx, "CREATE TABLE tx_test (name TEXT PRIMARY KEY)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	insert := func(ctx context.Context, name string) error {
+		_, err := pool.Querier(ctx).Exec(ctx, "INSERT INTO tx_test (name) VALUES ($1)", name)
+		return err
+	}
+	count := func() int {
+		var n int
+		if err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM tx_test").Scan(&n); err != nil {
+			t.Fatalf("Failed to count rows: %v", err)
+		}
+		return n
+	}
+
+	// A failing step rolls back the writes before it, including those of a nested RunInTx
+	failure := errors.New("second step failed")
+	err = pool.RunInTx(ctx, func(ctx context.Context) error {
+		if _, ok := db.TxFromContext(ctx); !ok {
+			t.Fatal("Expected the context to carry the transaction")
+		}
+		if err := insert(ctx, "first"); err != nil {
+			return err
+		}
+		if err := pool.RunInTx(ctx, func(ctx context.Context) error { return insert(ctx, "nested") }); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the step error, got: %v", err)
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("Expected all writes to roll back, found %d rows", n)
+	}
+
+	// Successful steps commit together
+	err = pool.RunInTx(ctx, func(ctx context.Context) error {
+		if err := insert(ctx, "first"); err != nil {
+			return err
+		}
+		return insert(ctx, "second")
+	})
+	if err != nil {
+		t.Fatalf("Expected the transaction to commit: %v", err)
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("Expected 2 committed rows, found %d", n)
+	}
+
+	// Outside a transaction the pool is used directly
+	if _, ok := db.TxFromContext(ctx); ok {
+		t.Fatal("Expected no transaction in a plain context")
+	}
+	if err := insert(ctx, "third"); err != nil {
+		t.Fatalf("Failed to insert without a transaction: %v", err)
+	}
+	if n := count(); n != 3 {
+		t.Fatalf("Expected 3 rows, found %d", n)
+	}
+}
+
+func TestAcquireTimeoutReportsPoolExhaustion(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	dbConfig := tc.GetDBConfig(test.ConfigDb)
+	dbConfig.MaxConns = 2
+	dbConfig.MinConns = 0
+	dbConfig.AcquireTimeout = 200 * time.Millisecond
+	pool, err := db.NewPool(ctx, dbConfig)
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	defer pool.Close()
+
+	// Hold every connection of the pool
+	for range dbConfig.MaxConns {
+		conn, err := pool.AcquireTimeout(ctx, time.Second)
+		if err != nil {
+			t.Fatalf("Failed to acquire connection: %v", err)
+		}
+		defer conn.Release()
+	}
+
+	start := time.Now()
+	_, err = pool.AcquireTimeout(ctx, 100*time.Millisecond)
+	if !errors.Is(err, db.ErrPoolExhausted) {
+		t.Fatalf("Expected ErrPoolExhausted from a saturated pool, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the saturated acquire to give up after its timeout, took %s", elapsed)
+	}
+	if !strings.Contains(err.Error(), "(2 of 2 in use)") {
+		t.Fatalf("Expected the error to report the pool occupancy, got: %v", err)
+	}
+
+	// Repository statements and transactions see the same error through the configured timeout
+	var one int
+	if err := pool.Querier(ctx).QueryRow(ctx, "SELECT 1").Scan(&one); !errors.Is(err, db.ErrPoolExhausted) {
+		t.Fatalf("Expected ErrPoolExhausted from Querier, got: %v", err)
+	}
+	err = pool.RunInTx(ctx, func(ctx context.Context) error { return nil })
+	if !errors.Is(err, db.ErrPoolExhausted) {
+		t.Fatalf("Expected ErrPoolExhausted from RunInTx, got: %v", err)
+	}
+
+	// The caller's own deadline stays a deadline, not saturation
+	deadlineCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = pool.AcquireTimeout(deadlineCtx, time.Second)
+	if errors.Is(err, db.ErrPoolExhausted) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded from the caller's deadline, got: %v", err)
+	}
+}
+
+func TestAcquireTimeoutReleasesConnections(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// With a single connection every statement must release it for the next one to run
+	dbConfig := tc.GetDBConfig(test.ConfigDb)
+	dbConfig.MaxConns = 1
+	dbConfig.MinConns = 0
+	dbConfig.AcquireTimeout = time.Second
+	pool, err := db.NewPool(ctx, dbConfig)
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	defer pool.Close()
+
+	querier := pool.Querier(ctx)
+	for i := range 3 {
+		var one int
+		if err := querier.QueryRow(ctx, "SELECT 1").Scan(&one); err != nil {
+			t.Fatalf("QueryRow %d failed: %v", i, err)
+		}
+		if _, err := querier.Exec(ctx, "SELECT 1"); err != nil {
+			t.Fatalf("Exec %d failed: %v", i, err)
+		}
+		rows, err := querier.Query(ctx, "SELECT generate_series(1, 3)")
+		if err != nil {
+			t.Fatalf("Query %d failed: %v", i, err)
+		}
+		for rows.Next() {
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatalf("Query %d rows failed: %v", i, err)
+		}
+		if err := pool.RunInTx(ctx, func(ctx context.Context) error { return errors.New("roll back") }); err == nil {
+			t.Fatalf("Expected RunInTx %d to return the error of fn", i)
+		}
+		if err := pool.RunInTx(ctx, func(ctx context.Context) error { return nil }); err != nil {
+			t.Fatalf("RunInTx %d failed: %v", i, err)
+		}
+	}
+}
+
+func TestQueryCounterCountsStatements(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	pool := tc.GetDBPool(test.ConfigDb)
+	counted := db.WithQueryCounter(ctx)
+
+	// Two statements on the pool
+	var one int
+	for range 2 {
+		if err := pool.Querier(counted).QueryRow(counted, "SELECT 1").Scan(&one); err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+	}
+	if queries := db.QueriesFromContext(counted); queries != 2 {
+		t.Fatalf("Expected 2 queries, got %d", queries)
+	}
+
+	// A transaction adds its BEGIN and COMMIT to the statements it runs
+	err = pool.RunInTx(counted, func(ctx context.Context) error {
+		_, err := pool.Querier(ctx).Exec(ctx, "SELECT 1")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to run transaction: %v", err)
+	}
+	if queries := db.QueriesFromContext(counted); queries != 5 {
+		t.Fatalf("Expected 5 queries after the transaction, got %d", queries)
+	}
+
+	// Statements without a counter are not counted anywhere
+	if err := pool.Querier(ctx).QueryRow(ctx, "SELECT 1").Scan(&one); err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if db.QueriesFromContext(ctx) != 0 || db.QueriesFromContext(counted) != 5 {
+		t.Fatalf("Expected uncounted statements to leave the counter at 5, got %d", db.QueriesFromContext(counted))
+	}
+}
+
+func TestQueryJSON(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	pool := tc.GetDBPool(test.ConfigDb)
+	for _, name := range []string{"json-account-1", "json-account-2"} {
+		if _, err := pool.Exec(ctx, "INSERT INTO accounts (tenant_id, id, name, type) VALUES ($1, $2, $3, 1)", testTenant, []byte(name), name); err != nil {
+			t.Fatalf("Failed to seed account %s: %v", name, err)
+		}
+	}
+
+	data, err := pool.QueryJSON(ctx, "SELECT tenant_id, id, type, created_at, gen_random_uuid() AS request_id FROM accounts WHERE tenant_id = $1 ORDER BY id", testTenant)
+	if err != nil {
+		t.Fatalf("Failed to query JSON: %v", err)
+	}
+
+	var rows []map[string]any
+	if err := json.Unmarshal(data, &rows); err != nil {
+		t.Fatalf("QueryJSON returned invalid JSON %s: %v", data, err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d: %s", len(rows), data)
+	}
+	for _, row := range rows {
+		for _, key := range []string{"tenant_id", "id", "type", "created_at", "request_id"} {
+			if _, ok := row[key]; !ok {
+				t.Fatalf("Row is missing key %q: %v", key, row)
+			}
+		}
+		if row["tenant_id"] != testTenant || row["type"] != float64(1) {
+			t.Fatalf("Unexpected row values: %v", row)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, row["created_at"].(string)); err != nil {
+			t.Fatalf("Expected RFC 3339 created_at, got %v", row["created_at"])
+		}
+		if requestID, _ := row["request_id"].(string); len(requestID) != 36 {
+			t.Fatalf("Expected UUID string request_id, got %v", row["request_id"])
+		}
+	}
+
+	// Bytea columns are base64 encoded
+	id, err := base64.StdEncoding.DecodeString(rows[0]["id"].(string))
+	if err != nil || string(id) != "json-account-1" {
+		t.Fatalf("Expected base64 encoded id of json-account-1, got %v", rows[0]["id"])
+	}
+}
+
+func TestQueryJSONRowCap(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	pool := tc.GetDBPool(test.ConfigDb)
+	if _, err := pool.QueryJSON(ctx, "SELECT generate_series(1, $1::int) AS n", db.MaxQueryJSONRows); err != nil {
+		t.Fatalf("Expected %d rows to be allowed, got: %v", db.MaxQueryJSONRows, err)
+	}
+	if _, err := pool.QueryJSON(ctx, "SELECT generate_series(1, $1::int) AS n", db.MaxQueryJSONRows+1); err == nil {
+		t.Fatal("Expected an error when the row cap is exceeded")
+	}
+
+	// Writes are rejected because the query runs in a read-only transaction
+	if _, err := pool.QueryJSON(ctx, "INSERT INTO accounts (tenant_id, id, type) VALUES ('t', 'x', 1) RETURNING id"); err == nil {
+		t.Fatal("Expected QueryJSON to reject writes")
+	}
+}
+
+func TestRepositoryDeleteAccountRowsAffected(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	pool := tc.GetDBPool(test.ConfigDb)
+	repo := repository.NewAccountRepository(pool)
+	tenantCtx := tenant.WithTenantID(ctx, testTenant)
+
+	// Zero rows is a successful no-op
+	rows, err := repo.DeleteAccount(tenantCtx, ids.AccountID("missing-account"))
+	if err != nil {
+		t.Fatalf("Expected no error deleting a missing account, got: %v", err)
+	}
+	if rows != 0 {
+		t.Fatalf("Expected 0 rows affected, got %d", rows)
+	}
+
+	if _, err := pool.Exec(ctx, "INSERT INTO accounts (tenant_id, id, name, type) VALUES ($1, $2, $3, 1)", testTenant, []byte("existing-account"), "existing-account"); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+	rows, err = repo.DeleteAccount(tenantCtx, ids.AccountID("existing-account"))
+	if err != nil {
+		t.Fatalf("Failed to delete account: %v", err)
+	}
+	if rows != 1 {
+		t.Fatalf("Expected 1 row affected, got %d", rows)
+	}
+
+	// A second delete of the same account is a no-op again
+	if rows, err = repo.DeleteAccount(tenantCtx, ids.AccountID("existing-account")); err != nil || rows != 0 {
+		t.Fatalf("Expected repeated delete to affect 0 rows without error, got %d, %v", rows, err)
+	}
+
+	// The handler reports a missing account with db.ErrNotFound rather than a status code
+	req := &configpb.AccountDeletionRequestProto{Id: ids.AccountID("existing-account").String()}
+	if _, err := repo.HandleAccountDeletionRequest(tenantCtx, req); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound deleting a missing account, got: %v", err)
+	}
+}
+
+func TestRepositoryAccountMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	pool := tc.GetDBPool(test.ConfigDb)
+	repo := repository.NewAccountRepository(pool)
+	tenantCtx := tenant.WithTenantID(ctx, testTenant)
+	accountID := ids.AccountID("metadata-account")
+
+	if _, err := pool.Exec(ctx, "INSERT INTO accounts (tenant_id, id, name, type) VALUES ($1, $2, $3, 1)", testTenant, accountID.Bytes(), "metadata-account"); err != nil {
+		t.Fatalf("Failed to seed account: %v", err)
+	}
+
+	// mustStruct converts a fixture to metadata
+	mustStruct := func(m map[string]any) *structpb.Struct {
+		s, err := structpb.NewStruct(m)
+		if err != nil {
+			t.Fatalf("Failed to convert %v: %v", m, err)
+		}
+		return s
+	}
+
+	// Seeded accounts start with an empty object
+	metadata, err := repo.GetMetadata(tenantCtx, accountID)
+	if err != nil || len(metadata.AsMap()) != 0 {
+		t.Fatalf("Expected empty metadata, got %v: %v", metadata.AsMap(), err)
+	}
+
+	set := map[string]any{"display_name": "Alice", "settings": map[string]any{"theme": "dark"}}
+	if _, err := repo.SetMetadata(tenantCtx, accountID, mustStruct(set)); err != nil {
+		t.Fatalf("Failed to set metadata: %v", err)
+	}
+
+	// Merging replaces top-level keys and keeps the others
+	merged, err := repo.MergeMetadata(tenantCtx, accountID, mustStruct(map[string]any{
+		"avatar":   "https://example.com/alice.png",
+		"settings": map[string]any{"locale": "nl"},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to merge metadata: %v", err)
+	}
+	want := map[string]any{
+		"display_name": "Alice",
+		"avatar":       "https://example.com/alice.png",
+		"settings":     map[string]any{"locale": "nl"},
+	}
+	if got := merged.AsMap(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Merge returned %v, want %v", got, want)
+	}
+	if metadata, err := repo.GetMetadata(tenantCtx, accountID); err != nil || !reflect.DeepEqual(metadata.AsMap(), want) {
+		t.Fatalf("Expected to read back %v, got %v: %v", want, metadata.AsMap(), err)
+	}
+
+	// Oversized metadata is rejected before it reaches the database
+	oversized := mustStruct(map[string]any{"blob": strings.Repeat("x", repository.MaxMetadataBytes)})
+	if _, err := repo.SetMetadata(tenantCtx, accountID, oversized); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument for oversized metadata, got: %v", err)
+	}
+
+	// Merges that are small on their own but grow the stored metadata past the limit are rejected by the database
+	half := strings.Repeat("y", repository.MaxMetadataBytes/2)
+	if _, err := repo.MergeMetadata(tenantCtx, accountID, mustStruct(map[string]any{"first": half})); err != nil {
+		t.Fatalf("Failed to merge half the limit: %v", err)
+	}
+	_, err = repo.MergeMetadata(tenantCtx, accountID, mustStruct(map[string]any{"second": half}))
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument for a merge past the limit, got: %v", err)
+	}
+	if metadata, err := repo.GetMetadata(tenantCtx, accountID); err != nil || metadata.AsMap()["second"] != nil {
+		t.Fatalf("Expected the rejected merge to leave the metadata untouched, got: %v", err)
+	}
+
+	if _, err := repo.GetMetadata(tenantCtx, ids.AccountID("missing-account")); status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected NotFound for a missing account, got: %v", err)
+	}
+	if _, err := repo.MergeMetadata(tenantCtx, ids.AccountID("missing-account"), nil); status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected NotFound merging into a missing account, got: %v", err)
+	}
+}
+
+func TestPostMigrationSQLInstallsExtension(t *testing.T) {
+	ctx := context.Background()
+
+	configDb := test.ConfigDb.WithPostMigrationSQL(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`)
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(configDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	var id string
+	if err := tc.GetDBPool(configDb).QueryRow(ctx, "SELECT uuid_generate_v4()::text").Scan(&id); err != nil {
+		t.Fatalf("Failed to call uuid_generate_v4 from the post-migration extension: %v", err)
+	}
+	if len(id) != 36 {
+		t.Fatalf("Expected a UUID, got %q", id)
+	}
+}
+
+func TestPostMigrateErrorFailsBuild(t *testing.T) {
+	ctx := context.Background()
+
+	hookErr := errors.New("fixture failed")
+	_, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb.WithPostMigrate(func(ctx context.Context, pool *db.DBPool) error {
+			return hookErr
+		})).
+		Build(ctx)
+	if !errors.Is(err, hookErr) {
+		t.Fatalf("Expected Build to fail with the post-migration error, got: %v", err)
+	}
+}
+
+func TestContainerStartupWithLongerTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	// Forget the cached container so this build runs the wait strategy again
+	test.ResetSharedContainer()
+
+	tc, err := test.NewTestContextBuilder().
+		WithContainerWaitStrategy(test.WaitForSQL).
+		WithContainerStartupTimeout(3 * time.Minute).
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context with a longer startup timeout: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	var one int
+	if err := tc.GetDBPool(test.ConfigDb).QueryRow(ctx, "SELECT 1").Scan(&one); err != nil || one != 1 {
+		t.Fatalf("Expected the database to answer queries, got %d, %v", one, err)
+	}
+}
+
+func TestMigrationLoggerCapturesMigrationLogs(t *testing.T) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	tc, err := test.NewTestContextBuilder().
+		WithMigrationLogger(log.New(&buf, "", 0)).
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	logs := buf.String()
+	for _, want := range []string{"Looking for migrations in", "Applying migration", "All migrations completed successfully", "Migrations completed successfully for database"} {
+		if !strings.Contains(logs, want) {
+			t.Errorf("Expected migration logs to contain %q, got:\n%s", want, logs)
+		}
+	}
+}
+
+func TestScanJSONDecodesJSONB(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	type settings struct {
+		Theme  string   `json:"theme"`
+		Limits []int    `json:"limits"`
+		Tags   []string `json:"tags"`
+	}
+
+	var got settings
+	missing := settings{Theme: "unchanged"}
+	err = tc.GetDBPool(test.ConfigDb).QueryRow(ctx,
+		`SELECT '{"theme": "dark", "limits": [1, 2], "tags": ["a"]}'::jsonb, NULL::jsonb`,
+	).Scan(db.ScanJSON(&got), db.ScanJSON(&missing))
+	if err != nil {
+		t.Fatalf("Failed to scan JSONB: %v", err)
+	}
+
+	if got.Theme != "dark" || len(got.Limits) != 2 || got.Limits[1] != 2 || len(got.Tags) != 1 {
+		t.Fatalf("Unexpected decoded struct: %+v", got)
+	}
+	if missing.Theme != "unchanged" {
+		t.Fatalf("Expected NULL to leave the destination unchanged, got %+v", missing)
+	}
+}
+
+func TestConcurrentMigrationRunsAreSerialized(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// The slow second migration keeps the first run busy while the other one starts
+	dir := t.TempDir()
+	migrations := map[string]string{
+		"30000101000001_create_lock_probe.sql": "-- migrate:up\nCREATE TABLE lock_probe (id INT);\n\n-- migrate:down\nDROP TABLE lock_probe;\n",
+		"30000101000002_fill_lock_probe.sql":   "-- migrate:up\nSELECT pg_sleep(1);\nINSERT INTO lock_probe VALUES (1);\n\n-- migrate:down\nDELETE FROM lock_probe;\n",
+	}
+	for name, content := range migrations {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write migration %s: %v", name, err)
+		}
+	}
+
+	dbURL := tc.GetDBConfig(test.ConfigDb).ConnectionString()
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- test.RunDbmateMigrationsWithOptions(ctx, test.DbmateOptions{LockTimeout: 30 * time.Second}, dbURL, dir, nil)
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Expected both migration runs to complete, got: %v", err)
+		}
+	}
+
+	pool := tc.GetDBPool(test.ConfigDb)
+	var probes, versions int
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM lock_probe").Scan(&probes); err != nil {
+		t.Fatalf("Failed to count probe rows: %v", err)
+	}
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM schema_migrations WHERE version LIKE '3000%'").Scan(&versions); err != nil {
+		t.Fatalf("Failed to count applied migrations: %v", err)
+	}
+	if probes != 1 || versions != 2 {
+		t.Fatalf("Expected each migration to run once, got %d probe rows and %d versions", probes, versions)
+	}
+}
+
+func TestMigrationAbortsWhenContextIsCancelled(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	dir := t.TempDir()
+	migration := "-- migrate:up\nSELECT pg_sleep(60);\n\n-- migrate:down\nSELECT 1;\n"
+	if err := os.WriteFile(filepath.Join(dir, "30000101000001_stuck.sql"), []byte(migration), 0644); err != nil {
+		t.Fatalf("Failed to write migration: %v", err)
+	}
+
+	// Cancel while the migration sleeps, as when the caller's timeout fires
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	time.AfterFunc(500*time.Millisecond, cancel)
+
+	start := time.Now()
+	err = test.RunDbmateMigrations(runCtx, tc.GetDBConfig(test.ConfigDb).ConnectionString(), dir, nil)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("Expected the migration to abort promptly, returned after %s", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a context error, got: %v", err)
+	}
+
+	var versions int
+	if err := tc.GetDBPool(test.ConfigDb).QueryRow(ctx, "SELECT count(*) FROM schema_migrations WHERE version = '30000101000001'").Scan(&versions); err != nil {
+		t.Fatalf("Failed to count applied migrations: %v", err)
+	}
+	if versions != 0 {
+		t.Fatal("Expected the aborted migration not to be recorded")
+	}
+}
+
+// cancelAfterErrChecks is a context that cancels itself once Err has been checked the given number of times
+type cancelAfterErrChecks struct {
+	context.Context
+	cancel    context.CancelFunc
+	remaining atomic.Int64
+	checks    atomic.Int64
+}
+
+func (c *cancelAfterErrChecks) Err() error {
+	c.checks.Add(1)
+	if c.remaining.Add(-1) == 0 {
+		c.cancel()
+	}
+	return c.Context.Err()
+}
+
+func TestListAccountsStopsWhenContextIsCancelled(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	const total = 10000
+	pool := tc.GetDBPool(test.ConfigDb)
+	_, err = pool.Exec(ctx,
+		"INSERT INTO accounts (tenant_id, id, name, type) SELECT $1, convert_to('bulk-' || i, 'UTF8'), 'bulk-' || i, 1 FROM generate_series(1, $2::int) AS i",
+		testTenant, total,
+	)
+	if err != nil {
+		t.Fatalf("Failed to seed accounts: %v", err)
+	}
+
+	// The context is cancelled part way through the scan, as when a client disconnects
+	cancelCtx, cancel := context.WithCancel(tenant.WithTenantID(ctx, testTenant))
+	defer cancel()
+	listCtx := &cancelAfterErrChecks{Context: cancelCtx, cancel: cancel}
+	listCtx.remaining.Store(100)
+
+	repo := repository.NewAccountRepository(pool)
+	_, err = repo.HandleListAccountsRequest(listCtx, &configpb.ListAccountsRequestProto{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a context error, got: %v", err)
+	}
+	if checks := listCtx.checks.Load(); checks >= total {
+		t.Fatalf("Expected the scan to stop early, context was checked %d times for %d rows", checks, total)
+	}
+}
+
+// recordingT captures the failures of a helper under test instead of failing the test
+type recordingT struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertNoLeaksPassesAfterCleanUp(t *testing.T) {
+	ctx := context.Background()
+
+	// Start the shared container first so its goroutines are not reported
+	warmUp, err := test.NewTestContextBuilder().Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	if err := warmUp.CleanUp(ctx); err != nil {
+		t.Fatalf("Failed to clean up test context: %v", err)
+	}
+	ignore := goleak.IgnoreCurrent()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+
+	var one int
+	if err := tc.GetDBPool(test.ConfigDb).QueryRow(ctx, "SELECT 1").Scan(&one); err != nil {
+		t.Fatalf("Failed to query test database: %v", err)
+	}
+
+	if err := tc.CleanUp(ctx); err != nil {
+		t.Fatalf("Failed to clean up test context: %v", err)
+	}
+	test.AssertNoLeaks(t, tc, test.WithGoroutineLeakCheck(ignore))
+}
+
+func TestCleanUpClosesGrpcClients(t *testing.T) {
+	ctx := context.Background()
+
+	// Start the shared container first so its goroutines are not reported
+	warmUp, err := test.NewTestContextBuilder().Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	if err := warmUp.CleanUp(ctx); err != nil {
+		t.Fatalf("Failed to clean up test context: %v", err)
+	}
+	ignore := goleak.IgnoreCurrent()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+
+	// Repeated calls share one connection
+	client := tc.GrpcClient(test.GrpcServer)
+	if tc.GrpcClient(test.GrpcServer) != client {
+		t.Fatal("Expected GrpcClient to return the same client for the same server")
+	}
+	if _, err := client.CreateAccount(ctx, "pooled-account"); err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	other := tc.NewGrpcClient(test.GrpcServer, configClient.Config{Insecure: true, TenantID: "other-tenant"})
+	if _, err := other.ListAccounts(ctx); err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+
+	if err := tc.CleanUp(ctx); err != nil {
+		t.Fatalf("Failed to clean up test context: %v", err)
+	}
+	if client.State() != connectivity.Shutdown || other.State() != connectivity.Shutdown {
+		t.Fatalf("Expected CleanUp to close every client, got states %s and %s", client.State(), other.State())
+	}
+	test.AssertNoLeaks(t, tc, test.WithGoroutineLeakCheck(ignore))
+}
+
+func TestAssertNoLeaksDetectsLeakedConnection(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+
+	// A connection the test forgets to close outlives CleanUp and keeps the database from being dropped
+	dbConfig := *tc.GetDBConfig(test.ConfigDb)
+	leaked, err := pgx.Connect(ctx, dbConfig.ConnectionString())
+	if err != nil {
+		t.Fatalf("Failed to open connection: %v", err)
+	}
+
+	if err := tc.CleanUp(ctx); err != nil {
+		t.Fatalf("Failed to clean up test context: %v", err)
+	}
+
+	rt := &recordingT{TB: t}
+	test.AssertNoLeaks(rt, tc)
+	if len(rt.failures) != 1 || !strings.Contains(rt.failures[0], dbConfig.Database) {
+		t.Fatalf("Expected the leaked connection to %s to be reported, got %v", dbConfig.Database, rt.failures)
+	}
+
+	// Close the leak and drop the database CleanUp had to leave behind
+	if err := leaked.Close(ctx); err != nil {
+		t.Fatalf("Failed to close leaked connection: %v", err)
+	}
+	test.AssertNoLeaks(t, tc)
+
+	adminConfig := dbConfig
+	adminConfig.Database = "postgres"
+	admin, err := pgx.Connect(ctx, adminConfig.ConnectionString())
+	if err != nil {
+		t.Fatalf("Failed to connect to postgres database: %v", err)
+	}
+	defer admin.Close(ctx)
+	if _, err := admin.Exec(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS %s", dbConfig.Database)); err != nil {
+		t.Fatalf("Failed to drop leftover test database: %v", err)
+	}
+}
+
+func TestRetryOnSerializationRetriesConflictingTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb.WithPostMigrationSQL("CREATE TABLE counters (id INT PRIMARY KEY, n INT NOT NULL); INSERT INTO counters VALUES (1, 0)")).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	pool := tc.GetDBPool(test.ConfigDb)
+
+	// Read-modify-write in a serializable transaction; on the first attempt a concurrent
+	// transaction increments the counter between the read and the write
+	attempts := 0
+	err = db.RetryOnSerialization(ctx, func(ctx context.Context) error {
+		attempts++
+		tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		var n int
+		if err := tx.QueryRow(ctx, "SELECT n FROM counters WHERE id = 1").Scan(&n); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			if _, err := pool.Exec(ctx, "UPDATE counters SET n = n + 1 WHERE id = 1"); err != nil {
+				return fmt.Errorf("concurrent increment failed: %w", err)
+			}
+		}
+		if _, err := tx.Exec(ctx, "UPDATE counters SET n = $1 WHERE id = 1", n+1); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	})
+	if err != nil {
+		t.Fatalf("Expected the retry to succeed, got: %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("Expected the conflict to cost one retry, got %d attempts", attempts)
+	}
+
+	// Neither increment was lost
+	var n int
+	if err := pool.QueryRow(ctx, "SELECT n FROM counters WHERE id = 1").Scan(&n); err != nil {
+		t.Fatalf("Failed to read counter: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("Expected both increments, got %d", n)
+	}
+}
+
+func TestAssertSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	pool := tc.GetDBPool(test.ConfigDb)
+
+	// The migrated test database is at the version the repository was built for
+	if err := pool.AssertSchemaVersion(ctx, repository.SchemaVersion); err != nil {
+		t.Fatalf("Expected the migrated database to be at the repository's schema version: %v", err)
+	}
+
+	// An older or newer binary refuses the database
+	for _, expected := range []uint64{repository.SchemaVersion - 1, repository.SchemaVersion + 1} {
+		err := pool.AssertSchemaVersion(ctx, expected)
+		if !errors.Is(err, db.ErrSchemaVersionMismatch) {
+			t.Fatalf("Expected a mismatch asserting version %d, got: %v", expected, err)
+		}
+	}
+}
+
+func TestExecExpectRows(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb.WithPostMigrationSQL("CREATE TABLE flags (id INT PRIMARY KEY, enabled BOOLEAN NOT NULL); INSERT INTO flags VALUES (1, false), (2, false)")).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	pool := tc.GetDBPool(test.ConfigDb)
+
+	// Meeting the threshold returns the affected rows
+	rows, err := pool.ExecExpectRows(ctx, 2, "UPDATE flags SET enabled = true")
+	if err != nil || rows != 2 {
+		t.Fatalf("Expected 2 affected rows, got %d: %v", rows, err)
+	}
+
+	// Fewer affected rows fail with ErrNotFound, still reporting how many were affected
+	rows, err = pool.ExecExpectRows(ctx, 2, "DELETE FROM flags WHERE id = 1")
+	if !errors.Is(err, db.ErrNotFound) || rows != 1 {
+		t.Fatalf("Expected ErrNotFound with 1 affected row, got %d: %v", rows, err)
+	}
+	rows, err = pool.ExecExpectRows(ctx, 1, "DELETE FROM flags WHERE id = 1")
+	if !errors.Is(err, db.ErrNotFound) || rows != 0 {
+		t.Fatalf("Expected ErrNotFound deleting a missing row, got %d: %v", rows, err)
+	}
+
+	// Inside RunInTx the failure rolls the statement back
+	err = pool.RunInTx(ctx, func(ctx context.Context) error {
+		_, err := pool.ExecExpectRows(ctx, 2, "DELETE FROM flags")
+		return err
+	})
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound from the transaction, got: %v", err)
+	}
+	var remaining int
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM flags").Scan(&remaining); err != nil {
+		t.Fatalf("Failed to count flags: %v", err)
+	}
+	if remaining != 1 {
+		t.Fatalf("Expected the failed delete to be rolled back, %d rows remain", remaining)
+	}
+
+	// Statement errors are returned as they are
+	if _, err := pool.ExecExpectRows(ctx, 0, "DELETE FROM missing_table"); err == nil || errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected the statement error, got: %v", err)
+	}
+}
+
+func TestExecReturning(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb.WithPostMigrationSQL("CREATE TABLE flags (id INT PRIMARY KEY, name TEXT NOT NULL); INSERT INTO flags VALUES (1, 'dark-mode')")).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	pool := tc.GetDBPool(test.ConfigDb)
+
+	// The returned row is scanned into dest
+	var name string
+	if err := pool.ExecReturning(ctx, []any{&name}, "DELETE FROM flags WHERE id = 1 RETURNING name"); err != nil {
+		t.Fatalf("Failed to delete flag: %v", err)
+	}
+	if name != "dark-mode" {
+		t.Fatalf("Expected the deleted flag's name, got %q", name)
+	}
+
+	// No affected row fails with ErrNotFound
+	err = pool.ExecReturning(ctx, []any{&name}, "DELETE FROM flags WHERE id = 1 RETURNING name")
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound deleting a missing row, got: %v", err)
+	}
+
+	// Statement errors are returned as they are
+	err = pool.ExecReturning(ctx, []any{&name}, "DELETE FROM missing_table RETURNING name")
+	if err == nil || errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected the statement error, got: %v", err)
+	}
+}
+
+func TestListenSharesOneConnectionPerChannel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(context.Background()); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	pool := tc.GetDBPool(test.ConfigDb)
+
+	// More listeners than the pool has connections
+	listeners := make([]*db.Listener, pool.Stat().MaxConns()+2)
+	for i := range listeners {
+		listener, err := pool.Listen(ctx, "shared_channel")
+		if err != nil {
+			t.Fatalf("Failed to open listener %d: %v", i, err)
+		}
+		defer listener.Close()
+		listeners[i] = listener
+	}
+	if acquired := pool.Stat().AcquiredConns(); acquired != 0 {
+		t.Fatalf("Expected listeners to hold no pooled connection, %d are acquired", acquired)
+	}
+
+	// Queries still get a connection, and every listener receives the notification
+	if _, err := pool.Exec(ctx, "SELECT pg_notify('shared_channel', 'hello')"); err != nil {
+		t.Fatalf("Failed to notify: %v", err)
+	}
+	for i, listener := range listeners {
+		payload, err := listener.Wait(ctx)
+		if err != nil {
+			t.Fatalf("Listener %d failed to receive the notification: %v", i, err)
+		}
+		if payload != "hello" {
+			t.Fatalf("Listener %d received %q, want hello", i, payload)
+		}
+	}
+
+	// The channel's connection is the only one listening
+	var listening int
+	err = pool.QueryRow(ctx, "SELECT count(*) FROM pg_stat_activity WHERE datname = current_database() AND query LIKE 'LISTEN%'").Scan(&listening)
+	if err != nil {
+		t.Fatalf("Failed to count listening connections: %v", err)
+	}
+	if listening != 1 {
+		t.Fatalf("Expected 1 listening connection, got %d", listening)
+	}
+}
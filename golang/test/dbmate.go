@@ -21,10 +21,55 @@ type DbmateMigration struct {
 	DownSQL string
 }
 
+// MigrationLogger receives migration progress messages; *log.Logger satisfies it
+type MigrationLogger interface {
+	Printf(format string, v ...any)
+}
+
+// DefaultMigrationLockTimeout is how long a migration run waits for a concurrent run on the same database
+const DefaultMigrationLockTimeout = 2 * time.Minute
+
+const (
+	// migrationLockKey identifies the advisory lock serializing migration runs on a database ("dbmate" in hex)
+	migrationLockKey int64 = 0x64626d617465
+	// migrationLockRetryInterval is how often a waiting run retries the migration lock
+	migrationLockRetryInterval = 250 * time.Millisecond
+)
+
+// DbmateOptions tunes a migration run
+type DbmateOptions struct {
+	// Logger receives progress messages; nil writes to the global logger
+	Logger MigrationLogger
+	// LockTimeout bounds the wait for a concurrent run to finish; zero uses DefaultMigrationLockTimeout
+	LockTimeout time.Duration
+}
+
 // RunDbmateMigrations runs dbmate format migrations from a directory
 // This allows tests to use the same migration files as production
 // replacements is a map of strings to replace in the SQL before execution (e.g., database names)
 func RunDbmateMigrations(ctx context.Context, dbURL string, migrationsDir string, replacements map[string]string) error {
+	return RunDbmateMigrationsWithLogger(ctx, nil, dbURL, migrationsDir, replacements)
+}
+
+// RunDbmateMigrationsWithLogger runs dbmate migrations, writing progress to logger
+// A nil logger writes to the global logger
+func RunDbmateMigrationsWithLogger(ctx context.Context, logger MigrationLogger, dbURL string, migrationsDir string, replacements map[string]string) error {
+	return RunDbmateMigrationsWithOptions(ctx, DbmateOptions{Logger: logger}, dbURL, migrationsDir, replacements)
+}
+
+// RunDbmateMigrationsWithOptions runs dbmate migrations while holding the database's migration lock
+// A concurrent run waits up to opts.LockTimeout for the lock, then finds the migrations already applied
+// Cancelling ctx aborts the running migration, rolling it back, and returns an error wrapping ctx.Err()
+func RunDbmateMigrationsWithOptions(ctx context.Context, opts DbmateOptions, dbURL string, migrationsDir string, replacements map[string]string) error {
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	lockTimeout := opts.LockTimeout
+	if lockTimeout == 0 {
+		lockTimeout = DefaultMigrationLockTimeout
+	}
+
 	// Connect to database
 	pool, err := pgxpool.New(ctx, dbURL)
 	if err != nil {
@@ -32,6 +77,13 @@ func RunDbmateMigrations(ctx context.Context, dbURL string, migrationsDir string
 	}
 	defer pool.Close()
 
+	// Serialize with other runs before looking at the schema
+	release, err := acquireMigrationLock(ctx, pool, lockTimeout, logger)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Create schema_migrations table (dbmate uses this)
 	_, err = pool.Exec(ctx, `
 		CREATE TABLE IF NOT EXISTS schema_migrations (
@@ -42,7 +94,7 @@ func RunDbmateMigrations(ctx context.Context, dbURL string, migrationsDir string
 		return fmt.Errorf("failed to create schema_migrations table: %w", err)
 	}
 
-	log.Printf("Looking for migrations in: %s", migrationsDir)
+	logger.Printf("Looking for migrations in: %s", migrationsDir)
 
 	// Read migration files
 	migrations, err := readDbmateMigrations(migrationsDir)
@@ -50,7 +102,7 @@ func RunDbmateMigrations(ctx context.Context, dbURL string, migrationsDir string
 		return fmt.Errorf("failed to read migrations: %w", err)
 	}
 
-	log.Printf("Found %d migration files in %s", len(migrations), migrationsDir)
+	logger.Printf("Found %d migration files in %s", len(migrations), migrationsDir)
 
 	// Get applied migrations
 	appliedVersions, err := getAppliedMigrations(ctx, pool)
@@ -58,16 +110,19 @@ func RunDbmateMigrations(ctx context.Context, dbURL string, migrationsDir string
 		return fmt.Errorf("failed to get applied migrations: %w", err)
 	}
 
-	log.Printf("Already applied: %d migrations", len(appliedVersions))
+	logger.Printf("Already applied: %d migrations", len(appliedVersions))
 
 	// Apply pending migrations
 	for _, migration := range migrations {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("migrations aborted before %s: %w", migration.Version, err)
+		}
 		if _, applied := appliedVersions[migration.Version]; applied {
-			log.Printf("Migration %s already applied, skipping", migration.Version)
+			logger.Printf("Migration %s already applied, skipping", migration.Version)
 			continue
 		}
 
-		log.Printf("Applying migration %s: %s", migration.Version, migration.Name)
+		logger.Printf("Applying migration %s: %s", migration.Version, migration.Name)
 
 		// Execute migration in a transaction
 		tx, err := pool.Begin(ctx)
@@ -81,9 +136,12 @@ func RunDbmateMigrations(ctx context.Context, dbURL string, migrationsDir string
 			upSQL = strings.ReplaceAll(upSQL, old, new)
 		}
 
-		// Execute the up migration
+		// Execute the up migration; pgx cancels the statement on the server when ctx is done
 		if _, err := tx.Exec(ctx, upSQL); err != nil {
-			tx.Rollback(ctx)
+			tx.Rollback(context.Background())
+			if ctx.Err() != nil {
+				return fmt.Errorf("migration %s aborted: %w", migration.Version, ctx.Err())
+			}
 			return fmt.Errorf("failed to execute migration %s: %w", migration.Version, err)
 		}
 
@@ -97,13 +155,57 @@ func RunDbmateMigrations(ctx context.Context, dbURL string, migrationsDir string
 			return fmt.Errorf("failed to commit migration %s: %w", migration.Version, err)
 		}
 
-		log.Printf("Migration %s applied successfully", migration.Version)
+		logger.Printf("Migration %s applied successfully", migration.Version)
 	}
 
-	log.Println("All migrations completed successfully")
+	logger.Printf("All migrations completed successfully")
 	return nil
 }
 
+// acquireMigrationLock takes the migration advisory lock on a dedicated connection, retrying until timeout or ctx ends
+// The returned function releases the lock and the connection
+func acquireMigrationLock(ctx context.Context, pool *pgxpool.Pool, timeout time.Duration, logger MigrationLogger) (func(), error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection for migration lock: %w", err)
+	}
+
+	lockCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(migrationLockRetryInterval)
+	defer ticker.Stop()
+
+	for waiting := false; ; waiting = true {
+		var locked bool
+		if err := conn.QueryRow(lockCtx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&locked); err != nil {
+			conn.Release()
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if locked {
+			break
+		}
+
+		if !waiting {
+			logger.Printf("Waiting up to %s for a concurrent migration run to finish", timeout)
+		}
+		select {
+		case <-lockCtx.Done():
+			conn.Release()
+			return nil, fmt.Errorf("failed to acquire migration lock within %s: %w", timeout, lockCtx.Err())
+		case <-ticker.C:
+		}
+	}
+
+	return func() {
+		// Unlock even if ctx is done; closing the pool ends the session and releases the lock anyway
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			logger.Printf("Failed to release migration lock: %v", err)
+		}
+		conn.Release()
+	}, nil
+}
+
 // readDbmateMigrations reads and parses dbmate format migration files
 func readDbmateMigrations(dir string) ([]DbmateMigration, error) {
 	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
@@ -199,12 +301,28 @@ func getAppliedMigrations(ctx context.Context, pool *pgxpool.Pool) (map[string]b
 
 // MustRunDbmateMigrations runs dbmate migrations or panics
 func MustRunDbmateMigrations(migrationsDir string, dbURL string, replacements map[string]string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	MustRunDbmateMigrationsWithLogger(nil, migrationsDir, dbURL, replacements)
+}
+
+// MustRunDbmateMigrationsWithLogger runs dbmate migrations with progress written to logger or exits
+// A nil logger writes to the global logger; the fatal error always goes to the global logger
+func MustRunDbmateMigrationsWithLogger(logger MigrationLogger, migrationsDir string, dbURL string, replacements map[string]string) {
+	MustRunDbmateMigrationsWithContext(context.Background(), logger, migrationsDir, dbURL, replacements)
+}
+
+// MustRunDbmateMigrationsWithContext runs dbmate migrations or exits, aborting them when ctx is cancelled
+// The run is also bounded by a 5 minute timeout
+func MustRunDbmateMigrationsWithContext(ctx context.Context, logger MigrationLogger, migrationsDir string, dbURL string, replacements map[string]string) {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
-	log.Printf("Running dbmate migrations from %s...", migrationsDir)
-	if err := RunDbmateMigrations(ctx, dbURL, migrationsDir, replacements); err != nil {
+	logger.Printf("Running dbmate migrations from %s...", migrationsDir)
+	if err := RunDbmateMigrationsWithLogger(ctx, logger, dbURL, migrationsDir, replacements); err != nil {
 		log.Fatalf("Failed to run dbmate migrations: %v", err)
 	}
-	log.Println("Dbmate migrations completed successfully")
+	logger.Printf("Dbmate migrations completed successfully")
 }
@@ -0,0 +1,56 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
+)
+
+// How often WaitForRows runs its query
+const waitForRowsInterval = 50 * time.Millisecond
+
+// Eventually polls cond every interval until it returns true, failing t if it hasn't within timeout
+// Use it instead of sleeping when asserting on something that happens in the background
+func Eventually(t testing.TB, timeout, interval time.Duration, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Condition not met within %s", timeout)
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
+// WaitForRows runs query with args until it returns want rows, or returns an error once ctx is done
+func WaitForRows(ctx context.Context, pool *db.DBPool, query string, want int, args ...any) error {
+	var got int
+	for {
+		rows, err := pool.Query(ctx, query, args...)
+		if err == nil {
+			got = 0
+			for rows.Next() {
+				got++
+			}
+			rows.Close()
+			err = rows.Err()
+		}
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to query rows: %w", err)
+		}
+		if err == nil && got == want {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("expected %d rows, last got %d: %w", want, got, ctx.Err())
+		case <-time.After(waitForRowsInterval):
+		}
+	}
+}
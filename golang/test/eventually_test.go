@@ -0,0 +1,60 @@
+package test_test
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/berendjan/golang-bazel-starter/golang/test"
+)
+
+// recordingTB records Fatalf instead of failing the test, stopping the goroutine like testing.T does
+type recordingTB struct {
+	testing.TB
+	failure string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.failure = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+// runEventually calls test.Eventually on a recordingTB and returns its failure, empty if it succeeded
+func runEventually(timeout time.Duration, cond func() bool) string {
+	tb := &recordingTB{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		test.Eventually(tb, timeout, time.Millisecond, cond)
+	}()
+	<-done
+	return tb.failure
+}
+
+func TestEventuallyPollsUntilConditionHolds(t *testing.T) {
+	var calls atomic.Int32
+	failure := runEventually(time.Second, func() bool {
+		return calls.Add(1) == 3
+	})
+	if failure != "" {
+		t.Fatalf("Expected Eventually to succeed, got: %s", failure)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("Expected the condition to be polled 3 times, got %d", got)
+	}
+}
+
+func TestEventuallyFailsAfterTimeout(t *testing.T) {
+	start := time.Now()
+	failure := runEventually(20*time.Millisecond, func() bool { return false })
+	if failure == "" {
+		t.Fatal("Expected Eventually to fail when the condition never holds")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("Expected Eventually to wait out the timeout, returned after %s", elapsed)
+	}
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+
+	"github.com/berendjan/golang-bazel-starter/golang/config/client"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/auth"
+)
+
+// FakeKratos serves the Kratos /sessions/whoami endpoint from a fixed set of sessions
+// Sessions are keyed by the value of the client.SessionCookieName cookie, as sent by client.SessionToken
+type FakeKratos struct {
+	*httptest.Server
+	calls atomic.Int64
+
+	mu       sync.Mutex
+	sessions map[string]auth.KratosSession
+}
+
+// NewFakeKratos starts a fake Kratos answering with sessions; the caller must Close it
+// Unknown or missing session cookies get a 401, any session found is returned as is, inactive ones included
+func NewFakeKratos(sessions map[string]auth.KratosSession) *FakeKratos {
+	k := &FakeKratos{sessions: make(map[string]auth.KratosSession, len(sessions))}
+	for token, session := range sessions {
+		k.sessions[token] = session
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sessions/whoami", k.whoami)
+	k.Server = httptest.NewServer(mux)
+	return k
+}
+
+// SetSession adds or replaces the session of token
+func (k *FakeKratos) SetSession(token string, session auth.KratosSession) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.sessions[token] = session
+}
+
+// AuthMiddleware returns an AuthMiddleware validating sessions against this fake
+func (k *FakeKratos) AuthMiddleware() *auth.AuthMiddleware {
+	return auth.NewAuthMiddleware(k.URL)
+}
+
+// Calls returns how many whoami requests the fake answered
+func (k *FakeKratos) Calls() int64 {
+	return k.calls.Load()
+}
+
+// whoami answers like Kratos: the session as JSON, or a 401 error body
+func (k *FakeKratos) whoami(w http.ResponseWriter, r *http.Request) {
+	k.calls.Add(1)
+	w.Header().Set("Content-Type", "application/json")
+
+	var session auth.KratosSession
+	found := false
+	if cookie, err := r.Cookie(client.SessionCookieName); err == nil {
+		k.mu.Lock()
+		session, found = k.sessions[cookie.Value]
+		k.mu.Unlock()
+	}
+	if !found {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{
+			"error": map[string]any{
+				"code":    http.StatusUnauthorized,
+				"status":  "Unauthorized",
+				"message": "No valid session credentials found in the request.",
+			},
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(session)
+}
@@ -0,0 +1,199 @@
+package test_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	configClient "github.com/berendjan/golang-bazel-starter/golang/config/client"
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/auth"
+	"github.com/berendjan/golang-bazel-starter/golang/test"
+	commonpb "github.com/berendjan/golang-bazel-starter/proto/common/v1"
+	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
+)
+
+// fakeSessions are the sessions every fake Kratos test starts with
+var fakeSessions = map[string]auth.KratosSession{
+	"active-token":  {ID: "session-1", Active: true, Identity: auth.KratosIdentity{ID: "kratos-user"}},
+	"expired-token": {ID: "session-2", Active: false, Identity: auth.KratosIdentity{ID: "expired-user"}},
+}
+
+func TestFakeKratosValidatesSessions(t *testing.T) {
+	kratos := test.NewFakeKratos(fakeSessions)
+	defer kratos.Close()
+	middleware := kratos.AuthMiddleware()
+
+	// withSession returns an incoming context carrying token as the session cookie
+	withSession := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(),
+			metadata.Pairs("cookie", configClient.SessionCookieName+"="+token))
+	}
+
+	userID, err := middleware.ExtractUserID(withSession("active-token"))
+	if err != nil {
+		t.Fatalf("Expected the active session to authenticate, got: %v", err)
+	}
+	if userID != "kratos-user" {
+		t.Fatalf("Expected user kratos-user, got %s", userID)
+	}
+
+	_, err = middleware.ExtractUserID(withSession("expired-token"))
+	if status.Code(err) != codes.Unauthenticated || status.Convert(err).Message() != "session is not active" {
+		t.Fatalf("Expected the inactive session to be rejected as not active, got: %v", err)
+	}
+
+	_, err = middleware.ExtractUserID(withSession("unknown-token"))
+	if status.Code(err) != codes.Unauthenticated || status.Convert(err).Message() != "invalid session" {
+		t.Fatalf("Expected the unknown cookie to be rejected as invalid, got: %v", err)
+	}
+
+	if calls := kratos.Calls(); calls != 3 {
+		t.Fatalf("Expected 3 whoami calls, got %d", calls)
+	}
+}
+
+func TestCreateAccountAuthenticatedByFakeKratos(t *testing.T) {
+	ctx := context.Background()
+
+	kratos := test.NewFakeKratos(fakeSessions)
+	defer kratos.Close()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Requests are authenticated by the real AuthMiddleware calling the fake
+	tc.AuthValidator().Delegate = kratos.AuthMiddleware()
+
+	// clientWithSession returns a client sending token as the session cookie
+	clientWithSession := func(token string) *configClient.ConfigurationClient {
+		return tc.NewGrpcClient(test.GrpcServer, configClient.Config{
+			Insecure:    true,
+			TenantID:    testTenant,
+			Credentials: configClient.SessionToken(token),
+		})
+	}
+
+	if _, err := clientWithSession("active-token").CreateAccount(ctx, "kratos-account"); err != nil {
+		t.Fatalf("Failed to create account with an active session: %v", err)
+	}
+	var userID string
+	err = tc.GetDBPool(test.ConfigDb).QueryRow(ctx,
+		"SELECT user_id FROM audit_log WHERE target_id = $1",
+		[]byte("kratos-account"),
+	).Scan(&userID)
+	if err != nil {
+		t.Fatalf("Failed to find audit row for created account: %v", err)
+	}
+	if userID != "kratos-user" {
+		t.Fatalf("Expected the audit row to record the Kratos identity, got %s", userID)
+	}
+
+	for _, token := range []string{"expired-token", "unknown-token"} {
+		if _, err := clientWithSession(token).CreateAccount(ctx, "rejected-"+token); status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("Expected Unauthenticated for %s, got: %v", token, err)
+		}
+	}
+}
+
+func TestGroupEventStreamRejectsUnauthenticatedAccept(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	kratos := test.NewFakeKratos(fakeSessions)
+	defer kratos.Close()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(context.Background()); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+	tc.AuthValidator().Delegate = kratos.AuthMiddleware()
+	pool := tc.GetDBPool(test.ConfigDb)
+
+	groupID := &commonpb.ConfigurationIdProto{Id: []byte("guarded-group"), Type: 2}
+	accept := &configpb.GroupEventStreamRequestProto{
+		Action: &configpb.GroupEventStreamRequestProto_AcceptRequestToJoin{
+			AcceptRequestToJoin: &configpb.AcceptRequestToJoinGroupProto{
+				AccountId:         ids.AccountID("member").Proto(),
+				GroupId:           groupID,
+				InviteeId:         ids.AccountID("joiner").Proto(),
+				EncryptedGroupKey: []byte("encrypted-group-key"),
+			},
+		},
+	}
+	// count returns the rows of the test tenant's events and the audit entries of accepted join requests
+	count := func() (events, audited int) {
+		if err := pool.QueryRow(ctx, "SELECT count(*) FROM configuration_events WHERE tenant_id = $1", testTenant).Scan(&events); err != nil {
+			t.Fatalf("Failed to count events: %v", err)
+		}
+		if err := pool.QueryRow(ctx, "SELECT count(*) FROM audit_log WHERE method = 'AcceptRequestToJoinGroup'").Scan(&audited); err != nil {
+			t.Fatalf("Failed to count audit rows: %v", err)
+		}
+		return events, audited
+	}
+
+	// Without a session the acceptance ends the stream with Unauthenticated and stores nothing
+	anonymous := tc.NewGrpcClient(test.GrpcServer, configClient.Config{Insecure: true, TenantID: testTenant})
+	stream, err := anonymous.GroupEventStream(ctx, groupID)
+	if err != nil {
+		t.Fatalf("Failed to open the anonymous stream: %v", err)
+	}
+	if err := stream.Send(accept); err != nil {
+		t.Fatalf("Failed to send the acceptance: %v", err)
+	}
+	if event, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Expected Unauthenticated for an anonymous acceptance, got %v, %v", event, err)
+	}
+	if events, audited := count(); events != 0 || audited != 0 {
+		t.Fatalf("Expected the rejected acceptance to store nothing, got %d events and %d audit rows", events, audited)
+	}
+
+	// With a session the acceptance is delivered and audited as the Kratos user
+	member := tc.NewGrpcClient(test.GrpcServer, configClient.Config{
+		Insecure:    true,
+		TenantID:    testTenant,
+		Credentials: configClient.SessionToken("active-token"),
+	})
+	stream, err = member.GroupEventStream(ctx, groupID)
+	if err != nil {
+		t.Fatalf("Failed to open the member's stream: %v", err)
+	}
+	if err := stream.Send(accept); err != nil {
+		t.Fatalf("Failed to send the acceptance: %v", err)
+	}
+	if event, err := stream.Recv(); err != nil || event.GetPendingMemberAcceptedEvent() == nil {
+		t.Fatalf("Expected the acceptance to be delivered, got %v, %v", event, err)
+	}
+	if events, audited := count(); events != 1 || audited != 1 {
+		t.Fatalf("Expected 1 event and 1 audit row, got %d and %d", events, audited)
+	}
+	var userID string
+	if err := pool.QueryRow(ctx, "SELECT user_id FROM audit_log WHERE method = 'AcceptRequestToJoinGroup'").Scan(&userID); err != nil {
+		t.Fatalf("Failed to read the audit row: %v", err)
+	}
+	if userID != "kratos-user" {
+		t.Fatalf("Expected the audit row to record the Kratos identity, got %s", userID)
+	}
+}
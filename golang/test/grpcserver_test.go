@@ -1,13 +1,54 @@
 package test_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
 
 	configClient "github.com/berendjan/golang-bazel-starter/golang/config/client"
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
+	"github.com/berendjan/golang-bazel-starter/golang/framework/serverbase"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/dedup"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/tenant"
 	"github.com/berendjan/golang-bazel-starter/golang/test"
+	commonpb "github.com/berendjan/golang-bazel-starter/proto/common/v1"
+	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
+	gw "github.com/berendjan/golang-bazel-starter/proto/configuration_service/v1/gateway"
 )
 
+// testTenant is the tenant all test clients act on behalf of
+const testTenant = test.TestTenantID
+
 // TestBuilderWithServers demonstrates using the builder to create servers
 func TestCreateAccount(t *testing.T) {
 	ctx := context.Background()
@@ -26,7 +67,7 @@ func TestCreateAccount(t *testing.T) {
 	}()
 
 	// Send request to server with client
-	client := configClient.MustNewClient(ctx, &configClient.Config{ServerAddress: tc.GetGrpcClient(test.GrpcServer), Insecure: true})
+	client := tc.GrpcClient(test.GrpcServer)
 
 	testName := "test account"
 
@@ -35,13 +76,41 @@ func TestCreateAccount(t *testing.T) {
 		t.Fatalf("Failed to create test account: %v", err)
 	}
 
-	name := string(acc.AccountId.Id)
+	name := acc.GetName()
 
 	if name != testName {
 		t.Fatalf("Returning name does not match")
 	}
 }
 
+func TestCreateAccountTraversesMiddlewareChain(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+	if _, err := client.CreateAccount(test.WithMessageID(ctx, "create"), "traced account"); err != nil {
+		t.Fatalf("Failed to create test account: %v", err)
+	}
+
+	// Audit runs after the middleware and before the repository stores the account
+	want := []string{"middlewareOne", "middlewareTwo", "auditMiddleware", "accountRepository"}
+	if got := tc.RecordingMessenger().Trace("create"); !slices.Equal(got, want) {
+		t.Fatalf("Expected the account creation to traverse %v, got %v", want, got)
+	}
+}
+
 func TestDeleteAccount(t *testing.T) {
 	ctx := context.Background()
 
@@ -59,7 +128,7 @@ func TestDeleteAccount(t *testing.T) {
 	}()
 
 	// Create a client
-	client := configClient.MustNewClient(ctx, &configClient.Config{ServerAddress: tc.GetGrpcClient(test.GrpcServer), Insecure: true})
+	client := tc.GrpcClient(test.GrpcServer)
 
 	testName := "account-to-delete"
 
@@ -69,9 +138,9 @@ func TestDeleteAccount(t *testing.T) {
 		t.Fatalf("Failed to create test account: %v", err)
 	}
 
-	accountID := string(acc.AccountId.Id)
-	if accountID != testName {
-		t.Fatalf("Created account ID does not match: got %s, want %s", accountID, testName)
+	accountID := ids.AccountIDFromProto(acc.GetAccountId())
+	if !accountID.Equal(ids.AccountID(testName)) {
+		t.Fatalf("Created account ID does not match: got %q, want %q", accountID.Bytes(), testName)
 	}
 
 	// Delete the account
@@ -84,8 +153,49 @@ func TestDeleteAccount(t *testing.T) {
 		t.Fatalf("Expected status code 200, got %d: %s", deleteResp.Code, deleteResp.Message)
 	}
 
-	if deleteResp.Message != "Account deleted successfully" {
-		t.Fatalf("Unexpected delete message: %s", deleteResp.Message)
+	// The message names the deleted account so clients can confirm what was removed
+	if want := fmt.Sprintf("Account deleted: %s (%s)", testName, accountID); deleteResp.Message != want {
+		t.Fatalf("Expected delete message %q, got %q", want, deleteResp.Message)
+	}
+}
+
+func TestDeleteAccountByIDThatLooksLikeBase64(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	// "test" is valid base64 itself; the ID must reach the repository unchanged
+	acc, err := client.CreateAccount(ctx, "test")
+	if err != nil {
+		t.Fatalf("Failed to create test account: %v", err)
+	}
+	if _, err := client.DeleteAccount(ctx, ids.AccountIDFromProto(acc.GetAccountId())); err != nil {
+		t.Fatalf("Failed to delete account: %v", err)
+	}
+
+	// A raw, unencoded ID is rejected instead of silently matching nothing
+	conn, err := grpc.NewClient("passthrough:///"+tc.GetGrpcClient(test.GrpcServer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer conn.Close()
+	tenantCtx := metadata.AppendToOutgoingContext(ctx, "x-tenant-id", testTenant)
+	_, err = gw.NewConfigurationClient(conn).DeleteAccount(tenantCtx, &configpb.AccountDeletionRequestProto{Id: "not base64!"})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument for a malformed ID, got %s: %v", code, err)
 	}
 }
 
@@ -106,10 +216,10 @@ func TestDeleteAccountNotFound(t *testing.T) {
 	}()
 
 	// Create a client
-	client := configClient.MustNewClient(ctx, &configClient.Config{ServerAddress: tc.GetGrpcClient(test.GrpcServer), Insecure: true})
+	client := tc.GrpcClient(test.GrpcServer)
 
 	// Try to delete a non-existent account
-	_, err = client.DeleteAccount(ctx, "non-existent-account")
+	_, err = client.DeleteAccount(ctx, ids.AccountID("non-existent-account"))
 	if err == nil {
 		t.Fatal("Expected error when deleting non-existent account, got nil")
 	}
@@ -118,7 +228,80 @@ func TestDeleteAccountNotFound(t *testing.T) {
 	if err.Error() == "" {
 		t.Fatal("Error message should not be empty")
 	}
+	if code := status.Code(err); code != codes.NotFound {
+		t.Fatalf("Expected NotFound, got %s: %v", code, err)
+	}
 	t.Logf("Got expected error: %v", err)
+
+	// Deleting a missing account is a no-op and is not audited
+	var audited int
+	err = tc.GetDBPool(test.ConfigDb).QueryRow(ctx, "SELECT count(*) FROM audit_log WHERE method = 'DeleteAccount'").Scan(&audited)
+	if err != nil {
+		t.Fatalf("Failed to count audit rows: %v", err)
+	}
+	if audited != 0 {
+		t.Fatalf("Expected no audit row for a no-op delete, got %d", audited)
+	}
+}
+
+func TestRetriedDeleteAccount(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		server   test.ServerConfig
+		wantCode codes.Code
+	}{
+		{name: "NotFoundByDefault", server: test.GrpcServer, wantCode: codes.NotFound},
+		{name: "OKWithIdempotentDeletes", server: test.IdempotentDeleteGrpcServer, wantCode: codes.OK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			testCtx, err := test.NewTestContextBuilder().
+				WithDatabase(test.ConfigDb).
+				WithServer(tc.server).
+				Build(ctx)
+			if err != nil {
+				t.Fatalf("Failed to create test context: %v", err)
+			}
+			defer func() {
+				if err := testCtx.CleanUp(ctx); err != nil {
+					t.Logf("Warning: cleanup failed: %v", err)
+				}
+			}()
+
+			client := testCtx.GrpcClient(tc.server)
+			account, err := client.CreateAccount(ctx, "deleted-twice")
+			if err != nil {
+				t.Fatalf("Failed to create account: %v", err)
+			}
+			accountID := ids.AccountIDFromProto(account.GetAccountId())
+
+			// An earlier attempt removed the account; the dedup window short-circuits only exact retries
+			// arriving right after it, so remove it directly to model a retry arriving later
+			pool := testCtx.GetDBPool(test.ConfigDb)
+			if _, err := pool.Exec(ctx, "DELETE FROM accounts WHERE id = $1", accountID.Bytes()); err != nil {
+				t.Fatalf("Failed to remove account: %v", err)
+			}
+
+			resp, err := client.DeleteAccount(ctx, accountID)
+			if code := status.Code(err); code != tc.wantCode {
+				t.Fatalf("Expected %s for the retried delete, got %s: %v", tc.wantCode, code, err)
+			}
+			if tc.wantCode == codes.OK && resp.GetCode() != 200 {
+				t.Fatalf("Expected code 200 for the retried delete, got %d: %s", resp.GetCode(), resp.GetMessage())
+			}
+
+			// Deleting a missing account is a no-op and is not audited in either mode
+			var audited int
+			err = pool.QueryRow(ctx, "SELECT count(*) FROM audit_log WHERE method = 'DeleteAccount'").Scan(&audited)
+			if err != nil {
+				t.Fatalf("Failed to count audit rows: %v", err)
+			}
+			if audited != 0 {
+				t.Fatalf("Expected no audited delete, got %d", audited)
+			}
+		})
+	}
 }
 
 func TestListAccounts(t *testing.T) {
@@ -138,7 +321,7 @@ func TestListAccounts(t *testing.T) {
 	}()
 
 	// Create a client
-	client := configClient.MustNewClient(ctx, &configClient.Config{ServerAddress: tc.GetGrpcClient(test.GrpcServer), Insecure: true})
+	client := tc.GrpcClient(test.GrpcServer)
 
 	// Initially, list should be empty
 	accounts, err := client.ListAccounts(ctx)
@@ -172,7 +355,7 @@ func TestListAccounts(t *testing.T) {
 	// Verify all test accounts are in the list
 	accountMap := make(map[string]bool)
 	for _, acc := range accounts {
-		accountMap[string(acc.AccountId.Id)] = true
+		accountMap[acc.GetName()] = true
 	}
 
 	for _, name := range testAccounts {
@@ -199,7 +382,7 @@ func TestListAccountsEmpty(t *testing.T) {
 	}()
 
 	// Create a client
-	client := configClient.MustNewClient(ctx, &configClient.Config{ServerAddress: tc.GetGrpcClient(test.GrpcServer), Insecure: true})
+	client := tc.GrpcClient(test.GrpcServer)
 
 	// List accounts on a fresh database (should be empty or return without error)
 	accounts, err := client.ListAccounts(ctx)
@@ -227,7 +410,7 @@ func TestAccountLifecycle(t *testing.T) {
 	}()
 
 	// Create a client
-	client := configClient.MustNewClient(ctx, &configClient.Config{ServerAddress: tc.GetGrpcClient(test.GrpcServer), Insecure: true})
+	client := tc.GrpcClient(test.GrpcServer)
 
 	testName := "lifecycle-account"
 
@@ -236,7 +419,7 @@ func TestAccountLifecycle(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create account: %v", err)
 	}
-	t.Logf("Created account: %s", string(acc.AccountId.Id))
+	t.Logf("Created account: %s", acc.GetName())
 
 	// 2. Verify it appears in list
 	accounts, err := client.ListAccounts(ctx)
@@ -246,7 +429,7 @@ func TestAccountLifecycle(t *testing.T) {
 
 	found := false
 	for _, a := range accounts {
-		if string(a.AccountId.Id) == testName {
+		if a.GetName() == testName {
 			found = true
 			break
 		}
@@ -256,7 +439,7 @@ func TestAccountLifecycle(t *testing.T) {
 	}
 
 	// 3. Delete the account
-	deleteResp, err := client.DeleteAccount(ctx, testName)
+	deleteResp, err := client.DeleteAccount(ctx, ids.AccountID(testName))
 	if err != nil {
 		t.Fatalf("Failed to delete account: %v", err)
 	}
@@ -272,7 +455,7 @@ func TestAccountLifecycle(t *testing.T) {
 	}
 
 	for _, a := range accounts {
-		if string(a.AccountId.Id) == testName {
+		if a.GetName() == testName {
 			t.Fatal("Deleted account still appears in list")
 		}
 	}
@@ -296,7 +479,7 @@ func TestCreateAccountValidation(t *testing.T) {
 	}()
 
 	// Create a client
-	client := configClient.MustNewClient(ctx, &configClient.Config{ServerAddress: tc.GetGrpcClient(test.GrpcServer), Insecure: true})
+	client := tc.GrpcClient(test.GrpcServer)
 
 	// Try to create account with empty name
 	_, err = client.CreateAccount(ctx, "")
@@ -304,4 +487,2430 @@ func TestCreateAccountValidation(t *testing.T) {
 		t.Fatal("Expected error when creating account with empty name, got nil")
 	}
 	t.Logf("Got expected validation error: %v", err)
+
+	// The error names the offending field for clients to map to their form
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument, got %s: %v", st.Code(), err)
+	}
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = append(violations, badRequest.GetFieldViolations()...)
+		}
+	}
+	if len(violations) != 1 || violations[0].GetField() != "name" {
+		t.Fatalf("Expected a BadRequest violation of field \"name\", got %v", violations)
+	}
+}
+
+func TestCreateAccountRejectsOverLongName(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	longest := strings.Repeat("a", ids.MaxAccountIDBytes)
+	if _, err := client.CreateAccount(ctx, longest); err != nil {
+		t.Fatalf("Expected a name of %d bytes to be accepted: %v", ids.MaxAccountIDBytes, err)
+	}
+
+	// The API rejects the name as a field violation before any write
+	_, err = client.CreateAccount(ctx, longest+"a")
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument for an over-long name, got %s: %v", st.Code(), err)
+	}
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = append(violations, badRequest.GetFieldViolations()...)
+		}
+	}
+	if len(violations) != 1 || violations[0].GetField() != "name" {
+		t.Fatalf("Expected a BadRequest violation of field \"name\", got %v", violations)
+	}
+
+	// Renaming around the limit is rejected by the repository
+	accountID := ids.AccountID(longest)
+	if _, err := client.UpdateAccount(ctx, accountID, longest+"a", nil, "name"); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument renaming to an over-long name, got: %v", err)
+	}
+
+	// Rows written around the repository are caught by the constraint
+	_, err = tc.GetDBPool(test.ConfigDb).Exec(ctx,
+		"INSERT INTO accounts (tenant_id, id, name, type) VALUES ($1, $2, $3, $4)",
+		testTenant, []byte(longest+"b"), longest+"b", ids.AccountType,
+	)
+	if !db.IsCheckViolation(err, "accounts_name_length") {
+		t.Fatalf("Expected the database to reject an over-long name, got: %v", err)
+	}
+
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].GetName() != longest {
+		t.Fatalf("Expected only the account at the limit, got %d accounts", len(accounts))
+	}
+}
+
+func TestCreateAccountWithoutTenant(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Create a client without a tenant
+	client := tc.NewGrpcClient(test.GrpcServer, configClient.Config{Insecure: true})
+
+	_, err = client.CreateAccount(ctx, "tenantless-account")
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expected PermissionDenied when creating without tenant, got: %v", err)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	clientA := tc.NewGrpcClient(test.GrpcServer, configClient.Config{Insecure: true, TenantID: "tenant-a"})
+	clientB := tc.NewGrpcClient(test.GrpcServer, configClient.Config{Insecure: true, TenantID: "tenant-b"})
+
+	testName := "tenant-a-account"
+
+	if _, err := clientA.CreateAccount(ctx, testName); err != nil {
+		t.Fatalf("Failed to create account for tenant A: %v", err)
+	}
+
+	// Tenant B must not see tenant A's account
+	accountsB, err := clientB.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list accounts for tenant B: %v", err)
+	}
+	for _, a := range accountsB {
+		if a.GetName() == testName {
+			t.Fatal("Tenant B can list tenant A's account")
+		}
+	}
+
+	// Tenant B must not be able to delete tenant A's account
+	if _, err := clientB.DeleteAccount(ctx, ids.AccountID(testName)); err == nil {
+		t.Fatal("Tenant B deleted tenant A's account")
+	}
+
+	// Tenant A's account must still exist
+	accountsA, err := clientA.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list accounts for tenant A: %v", err)
+	}
+	found := false
+	for _, a := range accountsA {
+		if a.GetName() == testName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatal("Tenant A's account is missing after tenant B's delete attempt")
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent writes from server goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLogs routes slog output to a JSON buffer for the duration of the test
+func captureLogs(t *testing.T) *syncBuffer {
+	t.Helper()
+	logs := &syncBuffer{}
+
+	previous, writer, flags := slog.Default(), log.Writer(), log.Flags()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(logs, nil)))
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	})
+
+	return logs
+}
+
+// findRPCLog returns the first rpc log entry for the given method
+func findRPCLog(t *testing.T, logs string, method string) map[string]any {
+	t.Helper()
+	for _, line := range strings.Split(logs, "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		if entry["msg"] == "rpc" && entry["method"] == method {
+			return entry
+		}
+	}
+	t.Fatalf("No rpc log entry found for method %s", method)
+	return nil
+}
+
+// findRouteLog returns the first log entry with the given message for a messenger route
+func findRouteLog(t *testing.T, logs string, msg string, route string) map[string]any {
+	t.Helper()
+	for _, line := range strings.Split(logs, "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		if entry["msg"] == msg && entry["route"] == route {
+			return entry
+		}
+	}
+	t.Fatalf("No %q log entry found for route %s", msg, route)
+	return nil
+}
+
+func TestMessengerRoutesAreLogged(t *testing.T) {
+	ctx := context.Background()
+	logs := captureLogs(t)
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+	if _, err := client.CreateAccount(ctx, "routed-account"); err != nil {
+		t.Fatalf("Failed to create test account: %v", err)
+	}
+
+	// Both the API's route and the middleware's onward route are logged on entry and exit
+	for _, route := range []string{"SendMiddleOneRequestFromAccountApi", "SendMiddleOneRequestFromMiddlewareOne"} {
+		findRouteLog(t, logs.String(), "route started", route)
+		finished := findRouteLog(t, logs.String(), "route finished", route)
+		if _, ok := finished["duration"].(float64); !ok {
+			t.Errorf("Expected numeric duration for route %s, got %v", route, finished["duration"])
+		}
+		if _, ok := finished["error"]; ok {
+			t.Errorf("Expected no error for route %s, got %v", route, finished["error"])
+		}
+	}
+}
+
+func TestRPCLogsIncludeUserID(t *testing.T) {
+	ctx := context.Background()
+	logs := captureLogs(t)
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Authenticated call: the injected TestAuthValidator authenticates as the test user
+	client := tc.GrpcClient(test.GrpcServer)
+	if _, err := client.CreateAccount(ctx, "logged-account"); err != nil {
+		t.Fatalf("Failed to create test account: %v", err)
+	}
+
+	// Unauthenticated call: the health service never authenticates
+	conn, err := grpc.NewClient("passthrough:///"+tc.GetGrpcClient(test.GrpcServer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create health client: %v", err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Health check failed: %v", err)
+	}
+
+	createLog := findRPCLog(t, logs.String(), "/configuration_service.v1.Configuration/CreateAccount")
+	if createLog["user_id"] != test.TestUserID {
+		t.Fatalf("Expected create log with user_id %q, got: %v", test.TestUserID, createLog)
+	}
+
+	healthLog := findRPCLog(t, logs.String(), "/grpc.health.v1.Health/Check")
+	if userID, ok := healthLog["user_id"]; ok {
+		t.Fatalf("Expected health check log without user_id, got %v", userID)
+	}
+}
+
+func TestServerLoggerCapturesLogsOfItsOwnServer(t *testing.T) {
+	ctx := context.Background()
+
+	// Two test contexts, as two concurrent tests would have, each with its own server logger
+	newContext := func(logs *syncBuffer) *test.TestContext {
+		tc, err := test.NewTestContextBuilder().
+			WithDatabase(test.ConfigDb).
+			WithServer(test.GrpcServer).
+			WithServerLogger(slog.New(slog.NewJSONHandler(logs, nil))).
+			Build(ctx)
+		if err != nil {
+			t.Fatalf("Failed to create test context: %v", err)
+		}
+		t.Cleanup(func() {
+			if err := tc.CleanUp(ctx); err != nil {
+				t.Logf("Warning: cleanup failed: %v", err)
+			}
+		})
+		return tc
+	}
+	logsA, logsB := &syncBuffer{}, &syncBuffer{}
+	tcA, tcB := newContext(logsA), newContext(logsB)
+
+	if _, err := tcA.GrpcClient(test.GrpcServer).CreateAccount(ctx, "logged-by-a"); err != nil {
+		t.Fatalf("Failed to create test account: %v", err)
+	}
+
+	findRPCLog(t, logsA.String(), "/configuration_service.v1.Configuration/CreateAccount")
+	findRouteLog(t, logsA.String(), "route started", "SendMiddleOneRequestFromAccountApi")
+	if strings.Contains(logsB.String(), "CreateAccount") {
+		t.Fatalf("Expected the other server's logger to miss the call, got:\n%s", logsB.String())
+	}
+
+	// The other context's calls reach its own logger only
+	before := logsA.String()
+	if _, err := tcB.GrpcClient(test.GrpcServer).ListAccounts(ctx); err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	findRPCLog(t, logsB.String(), "/configuration_service.v1.Configuration/ListAccounts")
+	if logsA.String() != before {
+		t.Fatalf("Expected no new logs for the first server, got:\n%s", strings.TrimPrefix(logsA.String(), before))
+	}
+}
+
+func TestCreateAccountIsAudited(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	testName := "audited-account"
+	if _, err := client.CreateAccount(ctx, testName); err != nil {
+		t.Fatalf("Failed to create test account: %v", err)
+	}
+
+	var userID, method, tenantID, result string
+	err = tc.GetDBPool(test.ConfigDb).QueryRow(ctx,
+		"SELECT user_id, method, tenant_id, result FROM audit_log WHERE target_id = $1",
+		[]byte(testName),
+	).Scan(&userID, &method, &tenantID, &result)
+	if err != nil {
+		t.Fatalf("Failed to find audit row for created account: %v", err)
+	}
+
+	if userID != test.TestUserID {
+		t.Fatalf("Audit row user does not match: got %s, want %s", userID, test.TestUserID)
+	}
+	if method != "CreateAccount" || tenantID != testTenant || result != "success" {
+		t.Fatalf("Unexpected audit row: method=%s tenant=%s result=%s", method, tenantID, result)
+	}
+}
+
+func TestUpdateAndDeleteAreAuditedWithTheUser(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+	account, err := client.CreateAccount(ctx, "mutated-account")
+	if err != nil {
+		t.Fatalf("Failed to create test account: %v", err)
+	}
+	accountID := ids.AccountIDFromProto(account.GetAccountId())
+	if _, err := client.UpdateAccount(ctx, accountID, "renamed-account", nil, "name"); err != nil {
+		t.Fatalf("Failed to update test account: %v", err)
+	}
+	if _, err := client.DeleteAccount(ctx, accountID); err != nil {
+		t.Fatalf("Failed to delete test account: %v", err)
+	}
+
+	for _, method := range []string{"UpdateAccount", "DeleteAccount"} {
+		var userID string
+		err := tc.GetDBPool(test.ConfigDb).QueryRow(ctx,
+			"SELECT user_id FROM audit_log WHERE target_id = $1 AND method = $2",
+			accountID.Bytes(), method,
+		).Scan(&userID)
+		if err != nil {
+			t.Fatalf("Failed to find audit row for %s: %v", method, err)
+		}
+		if userID != test.TestUserID {
+			t.Fatalf("Expected the %s audit row to record user %q, got %q", method, test.TestUserID, userID)
+		}
+	}
+}
+
+func TestClientSendsConfiguredCredentials(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+	validator := tc.AuthValidator()
+
+	// A static session token is sent as the Kratos session cookie
+	client := tc.NewGrpcClient(test.GrpcServer, configClient.Config{
+		Insecure:    true,
+		TenantID:    testTenant,
+		Credentials: configClient.SessionToken("static-token"),
+	})
+	if _, err := client.CreateAccount(ctx, "cookie-account"); err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	if got, want := validator.LastCookie(), configClient.SessionCookieName+"=static-token"; got != want {
+		t.Fatalf("Expected the server to receive cookie %q, got %q", want, got)
+	}
+
+	// A func source runs before every call
+	var refreshes atomic.Int32
+	refreshing := tc.NewGrpcClient(test.GrpcServer, configClient.Config{
+		Insecure: true,
+		TenantID: testTenant,
+		Credentials: func(ctx context.Context) (string, error) {
+			return fmt.Sprintf("ory_kratos_session=session-%d", refreshes.Add(1)), nil
+		},
+	})
+	for i := 1; i <= 2; i++ {
+		if _, err := refreshing.CreateAccount(ctx, fmt.Sprintf("refreshed-account-%d", i)); err != nil {
+			t.Fatalf("Failed to create account: %v", err)
+		}
+		if got, want := validator.LastCookie(), fmt.Sprintf("ory_kratos_session=session-%d", i); got != want {
+			t.Fatalf("Expected call %d to carry cookie %q, got %q", i, want, got)
+		}
+	}
+
+	// A failing source fails the call before it is sent
+	failing := tc.NewGrpcClient(test.GrpcServer, configClient.Config{
+		Insecure: true,
+		TenantID: testTenant,
+		Credentials: func(ctx context.Context) (string, error) {
+			return "", fmt.Errorf("session expired")
+		},
+	})
+	calls := validator.Calls()
+	if _, err := failing.CreateAccount(ctx, "uncredentialed-account"); err == nil || !strings.Contains(err.Error(), "session expired") {
+		t.Fatalf("Expected the credential error, got: %v", err)
+	}
+	if validator.Calls() != calls {
+		t.Fatal("Expected a call without credentials not to reach the server")
+	}
+}
+
+func TestCreateAccountRollsBackWhenAuditFails(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Make the audit write, the second write of the RPC, fail for one account
+	pool := tc.GetDBPool(test.ConfigDb)
+	testName := "unauditable-account"
+	if _, err := pool.Exec(ctx, "ALTER TABLE audit_log ADD CONSTRAINT reject_unauditable CHECK (convert_from(target_id, 'UTF8') <> 'unauditable-account')"); err != nil {
+		t.Fatalf("Failed to add audit constraint: %v", err)
+	}
+
+	client := tc.GrpcClient(test.GrpcServer)
+	if _, err := client.CreateAccount(ctx, testName); err == nil {
+		t.Fatal("Expected account creation to fail when its audit entry can't be written")
+	}
+
+	// The account insert succeeded before the audit failed, and rolled back with it
+	var accounts int
+	if err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM accounts WHERE id = $1", []byte(testName)).Scan(&accounts); err != nil {
+		t.Fatalf("Failed to count accounts: %v", err)
+	}
+	if accounts != 0 {
+		t.Fatalf("Expected the account insert to roll back with the failed audit, found %d accounts", accounts)
+	}
+
+	// Other accounts are still created and audited together
+	if _, err := client.CreateAccount(ctx, "auditable-account"); err != nil {
+		t.Fatalf("Failed to create auditable account: %v", err)
+	}
+	var audited int
+	if err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM audit_log WHERE target_id = $1", []byte("auditable-account")).Scan(&audited); err != nil {
+		t.Fatalf("Failed to count audit entries: %v", err)
+	}
+	if audited != 1 {
+		t.Fatalf("Expected 1 audit entry for the committed account, got %d", audited)
+	}
+}
+
+func TestInjectedAuthValidatorAuthenticatesRequests(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Requests carry no session cookie; only the injected validator can authenticate them
+	validator := tc.AuthValidator()
+	validator.UserID = "injected-user"
+	before := validator.Calls()
+
+	client := tc.GrpcClient(test.GrpcServer)
+	testName := "validated-account"
+	if _, err := client.CreateAccount(ctx, testName); err != nil {
+		t.Fatalf("Failed to create test account: %v", err)
+	}
+
+	if calls := validator.Calls() - before; calls != 1 {
+		t.Fatalf("Expected the injected validator to authenticate 1 request, got %d", calls)
+	}
+
+	var userID string
+	err = tc.GetDBPool(test.ConfigDb).QueryRow(ctx,
+		"SELECT user_id FROM audit_log WHERE target_id = $1",
+		[]byte(testName),
+	).Scan(&userID)
+	if err != nil {
+		t.Fatalf("Failed to find audit row for created account: %v", err)
+	}
+	if userID != "injected-user" {
+		t.Fatalf("Expected the audit row to record the injected user, got %s", userID)
+	}
+}
+
+func TestCreateAccountRecordsSpanPerHop(t *testing.T) {
+	ctx := context.Background()
+
+	// Record spans of the generated messenger in memory
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer func() {
+		otel.SetTracerProvider(previous)
+		provider.Shutdown(ctx)
+	}()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+	if _, err := client.CreateAccount(ctx, "traced-account"); err != nil {
+		t.Fatalf("Failed to create test account: %v", err)
+	}
+
+	// CreateAccount hops accountApi -> middlewareOne -> auditMiddleware -> accountRepository
+	spans := exporter.GetSpans()
+	byName := make(map[string]tracetest.SpanStub, len(spans))
+	for _, span := range spans {
+		if _, ok := byName[span.Name]; ok {
+			t.Fatalf("Expected one span per hop, got %s twice", span.Name)
+		}
+		byName[span.Name] = span
+	}
+
+	hops := []string{"accountApi/MiddleOneRequest", "middlewareOne/MiddleOneRequest", "auditMiddleware/MiddleOneRequest"}
+	if len(spans) != len(hops) {
+		t.Fatalf("Expected %d spans, got %d: %v", len(hops), len(spans), byName)
+	}
+	for i, hop := range hops {
+		span, ok := byName[hop]
+		if !ok {
+			t.Fatalf("Missing span for hop %s, got: %v", hop, byName)
+		}
+		if i == 0 {
+			continue
+		}
+		// Each hop is nested under the previous one, so the chain forms a single trace
+		parent := byName[hops[i-1]]
+		if span.Parent.SpanID() != parent.SpanContext.SpanID() || span.SpanContext.TraceID() != parent.SpanContext.TraceID() {
+			t.Fatalf("Expected span %s to be a child of %s", hop, hops[i-1])
+		}
+	}
+}
+
+// seedExportAccounts inserts total accounts for the test tenant
+func seedExportAccounts(t *testing.T, ctx context.Context, tc *test.TestContext, total int) {
+	t.Helper()
+	_, err := tc.GetDBPool(test.ConfigDb).Exec(ctx,
+		"INSERT INTO accounts (tenant_id, id, name, type) SELECT $1, convert_to('export-' || i, 'UTF8'), 'export-' || i, 1 FROM generate_series(1, $2::int) AS i",
+		testTenant, total,
+	)
+	if err != nil {
+		t.Fatalf("Failed to seed accounts: %v", err)
+	}
+}
+
+func TestExportAccountsStreamsAllAccounts(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	const total, batchSize = 3000, 250
+	seedExportAccounts(t, ctx, tc, total)
+
+	client := tc.GrpcClient(test.GrpcServer)
+	seen := make(map[string]bool, total)
+	batches := 0
+	for batch, err := range client.ExportAccounts(ctx, batchSize) {
+		if err != nil {
+			t.Fatalf("Export failed after %d accounts: %v", len(seen), err)
+		}
+		if len(batch) == 0 || len(batch) > batchSize {
+			t.Fatalf("Expected batches of 1 to %d accounts, got %d", batchSize, len(batch))
+		}
+		for _, account := range batch {
+			seen[account.GetName()] = true
+		}
+		batches++
+	}
+
+	if len(seen) != total {
+		t.Fatalf("Expected %d distinct exported accounts, got %d", total, len(seen))
+	}
+	if batches != total/batchSize {
+		t.Fatalf("Expected %d batches, got %d", total/batchSize, batches)
+	}
+
+	// Other tenants' accounts are not exported
+	otherClient := tc.NewGrpcClient(test.GrpcServer, configClient.Config{Insecure: true, TenantID: "other-tenant"})
+	for batch, err := range otherClient.ExportAccounts(ctx, 0) {
+		if err != nil {
+			t.Fatalf("Export for other tenant failed: %v", err)
+		}
+		t.Fatalf("Expected no accounts for another tenant, got a batch of %d", len(batch))
+	}
+}
+
+func TestExportAccountsGoesThroughStreamInterceptors(t *testing.T) {
+	ctx := context.Background()
+	logs := captureLogs(t)
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Each tenant exports exactly the accounts it created
+	clients := map[string]*configClient.ConfigurationClient{
+		"export-tenant-a": tc.NewGrpcClient(test.GrpcServer, configClient.Config{Insecure: true, TenantID: "export-tenant-a"}),
+		"export-tenant-b": tc.NewGrpcClient(test.GrpcServer, configClient.Config{Insecure: true, TenantID: "export-tenant-b"}),
+	}
+	for tenantID, client := range clients {
+		if _, err := client.CreateAccount(ctx, "account-of-"+tenantID); err != nil {
+			t.Fatalf("Failed to create account for %s: %v", tenantID, err)
+		}
+	}
+	for tenantID, client := range clients {
+		var names []string
+		for batch, err := range client.ExportAccounts(ctx, 0) {
+			if err != nil {
+				t.Fatalf("Export for %s failed: %v", tenantID, err)
+			}
+			for _, account := range batch {
+				names = append(names, account.GetName())
+			}
+		}
+		if !slices.Equal(names, []string{"account-of-" + tenantID}) {
+			t.Fatalf("Expected %s to export only its own account, got %v", tenantID, names)
+		}
+	}
+
+	// The stream is logged like unary calls
+	exportLog := findRPCLog(t, logs.String(), "/configuration_service.v1.Configuration/ExportAccounts")
+	if exportLog["code"] != codes.OK.String() {
+		t.Fatalf("Expected the export to be logged as OK, got: %v", exportLog)
+	}
+
+	// Oversized metadata is rejected before the export starts
+	oversizedCtx := metadata.AppendToOutgoingContext(ctx, "x-padding", strings.Repeat("a", 16<<10))
+	for _, err := range clients["export-tenant-a"].ExportAccounts(oversizedCtx, 0) {
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("Expected ResourceExhausted for oversized metadata, got: %v", err)
+		}
+		return
+	}
+	t.Fatal("Expected the export with oversized metadata to fail")
+}
+
+func TestExportAccountsStopsWhenStreamIsCancelled(t *testing.T) {
+	ctx := context.Background()
+	logs := captureLogs(t)
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	const total = 5000
+	seedExportAccounts(t, ctx, tc, total)
+
+	// Stop after the first batch; the client cancels the stream
+	client := tc.GrpcClient(test.GrpcServer)
+	for _, err := range client.ExportAccounts(ctx, 100) {
+		if err != nil {
+			t.Fatalf("Export failed: %v", err)
+		}
+		break
+	}
+
+	// The server notices the cancellation, stops the scan and rolls back the cursor's transaction
+	test.Eventually(t, 5*time.Second, 50*time.Millisecond, func() bool {
+		if strings.Contains(logs.String(), fmt.Sprintf("Exported %d accounts", total)) {
+			t.Fatal("Expected the export to stop when the stream was cancelled, but it exported every account")
+		}
+		return strings.Contains(logs.String(), "Stopped exporting accounts after")
+	})
+
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err = test.WaitForRows(waitCtx, tc.GetDBPool(test.ConfigDb),
+		`SELECT pid FROM pg_stat_activity
+		 WHERE datname = current_database() AND pid <> pg_backend_pid()
+		 AND (state LIKE 'idle in transaction%' OR (state = 'active' AND query LIKE 'FETCH%'))`,
+		0,
+	)
+	if err != nil {
+		t.Fatalf("Expected the export cursor to be closed: %v", err)
+	}
+}
+
+func TestServerAddrsAreDialable(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// The gRPC address reported by the server must accept gRPC calls
+	conn, err := grpc.NewClient("passthrough:///"+tc.GetGrpcClient(test.GrpcServer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create gRPC client: %v", err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Failed to call gRPC server at %s: %v", tc.GetGrpcClient(test.GrpcServer), err)
+	}
+
+	// The HTTP address reported by the server must serve the gateway
+	resp, err := httpClient.Get(tc.GetHttpClient(test.GrpcServer) + "/v1/accounts")
+	if err != nil {
+		t.Fatalf("Failed to call HTTP gateway at %s: %v", tc.GetHttpClient(test.GrpcServer), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 from HTTP gateway, got %d", resp.StatusCode)
+	}
+}
+
+func TestClientRecoversAfterServerRestart(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.NewGrpcClient(test.GrpcServer, configClient.Config{
+		Insecure:          true,
+		TenantID:          testTenant,
+		ReconnectMaxDelay: 200 * time.Millisecond,
+	})
+
+	if _, err := client.CreateAccount(ctx, "before-restart"); err != nil {
+		t.Fatalf("Failed to create account before restart: %v", err)
+	}
+
+	if err := tc.RestartServer(ctx, test.GrpcServer); err != nil {
+		t.Fatalf("Failed to restart server: %v", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := client.WaitForConn(waitCtx); err != nil {
+		t.Fatalf("Client did not reconnect after restart: %v", err)
+	}
+
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list accounts after reconnect: %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].GetName() != "before-restart" {
+		t.Fatalf("Expected the account created before restart, got %d accounts", len(accounts))
+	}
+}
+
+func TestClientStreamsWaitForConnAfterServerRestart(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.NewGrpcClient(test.GrpcServer, configClient.Config{
+		Insecure:           true,
+		TenantID:           testTenant,
+		ReconnectMaxDelay:  200 * time.Millisecond,
+		WaitForConnTimeout: 10 * time.Second,
+	})
+
+	if _, err := client.CreateAccount(ctx, "before-restart"); err != nil {
+		t.Fatalf("Failed to create account before restart: %v", err)
+	}
+
+	if err := tc.RestartServer(ctx, test.GrpcServer); err != nil {
+		t.Fatalf("Failed to restart server: %v", err)
+	}
+
+	// Without calling WaitForConn first, the stream waits for the reconnect instead of failing fast
+	var exported int
+	for batch, err := range client.ExportAccounts(ctx, 10) {
+		if err != nil {
+			t.Fatalf("Failed to export accounts after restart: %v", err)
+		}
+		exported += len(batch)
+	}
+	if exported != 1 {
+		t.Fatalf("Expected the account created before restart, exported %d accounts", exported)
+	}
+}
+
+// payloadRecorder is a stats handler that records the sizes of received messages
+type payloadRecorder struct {
+	mu       sync.Mutex
+	payloads []*stats.InPayload
+}
+
+func (r *payloadRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r *payloadRecorder) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if in, ok := s.(*stats.InPayload); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.payloads = append(r.payloads, in)
+	}
+}
+
+func (r *payloadRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r *payloadRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+func TestListAccountsResponseIsCompressed(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Seed enough similar accounts for the listing to compress well, sending gzip compressed requests
+	client := tc.NewGrpcClient(test.GrpcServer, configClient.Config{
+		Insecure:    true,
+		TenantID:    testTenant,
+		Compression: "gzip",
+	})
+	for i := range 100 {
+		if _, err := client.CreateAccount(ctx, fmt.Sprintf("compressible-account-%03d", i)); err != nil {
+			t.Fatalf("Failed to create account %d: %v", i, err)
+		}
+	}
+
+	// List with a plain client that accepts gzip but does not request it
+	recorder := &payloadRecorder{}
+	conn, err := grpc.NewClient("passthrough:///"+tc.GetGrpcClient(test.GrpcServer),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(recorder),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial server: %v", err)
+	}
+	defer conn.Close()
+
+	listCtx := metadata.AppendToOutgoingContext(ctx, tenant.MetadataKey, testTenant)
+	resp, err := gw.NewConfigurationClient(conn).ListAccounts(listCtx, &configpb.ListAccountsRequestProto{})
+	if err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	if len(resp.GetAccounts()) != 100 {
+		t.Fatalf("Expected 100 accounts, got %d", len(resp.GetAccounts()))
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.payloads) != 1 {
+		t.Fatalf("Expected 1 received payload, got %d", len(recorder.payloads))
+	}
+	payload := recorder.payloads[0]
+	if payload.CompressedLength >= payload.Length {
+		t.Fatalf("Expected compressed response, got %d compressed bytes for %d uncompressed bytes", payload.CompressedLength, payload.Length)
+	}
+	t.Logf("Response compressed from %d to %d bytes", payload.Length, payload.CompressedLength)
+}
+
+func TestExportAccountsResponseIsCompressed(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Seed enough similar accounts for a single exported batch to compress well
+	client := tc.GrpcClient(test.GrpcServer)
+	for i := range 100 {
+		if _, err := client.CreateAccount(ctx, fmt.Sprintf("exported-account-%03d", i)); err != nil {
+			t.Fatalf("Failed to create account %d: %v", i, err)
+		}
+	}
+
+	// Export with a plain client that accepts gzip but does not request it
+	recorder := &payloadRecorder{}
+	conn, err := grpc.NewClient("passthrough:///"+tc.GetGrpcClient(test.GrpcServer),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(recorder),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial server: %v", err)
+	}
+	defer conn.Close()
+
+	exportCtx := metadata.AppendToOutgoingContext(ctx, tenant.MetadataKey, testTenant)
+	stream, err := gw.NewConfigurationClient(conn).ExportAccounts(exportCtx, &configpb.ExportAccountsRequestProto{BatchSize: 100})
+	if err != nil {
+		t.Fatalf("Failed to export accounts: %v", err)
+	}
+	var exported int
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to receive exported accounts: %v", err)
+		}
+		exported += len(batch.GetAccounts())
+	}
+	if exported != 100 {
+		t.Fatalf("Expected 100 exported accounts, got %d", exported)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.payloads) != 1 {
+		t.Fatalf("Expected 1 received payload, got %d", len(recorder.payloads))
+	}
+	payload := recorder.payloads[0]
+	if payload.CompressedLength >= payload.Length {
+		t.Fatalf("Expected compressed batch, got %d compressed bytes for %d uncompressed bytes", payload.CompressedLength, payload.Length)
+	}
+}
+
+func TestListAccountsByDateRange(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Seed accounts one hour apart directly so their creation times are known exactly
+	base := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	names := []string{"account-0", "account-1", "account-2", "account-3"}
+	for i, name := range names {
+		_, err := tc.GetDBPool(test.ConfigDb).Exec(ctx,
+			"INSERT INTO accounts (tenant_id, id, name, type, created_at) VALUES ($1, $2, $3, 1, $4)",
+			testTenant, []byte(name), name, base.Add(time.Duration(i)*time.Hour),
+		)
+		if err != nil {
+			t.Fatalf("Failed to seed account %s: %v", name, err)
+		}
+	}
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		expected []string
+	}{
+		{"both bounds inclusive", base.Add(time.Hour), base.Add(2 * time.Hour), []string{"account-1", "account-2"}},
+		{"bounds just inside exclude edges", base.Add(time.Hour + time.Microsecond), base.Add(2*time.Hour - time.Microsecond), nil},
+		{"open upper bound", base.Add(2 * time.Hour), time.Time{}, []string{"account-2", "account-3"}},
+		{"open lower bound", time.Time{}, base, []string{"account-0"}},
+		{"range before all accounts", base.Add(-2 * time.Hour), base.Add(-time.Hour), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, err := client.ListAccountsByDateRange(ctx, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("Failed to list accounts by date range: %v", err)
+			}
+
+			var got []string
+			for _, account := range accounts {
+				got = append(got, account.GetName())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Fatalf("Expected accounts %v in creation order, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCountAccounts(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	expectCount := func(want int64) {
+		t.Helper()
+		count, err := client.CountAccounts(ctx)
+		if err != nil {
+			t.Fatalf("Failed to count accounts: %v", err)
+		}
+		if count != want {
+			t.Fatalf("Expected %d accounts, counted %d", want, count)
+		}
+		accounts, err := client.ListAccounts(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list accounts: %v", err)
+		}
+		if int64(len(accounts)) != count {
+			t.Fatalf("Expected the count %d to match the %d listed accounts", count, len(accounts))
+		}
+	}
+
+	expectCount(0)
+	for _, name := range []string{"count-0", "count-1", "count-2", "count-3"} {
+		if _, err := client.CreateAccount(ctx, name); err != nil {
+			t.Fatalf("Failed to create account %s: %v", name, err)
+		}
+	}
+	expectCount(4)
+
+	for _, name := range []string{"count-1", "count-3"} {
+		if _, err := client.DeleteAccount(ctx, ids.AccountID(name)); err != nil {
+			t.Fatalf("Failed to delete account %s: %v", name, err)
+		}
+	}
+	expectCount(2)
+
+	// The date filters of ListAccounts apply too
+	base := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	for i, name := range []string{"dated-0", "dated-1", "dated-2"} {
+		_, err := tc.GetDBPool(test.ConfigDb).Exec(ctx,
+			"INSERT INTO accounts (tenant_id, id, name, type, created_at) VALUES ($1, $2, $3, $4, $5)",
+			testTenant, []byte(name), name, ids.AccountType, base.Add(time.Duration(i)*time.Hour),
+		)
+		if err != nil {
+			t.Fatalf("Failed to seed account %s: %v", name, err)
+		}
+	}
+	count, err := client.CountAccountsByDateRange(ctx, base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to count accounts by date range: %v", err)
+	}
+	accounts, err := client.ListAccountsByDateRange(ctx, base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to list accounts by date range: %v", err)
+	}
+	if count != 2 || int64(len(accounts)) != count {
+		t.Fatalf("Expected 2 accounts in range, counted %d and listed %d", count, len(accounts))
+	}
+	expectCount(5)
+}
+
+func TestServerBaseUnaryInterceptor(t *testing.T) {
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	var lastMethod atomic.Value
+	server := test.GrpcServer.WithUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		calls.Add(1)
+		lastMethod.Store(info.FullMethod)
+		return handler(ctx, req)
+	})
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(server).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(server)
+
+	if _, err := client.ListAccounts(ctx); err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("Expected interceptor to fire once, fired %d times", got)
+	}
+	if method, _ := lastMethod.Load().(string); !strings.HasSuffix(method, "/ListAccounts") {
+		t.Fatalf("Expected interceptor to see ListAccounts, saw %q", method)
+	}
+}
+
+func TestAccountMetadataRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	// JSON numbers come back as float64, so the fixture only uses float64 numbers
+	metadata := map[string]any{
+		"plan":     "pro",
+		"seats":    float64(12),
+		"trial":    false,
+		"owner":    nil,
+		"tags":     []any{"beta", "eu"},
+		"settings": map[string]any{"theme": "dark", "limits": map[string]any{"daily": float64(100)}},
+	}
+
+	created, err := client.CreateAccountWithMetadata(ctx, "account-with-metadata", metadata)
+	if err != nil {
+		t.Fatalf("Failed to create account with metadata: %v", err)
+	}
+	if got := created.GetMetadata().AsMap(); !reflect.DeepEqual(got, metadata) {
+		t.Fatalf("Create returned metadata %v, want %v", got, metadata)
+	}
+
+	if _, err := client.CreateAccount(ctx, "account-without-metadata"); err != nil {
+		t.Fatalf("Failed to create account without metadata: %v", err)
+	}
+
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	found := 0
+	for _, account := range accounts {
+		switch account.GetName() {
+		case "account-with-metadata":
+			found++
+			if got := account.GetMetadata().AsMap(); !reflect.DeepEqual(got, metadata) {
+				t.Errorf("List returned metadata %v, want %v", got, metadata)
+			}
+		case "account-without-metadata":
+			found++
+			if got := account.GetMetadata().AsMap(); len(got) != 0 {
+				t.Errorf("Expected empty metadata for an account created without it, got %v", got)
+			}
+		}
+	}
+	if found != 2 {
+		t.Fatalf("Expected both accounts in the listing, found %d", found)
+	}
+}
+
+func TestCreateAccountDuplicateName(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	if _, err := client.CreateAccount(ctx, "Duplicate-Account"); err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	// Same name and a case variant both conflict with the existing account
+	for _, name := range []string{"Duplicate-Account", "duplicate-account", "DUPLICATE-ACCOUNT"} {
+		_, err := client.CreateAccount(ctx, name)
+		if code := status.Code(err); code != codes.AlreadyExists {
+			t.Fatalf("Creating %q: expected AlreadyExists, got %s: %v", name, code, err)
+		}
+	}
+
+	// The name is only unique within a tenant
+	otherClient := tc.NewGrpcClient(test.GrpcServer, configClient.Config{Insecure: true, TenantID: "other-tenant"})
+	if _, err := otherClient.CreateAccount(ctx, "duplicate-account"); err != nil {
+		t.Fatalf("Expected another tenant to reuse the name, got: %v", err)
+	}
+
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	if len(accounts) != 1 {
+		t.Fatalf("Expected only the original account, got %d", len(accounts))
+	}
+}
+
+func TestCaseSensitiveAccountNames(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Case sensitivity is chosen by the column type; TEXT only rejects exact duplicates
+	if _, err := tc.GetDBPool(test.ConfigDb).Exec(ctx, "ALTER TABLE accounts ALTER COLUMN name TYPE TEXT"); err != nil {
+		t.Fatalf("Failed to make account names case-sensitive: %v", err)
+	}
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	if _, err := client.CreateAccount(ctx, "Case-Account"); err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	if _, err := client.CreateAccount(ctx, "case-account"); err != nil {
+		t.Fatalf("Expected a case variant to be accepted, got: %v", err)
+	}
+	_, err = client.CreateAccount(ctx, "Case-Account")
+	if code := status.Code(err); code != codes.AlreadyExists {
+		t.Fatalf("Expected AlreadyExists for the exact name, got %s: %v", code, err)
+	}
+}
+
+func TestDuplicateRequestsAreCountedAndShortCircuited(t *testing.T) {
+	ctx := context.Background()
+
+	const createAccountMethod = "/configuration_service.v1.Configuration/CreateAccount"
+	reader := sdkmetric.NewManualReader()
+	detector := dedup.NewDetector(time.Minute).
+		WithShortCircuit(createAccountMethod).
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	server := test.GrpcServer.WithUnaryInterceptor(detector.UnaryServerInterceptor())
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(server).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(server)
+
+	// Fire identical listings at once, as a retry storm would
+	const listings = 5
+	var wg sync.WaitGroup
+	for range listings {
+		wg.Go(func() {
+			if _, err := client.ListAccounts(ctx); err != nil {
+				t.Errorf("Failed to list accounts: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	// A listing by another tenant is not a duplicate
+	other := tc.NewGrpcClient(server, configClient.Config{Insecure: true, TenantID: "other-tenant"})
+	if _, err := other.ListAccounts(ctx); err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+
+	if got := duplicateRequests(t, reader, "/configuration_service.v1.Configuration/ListAccounts", false); got != listings-1 {
+		t.Fatalf("Expected %d duplicate listings, got %d", listings-1, got)
+	}
+
+	// A duplicate create gets the first response instead of AlreadyExists
+	first, err := client.CreateAccount(ctx, "deduplicated-account")
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	second, err := client.CreateAccount(ctx, "deduplicated-account")
+	if err != nil {
+		t.Fatalf("Expected the duplicate create to be short-circuited, got: %v", err)
+	}
+	if !ids.AccountIDFromProto(second.GetAccountId()).Equal(ids.AccountIDFromProto(first.GetAccountId())) {
+		t.Fatalf("Expected the first account to be replayed, got %v", second.GetAccountId())
+	}
+	if got := duplicateRequests(t, reader, createAccountMethod, true); got != 1 {
+		t.Fatalf("Expected 1 short-circuited create, got %d", got)
+	}
+
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	if len(accounts) != 1 {
+		t.Fatalf("Expected the duplicate create not to reach the database, got %d accounts", len(accounts))
+	}
+}
+
+func TestServerCountsShortCircuitedDeletes(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+	account, err := client.CreateAccount(ctx, "deleted-by-a-retry")
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	accountID := ids.AccountIDFromProto(account.GetAccountId())
+
+	// The server's own detector replays the first delete for an immediate retry instead of NotFound
+	for range 2 {
+		if _, err := client.DeleteAccount(ctx, accountID); err != nil {
+			t.Fatalf("Failed to delete account: %v", err)
+		}
+	}
+
+	// It records the duplicate with the meter provider the server was created with
+	const deleteAccountMethod = "/configuration_service.v1.Configuration/DeleteAccount"
+	if got := duplicateRequests(t, tc.MetricReader(), deleteAccountMethod, true); got != 1 {
+		t.Fatalf("Expected 1 short-circuited delete, got %d", got)
+	}
+}
+
+func TestDeleteAfterRecreateIsNotReplayed(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+	account, err := client.CreateAccount(ctx, "recreated-account")
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	accountID := ids.AccountIDFromProto(account.GetAccountId())
+
+	// Delete, re-create and delete again within the deduplication window
+	if _, err := client.DeleteAccount(ctx, accountID); err != nil {
+		t.Fatalf("Failed to delete account: %v", err)
+	}
+	if _, err := client.CreateAccount(ctx, "recreated-account"); err != nil {
+		t.Fatalf("Failed to re-create account: %v", err)
+	}
+	if _, err := client.DeleteAccount(ctx, accountID); err != nil {
+		t.Fatalf("Failed to delete the re-created account: %v", err)
+	}
+
+	// The second delete ran instead of replaying the first one's response
+	if _, err := client.GetAccount(ctx, accountID); status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected the re-created account to be deleted, got: %v", err)
+	}
+	const deleteAccountMethod = "/configuration_service.v1.Configuration/DeleteAccount"
+	if got := duplicateRequests(t, tc.MetricReader(), deleteAccountMethod, true); got != 0 {
+		t.Fatalf("Expected no short-circuited delete, got %d", got)
+	}
+}
+
+func TestConnectionMetricsAreRecordedWithTheServersMeterProvider(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+	if _, err := client.CreateAccount(ctx, "metered-account"); err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	// The provider passed to NewGrpcServer records the connection and the request bytes
+	if got := serverMetric(t, tc.MetricReader(), serverbase.OpenConnectionsMetric); got < 1 {
+		t.Fatalf("Expected at least 1 open connection, got %d", got)
+	}
+	if got := serverMetric(t, tc.MetricReader(), serverbase.ReceivedBytesMetric); got <= 0 {
+		t.Fatalf("Expected received bytes to be recorded, got %d", got)
+	}
+}
+
+func TestDegradedModeServesCachedReadsWhileDatabaseIsDown(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.DegradedGrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.DegradedGrpcServer)
+	healthzURL := tc.GetHttpClient(test.DegradedGrpcServer) + serverbase.HealthzPath
+
+	// awaitHealthz polls /healthz until it answers with code, since the readiness check runs in the background
+	awaitHealthz := func(code int) {
+		t.Helper()
+		test.Eventually(t, 10*time.Second, 50*time.Millisecond, func() bool {
+			resp, err := http.Get(healthzURL)
+			if err != nil {
+				return false
+			}
+			resp.Body.Close()
+			return resp.StatusCode == code
+		})
+	}
+	awaitHealthz(http.StatusOK)
+
+	if _, err := client.CreateAccount(ctx, "cached-account"); err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	// Listing while the database is up fills the cache
+	if _, err := client.ListAccounts(ctx); err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+
+	tc.GetDBPool(test.ConfigDb).Close()
+
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Expected the cached listing while the database is down, got: %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].GetName() != "cached-account" {
+		t.Fatalf("Expected the cached account, got %v", accounts)
+	}
+
+	// Nothing is cached for another tenant, and writes are never served from the cache
+	other := tc.NewGrpcClient(test.DegradedGrpcServer, configClient.Config{Insecure: true, TenantID: "other-tenant"})
+	if _, err := other.ListAccounts(ctx); status.Code(err) != codes.Unavailable {
+		t.Fatalf("Expected Unavailable for an uncached listing, got: %v", err)
+	}
+	if _, err := client.CreateAccount(ctx, "new-account"); status.Code(err) != codes.Unavailable {
+		t.Fatalf("Expected Unavailable for a write, got: %v", err)
+	}
+
+	awaitHealthz(http.StatusServiceUnavailable)
+}
+
+// serverMetric returns the int64 sum recorded for name over all attributes
+func serverMetric(t *testing.T, reader sdkmetric.Reader, name string) int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+	var total int64
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != name {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("Expected %s to be an int64 sum, got %T", name, m.Data)
+			}
+			for _, dp := range sum.DataPoints {
+				total += dp.Value
+			}
+		}
+	}
+	return total
+}
+
+// duplicateRequests returns the duplicate request count recorded for method
+func duplicateRequests(t *testing.T, reader sdkmetric.Reader, method string, shortCircuited bool) int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+	want := attribute.NewSet(
+		attribute.String("rpc.method", method),
+		attribute.Bool("short_circuited", shortCircuited),
+	)
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != dedup.DuplicateRequestsMetric {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("Expected %s to be an int64 sum, got %T", m.Name, m.Data)
+			}
+			for _, dp := range sum.DataPoints {
+				if dp.Attributes.Equals(&want) {
+					return dp.Value
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestUpdateAccountWritesOnlyMaskedFields(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	// stored returns the name and metadata of the account in the database
+	stored := func(accountID ids.AccountID) (string, map[string]any) {
+		var name string
+		var metadata map[string]any
+		err := tc.GetDBPool(test.ConfigDb).QueryRow(ctx,
+			"SELECT name, metadata FROM accounts WHERE tenant_id = $1 AND id = $2",
+			testTenant, accountID.Bytes(),
+		).Scan(&name, &metadata)
+		if err != nil {
+			t.Fatalf("Failed to read account %s: %v", accountID, err)
+		}
+		return name, metadata
+	}
+
+	tests := []struct {
+		name         string
+		paths        []string
+		renames      bool
+		wantMetadata map[string]any
+	}{
+		{name: "name only", paths: []string{"name"}, renames: true, wantMetadata: map[string]any{"plan": "free"}},
+		{name: "metadata only", paths: []string{"metadata"}, renames: false, wantMetadata: map[string]any{"plan": "pro"}},
+		{name: "both", paths: []string{"name", "metadata"}, renames: true, wantMetadata: map[string]any{"plan": "pro"}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Names are unique per tenant, so every case uses its own
+			originalName, newName := fmt.Sprintf("original-%d", i), fmt.Sprintf("renamed-%d", i)
+			created, err := client.CreateAccountWithMetadata(ctx, originalName, map[string]any{"plan": "free"})
+			if err != nil {
+				t.Fatalf("Failed to create account: %v", err)
+			}
+			accountID := ids.AccountIDFromProto(created.GetAccountId())
+			wantName := originalName
+			if tt.renames {
+				wantName = newName
+			}
+
+			updated, err := client.UpdateAccount(ctx, accountID, newName, map[string]any{"plan": "pro"}, tt.paths...)
+			if err != nil {
+				t.Fatalf("Failed to update account: %v", err)
+			}
+			if got := updated.GetMetadata().AsMap(); !reflect.DeepEqual(got, tt.wantMetadata) {
+				t.Errorf("Update returned metadata %v, want %v", got, tt.wantMetadata)
+			}
+
+			name, metadata := stored(accountID)
+			if name != wantName {
+				t.Errorf("Expected stored name %q, got %q", wantName, name)
+			}
+			if !reflect.DeepEqual(metadata, tt.wantMetadata) {
+				t.Errorf("Expected stored metadata %v, got %v", tt.wantMetadata, metadata)
+			}
+		})
+	}
+
+	// Unknown paths, empty names and missing accounts are rejected without writing anything
+	created, err := client.CreateAccountWithMetadata(ctx, "untouched", map[string]any{"plan": "free"})
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	accountID := ids.AccountIDFromProto(created.GetAccountId())
+
+	// The client refuses an update without paths rather than sending a mask the server reads as every field
+	if _, err := client.UpdateAccount(ctx, accountID, "", nil); !errors.Is(err, configClient.ErrNoUpdatePaths) {
+		t.Fatalf("Expected ErrNoUpdatePaths for an update without paths, got: %v", err)
+	}
+	for _, paths := range [][]string{{"type"}, {"name", "created_at"}, {"id"}} {
+		_, err := client.UpdateAccount(ctx, accountID, "changed", nil, paths...)
+		if code := status.Code(err); code != codes.InvalidArgument {
+			t.Fatalf("Updating %v: expected InvalidArgument, got %s: %v", paths, code, err)
+		}
+	}
+	if _, err := client.UpdateAccount(ctx, accountID, "", nil, "name"); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument for an empty name, got: %v", err)
+	}
+	if name, metadata := stored(accountID); name != "untouched" || metadata["plan"] != "free" {
+		t.Fatalf("Expected the rejected updates to leave the account untouched, got %q %v", name, metadata)
+	}
+
+	_, err = client.UpdateAccount(ctx, ids.AccountID("missing-account"), "changed", nil, "name")
+	if code := status.Code(err); code != codes.NotFound {
+		t.Fatalf("Expected NotFound for a missing account, got %s: %v", code, err)
+	}
+}
+
+func TestListAccountsQueryCountDoesNotGrowWithAccounts(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	queries := make(map[string][]int64)
+	server := test.GrpcServer.WithUnaryInterceptor(db.QueryCountUnaryInterceptor(func(method string, n int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries[method] = append(queries[method], n)
+	}))
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(server).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	// Listing one account and listing five must take the same single query
+	for i := range 5 {
+		if _, err := client.CreateAccount(ctx, fmt.Sprintf("counted-%d", i)); err != nil {
+			t.Fatalf("Failed to create account: %v", err)
+		}
+		if i != 0 && i != 4 {
+			continue
+		}
+		if _, err := client.ListAccounts(ctx); err != nil {
+			t.Fatalf("Failed to list accounts: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	listed := queries["/configuration_service.v1.Configuration/ListAccounts"]
+	if !slices.Equal(listed, []int64{1, 1}) {
+		t.Fatalf("Expected one query per ListAccounts call, got %v", listed)
+	}
+	// Each creation inserts the account and its audit entry in one transaction
+	for _, n := range queries["/configuration_service.v1.Configuration/CreateAccount"] {
+		if n != 4 {
+			t.Fatalf("Expected BEGIN, two INSERTs and COMMIT per CreateAccount, got %v", queries)
+		}
+	}
+}
+
+func TestListAccountsRunsOneQuery(t *testing.T) {
+	ctx := context.Background()
+
+	configDb := test.ConfigDb.WithQueryCount()
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(configDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+	for i := range 3 {
+		if _, err := client.CreateAccount(ctx, fmt.Sprintf("round-trip-%d", i)); err != nil {
+			t.Fatalf("Failed to create account: %v", err)
+		}
+	}
+
+	database := tc.Database(configDb)
+	database.ResetQueryCount()
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	if len(accounts) != 3 {
+		t.Fatalf("Expected 3 accounts, got %d", len(accounts))
+	}
+	if n := database.QueryCount(); n != 1 {
+		t.Fatalf("Expected ListAccounts to run 1 query, got %d", n)
+	}
+
+	// Reading the accounts one by one, as a loop-based ListAccounts would, takes a query per account
+	database.ResetQueryCount()
+	for _, account := range accounts {
+		if _, err := client.GetAccount(ctx, ids.AccountIDFromProto(account.GetAccountId())); err != nil {
+			t.Fatalf("Failed to get account: %v", err)
+		}
+	}
+	if n := database.QueryCount(); n == 1 {
+		t.Fatal("Expected a query per account to fail the single query assertion")
+	} else if n != int64(len(accounts)) {
+		t.Fatalf("Expected %d queries reading the accounts one by one, got %d", len(accounts), n)
+	}
+}
+
+func TestAccountIDStringRoundTripsBinaryIDs(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	// Created accounts round-trip through their string form
+	created, err := client.CreateAccount(ctx, "encoded-account")
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	if _, err := client.DeleteAccountByIDString(ctx, configClient.AccountIDString(created)); err != nil {
+		t.Fatalf("Failed to delete account by its ID string: %v", err)
+	}
+
+	// A binary UUID is not valid UTF-8, so only the encoded form identifies it
+	uuid := []byte{0x9f, 0x00, 0xfe, 0x3a, 0xc1, 0xff, 0x4b, 0x07, 0x80, 0x2d, 0x00, 0xe9, 0x5c, 0xbf, 0x11, 0x6f}
+	_, err = tc.GetDBPool(test.ConfigDb).Exec(ctx,
+		"INSERT INTO accounts (tenant_id, id, name, type) VALUES ($1, $2, $3, $4)",
+		testTenant, uuid, "binary-uuid-account", ids.AccountType,
+	)
+	if err != nil {
+		t.Fatalf("Failed to insert account with a binary ID: %v", err)
+	}
+
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	if len(accounts) != 1 {
+		t.Fatalf("Expected only the binary account, got %d", len(accounts))
+	}
+	encoded := configClient.AccountIDString(accounts[0])
+	if want := base64.StdEncoding.EncodeToString(uuid); encoded != want {
+		t.Fatalf("Expected the ID as standard base64 %s, got %s", want, encoded)
+	}
+
+	// The URL-safe form of the same ID is accepted too
+	urlSafe := base64.RawURLEncoding.EncodeToString(uuid)
+	if _, err := client.DeleteAccountByIDString(ctx, urlSafe); err != nil {
+		t.Fatalf("Failed to delete the binary account by %s: %v", urlSafe, err)
+	}
+	if accounts, err := client.ListAccounts(ctx); err != nil || len(accounts) != 0 {
+		t.Fatalf("Expected no accounts after deleting by ID string, got %d: %v", len(accounts), err)
+	}
+
+	if _, err := client.DeleteAccountByIDString(ctx, "not base64!"); err == nil {
+		t.Fatal("Expected an invalid ID string to be rejected")
+	}
+}
+
+func TestGetAccountReturnsMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	metadata := map[string]any{"display_name": "Bob", "settings": map[string]any{"theme": "light"}}
+	created, err := client.CreateAccountWithMetadata(ctx, "get-account", metadata)
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	accountID := ids.AccountIDFromProto(created.GetAccountId())
+
+	account, err := client.GetAccount(ctx, accountID)
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	if !ids.AccountIDFromProto(account.GetAccountId()).Equal(accountID) {
+		t.Fatalf("Expected account %s, got %s", accountID, ids.AccountIDFromProto(account.GetAccountId()))
+	}
+	if got := account.GetMetadata().AsMap(); !reflect.DeepEqual(got, metadata) {
+		t.Fatalf("Get returned metadata %v, want %v", got, metadata)
+	}
+
+	if _, err := client.GetAccount(ctx, ids.AccountID("missing-account")); status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected NotFound for a missing account, got: %v", err)
+	}
+
+	// Metadata over the size limit is rejected on create and update
+	oversized := map[string]any{"blob": strings.Repeat("x", 16<<10)}
+	if _, err := client.CreateAccountWithMetadata(ctx, "oversized-account", oversized); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument creating oversized metadata, got: %v", err)
+	}
+	if _, err := client.UpdateAccount(ctx, accountID, "", oversized, "metadata"); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected InvalidArgument updating to oversized metadata, got: %v", err)
+	}
+	if account, err := client.GetAccount(ctx, accountID); err != nil || !reflect.DeepEqual(account.GetMetadata().AsMap(), metadata) {
+		t.Fatalf("Expected the rejected update to keep the metadata, got: %v", err)
+	}
+}
+
+func TestAccountNameIsIndependentOfIDScheme(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	created, err := client.CreateAccount(ctx, "named-account")
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	if created.GetName() != "named-account" {
+		t.Fatalf("Expected create to return name named-account, got %q", created.GetName())
+	}
+
+	// An account whose ID is a binary UUID still reports the name it was created with
+	uuid := []byte{0x3c, 0x91, 0x00, 0xde, 0x7a, 0xff, 0x4e, 0x12, 0x8b, 0x05, 0xc4, 0x00, 0x61, 0xe2, 0x9d, 0xb0}
+	_, err = tc.GetDBPool(test.ConfigDb).Exec(ctx,
+		"INSERT INTO accounts (tenant_id, id, name, type) VALUES ($1, $2, $3, $4)",
+		testTenant, uuid, "uuid-named-account", ids.AccountType,
+	)
+	if err != nil {
+		t.Fatalf("Failed to insert account with a binary ID: %v", err)
+	}
+
+	want := map[string]string{
+		ids.AccountIDFromProto(created.GetAccountId()).String(): "named-account",
+		ids.AccountID(uuid).String():                            "uuid-named-account",
+	}
+	for _, accountID := range []ids.AccountID{ids.AccountIDFromProto(created.GetAccountId()), ids.AccountID(uuid)} {
+		account, err := client.GetAccount(ctx, accountID)
+		if err != nil {
+			t.Fatalf("Failed to get account %s: %v", accountID, err)
+		}
+		if name := want[accountID.String()]; account.GetName() != name {
+			t.Fatalf("Expected get to return name %q for %s, got %q", name, accountID, account.GetName())
+		}
+	}
+
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	if len(accounts) != len(want) {
+		t.Fatalf("Expected %d accounts, got %d", len(want), len(accounts))
+	}
+	for _, account := range accounts {
+		encoded := ids.AccountIDFromProto(account.GetAccountId()).String()
+		if account.GetName() != want[encoded] {
+			t.Fatalf("Expected list to return name %q for %s, got %q", want[encoded], encoded, account.GetName())
+		}
+	}
+}
+
+func TestGroupEventStreamDeliversActionsToOtherSubscribers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(context.Background()); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+	groupID := &commonpb.ConfigurationIdProto{Id: []byte("group-events"), Type: 2}
+	joiner := ids.AccountID("joiner").Proto()
+	member := ids.AccountID("member").Proto()
+
+	streamCtx, cancelStreams := context.WithCancel(ctx)
+	defer cancelStreams()
+
+	joinerStream, err := client.GroupEventStream(streamCtx, groupID)
+	if err != nil {
+		t.Fatalf("Failed to open the joiner's stream: %v", err)
+	}
+	memberStream, err := client.GroupEventStream(streamCtx, groupID)
+	if err != nil {
+		t.Fatalf("Failed to open the member's stream: %v", err)
+	}
+
+	requestToJoin := &configpb.GroupEventStreamRequestProto{
+		Action: &configpb.GroupEventStreamRequestProto_RequestToJoin{
+			RequestToJoin: &configpb.RequestToJoinGroupProto{
+				AccountId:       joiner,
+				GroupId:         groupID,
+				InviterId:       member,
+				X25519PublicKey: []byte("joiner-public-key"),
+			},
+		},
+	}
+	if err := joinerStream.Send(requestToJoin); err != nil {
+		t.Fatalf("Failed to send the join request: %v", err)
+	}
+
+	event, err := memberStream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive the join request on the member's stream: %v", err)
+	}
+	pending := event.GetPendingMemberEvent()
+	if pending == nil {
+		t.Fatalf("Expected a pending member event, got %v", event)
+	}
+	if !bytes.Equal(pending.GetAccountId().GetId(), joiner.GetId()) {
+		t.Fatalf("Expected the pending member %q, got %q", joiner.GetId(), pending.GetAccountId().GetId())
+	}
+	if !bytes.Equal(pending.GetX25519PublicKey(), []byte("joiner-public-key")) {
+		t.Fatalf("Expected the joiner's public key, got %q", pending.GetX25519PublicKey())
+	}
+
+	// The member accepts on its own stream; the joiner sees its request, then the acceptance
+	accept := &configpb.GroupEventStreamRequestProto{
+		Action: &configpb.GroupEventStreamRequestProto_AcceptRequestToJoin{
+			AcceptRequestToJoin: &configpb.AcceptRequestToJoinGroupProto{
+				AccountId:         member,
+				GroupId:           groupID,
+				InviteeId:         joiner,
+				EncryptedGroupKey: []byte("encrypted-group-key"),
+			},
+		},
+	}
+	if err := memberStream.Send(accept); err != nil {
+		t.Fatalf("Failed to send the acceptance: %v", err)
+	}
+
+	if event, err := joinerStream.Recv(); err != nil || event.GetPendingMemberEvent() == nil {
+		t.Fatalf("Expected the joiner to receive its own join request first, got %v, %v", event, err)
+	}
+	event, err = joinerStream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive the acceptance on the joiner's stream: %v", err)
+	}
+	accepted := event.GetPendingMemberAcceptedEvent()
+	if accepted == nil {
+		t.Fatalf("Expected a pending member accepted event, got %v", event)
+	}
+	if !bytes.Equal(accepted.GetAccountId().GetId(), joiner.GetId()) {
+		t.Fatalf("Expected the acceptance for %q, got %q", joiner.GetId(), accepted.GetAccountId().GetId())
+	}
+}
+
+func TestGroupEventStreamOnlyDeliversTheTenantsEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(context.Background()); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	// Both tenants subscribe to a group with the same ID
+	groupID := &commonpb.ConfigurationIdProto{Id: []byte("shared-group-id"), Type: 2}
+	streamCtx, cancelStreams := context.WithCancel(ctx)
+	defer cancelStreams()
+	openStream := func(tenantID string) grpc.BidiStreamingClient[configpb.GroupEventStreamRequestProto, configpb.ConfigurationEventProto] {
+		client := tc.NewGrpcClient(test.GrpcServer, configClient.Config{Insecure: true, TenantID: tenantID})
+		stream, err := client.GroupEventStream(streamCtx, groupID)
+		if err != nil {
+			t.Fatalf("Failed to open the stream of %s: %v", tenantID, err)
+		}
+		return stream
+	}
+	requestToJoin := func(joiner string) *configpb.GroupEventStreamRequestProto {
+		return &configpb.GroupEventStreamRequestProto{
+			Action: &configpb.GroupEventStreamRequestProto_RequestToJoin{
+				RequestToJoin: &configpb.RequestToJoinGroupProto{
+					AccountId:       ids.AccountID(joiner).Proto(),
+					GroupId:         groupID,
+					InviterId:       ids.AccountID("inviter").Proto(),
+					X25519PublicKey: []byte(joiner + "-public-key"),
+				},
+			},
+		}
+	}
+	streamA, streamB := openStream("group-tenant-a"), openStream("group-tenant-b")
+
+	// Tenant A's request is stored and delivered to tenant A first
+	if err := streamA.Send(requestToJoin("joiner-a")); err != nil {
+		t.Fatalf("Failed to send tenant A's join request: %v", err)
+	}
+	if event, err := streamA.Recv(); err != nil || !bytes.Equal(event.GetPendingMemberEvent().GetAccountId().GetId(), []byte("joiner-a")) {
+		t.Fatalf("Expected tenant A to receive its join request, got %v, %v", event, err)
+	}
+
+	// Had tenant B seen tenant A's earlier event, it would arrive before tenant B's own
+	if err := streamB.Send(requestToJoin("joiner-b")); err != nil {
+		t.Fatalf("Failed to send tenant B's join request: %v", err)
+	}
+	event, err := streamB.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive on tenant B's stream: %v", err)
+	}
+	if got := event.GetPendingMemberEvent().GetAccountId().GetId(); !bytes.Equal(got, []byte("joiner-b")) {
+		t.Fatalf("Expected tenant B to only see its own join request, got the one of %q", got)
+	}
+}
+
+func TestWatchAccountsStreamsChangesInOrder(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(context.Background()); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	changes, err := client.WatchAccounts(watchCtx)
+	if err != nil {
+		t.Fatalf("Failed to watch accounts: %v", err)
+	}
+
+	// WatchAccounts returned, so the server is watching and sees both changes
+	account, err := client.CreateAccount(ctx, "watched-account")
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	accountID := ids.AccountIDFromProto(account.GetAccountId())
+	if _, err := client.DeleteAccount(ctx, accountID); err != nil {
+		t.Fatalf("Failed to delete account: %v", err)
+	}
+
+	for _, want := range []configpb.AccountChangeProto_Kind{
+		configpb.AccountChangeProto_KIND_CREATED,
+		configpb.AccountChangeProto_KIND_DELETED,
+	} {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				t.Fatalf("Watch ended before the %s change", want)
+			}
+			if change.Err != nil {
+				t.Fatalf("Watch failed waiting for the %s change: %v", want, change.Err)
+			}
+			if change.Kind != want || !change.AccountID.Equal(accountID) || change.Name != "watched-account" {
+				t.Fatalf("Expected %s of %s, got %s of %s (%q)", want, accountID, change.Kind, change.AccountID, change.Name)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for the %s change", want)
+		}
+	}
+
+	// Cancelling the watch closes the channel
+	stopWatching()
+	for range changes {
+	}
+}
+
+func TestMoreWatchersThanPoolConnections(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(context.Background()); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+
+	// Watchers share one listening connection, so the pool is left to queries
+	watchers := make([]<-chan configClient.AccountChange, tc.GetDBPool(test.ConfigDb).Stat().MaxConns()+2)
+	for i := range watchers {
+		changes, err := client.WatchAccounts(watchCtx)
+		if err != nil {
+			t.Fatalf("Failed to open watcher %d: %v", i, err)
+		}
+		watchers[i] = changes
+	}
+
+	if _, err := client.CreateAccount(ctx, "widely-watched"); err != nil {
+		t.Fatalf("Failed to create account with every watcher open: %v", err)
+	}
+	for i, changes := range watchers {
+		select {
+		case change := <-changes:
+			if change.Err != nil || change.Name != "widely-watched" {
+				t.Fatalf("Watcher %d expected the creation, got %+v", i, change)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for watcher %d", i)
+		}
+	}
+}
+
+func TestWatchAccountsOnlySeesTheTenantsChanges(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(context.Background()); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	watcher := tc.NewGrpcClient(test.GrpcServer, configClient.Config{Insecure: true, TenantID: "watch-tenant-a"})
+	other := tc.NewGrpcClient(test.GrpcServer, configClient.Config{Insecure: true, TenantID: "watch-tenant-b"})
+
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	changes, err := watcher.WatchAccounts(watchCtx)
+	if err != nil {
+		t.Fatalf("Failed to watch accounts: %v", err)
+	}
+
+	// Had the watch seen the other tenant's change, it would arrive before the watcher's own
+	if _, err := other.CreateAccount(ctx, "other-tenants-account"); err != nil {
+		t.Fatalf("Failed to create the other tenant's account: %v", err)
+	}
+	if _, err := watcher.CreateAccount(ctx, "watchers-account"); err != nil {
+		t.Fatalf("Failed to create the watcher's account: %v", err)
+	}
+
+	select {
+	case change, ok := <-changes:
+		if !ok || change.Err != nil {
+			t.Fatalf("Watch ended before the watcher's change: %v", change.Err)
+		}
+		if change.Kind != configpb.AccountChangeProto_KIND_CREATED || change.Name != "watchers-account" {
+			t.Fatalf("Expected only the watcher's own account, got %s of %q", change.Kind, change.Name)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for the watcher's change")
+	}
 }
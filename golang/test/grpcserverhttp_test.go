@@ -3,17 +3,47 @@ package test_test
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+
+	"github.com/berendjan/golang-bazel-starter/golang/config/api"
+	configClient "github.com/berendjan/golang-bazel-starter/golang/config/client"
+	"github.com/berendjan/golang-bazel-starter/golang/config/ids"
+	"github.com/berendjan/golang-bazel-starter/golang/framework/serverbase"
 	"github.com/berendjan/golang-bazel-starter/golang/test"
+	configpb "github.com/berendjan/golang-bazel-starter/proto/configuration/v1"
+	gw "github.com/berendjan/golang-bazel-starter/proto/configuration_service/v1/gateway"
 )
 
 // HTTP Tests using TestContext
 
+// httpClient sends every request on behalf of the test tenant
+var httpClient = &http.Client{Transport: tenantTransport{tenantID: testTenant}}
+
+// tenantTransport sets the header grpc-gateway forwards as "x-tenant-id" metadata
+type tenantTransport struct {
+	tenantID string
+}
+
+func (t tenantTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Grpc-Metadata-X-Tenant-Id", t.tenantID)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
 func TestHTTPCreateAccount(t *testing.T) {
 	ctx := context.Background()
 
@@ -37,7 +67,7 @@ func TestHTTPCreateAccount(t *testing.T) {
 	}
 	bodyBytes, _ := json.Marshal(reqBody)
 
-	resp, err := http.Post(
+	resp, err := httpClient.Post(
 		httpBaseURL+"/v1/accounts",
 		"application/json",
 		bytes.NewBuffer(bodyBytes),
@@ -67,6 +97,10 @@ func TestHTTPCreateAccount(t *testing.T) {
 		t.Fatal("Account ID should not be empty")
 	}
 
+	if name := result["name"]; name != "http-test-account" {
+		t.Fatalf("Expected name http-test-account, got %v", name)
+	}
+
 	t.Logf("Created account via HTTP with ID: %s", id)
 
 	// Clean up - delete the account
@@ -75,7 +109,7 @@ func TestHTTPCreateAccount(t *testing.T) {
 		fmt.Sprintf("%s/v1/accounts/%s", httpBaseURL, id),
 		nil,
 	)
-	deleteResp, err := http.DefaultClient.Do(deleteReq)
+	deleteResp, err := httpClient.Do(deleteReq)
 	if err != nil {
 		t.Logf("Warning: Failed to clean up account: %v", err)
 	} else {
@@ -107,7 +141,7 @@ func TestHTTPListAccounts(t *testing.T) {
 	}
 	bodyBytes, _ := json.Marshal(reqBody)
 
-	createResp, err := http.Post(
+	createResp, err := httpClient.Post(
 		httpBaseURL+"/v1/accounts",
 		"application/json",
 		bytes.NewBuffer(bodyBytes),
@@ -127,14 +161,14 @@ func TestHTTPListAccounts(t *testing.T) {
 			fmt.Sprintf("%s/v1/accounts/%s", httpBaseURL, accountID),
 			nil,
 		)
-		deleteResp, _ := http.DefaultClient.Do(deleteReq)
+		deleteResp, _ := httpClient.Do(deleteReq)
 		if deleteResp != nil {
 			deleteResp.Body.Close()
 		}
 	}()
 
 	// List accounts
-	resp, err := http.Get(httpBaseURL + "/v1/accounts")
+	resp, err := httpClient.Get(httpBaseURL + "/v1/accounts")
 	if err != nil {
 		t.Fatalf("Failed to list accounts via HTTP: %v", err)
 	}
@@ -186,7 +220,7 @@ func TestHTTPDeleteAccount(t *testing.T) {
 	}
 	bodyBytes, _ := json.Marshal(reqBody)
 
-	createResp, err := http.Post(
+	createResp, err := httpClient.Post(
 		httpBaseURL+"/v1/accounts",
 		"application/json",
 		bytes.NewBuffer(bodyBytes),
@@ -206,7 +240,7 @@ func TestHTTPDeleteAccount(t *testing.T) {
 		fmt.Sprintf("%s/v1/accounts/%s", httpBaseURL, accountID),
 		nil,
 	)
-	deleteResp, err := http.DefaultClient.Do(deleteReq)
+	deleteResp, err := httpClient.Do(deleteReq)
 	if err != nil {
 		t.Fatalf("Failed to delete account: %v", err)
 	}
@@ -249,7 +283,7 @@ func TestHTTPAccountLifecycle(t *testing.T) {
 	httpBaseURL := tc.GetHttpClient(test.GrpcServer)
 
 	// 1. List initial accounts
-	initialResp, err := http.Get(httpBaseURL + "/v1/accounts")
+	initialResp, err := httpClient.Get(httpBaseURL + "/v1/accounts")
 	if err != nil {
 		t.Fatalf("Failed to list initial accounts: %v", err)
 	}
@@ -267,7 +301,7 @@ func TestHTTPAccountLifecycle(t *testing.T) {
 	}
 	bodyBytes, _ := json.Marshal(reqBody)
 
-	createResp, err := http.Post(
+	createResp, err := httpClient.Post(
 		httpBaseURL+"/v1/accounts",
 		"application/json",
 		bytes.NewBuffer(bodyBytes),
@@ -283,7 +317,7 @@ func TestHTTPAccountLifecycle(t *testing.T) {
 	t.Logf("Created account via HTTP: %s", accountID)
 
 	// 3. Verify account appears in list
-	afterCreateResp, err := http.Get(httpBaseURL + "/v1/accounts")
+	afterCreateResp, err := httpClient.Get(httpBaseURL + "/v1/accounts")
 	if err != nil {
 		t.Fatalf("Failed to list accounts after create: %v", err)
 	}
@@ -302,7 +336,7 @@ func TestHTTPAccountLifecycle(t *testing.T) {
 		fmt.Sprintf("%s/v1/accounts/%s", httpBaseURL, accountID),
 		nil,
 	)
-	deleteResp, err := http.DefaultClient.Do(deleteReq)
+	deleteResp, err := httpClient.Do(deleteReq)
 	if err != nil {
 		t.Fatalf("Failed to delete account: %v", err)
 	}
@@ -316,7 +350,7 @@ func TestHTTPAccountLifecycle(t *testing.T) {
 	t.Logf("Deleted account via HTTP: %s", accountID)
 
 	// 5. Verify account no longer in list
-	afterDeleteResp, err := http.Get(httpBaseURL + "/v1/accounts")
+	afterDeleteResp, err := httpClient.Get(httpBaseURL + "/v1/accounts")
 	if err != nil {
 		t.Fatalf("Failed to list accounts after delete: %v", err)
 	}
@@ -351,23 +385,204 @@ func TestHTTPDeleteAccountNotFound(t *testing.T) {
 	httpBaseURL := tc.GetHttpClient(test.GrpcServer)
 
 	// Try to delete a non-existent account
+	missingID := ids.AccountID("non-existent-account")
 	deleteReq, _ := http.NewRequest(
 		http.MethodDelete,
-		fmt.Sprintf("%s/v1/accounts/%s", httpBaseURL, "non-existent-account"),
+		fmt.Sprintf("%s/v1/accounts/%s", httpBaseURL, missingID.PathSegment()),
 		nil,
 	)
-	deleteResp, err := http.DefaultClient.Do(deleteReq)
+	deleteResp, err := httpClient.Do(deleteReq)
 	if err != nil {
 		t.Fatalf("Failed to send delete request: %v", err)
 	}
 	defer deleteResp.Body.Close()
 
-	// Should get a non-200 status code
-	if deleteResp.StatusCode == http.StatusOK {
-		t.Fatal("Expected error status when deleting non-existent account")
+	if deleteResp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(deleteResp.Body)
+		t.Fatalf("Expected status 404 when deleting non-existent account, got %d: %s", deleteResp.StatusCode, string(body))
+	}
+
+	// The gateway renders the ErrorInfo detail with a stable reason and the missing ID
+	var errorBody struct {
+		Code    int `json:"code"`
+		Details []struct {
+			Type     string            `json:"@type"`
+			Reason   string            `json:"reason"`
+			Metadata map[string]string `json:"metadata"`
+		} `json:"details"`
+	}
+	if err := json.NewDecoder(deleteResp.Body).Decode(&errorBody); err != nil {
+		t.Fatalf("Failed to decode error body: %v", err)
+	}
+	if errorBody.Code != int(codes.NotFound) {
+		t.Fatalf("Expected gRPC code NotFound in the error body, got %d", errorBody.Code)
+	}
+	if len(errorBody.Details) != 1 || errorBody.Details[0].Type != "type.googleapis.com/google.rpc.ErrorInfo" {
+		t.Fatalf("Expected a single ErrorInfo detail, got %+v", errorBody.Details)
+	}
+	if detail := errorBody.Details[0]; detail.Reason != "ACCOUNT_NOT_FOUND" || detail.Metadata["id"] != missingID.String() {
+		t.Fatalf("Expected reason ACCOUNT_NOT_FOUND for ID %s, got %+v", missingID, detail)
+	}
+
+	// A raw ID that isn't base64 is rejected rather than guessed at
+	rawReq, _ := http.NewRequest(http.MethodDelete, httpBaseURL+"/v1/accounts/missing!", nil)
+	rawResp, err := httpClient.Do(rawReq)
+	if err != nil {
+		t.Fatalf("Failed to send delete request: %v", err)
+	}
+	defer rawResp.Body.Close()
+
+	if rawResp.StatusCode != http.StatusBadRequest {
+		body, _ := io.ReadAll(rawResp.Body)
+		t.Fatalf("Expected status 400 for a raw ID, got %d: %s", rawResp.StatusCode, string(body))
+	}
+}
+
+func TestHTTPDeleteAccountWithSlashInID(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	client := tc.GrpcClient(test.GrpcServer)
+	httpBaseURL := tc.GetHttpClient(test.GrpcServer)
+
+	// The raw IDs contain a slash and so do their standard base64 forms, e.g. "dGVhbS9iZXRhPz8/";
+	// the path takes either the URL-safe form or the standard form percent-encoded
+	for _, c := range []struct {
+		name    string
+		segment func(ids.AccountID) string
+	}{
+		{name: "team/alpha??", segment: ids.AccountID.PathSegment},
+		{name: "team/beta???", segment: func(id ids.AccountID) string { return url.PathEscape(id.String()) }},
+	} {
+		account, err := client.CreateAccount(ctx, c.name)
+		if err != nil {
+			t.Fatalf("Failed to create account %q: %v", c.name, err)
+		}
+		accountID := ids.AccountIDFromProto(account.GetAccountId())
+
+		deleteReq, _ := http.NewRequest(
+			http.MethodDelete,
+			fmt.Sprintf("%s/v1/accounts/%s", httpBaseURL, c.segment(accountID)),
+			nil,
+		)
+		deleteResp, err := httpClient.Do(deleteReq)
+		if err != nil {
+			t.Fatalf("Failed to send delete request: %v", err)
+		}
+		body, _ := io.ReadAll(deleteResp.Body)
+		deleteResp.Body.Close()
+
+		if deleteResp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 deleting %q via %s, got %d: %s", c.name, deleteReq.URL.EscapedPath(), deleteResp.StatusCode, string(body))
+		}
+	}
+
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list accounts: %v", err)
+	}
+	if len(accounts) != 0 {
+		t.Fatalf("Expected both accounts to be deleted, got %d", len(accounts))
+	}
+}
+
+func TestHTTPAccountIDRoundTripsBetweenBodyAndPath(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(test.GrpcServer).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	httpBaseURL := tc.GetHttpClient(test.GrpcServer)
+
+	// The raw ID is non-ASCII and its standard base64 form, "Y2Fmw6k/Pn4=", needs both "/" and "="
+	bodyBytes, _ := json.Marshal(map[string]string{"name": "café?>~"})
+	createResp, err := httpClient.Post(httpBaseURL+"/v1/accounts", "application/json", bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	var created struct {
+		AccountID struct {
+			ID string `json:"id"`
+		} `json:"accountId"`
+	}
+	err = json.NewDecoder(createResp.Body).Decode(&created)
+	createResp.Body.Close()
+	if err != nil {
+		t.Fatalf("Failed to decode created account: %v", err)
+	}
+	bodyID := created.AccountID.ID
+	accountID, err := ids.ParseAccountID(bodyID)
+	if err != nil {
+		t.Fatalf("Failed to parse account ID %q from the response body: %v", bodyID, err)
+	}
+	if string(accountID) != "café?>~" {
+		t.Fatalf("Expected the body ID to decode to the raw ID, got %q", accountID)
+	}
+
+	// The ID read from a response body addresses the same account in the path, in either base64 form
+	for _, segment := range []string{url.PathEscape(bodyID), accountID.PathSegment()} {
+		getResp, err := httpClient.Get(fmt.Sprintf("%s/v1/accounts/%s", httpBaseURL, segment))
+		if err != nil {
+			t.Fatalf("Failed to get account: %v", err)
+		}
+		var got struct {
+			AccountID struct {
+				ID string `json:"id"`
+			} `json:"accountId"`
+		}
+		body, _ := io.ReadAll(getResp.Body)
+		getResp.Body.Close()
+		if getResp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 getting /v1/accounts/%s, got %d: %s", segment, getResp.StatusCode, string(body))
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("Failed to decode account: %v", err)
+		}
+		if got.AccountID.ID != bodyID {
+			t.Fatalf("Expected /v1/accounts/%s to return ID %s, got %s", segment, bodyID, got.AccountID.ID)
+		}
+	}
+
+	deleteReq, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/v1/accounts/%s", httpBaseURL, url.PathEscape(bodyID)), nil)
+	deleteResp, err := httpClient.Do(deleteReq)
+	if err != nil {
+		t.Fatalf("Failed to send delete request: %v", err)
+	}
+	deleteResp.Body.Close()
+	if deleteResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 deleting %s, got %d", bodyID, deleteResp.StatusCode)
 	}
 
-	t.Logf("Got expected error status: %d", deleteResp.StatusCode)
+	getResp, err := httpClient.Get(fmt.Sprintf("%s/v1/accounts/%s", httpBaseURL, accountID.PathSegment()))
+	if err != nil {
+		t.Fatalf("Failed to get account: %v", err)
+	}
+	getResp.Body.Close()
+	if getResp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected status 404 after delete, got %d", getResp.StatusCode)
+	}
 }
 
 func TestHTTPCreateAccountValidation(t *testing.T) {
@@ -394,7 +609,7 @@ func TestHTTPCreateAccountValidation(t *testing.T) {
 	}
 	bodyBytes, _ := json.Marshal(reqBody)
 
-	resp, err := http.Post(
+	resp, err := httpClient.Post(
 		httpBaseURL+"/v1/accounts",
 		"application/json",
 		bytes.NewBuffer(bodyBytes),
@@ -410,4 +625,228 @@ func TestHTTPCreateAccountValidation(t *testing.T) {
 	}
 
 	t.Logf("Got expected validation error status: %d", resp.StatusCode)
+
+	// The gateway renders the BadRequest detail in the error body
+	type fieldViolation struct {
+		Field string `json:"field"`
+	}
+	var errorBody struct {
+		Details []struct {
+			Type string `json:"@type"`
+			// Named after the marshaler's field naming
+			FieldViolations      []fieldViolation `json:"field_violations"`
+			FieldViolationsCamel []fieldViolation `json:"fieldViolations"`
+		} `json:"details"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errorBody); err != nil {
+		t.Fatalf("Failed to decode error body: %v", err)
+	}
+	if len(errorBody.Details) != 1 || errorBody.Details[0].Type != "type.googleapis.com/google.rpc.BadRequest" {
+		t.Fatalf("Expected a single BadRequest detail, got %+v", errorBody.Details)
+	}
+	violations := append(errorBody.Details[0].FieldViolations, errorBody.Details[0].FieldViolationsCamel...)
+	if len(violations) != 1 || violations[0].Field != "name" {
+		t.Fatalf("Expected a violation of field \"name\", got %+v", violations)
+	}
+}
+
+func TestHTTPGatewayMethodFilter(t *testing.T) {
+	ctx := context.Background()
+
+	server := test.GrpcServer.WithGatewayMethodFilter("/configuration_service.v1.Configuration/ListAccounts")
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(server).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	httpBaseURL := tc.GetHttpClient(server)
+
+	// The denied method is not routed over HTTP
+	resp, err := httpClient.Get(httpBaseURL + "/v1/accounts")
+	if err != nil {
+		t.Fatalf("Failed to list accounts via HTTP: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected status 404 for a denied method, got %d", resp.StatusCode)
+	}
+
+	// Other methods on the same path stay routed
+	resp, err = httpClient.Post(httpBaseURL+"/v1/accounts", "application/json", bytes.NewBufferString(`{"name":"filtered-gateway-account"}`))
+	if err != nil {
+		t.Fatalf("Failed to create account via HTTP: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 for an allowed method, got %d", resp.StatusCode)
+	}
+
+	// The denied method is still served over gRPC
+	client := tc.GrpcClient(server)
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Expected ListAccounts to succeed over gRPC: %v", err)
+	}
+	if len(accounts) != 1 {
+		t.Fatalf("Expected 1 account, got %d", len(accounts))
+	}
+}
+
+// connCounter is a stats handler that counts the connections a gRPC server accepts
+type connCounter struct {
+	conns atomic.Int32
+}
+
+func (c *connCounter) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (c *connCounter) HandleRPC(context.Context, stats.RPCStats) {}
+
+func (c *connCounter) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (c *connCounter) HandleConn(_ context.Context, s stats.ConnStats) {
+	if _, ok := s.(*stats.ConnBegin); ok {
+		c.conns.Add(1)
+	}
+}
+
+// countingServer serves the account API from a gRPC server counting its connections
+type countingServer struct {
+	accountApi *api.ConfigurationApi
+	counter    *connCounter
+}
+
+func (s *countingServer) Register(sb *serverbase.ServerBuilder, grpcPort, httpPort int) error {
+	sb.WithGRPCOptions(grpcPort, grpc.StatsHandler(s.counter))
+	sb.RegisterService(grpcPort, httpPort, s.accountApi)
+	return nil
+}
+
+func TestHTTPGatewayCallsServerInProcess(t *testing.T) {
+	sender := &flakyListSender{response: &configpb.ListAccountsResponseProto{
+		Accounts: []*configpb.AccountConfigurationProto{{}},
+	}}
+	counter := &connCounter{}
+	server := serverbase.NewServerBase()
+	server.ServerInterface = &countingServer{accountApi: api.NewConfigurationApi(sender), counter: counter}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Launch(0, 0)
+	}()
+	defer func() {
+		server.Shutdown()
+		<-done
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(ctx); err != nil {
+		t.Fatalf("Server did not start: %v", err)
+	}
+
+	// The gateway answers from the API itself
+	for range 3 {
+		resp, err := httpClient.Get("http://" + server.HTTPAddr().String() + "/v1/accounts")
+		if err != nil {
+			t.Fatalf("Failed to call HTTP gateway: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200 from HTTP gateway, got %d", resp.StatusCode)
+		}
+	}
+
+	// None of the requests went through a connection to the gRPC port
+	if got := counter.conns.Load(); got != 0 {
+		t.Fatalf("Expected the gateway to make no gRPC connection, the gRPC server accepted %d", got)
+	}
+}
+
+func TestRemoteGatewayProxiesToGRPCServer(t *testing.T) {
+	ctx := context.Background()
+	tc, server, certs := newTLSTestContext(t, ctx)
+
+	caCert, err := os.ReadFile(certs.CAFile)
+	if err != nil {
+		t.Fatalf("Failed to read CA certificate: %v", err)
+	}
+	caCertPool := x509.NewCertPool()
+	caCertPool.AppendCertsFromPEM(caCert)
+
+	// A separate gateway server dials the TLS gRPC server over localhost
+	gateway := serverbase.NewServerBase()
+	gateway.ServerInterface = serverbase.NewRemoteGateway(tc.GetGrpcClient(server), gw.RegisterConfigurationHandlerFromEndpoint).
+		WithTLS(&tls.Config{RootCAs: caCertPool, ServerName: tlsServerName})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		gateway.Launch(0, 0)
+	}()
+	defer func() {
+		gateway.Shutdown()
+		<-done
+	}()
+
+	readyCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := gateway.WaitUntilReady(readyCtx); err != nil {
+		t.Fatalf("Gateway did not start: %v", err)
+	}
+	if addr := gateway.GRPCAddr(); addr != nil {
+		t.Fatalf("Expected the gateway to serve no gRPC port, got %s", addr)
+	}
+
+	gatewayURL := "http://" + gateway.HTTPAddr().String()
+	resp, err := httpClient.Post(gatewayURL+"/v1/accounts", "application/json", bytes.NewBufferString(`{"name":"remote-gateway-account"}`))
+	if err != nil {
+		t.Fatalf("Failed to create account through the gateway: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200 creating through the gateway, got %d", resp.StatusCode)
+	}
+
+	// The account was stored by the gRPC server behind the gateway
+	client, err := configClient.NewClient(ctx, &configClient.Config{
+		ServerAddress:      tc.GetGrpcClient(server),
+		CAFile:             certs.CAFile,
+		ServerNameOverride: tlsServerName,
+		TenantID:           testTenant,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	accounts, err := client.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list accounts over gRPC: %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].GetName() != "remote-gateway-account" {
+		t.Fatalf("Expected the account created through the gateway, got %d accounts", len(accounts))
+	}
+
+	// Errors from the upstream keep their HTTP mapping
+	resp, err = httpClient.Post(gatewayURL+"/v1/accounts", "application/json", bytes.NewBufferString(`{"name":""}`))
+	if err != nil {
+		t.Fatalf("Failed to call the gateway: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for an empty name, got %d", resp.StatusCode)
+	}
 }
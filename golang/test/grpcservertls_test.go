@@ -0,0 +1,80 @@
+package test_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	configClient "github.com/berendjan/golang-bazel-starter/golang/config/client"
+	"github.com/berendjan/golang-bazel-starter/golang/test"
+)
+
+// tlsServerName is the only name the generated server certificate is valid for
+const tlsServerName = "grpcserver.test"
+
+// newTLSTestContext starts a TLS server whose certificate does not match the dial host "localhost"
+func newTLSTestContext(t *testing.T, ctx context.Context) (*test.TestContext, test.ServerConfig, *test.TestCertificates) {
+	t.Helper()
+
+	certs, err := test.GenerateTestCertificates(t.TempDir(), tlsServerName)
+	if err != nil {
+		t.Fatalf("Failed to generate certificates: %v", err)
+	}
+	server := test.GrpcServer.WithTLS(certs.CertFile, certs.KeyFile)
+
+	tc, err := test.NewTestContextBuilder().
+		WithDatabase(test.ConfigDb).
+		WithServer(server).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	})
+	return tc, server, certs
+}
+
+func TestTLSClientWithServerNameOverride(t *testing.T) {
+	ctx := context.Background()
+	tc, server, certs := newTLSTestContext(t, ctx)
+
+	client, err := configClient.NewClient(ctx, &configClient.Config{
+		ServerAddress:      tc.GetGrpcClient(server),
+		CAFile:             certs.CAFile,
+		ServerNameOverride: tlsServerName,
+		TenantID:           testTenant,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.CreateAccount(ctx, "tls-account"); err != nil {
+		t.Fatalf("Failed to create account over TLS: %v", err)
+	}
+}
+
+func TestTLSClientRejectsMismatchedServerName(t *testing.T) {
+	ctx := context.Background()
+	tc, server, certs := newTLSTestContext(t, ctx)
+
+	// Without an override the client verifies the certificate against "localhost"
+	client, err := configClient.NewClient(ctx, &configClient.Config{
+		ServerAddress: tc.GetGrpcClient(server),
+		CAFile:        certs.CAFile,
+		TenantID:      testTenant,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := client.CreateAccount(callCtx, "tls-account"); err == nil {
+		t.Fatal("Expected certificate verification to fail without a server name override")
+	}
+}
@@ -0,0 +1,142 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc/connectivity"
+)
+
+// How long AssertNoLeaks waits for closed connections to disappear from pg_stat_activity
+const (
+	leakCheckTimeout       = 5 * time.Second
+	leakCheckRetryInterval = 100 * time.Millisecond
+)
+
+// LeakCheckOption configures AssertNoLeaks
+type LeakCheckOption func(*leakCheckConfig)
+
+type leakCheckConfig struct {
+	goroutines    bool
+	goleakOptions []goleak.Option
+}
+
+// WithGoroutineLeakCheck also fails on goroutines that are still running, as reported by goleak
+// Pass goleak.IgnoreCurrent() captured before Build to ignore goroutines that were already running, e.g. the shared container's
+func WithGoroutineLeakCheck(opts ...goleak.Option) LeakCheckOption {
+	return func(c *leakCheckConfig) {
+		c.goroutines = true
+		c.goleakOptions = opts
+	}
+}
+
+// AssertNoLeaks fails t if connections to the test databases of tc, or gRPC clients it created, are still open after CleanUp
+// A leaked connection is also why CleanUp warns that DROP DATABASE failed
+func AssertNoLeaks(t testing.TB, tc *TestContext, opts ...LeakCheckOption) {
+	t.Helper()
+
+	var config leakCheckConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*leakCheckTimeout)
+	defer cancel()
+
+	leaked, err := tc.openConnections(ctx)
+	if err != nil {
+		t.Errorf("Failed to check for leaked connections: %v", err)
+	} else if len(leaked) > 0 {
+		t.Errorf("Found %d leaked connections to test databases: %s", len(leaked), strings.Join(leaked, ", "))
+	}
+
+	if open := tc.openClients(); open > 0 {
+		t.Errorf("Found %d gRPC clients still open", open)
+	}
+
+	if config.goroutines {
+		if err := goleak.Find(config.goleakOptions...); err != nil {
+			t.Errorf("Found leaked goroutines: %v", err)
+		}
+	}
+}
+
+// openClients counts the gRPC clients created through tc that are not closed
+func (tc *TestContext) openClients() int {
+	tc.clientsMu.Lock()
+	defer tc.clientsMu.Unlock()
+
+	open := 0
+	for _, c := range tc.clients {
+		if c.State() != connectivity.Shutdown {
+			open++
+		}
+	}
+	return open
+}
+
+// openConnections returns the connections to the test databases of tc that are still open
+// Backends exit shortly after their client disconnects, so it retries for a while before reporting them
+func (tc *TestContext) openConnections(ctx context.Context) ([]string, error) {
+	dbNames := make([]string, 0, len(tc.databases))
+	for _, db := range tc.databases {
+		dbNames = append(dbNames, db.dbName)
+	}
+	if len(dbNames) == 0 {
+		return nil, nil
+	}
+
+	conn, err := pgx.Connect(ctx, tc.postgresConfig.ConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to postgres database: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	deadline := time.Now().Add(leakCheckTimeout)
+	for {
+		open, err := queryOpenConnections(ctx, conn, dbNames)
+		if err != nil || len(open) == 0 || time.Now().After(deadline) {
+			return open, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return open, nil
+		case <-time.After(leakCheckRetryInterval):
+		}
+	}
+}
+
+// queryOpenConnections lists the connections to the given databases as "database (pid N, state)"
+func queryOpenConnections(ctx context.Context, conn *pgx.Conn, dbNames []string) ([]string, error) {
+	rows, err := conn.Query(ctx,
+		`SELECT datname, pid, COALESCE(state, '') FROM pg_stat_activity
+		 WHERE datname = ANY($1) AND pid <> pg_backend_pid()
+		 ORDER BY datname, pid`,
+		dbNames,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pg_stat_activity: %w", err)
+	}
+	defer rows.Close()
+
+	var open []string
+	for rows.Next() {
+		var dbName, state string
+		var pid int32
+		if err := rows.Scan(&dbName, &pid, &state); err != nil {
+			return nil, fmt.Errorf("failed to scan pg_stat_activity: %w", err)
+		}
+		open = append(open, fmt.Sprintf("%s (pid %d, %s)", dbName, pid, state))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return open, nil
+}
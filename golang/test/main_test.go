@@ -0,0 +1,22 @@
+package test_test
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"github.com/berendjan/golang-bazel-starter/golang/test"
+)
+
+// TestMain fails the suite if the shared container was started more than once
+// Every test context is meant to reuse one Postgres container; recreating it slows the suite down considerably
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if count := test.ContainerStartCount(); count > 1 {
+		log.Printf("Shared PostgreSQL test container was started %d times, expected at most once", count)
+		if code == 0 {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
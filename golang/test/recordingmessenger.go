@@ -0,0 +1,56 @@
+package test
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/berendjan/golang-bazel-starter/golang/grpcserver/messenger"
+)
+
+// MessageIDHeader is the metadata header naming the message whose path a RecordingMessenger records
+// Over the HTTP gateway send it as "Grpc-Metadata-X-Test-Message-Id"
+const MessageIDHeader = "x-test-message-id"
+
+// WithMessageID returns ctx sending id in MessageIDHeader, so the test server records the call's path under id
+func WithMessageID(ctx context.Context, id string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, MessageIDHeader, id)
+}
+
+// RecordingMessenger is the messenger of the test servers, recording the receivers each message traverses
+// Only calls carrying MessageIDHeader are recorded
+type RecordingMessenger struct {
+	*messenger.GrpcMessenger
+
+	mu     sync.Mutex
+	traces map[string][]string // message ID -> receivers in the order they handled it
+}
+
+// NewRecordingMessenger installs a RecordingMessenger as the observer of m
+func NewRecordingMessenger(m *messenger.GrpcMessenger) *RecordingMessenger {
+	r := &RecordingMessenger{GrpcMessenger: m, traces: make(map[string][]string)}
+	m.WithObserver(r.record)
+	return r
+}
+
+// record appends receiver to the trace of the message ID in the incoming metadata of ctx, if any
+func (r *RecordingMessenger) record(ctx context.Context, _, receiver string) {
+	ids := metadata.ValueFromIncomingContext(ctx, MessageIDHeader)
+	if len(ids) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.traces[ids[0]] = append(r.traces[ids[0]], receiver)
+}
+
+// Trace returns the handlers that received the message sent with messageID, in order
+// e.g. [middlewareOne middlewareTwo auditMiddleware accountRepository] for a created account
+func (r *RecordingMessenger) Trace(messageID string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.traces[messageID])
+}
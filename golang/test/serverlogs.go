@@ -0,0 +1,46 @@
+package test
+
+import (
+	"log"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/serverbase"
+)
+
+// contextLogging serializes installing the default slog handler
+var contextLogging sync.Mutex
+
+// routeLogsByContext makes the default slog handler a serverbase.ContextHandler wrapping the current one
+// Records of calls to a server with a logger reach that logger; all others are written as before
+func routeLogsByContext() {
+	contextLogging.Lock()
+	defer contextLogging.Unlock()
+	if _, ok := slog.Default().Handler().(*serverbase.ContextHandler); ok {
+		return
+	}
+	// SetDefault redirects the log package into the new handler, whose fallback may write through the log
+	// package; keep it writing where it did so records don't loop
+	writer, flags := log.Writer(), log.Flags()
+	slog.SetDefault(slog.New(serverbase.NewContextHandler(slog.Default().Handler())))
+	log.SetOutput(writer)
+	log.SetFlags(flags)
+}
+
+// TestLogger returns a logger writing each record with t.Log, for WithServerLogger
+// The records show with the test that made the call, and only when it fails or runs verbosely
+func TestLogger(t testing.TB) *slog.Logger {
+	return slog.New(slog.NewTextHandler(testLogWriter{t: t}, nil))
+}
+
+// testLogWriter writes every record to t.Log
+type testLogWriter struct {
+	t testing.TB
+}
+
+func (w testLogWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
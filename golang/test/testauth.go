@@ -0,0 +1,64 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/auth"
+)
+
+// TestUserID is the user TestAuthValidator authenticates every request as
+const TestUserID = "test-user"
+
+// TestAuthValidator authenticates every request as UserID without calling Kratos
+// The test context provider injects it into the real MiddleOne
+type TestAuthValidator struct {
+	UserID string
+
+	// Delegate authenticates requests instead when set, e.g. FakeKratos.AuthMiddleware for real validation
+	Delegate auth.Validator
+
+	calls atomic.Int64
+
+	mu         sync.Mutex
+	lastCookie string
+}
+
+// Compile-time check that TestAuthValidator implements auth.Validator
+var _ auth.Validator = (*TestAuthValidator)(nil)
+
+// NewTestAuthValidator creates a validator authenticating every request as TestUserID
+func NewTestAuthValidator() *TestAuthValidator {
+	return &TestAuthValidator{UserID: TestUserID}
+}
+
+// ExtractUserID returns UserID for every request, or the Delegate's result, recording the cookie the request carried
+func (v *TestAuthValidator) ExtractUserID(ctx context.Context) (string, error) {
+	v.calls.Add(1)
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	v.mu.Lock()
+	v.lastCookie = strings.Join(md.Get("cookie"), "; ")
+	v.mu.Unlock()
+
+	if v.Delegate != nil {
+		return v.Delegate.ExtractUserID(ctx)
+	}
+	return v.UserID, nil
+}
+
+// LastCookie returns the cookie metadata of the last authenticated request, empty if it had none
+func (v *TestAuthValidator) LastCookie() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.lastCookie
+}
+
+// Calls returns how many requests the validator authenticated
+func (v *TestAuthValidator) Calls() int64 {
+	return v.calls.Load()
+}
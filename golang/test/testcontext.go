@@ -5,11 +5,12 @@
 // is reused across all tests for efficiency.
 //
 // Key features:
-// - Single shared container across all tests (created once via sync.Once)
+// - Single shared container across all tests (created on first successful start, retried after failures)
 // - Isolated databases per test (each test gets unique database(s))
 // - Automatic migration execution via dbmate format
 // - Support for multiple databases and servers per test
 // - Builder pattern for flexible configuration
+// - Configurable container wait strategy and timeout (TEST_CONTAINER_WAIT_STRATEGY, TEST_CONTAINER_WAIT_TIMEOUT)
 //
 // Example usage with builder:
 //
@@ -34,17 +35,24 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"math/rand"
-	"net/http"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/berendjan/golang-bazel-starter/golang/config/client"
 	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
 	"github.com/berendjan/golang-bazel-starter/golang/framework/serverbase"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
 	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -53,18 +61,61 @@ CREATE TABLE IF NOT EXISTS test_databases (
     dbname TEXT PRIMARY KEY,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
+
+-- Who created the database, so a leftover one can be traced to its test and process;
+-- added idempotently since the shared container may hold a table from before these columns
+ALTER TABLE test_databases ADD COLUMN IF NOT EXISTS test_id TEXT;
+ALTER TABLE test_databases ADD COLUMN IF NOT EXISTS hostname TEXT;
+ALTER TABLE test_databases ADD COLUMN IF NOT EXISTS pid INTEGER;
 `
 )
 
-var (
-	// Singleton container and connection info for test contexts
-	sharedContainer     testcontainers.Container
-	sharedContainerHost string
-	sharedContainerPort int
-	sharedContainerOnce sync.Once
-	sharedContainerErr  error
+// ApplicationName tags the connections of test contexts in pg_stat_activity
+const ApplicationName = "golang-test"
+
+// TestTenantID is the tenant of the clients returned by TestContext.GrpcClient
+const TestTenantID = "test-tenant"
+
+// ContainerWaitStrategy selects how the shared container is considered ready
+type ContainerWaitStrategy string
+
+const (
+	// WaitForLog waits for the PostgreSQL ready log line to appear twice (after init and after restart)
+	WaitForLog ContainerWaitStrategy = "log"
+	// WaitForSQL waits until a SQL query over TCP succeeds, independent of the log format
+	WaitForSQL ContainerWaitStrategy = "sql"
+
+	// Environment variables overriding the default wait strategy and timeout
+	ContainerWaitStrategyEnv = "TEST_CONTAINER_WAIT_STRATEGY"
+	ContainerWaitTimeoutEnv  = "TEST_CONTAINER_WAIT_TIMEOUT"
+
+	defaultContainerWaitTimeout = 60 * time.Second
 )
 
+// ContainerWaitConfig controls how long and how startup of the shared container is awaited
+type ContainerWaitConfig struct {
+	Strategy ContainerWaitStrategy
+	Timeout  time.Duration
+}
+
+// containerFactory starts a PostgreSQL container and returns it with its host and port
+type containerFactory func(ctx context.Context, waitConfig ContainerWaitConfig) (testcontainers.Container, string, int, error)
+
+// sharedContainerState caches the shared container once it started successfully
+// Failures are not cached, so a later call retries instead of failing every remaining test
+type sharedContainerState struct {
+	mu        sync.Mutex
+	container testcontainers.Container
+	host      string
+	port      int
+	factory   containerFactory
+	starts    int
+	resets    int
+}
+
+// Singleton container and connection info for test contexts
+var sharedContainer = &sharedContainerState{factory: startPostgresContainer}
+
 // TestContext provides isolated database and server instances for testing
 type TestContext struct {
 	testID              string
@@ -72,12 +123,18 @@ type TestContext struct {
 	databases           map[database]*TestDBContext
 	servers             map[server]*TestServerContext
 	postgresClient      *db.DBPool
+	postgresConfig      *db.Config
 	testContextProvider *TestContextProvider
+
+	clientsMu      sync.Mutex
+	clients        []*client.ConfigurationClient
+	defaultClients map[server]*client.ConfigurationClient
 }
 
 // TestDBContext manages a test database connection
 type TestDBContext struct {
 	client        *db.DBPool
+	clientConfig  *db.Config
 	dbName        string
 	dbURL         string
 	migrationsDir string
@@ -89,6 +146,7 @@ type TestServerContext struct {
 	grpcPort   int
 	httpPort   int
 	server     *serverbase.ServerBase
+	logger     *slog.Logger // receives the records of the server's calls, nil for the global logger
 	serverDone chan struct{}
 }
 
@@ -107,6 +165,30 @@ func (s *TestServerContext) Shutdown() {
 type DatabaseConfig struct {
 	database
 	migrationsDir string
+	postMigrate   []func(context.Context, *db.DBPool) error
+	countQueries  bool
+}
+
+// WithQueryCount returns a copy of the database configuration whose pool counts its statements
+// Read the count with TestContext.Database(...).QueryCount to assert the round trips of a handler
+func (c DatabaseConfig) WithQueryCount() DatabaseConfig {
+	c.countQueries = true
+	return c
+}
+
+// WithPostMigrate returns a copy of the database configuration that runs fn after the migrations
+// Use it for fixtures the app migrations don't provide, such as extensions or custom triggers
+func (c DatabaseConfig) WithPostMigrate(fn func(ctx context.Context, pool *db.DBPool) error) DatabaseConfig {
+	c.postMigrate = append(slices.Clip(c.postMigrate), fn)
+	return c
+}
+
+// WithPostMigrationSQL returns a copy of the database configuration that executes sql after the migrations
+func (c DatabaseConfig) WithPostMigrationSQL(sql string) DatabaseConfig {
+	return c.WithPostMigrate(func(ctx context.Context, pool *db.DBPool) error {
+		_, err := pool.Exec(ctx, sql)
+		return err
+	})
 }
 
 // ServerConfig holds configuration for a server to be created
@@ -115,10 +197,40 @@ type ServerConfig struct {
 	provider func(*TestContextProvider) *serverbase.ServerBase
 }
 
+// WithTLS returns a copy of the server configuration that serves TLS using the given certificate
+func (c ServerConfig) WithTLS(certFile, keyFile string) ServerConfig {
+	provider := c.provider
+	c.provider = func(tcp *TestContextProvider) *serverbase.ServerBase {
+		return provider(tcp).WithTLS(certFile, keyFile)
+	}
+	return c
+}
+
+// WithUnaryInterceptor returns a copy of the server configuration with additional unary interceptors
+func (c ServerConfig) WithUnaryInterceptor(interceptors ...grpc.UnaryServerInterceptor) ServerConfig {
+	provider := c.provider
+	c.provider = func(tcp *TestContextProvider) *serverbase.ServerBase {
+		return provider(tcp).WithUnaryInterceptor(interceptors...)
+	}
+	return c
+}
+
+// WithGatewayMethodFilter returns a copy of the server configuration keeping the given gRPC methods off the HTTP gateway
+func (c ServerConfig) WithGatewayMethodFilter(deny ...string) ServerConfig {
+	provider := c.provider
+	c.provider = func(tcp *TestContextProvider) *serverbase.ServerBase {
+		return provider(tcp).WithGatewayMethodFilter(deny...)
+	}
+	return c
+}
+
 // TestContextBuilder builds a TestContext with multiple databases and servers
 type TestContextBuilder struct {
-	databases []DatabaseConfig
-	servers   []ServerConfig
+	databases       []DatabaseConfig
+	servers         []ServerConfig
+	waitConfig      ContainerWaitConfig
+	migrationLogger MigrationLogger
+	serverLogger    *slog.Logger
 }
 
 // NewTestContextBuilder creates a new TestContextBuilder
@@ -141,12 +253,51 @@ func (b *TestContextBuilder) WithServer(serverConfig ServerConfig) *TestContextB
 	return b
 }
 
+// WithMigrationLogger routes migration logs of the test databases to logger instead of the global logger
+func (b *TestContextBuilder) WithMigrationLogger(logger MigrationLogger) *TestContextBuilder {
+	b.migrationLogger = logger
+	return b
+}
+
+// WithServerLogger routes the slog records of the test servers' gRPC calls to logger, e.g. TestLogger(t)
+// Without it they go to the global logger, interleaved with the calls of concurrent tests
+func (b *TestContextBuilder) WithServerLogger(logger *slog.Logger) *TestContextBuilder {
+	b.serverLogger = logger
+	return b
+}
+
+// WithContainerWaitStrategy overrides how startup of the shared container is awaited
+// It only applies when this build is the one that starts the shared container
+func (b *TestContextBuilder) WithContainerWaitStrategy(strategy ContainerWaitStrategy) *TestContextBuilder {
+	b.waitConfig.Strategy = strategy
+	return b
+}
+
+// WithContainerStartupTimeout overrides how long startup of the shared container is awaited
+// It only applies when this build is the one that starts the shared container
+func (b *TestContextBuilder) WithContainerStartupTimeout(timeout time.Duration) *TestContextBuilder {
+	b.waitConfig.Timeout = timeout
+	return b
+}
+
 // Build creates the TestContext with all configured databases and servers
 func (b *TestContextBuilder) Build(ctx context.Context) (*TestContext, error) {
 	testID := uuid.New().String()[:8]
 
+	// Builder settings take precedence over the environment
+	waitConfig, err := containerWaitConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if b.waitConfig.Strategy != "" {
+		waitConfig.Strategy = b.waitConfig.Strategy
+	}
+	if b.waitConfig.Timeout != 0 {
+		waitConfig.Timeout = b.waitConfig.Timeout
+	}
+
 	// Get or create the shared container
-	pgContainer, host, port, err := getOrCreateContainer(ctx)
+	pgContainer, host, port, err := getOrCreateContainer(ctx, waitConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -157,6 +308,8 @@ func (b *TestContextBuilder) Build(ctx context.Context) (*TestContext, error) {
 		Port:              port,
 		User:              "postgres",
 		Password:          "postgres",
+		AuthMode:          db.PasswordAuth,
+		ApplicationName:   ApplicationName,
 		Database:          "postgres",
 		SSLMode:           "disable",
 		MaxConns:          5,
@@ -181,7 +334,7 @@ func (b *TestContextBuilder) Build(ctx context.Context) (*TestContext, error) {
 	// Create all configured databases
 	databases := make(map[database]*TestDBContext)
 	for _, dbConfig := range b.databases {
-		dbCtx, err := createDatabase(ctx, testID, dbConfig, host, port, postgresClient)
+		dbCtx, err := createDatabase(ctx, testID, dbConfig, host, port, postgresClient, b.migrationLogger)
 		if err != nil {
 			// Clean up any created databases before returning error
 			for _, db := range databases {
@@ -200,17 +353,13 @@ func (b *TestContextBuilder) Build(ctx context.Context) (*TestContext, error) {
 
 	// Create all configured servers
 	servers := make(map[server]*TestServerContext)
-	for _, srvConfig := range b.servers {
-		srvCtx, err := createServer(ctx, srvConfig, dependencyProvider)
-		if err != nil {
-			// Clean up before returning error
-			for _, db := range databases {
-				db.client.Close()
-			}
-			postgresClient.Close()
-			return nil, fmt.Errorf("failed to create server '%s': %w", srvConfig.server, err)
+	if err := startServers(ctx, b.servers, dependencyProvider, b.serverLogger, servers); err != nil {
+		// Clean up before returning error; the servers already started were shut down
+		for _, db := range databases {
+			db.client.Close()
 		}
-		servers[srvConfig.server] = srvCtx
+		postgresClient.Close()
+		return nil, err
 	}
 
 	return &TestContext{
@@ -219,70 +368,150 @@ func (b *TestContextBuilder) Build(ctx context.Context) (*TestContext, error) {
 		databases:           databases,
 		servers:             servers,
 		postgresClient:      postgresClient,
+		postgresConfig:      testConfig,
 		testContextProvider: dependencyProvider,
 	}, nil
 }
 
 // getOrCreateContainer returns the singleton container, creating it if necessary
-func getOrCreateContainer(ctx context.Context) (testcontainers.Container, string, int, error) {
-	sharedContainerOnce.Do(func() {
-		log.Println("=== Initializing shared PostgreSQL test container (this should only happen ONCE) ===")
-
-		req := testcontainers.ContainerRequest{
-			Image:        "postgres:17",
-			ExposedPorts: []string{"5432/tcp", "29000:5432/tcp"},
-			Env: map[string]string{
-				"POSTGRES_USER":     "postgres",
-				"POSTGRES_PASSWORD": "postgres",
-				"POSTGRES_DB":       "postgres",
-			},
-			WaitingFor: wait.ForLog("database system is ready to accept connections").
-				WithOccurrence(2).
-				WithStartupTimeout(60 * time.Second),
-			Name: "test_postgres",
-			HostConfigModifier: (func(hc *container.HostConfig) {
-				hc.AutoRemove = false
-			}),
-		}
+func getOrCreateContainer(ctx context.Context, waitConfig ContainerWaitConfig) (testcontainers.Container, string, int, error) {
+	return sharedContainer.get(ctx, waitConfig)
+}
+
+// get returns the cached container or starts one with the factory
+func (s *sharedContainerState) get(ctx context.Context, waitConfig ContainerWaitConfig) (testcontainers.Container, string, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		pgContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
-			ContainerRequest: req,
-			Started:          true,
-			Reuse:            true,
-		})
+	if s.container == nil {
+		container, host, port, err := s.factory(ctx, waitConfig)
 		if err != nil {
-			sharedContainerErr = fmt.Errorf("failed to start container: %w", err)
-			return
+			return nil, "", 0, err
 		}
+		s.container, s.host, s.port = container, host, port
+		s.starts++
+	}
+	return s.container, s.host, s.port, nil
+}
+
+// startCount returns how many times the factory started a container, minus the explicit resets
+func (s *sharedContainerState) startCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.starts - s.resets
+}
+
+// reset forgets the cached container without terminating it and returns it
+func (s *sharedContainerState) reset() testcontainers.Container {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	container := s.container
+	if container != nil {
+		s.resets++
+	}
+	s.container, s.host, s.port = nil, "", 0
+	return container
+}
+
+// containerWaitConfigFromEnv returns the default wait config with environment overrides applied
+func containerWaitConfigFromEnv() (ContainerWaitConfig, error) {
+	waitConfig := ContainerWaitConfig{Strategy: WaitForLog, Timeout: defaultContainerWaitTimeout}
 
-		host, err := pgContainer.Host(ctx)
+	if strategy := os.Getenv(ContainerWaitStrategyEnv); strategy != "" {
+		waitConfig.Strategy = ContainerWaitStrategy(strategy)
+	}
+	if timeout := os.Getenv(ContainerWaitTimeoutEnv); timeout != "" {
+		d, err := time.ParseDuration(timeout)
 		if err != nil {
-			sharedContainerErr = fmt.Errorf("failed to get container host: %w", err)
-			return
+			return ContainerWaitConfig{}, fmt.Errorf("failed to parse %s: %w", ContainerWaitTimeoutEnv, err)
 		}
+		waitConfig.Timeout = d
+	}
+	return waitConfig, nil
+}
 
-		sharedContainer = pgContainer
-		sharedContainerHost = host
-		sharedContainerPort = 29000
+// waitStrategy returns the testcontainers wait strategy for the config
+func (c ContainerWaitConfig) waitStrategy() (wait.Strategy, error) {
+	if c.Timeout <= 0 {
+		return nil, fmt.Errorf("container startup timeout must be positive, got %s", c.Timeout)
+	}
 
-		log.Printf("=== Shared PostgreSQL test container ready at %s:%d ===", host, sharedContainerPort)
+	switch c.Strategy {
+	case WaitForLog:
+		return wait.ForLog("database system is ready to accept connections").
+			WithOccurrence(2).
+			WithStartupTimeout(c.Timeout), nil
+	case WaitForSQL:
+		// The init phase only listens on the unix socket, so a TCP connection means the final server is up
+		return wait.ForSQL("5432/tcp", "pgx", func(host string, port nat.Port) string {
+			return fmt.Sprintf("postgres://postgres:postgres@%s/postgres?sslmode=disable", net.JoinHostPort(host, port.Port()))
+		}).WithStartupTimeout(c.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown container wait strategy %q (expected %q or %q)", c.Strategy, WaitForLog, WaitForSQL)
+	}
+}
+
+// startPostgresContainer starts the shared PostgreSQL container, reusing a running one with the same name
+func startPostgresContainer(ctx context.Context, waitConfig ContainerWaitConfig) (testcontainers.Container, string, int, error) {
+	log.Println("=== Initializing shared PostgreSQL test container (this should only happen ONCE) ===")
+
+	waitingFor, err := waitConfig.waitStrategy()
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	req := testcontainers.ContainerRequest{
+		Image:        "postgres:17",
+		ExposedPorts: []string{"5432/tcp", "29000:5432/tcp"},
+		Env: map[string]string{
+			"POSTGRES_USER":     "postgres",
+			"POSTGRES_PASSWORD": "postgres",
+			"POSTGRES_DB":       "postgres",
+		},
+		WaitingFor: waitingFor,
+		Name:       "test_postgres",
+		HostConfigModifier: (func(hc *container.HostConfig) {
+			hc.AutoRemove = false
+		}),
+	}
+
+	pgContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+		Reuse:            true,
 	})
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to start container: %w", err)
+	}
 
-	if sharedContainerErr != nil {
-		return nil, "", 0, sharedContainerErr
+	host, err := pgContainer.Host(ctx)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to get container host: %w", err)
 	}
 
-	return sharedContainer, sharedContainerHost, sharedContainerPort, nil
+	const port = 29000
+	log.Printf("=== Shared PostgreSQL test container ready at %s:%d ===", host, port)
+	return pgContainer, host, port, nil
 }
 
 // createDatabase creates a single test database with migrations
-func createDatabase(ctx context.Context, testID string, config DatabaseConfig, host string, port int, postgresClient *db.DBPool) (*TestDBContext, error) {
+func createDatabase(ctx context.Context, testID string, config DatabaseConfig, host string, port int, postgresClient *db.DBPool, migrationLogger MigrationLogger) (*TestDBContext, error) {
+	if migrationLogger == nil {
+		migrationLogger = log.Default()
+	}
+
 	dbName := fmt.Sprintf("%s_%s", config.database, testID)
 
-	// Insert database name into test_databases table
-	_, err := postgresClient.Exec(ctx,
-		"INSERT INTO test_databases (dbname) VALUES ($1)",
-		dbName,
+	// Insert database name into test_databases table, with the test and process creating it
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Printf("Warning: failed to get hostname for test_databases: %v", err)
+	}
+	_, err = postgresClient.Exec(ctx,
+		"INSERT INTO test_databases (dbname, test_id, hostname, pid) VALUES ($1, $2, $3, $4)",
+		dbName, testID, hostname, os.Getpid(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert db_name: %w", err)
@@ -303,11 +532,11 @@ func createDatabase(ctx context.Context, testID string, config DatabaseConfig, h
 		string(config.database): dbName,
 	}
 
-	err = RunDbmateMigrations(ctx, dbURL, config.migrationsDir, replacements)
+	err = RunDbmateMigrationsWithLogger(ctx, migrationLogger, dbURL, config.migrationsDir, replacements)
 	if err != nil {
 		return nil, fmt.Errorf("migration failed: %w", err)
 	}
-	log.Printf("Migrations completed successfully for database %s", dbName)
+	migrationLogger.Printf("Migrations completed successfully for database %s", dbName)
 
 	// Connect to the test database
 	dbConfig := &db.Config{
@@ -315,6 +544,8 @@ func createDatabase(ctx context.Context, testID string, config DatabaseConfig, h
 		Port:              port,
 		User:              "postgres",
 		Password:          "postgres",
+		AuthMode:          db.PasswordAuth,
+		ApplicationName:   ApplicationName,
 		Database:          dbName,
 		SSLMode:           "disable",
 		MaxConns:          5,
@@ -322,6 +553,7 @@ func createDatabase(ctx context.Context, testID string, config DatabaseConfig, h
 		MaxConnLifetime:   time.Hour,
 		MaxConnIdleTime:   30 * time.Minute,
 		HealthCheckPeriod: 1 * time.Minute,
+		CountQueries:      config.countQueries,
 	}
 
 	client, err := db.NewPool(ctx, dbConfig)
@@ -329,22 +561,50 @@ func createDatabase(ctx context.Context, testID string, config DatabaseConfig, h
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	for _, postMigrate := range config.postMigrate {
+		if err := postMigrate(ctx, client); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("post-migration hook failed: %w", err)
+		}
+	}
+	// Only count what the test runs, not the fixtures
+	client.ResetQueryCount()
+
 	return &TestDBContext{
 		client:        client,
+		clientConfig:  dbConfig,
 		dbName:        dbName,
 		dbURL:         dbURL,
 		migrationsDir: config.migrationsDir,
 	}, nil
 }
 
-// createServer creates a test server instance
-func createServer(_ context.Context, config ServerConfig, dependencyProvider *TestContextProvider) (*TestServerContext, error) {
-
-	// Generate random ports in range 40000-50000
-	grpcPort := 40000 + rand.Intn(10000)
-	httpPort := grpcPort + 1
+// startServers starts a test server for each config on free ports, adding it to servers
+// If one fails, the servers already started are shut down so no goroutines or ports leak
+func startServers(ctx context.Context, configs []ServerConfig, dependencyProvider *TestContextProvider, logger *slog.Logger, servers map[server]*TestServerContext) error {
+	for _, srvConfig := range configs {
+		srvCtx, err := createServer(ctx, srvConfig, dependencyProvider, logger, 0, 0)
+		if err != nil {
+			for name, started := range servers {
+				started.Shutdown()
+				log.Printf("Shut down test server: %s", name)
+			}
+			return fmt.Errorf("failed to create server '%s': %w", srvConfig.server, err)
+		}
+		servers[srvConfig.server] = srvCtx
+	}
+	return nil
+}
 
-	server := config.provider(dependencyProvider)
+// createServer creates a test server instance on the given ports (0 picks free ports)
+// A non-nil logger receives the slog records of the server's gRPC calls
+func createServer(ctx context.Context, config ServerConfig, dependencyProvider *TestContextProvider, logger *slog.Logger, grpcPort, httpPort int) (*TestServerContext, error) {
+	// Test servers stop through CleanUp; signals stay with the test process
+	server := config.provider(dependencyProvider).WithoutSignalHandler()
+	if logger != nil {
+		routeLogsByContext()
+		server.WithLogger(logger)
+	}
 
 	// Channel to signal when server has completely shut down
 	serverDone := make(chan struct{})
@@ -357,9 +617,10 @@ func createServer(_ context.Context, config ServerConfig, dependencyProvider *Te
 		}
 	}()
 
-	// Wait for server to be ready by polling /health endpoint
-	healthURL := fmt.Sprintf("http://localhost:%d/health", httpPort)
-	if err := waitForHealthEndpoint(healthURL, 10*time.Second); err != nil {
+	// Wait for server to be listening
+	readyCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := server.WaitUntilReady(readyCtx); err != nil {
 		server.Shutdown()
 		<-serverDone
 		return nil, fmt.Errorf("server startup failed: %w", err)
@@ -367,8 +628,9 @@ func createServer(_ context.Context, config ServerConfig, dependencyProvider *Te
 
 	return &TestServerContext{
 		server:     server,
-		grpcPort:   grpcPort,
-		httpPort:   httpPort,
+		grpcPort:   server.GRPCAddr().(*net.TCPAddr).Port,
+		httpPort:   server.HTTPAddr().(*net.TCPAddr).Port,
+		logger:     logger,
 		serverDone: serverDone,
 	}, nil
 }
@@ -381,6 +643,39 @@ func (tx *TestContext) GetGrpcClient(server ServerConfig) string {
 	return fmt.Sprintf("localhost:%d", serverContext.grpcPort)
 }
 
+// GrpcClient returns an insecure client for TestTenantID connected to server
+// The client is shared by every caller on the test context and closed by CleanUp
+func (tx *TestContext) GrpcClient(serverConfig ServerConfig) *client.ConfigurationClient {
+	tx.clientsMu.Lock()
+	defer tx.clientsMu.Unlock()
+
+	if c := tx.defaultClients[serverConfig.server]; c != nil {
+		return c
+	}
+	c := tx.newGrpcClientLocked(serverConfig, client.Config{Insecure: true, TenantID: TestTenantID})
+	if tx.defaultClients == nil {
+		tx.defaultClients = make(map[server]*client.ConfigurationClient)
+	}
+	tx.defaultClients[serverConfig.server] = c
+	return c
+}
+
+// NewGrpcClient returns a new client connected to server with cfg, whose ServerAddress is filled in
+// Use it for clients with a non-default tenant or options; CleanUp closes it
+func (tx *TestContext) NewGrpcClient(server ServerConfig, cfg client.Config) *client.ConfigurationClient {
+	tx.clientsMu.Lock()
+	defer tx.clientsMu.Unlock()
+	return tx.newGrpcClientLocked(server, cfg)
+}
+
+// newGrpcClientLocked creates and tracks a client; the caller must hold clientsMu
+func (tx *TestContext) newGrpcClientLocked(server ServerConfig, cfg client.Config) *client.ConfigurationClient {
+	cfg.ServerAddress = tx.GetGrpcClient(server)
+	c := client.MustNewClient(context.Background(), &cfg)
+	tx.clients = append(tx.clients, c)
+	return c
+}
+
 func (tx *TestContext) GetHttpClient(server ServerConfig) string {
 	var serverContext *TestServerContext
 	if serverContext = tx.servers[server.server]; serverContext == nil {
@@ -389,9 +684,93 @@ func (tx *TestContext) GetHttpClient(server ServerConfig) string {
 	return fmt.Sprintf("http://localhost:%d", serverContext.httpPort)
 }
 
+// RestartServer shuts a server down and launches a fresh instance on the same ports
+// Clients connected to the old instance see transport failures until they reconnect
+func (tx *TestContext) RestartServer(ctx context.Context, server ServerConfig) error {
+	var serverContext *TestServerContext
+	if serverContext = tx.servers[server.server]; serverContext == nil {
+		panic(fmt.Sprintf("Server not registered: %s", server.server))
+	}
+
+	serverContext.Shutdown()
+	log.Printf("Shut down test server for restart: %s", server.server)
+
+	restarted, err := createServer(ctx, server, tx.testContextProvider, serverContext.logger, serverContext.grpcPort, serverContext.httpPort)
+	if err != nil {
+		delete(tx.servers, server.server)
+		return fmt.Errorf("failed to restart server '%s': %w", server.server, err)
+	}
+	tx.servers[server.server] = restarted
+	return nil
+}
+
+// AuthValidator returns the validator authenticating requests to the test servers in place of Kratos
+func (tx *TestContext) AuthValidator() *TestAuthValidator {
+	return tx.testContextProvider.authValidator
+}
+
+// MetricReader returns the reader collecting the metrics recorded by the test servers
+func (tx *TestContext) MetricReader() sdkmetric.Reader {
+	return tx.testContextProvider.metricReader
+}
+
+// RecordingMessenger returns the messenger shared by the test servers, nil if the context has no server
+func (tx *TestContext) RecordingMessenger() *RecordingMessenger {
+	return tx.testContextProvider.messenger
+}
+
+// Database returns the context of a database registered on the test context
+func (tx *TestContext) Database(database DatabaseConfig) *TestDBContext {
+	dbContext := tx.databases[database.database]
+	if dbContext == nil {
+		panic(fmt.Sprintf("Database not registered: %s", database.database))
+	}
+	return dbContext
+}
+
+// QueryCount returns the number of statements run on the database's pool since setup or ResetQueryCount
+// It includes the statements of the test servers; always 0 unless the database was added WithQueryCount
+func (d *TestDBContext) QueryCount() int64 {
+	return d.client.QueryCount()
+}
+
+// ResetQueryCount restarts QueryCount from 0, e.g. once the fixtures of a test are in place
+func (d *TestDBContext) ResetQueryCount() {
+	d.client.ResetQueryCount()
+}
+
+// GetDBPool returns the connection pool of a database registered on the test context
+func (tx *TestContext) GetDBPool(database DatabaseConfig) *db.DBPool {
+	var dbContext *TestDBContext
+	if dbContext = tx.databases[database.database]; dbContext == nil {
+		panic(fmt.Sprintf("Database not registered: %s", database.database))
+	}
+	return dbContext.client
+}
+
+// GetDBConfig returns a copy of the connection config of a database registered on the test context
+// Use it to open additional pools with custom settings; the caller must close them
+func (tx *TestContext) GetDBConfig(database DatabaseConfig) *db.Config {
+	var dbContext *TestDBContext
+	if dbContext = tx.databases[database.database]; dbContext == nil {
+		panic(fmt.Sprintf("Database not registered: %s", database.database))
+	}
+	config := *dbContext.clientConfig
+	return &config
+}
+
 // CleanUp tears down the test context, dropping all test databases and shutting down servers
 // Note: This does NOT terminate the shared container, which is reused across tests
 func (tc *TestContext) CleanUp(ctx context.Context) error {
+	// Close all clients before their servers stop
+	tc.clientsMu.Lock()
+	for _, c := range tc.clients {
+		if err := c.Close(); err != nil {
+			log.Printf("Warning: failed to close test client: %v", err)
+		}
+	}
+	tc.clientsMu.Unlock()
+
 	// Shutdown all servers
 	for name, srv := range tc.servers {
 		if srv != nil {
@@ -456,34 +835,25 @@ func (tc *TestContext) CleanUp(ctx context.Context) error {
 //	    os.Exit(code)
 //	}
 func TerminateSharedContainer(ctx context.Context) error {
-	if sharedContainer != nil {
+	if pgContainer := sharedContainer.reset(); pgContainer != nil {
 		log.Println("Terminating shared PostgreSQL test container...")
-		if err := sharedContainer.Terminate(ctx); err != nil {
+		if err := pgContainer.Terminate(ctx); err != nil {
 			return fmt.Errorf("failed to terminate shared container: %w", err)
 		}
-		sharedContainer = nil
 		log.Println("Shared PostgreSQL test container terminated")
 	}
 	return nil
 }
 
-// waitForHealthEndpoint polls the /health endpoint until it returns 200 OK or timeout expires
-func waitForHealthEndpoint(url string, timeout time.Duration) error {
-	client := &http.Client{Timeout: time.Second}
-	deadline := time.Now().Add(timeout)
-	start := time.Now()
-
-	for time.Now().Before(deadline) {
-		resp, err := client.Get(url)
-		if err == nil {
-			resp.Body.Close()
-			if resp.StatusCode == http.StatusOK {
-				log.Printf("Health endpoint %s responded OK after %v", url, time.Since(start))
-				return nil
-			}
-		}
-		time.Sleep(50 * time.Millisecond)
-	}
+// ResetSharedContainer forgets the shared container without terminating it
+// The next test context starts the container again, or reattaches to it if it is still running
+func ResetSharedContainer() {
+	sharedContainer.reset()
+}
 
-	return fmt.Errorf("health endpoint %s did not respond within %v", url, timeout)
+// ContainerStartCount returns how many times the shared container was started in this process
+// Starts following TerminateSharedContainer or ResetSharedContainer are not counted, so a suite that boots
+// Postgres once as designed reports 1; anything higher means the cached container was lost and recreated
+func ContainerStartCount() int {
+	return sharedContainer.startCount()
 }
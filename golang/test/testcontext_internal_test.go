@@ -0,0 +1,203 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/testcontainers/testcontainers-go"
+	"google.golang.org/grpc"
+
+	"github.com/berendjan/golang-bazel-starter/golang/framework/serverbase"
+)
+
+// fakeContainer stands in for a started container; calling any of its methods panics
+type fakeContainer struct {
+	testcontainers.Container
+}
+
+func TestSharedContainerRetriesAfterFailure(t *testing.T) {
+	ctx := context.Background()
+
+	started := &fakeContainer{}
+	calls := 0
+	state := &sharedContainerState{factory: func(ctx context.Context, waitConfig ContainerWaitConfig) (testcontainers.Container, string, int, error) {
+		calls++
+		if calls == 1 {
+			return nil, "", 0, errors.New("docker daemon not ready")
+		}
+		return started, "localhost", 29000, nil
+	}}
+
+	if _, _, _, err := state.get(ctx, ContainerWaitConfig{}); err == nil {
+		t.Fatal("Expected the first start to fail")
+	}
+
+	container, host, port, err := state.get(ctx, ContainerWaitConfig{})
+	if err != nil {
+		t.Fatalf("Expected the second start to succeed, got: %v", err)
+	}
+	if container != started || host != "localhost" || port != 29000 {
+		t.Fatalf("Unexpected container info: %v %s:%d", container, host, port)
+	}
+
+	// A successful start is cached
+	if _, _, _, err := state.get(ctx, ContainerWaitConfig{}); err != nil {
+		t.Fatalf("Expected cached container, got: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("Expected the factory to run twice, ran %d times", calls)
+	}
+	if count := state.startCount(); count != 1 {
+		t.Fatalf("Expected a failed start not to count, got %d starts", count)
+	}
+
+	// Reset forgets the container so the next call starts it again
+	if state.reset() != started {
+		t.Fatal("Expected reset to return the cached container")
+	}
+	if _, _, _, err := state.get(ctx, ContainerWaitConfig{}); err != nil {
+		t.Fatalf("Expected restart after reset to succeed, got: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("Expected the factory to run again after reset, ran %d times", calls)
+	}
+	if count := state.startCount(); count != 1 {
+		t.Fatalf("Expected a restart after an explicit reset not to count, got %d starts", count)
+	}
+}
+
+func TestContainerWaitConfigFromEnv(t *testing.T) {
+	t.Setenv(ContainerWaitStrategyEnv, "")
+	t.Setenv(ContainerWaitTimeoutEnv, "")
+	waitConfig, err := containerWaitConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Failed to read defaults: %v", err)
+	}
+	if waitConfig.Strategy != WaitForLog || waitConfig.Timeout != defaultContainerWaitTimeout {
+		t.Fatalf("Unexpected defaults: %+v", waitConfig)
+	}
+
+	t.Setenv(ContainerWaitStrategyEnv, "sql")
+	t.Setenv(ContainerWaitTimeoutEnv, "3m")
+	waitConfig, err = containerWaitConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Failed to read overrides: %v", err)
+	}
+	if waitConfig.Strategy != WaitForSQL || waitConfig.Timeout != 3*time.Minute {
+		t.Fatalf("Unexpected overrides: %+v", waitConfig)
+	}
+	if _, err := waitConfig.waitStrategy(); err != nil {
+		t.Fatalf("Expected a valid SQL wait strategy: %v", err)
+	}
+
+	t.Setenv(ContainerWaitTimeoutEnv, "soon")
+	if _, err := containerWaitConfigFromEnv(); err == nil {
+		t.Fatal("Expected an invalid timeout to fail")
+	}
+}
+
+func TestContainerWaitStrategyRejectsInvalidConfig(t *testing.T) {
+	if _, err := (ContainerWaitConfig{Strategy: "port", Timeout: time.Minute}).waitStrategy(); err == nil {
+		t.Fatal("Expected an unknown strategy to fail")
+	}
+	if _, err := (ContainerWaitConfig{Strategy: WaitForLog}).waitStrategy(); err == nil {
+		t.Fatal("Expected a zero timeout to fail")
+	}
+}
+
+// staticServer serves no services of its own, or fails to register with err
+type staticServer struct {
+	err error
+}
+
+func (s staticServer) Register(sb *serverbase.ServerBuilder, grpcPort, httpPort int) error {
+	if s.err != nil {
+		return s.err
+	}
+	sb.RegisterService(grpcPort, httpPort, s)
+	return nil
+}
+
+func (staticServer) RegisterGRPC(grpc.ServiceRegistrar) {}
+
+func (staticServer) RegisterGateway(context.Context, *runtime.ServeMux) error {
+	return nil
+}
+
+// staticServerConfig returns a config for a staticServer named name
+func staticServerConfig(name string, err error) ServerConfig {
+	return ServerConfig{server: server(name), provider: func(*TestContextProvider) *serverbase.ServerBase {
+		s := serverbase.NewServerBase()
+		s.ServerInterface = staticServer{err: err}
+		return s
+	}}
+}
+
+func TestStartServersShutsDownStartedServersOnFailure(t *testing.T) {
+	servers := make(map[server]*TestServerContext)
+	err := startServers(context.Background(), []ServerConfig{
+		staticServerConfig("first", nil),
+		staticServerConfig("second", errors.New("register failed")),
+	}, nil, nil, servers)
+	if err == nil {
+		t.Fatal("Expected the second server to fail to start")
+	}
+
+	first, ok := servers["first"]
+	if !ok {
+		t.Fatal("Expected the first server to have started")
+	}
+	select {
+	case <-first.serverDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the first server to be shut down")
+	}
+
+	// Its ports are released for later test contexts
+	if conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", first.grpcPort)); err == nil {
+		conn.Close()
+		t.Fatalf("Expected gRPC port %d to be closed", first.grpcPort)
+	}
+}
+
+func TestTestDatabasesRecordsCreator(t *testing.T) {
+	ctx := context.Background()
+
+	tc, err := NewTestContextBuilder().
+		WithDatabase(ConfigDb).
+		Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test context: %v", err)
+	}
+	defer func() {
+		if err := tc.CleanUp(ctx); err != nil {
+			t.Logf("Warning: cleanup failed: %v", err)
+		}
+	}()
+
+	var testID, hostname string
+	var pid int
+	err = tc.postgresClient.QueryRow(ctx,
+		"SELECT test_id, hostname, pid FROM test_databases WHERE dbname = $1",
+		tc.databases[configDb].dbName,
+	).Scan(&testID, &hostname, &pid)
+	if err != nil {
+		t.Fatalf("Failed to read the test_databases row: %v", err)
+	}
+
+	if testID != tc.testID {
+		t.Fatalf("Expected test_id %s, got %s", tc.testID, testID)
+	}
+	if want, _ := os.Hostname(); hostname != want {
+		t.Fatalf("Expected hostname %q, got %q", want, hostname)
+	}
+	if pid != os.Getpid() {
+		t.Fatalf("Expected pid %d, got %d", os.Getpid(), pid)
+	}
+}
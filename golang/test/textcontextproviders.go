@@ -1,15 +1,21 @@
 package test
 
 import (
+	"log"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/berendjan/golang-bazel-starter/golang/config/repository"
 	configRepository "github.com/berendjan/golang-bazel-starter/golang/config/repository"
+	"github.com/berendjan/golang-bazel-starter/golang/framework/db"
 	"github.com/berendjan/golang-bazel-starter/golang/framework/serverbase"
 	grpcserver "github.com/berendjan/golang-bazel-starter/golang/grpcserver"
 	"github.com/berendjan/golang-bazel-starter/golang/grpcserver/messenger"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/audit"
+	"github.com/berendjan/golang-bazel-starter/golang/middleware/middleone"
 	"github.com/berendjan/golang-bazel-starter/golang/middleware/middletwo"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 )
 
 type database string
@@ -35,19 +41,53 @@ var (
 
 var (
 	GrpcServer ServerConfig = ServerConfig{server: grpcServer, provider: func(tcp *TestContextProvider) *serverbase.ServerBase {
-		return grpcserver.NewGrpcServer(tcp.createMessenger()).ServerBase
+		return grpcserver.NewGrpcServer(tcp.createMessenger(), tcp.meterProvider).ServerBase
+	}}
+
+	// DegradedGrpcServer is GrpcServer serving cached account listings and reporting not ready while ConfigDb is down
+	DegradedGrpcServer ServerConfig = ServerConfig{server: grpcServer, provider: func(tcp *TestContextProvider) *serverbase.ServerBase {
+		return grpcserver.NewGrpcServer(tcp.createMessenger(), tcp.meterProvider).
+			WithDegradedMode(DegradedCacheTTL, tcp.pools.MustGet(repository.DbName)).
+			ServerBase
+	}}
+
+	// IdempotentDeleteGrpcServer is GrpcServer reporting deletes of missing accounts as success instead of NotFound
+	IdempotentDeleteGrpcServer ServerConfig = ServerConfig{server: grpcServer, provider: func(tcp *TestContextProvider) *serverbase.ServerBase {
+		return grpcserver.NewGrpcServer(tcp.createMessenger(), tcp.meterProvider).WithIdempotentDeletes().ServerBase
 	}}
 )
 
+// DegradedCacheTTL is how long DegradedGrpcServer serves a cached account listing
+const DegradedCacheTTL = time.Minute
+
 type TestContextProvider struct {
 	messengerOnce sync.Once
-	messenger     *messenger.GrpcMessenger
+	messenger     *RecordingMessenger
 	dbContexts    map[database]*TestDBContext
+	pools         *db.Registry
+	authValidator *TestAuthValidator
+	metricReader  *sdkmetric.ManualReader
+	meterProvider *sdkmetric.MeterProvider
 }
 
 func NewTestContextProvider(dbContexts map[database]*TestDBContext) *TestContextProvider {
+	// Register the test pools under their production database names
+	pools := db.NewRegistry()
+	for name, dbCtx := range dbContexts {
+		if err := pools.Register(string(name), dbCtx.client); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	// The servers record their metrics with a provider the tests read back through metricReader
+	metricReader := sdkmetric.NewManualReader()
+
 	return &TestContextProvider{
-		dbContexts: dbContexts,
+		dbContexts:    dbContexts,
+		pools:         pools,
+		authValidator: NewTestAuthValidator(),
+		metricReader:  metricReader,
+		meterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricReader)),
 	}
 }
 
@@ -55,22 +95,25 @@ func (tcp *TestContextProvider) createMessenger() *messenger.GrpcMessenger {
 	tcp.messengerOnce.Do(func() {
 
 		// Get database pool
-		pool := tcp.dbContexts[configDb].client
+		pool := tcp.pools.MustGet(repository.DbName)
 
-		// Create repository
+		// Create repositories
 		accountRepo := repository.NewAccountRepository(pool)
+		auditRepo := repository.NewAuditRepository(pool)
 
-		// Interchangable test middleware
-		middlewareOne := &TestMiddleOne{}
+		// The real middleware, authenticating with the test validator instead of Kratos
+		middlewareOne := middleone.NewMiddleOne(tcp.authValidator)
 		middlewareTwo := &middletwo.MiddleTwo{}
+		auditMiddleware := audit.NewAuditMiddleware(auditRepo)
 
-		// Create messenger with all dependencies
-		tcp.messenger = messenger.NewGrpcMessenger(
+		// Create messenger with all dependencies, recording the path of messages sent with a message ID
+		tcp.messenger = NewRecordingMessenger(messenger.NewGrpcMessenger(
 			accountRepo,
 			middlewareOne,
 			middlewareTwo,
-		)
+			auditMiddleware,
+		))
 	})
 
-	return tcp.messenger
+	return tcp.messenger.GrpcMessenger
 }
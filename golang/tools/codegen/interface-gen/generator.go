@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"go/format"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 )
@@ -31,16 +35,23 @@ func (g *Generator) RoutesForHandler(handlerName string) []Route {
 }
 
 // RoutesReceivedBy returns all routes where the given handler is a receiver
+// A message received from several sources, e.g. by a catch-all receiver, is only kept on its first route
+// since it has a single Handle method
 func (g *Generator) RoutesReceivedBy(handlerName string) []Route {
 	var routes []Route
+	received := make(map[string]bool)
 	for _, route := range g.spec.Routes {
 		// Check if this handler is a receiver for any message in this route
 		hasMessages := false
 		filteredMessages := []MessageRoute{}
 
 		for _, msg := range route.Messages {
+			if received[msg.Message] {
+				continue
+			}
 			for _, receiver := range msg.Receivers {
 				if receiver == handlerName {
+					received[msg.Message] = true
 					filteredMessages = append(filteredMessages, msg)
 					hasMessages = true
 					break
@@ -85,10 +96,65 @@ func (g *Generator) IsLastReceiver(handlerName, sourceName, messageType string)
 	return false
 }
 
+// TypeParams returns the type parameter list of a handler's interfaces, e.g. "[T proto.Message]", empty if not generic
+func (g *Generator) TypeParams(handlerName string) string {
+	params := g.typeParams(handlerName)
+	if len(params) == 0 {
+		return ""
+	}
+	decls := make([]string, len(params))
+	for i, p := range params {
+		constraint := p.Constraint
+		if constraint == "" {
+			constraint = "any"
+		}
+		decls[i] = p.Name + " " + constraint
+	}
+	return "[" + strings.Join(decls, ", ") + "]"
+}
+
+// TypeArgs returns the type arguments instantiating a handler's interfaces with its own parameters, e.g. "[T]"
+func (g *Generator) TypeArgs(handlerName string) string {
+	params := g.typeParams(handlerName)
+	if len(params) == 0 {
+		return ""
+	}
+	names := make([]string, len(params))
+	for i, p := range params {
+		names[i] = p.Name
+	}
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+// typeParams returns the type parameters declared by a handler
+func (g *Generator) typeParams(handlerName string) []TypeParam {
+	for _, handler := range g.spec.Handlers {
+		if handler.Name == handlerName {
+			return handler.TypeParams
+		}
+	}
+	return nil
+}
+
+// UsesContext returns true if any handler of the spec has a method, which all take a context
+func (g *Generator) UsesContext() bool {
+	for _, handler := range g.spec.Handlers {
+		if len(g.RoutesForHandler(handler.Name)) > 0 || len(g.RoutesReceivedBy(handler.Name)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // Generate produces the Go interface source code
 func (g *Generator) Generate() ([]byte, error) {
+	return g.execute("interfaces", fileTemplate)
+}
+
+// execute renders the template text with the Generator as context and formats the result
+func (g *Generator) execute(name, text string) ([]byte, error) {
 	// Create template with custom functions
-	tmpl, err := template.New("interfaces").Funcs(template.FuncMap{
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
 		"title": strings.Title,
 		"baseName": func(s string) string {
 			// Extract base name from type like "*configpb.AccountCreationRequestProto" -> "AccountCreationRequest"
@@ -101,7 +167,7 @@ func (g *Generator) Generate() ([]byte, error) {
 			}
 			return s
 		},
-	}).Parse(fileTemplate)
+	}).Parse(text)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -123,15 +189,116 @@ func (g *Generator) Generate() ([]byte, error) {
 	return formatted, nil
 }
 
+// HandlerPackage returns the sub-package name used for a handler with package_per_handler
+func HandlerPackage(handlerName string) string {
+	return strings.ToLower(handlerName)
+}
+
+// SplitTypesFile is the file of a split package shared by all handlers
+const SplitTypesFile = "types.go"
+
+// HandlerFile returns the file name used for a handler with split
+func HandlerFile(handlerName string) string {
+	return strings.ToLower(handlerName) + ".go"
+}
+
+// GenerateFiles produces the Go source of every output file, keyed by path relative to the output
+// With package_per_handler each handler gets "<package>/<package>.go", with split each handler gets
+// "<handler>.go" next to SplitTypesFile, otherwise the single file has key ""
+func (g *Generator) GenerateFiles() (map[string][]byte, error) {
+	switch {
+	case g.spec.PackagePerHandler:
+		files := make(map[string][]byte, len(g.spec.Handlers))
+		for _, handler := range g.spec.Handlers {
+			spec := g.handlerSpec(handler)
+			spec.Package = HandlerPackage(handler.Name)
+			code, err := NewGenerator(spec).Generate()
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate package for handler %s: %w", handler.Name, err)
+			}
+			files[path.Join(spec.Package, spec.Package+".go")] = code
+		}
+		return files, nil
+
+	case g.spec.Split:
+		types, err := g.execute("types", typesTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %s: %w", SplitTypesFile, err)
+		}
+		files := map[string][]byte{SplitTypesFile: types}
+		for _, handler := range g.spec.Handlers {
+			code, err := NewGenerator(g.handlerSpec(handler)).Generate()
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate file for handler %s: %w", handler.Name, err)
+			}
+			files[HandlerFile(handler.Name)] = code
+		}
+		return files, nil
+
+	default:
+		code, err := g.Generate()
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{"": code}, nil
+	}
+}
+
+// handlerSpec returns the spec of a single handler, keeping only the imports its signatures use
+func (g *Generator) handlerSpec(handler Handler) *InterfaceSpec {
+	spec := *g.spec
+	spec.Handlers = []Handler{handler}
+	spec.Imports = nil
+
+	// Collect the types in the handler's signatures and type parameter constraints
+	var types []string
+	for _, p := range handler.TypeParams {
+		types = append(types, p.Constraint)
+	}
+	for _, route := range append(g.RoutesForHandler(handler.Name), g.RoutesReceivedBy(handler.Name)...) {
+		for _, msg := range route.Messages {
+			types = append(types, msg.Message, msg.Returns())
+		}
+	}
+	used := strings.Join(types, " ")
+	for _, imp := range g.spec.Imports {
+		if regexp.MustCompile(`\b` + regexp.QuoteMeta(importName(imp)) + `\.`).MatchString(used) {
+			spec.Imports = append(spec.Imports, imp)
+		}
+	}
+
+	return &spec
+}
+
+// importName returns the name an import line is referenced by, e.g. 'configpb "example.com/v1"' -> "configpb"
+func importName(imp string) string {
+	fields := strings.Fields(imp)
+	if len(fields) > 1 {
+		return fields[0]
+	}
+	importPath, err := strconv.Unquote(strings.TrimSpace(imp))
+	if err != nil {
+		importPath = strings.Trim(imp, `"`)
+	}
+	return path.Base(importPath)
+}
+
 // WriteToFile generates code and writes it to the specified file
-func (g *Generator) WriteToFile(filepath string) error {
-	code, err := g.Generate()
+// With package_per_handler or split the output is a directory receiving one sub-package or file per handler
+func (g *Generator) WriteToFile(output string) error {
+	files, err := g.GenerateFiles()
 	if err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(filepath, code, 0644); err != nil {
-		return fmt.Errorf("failed to write output file: %w", err)
+	for name, code := range files {
+		target := filepath.Join(output, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := os.WriteFile(target, code, 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// update rewrites the golden files in testdata from the generator's output
+var update = flag.Bool("update", false, "update golden files")
+
+// newTestSpec returns a spec with one route returning a response and one response-less route
+func newTestSpec() *InterfaceSpec {
+	return &InterfaceSpec{
+		Package: "interfaces",
+		Handlers: []Handler{
+			{Name: "api", Type: "api.Api"},
+			{Name: "middleware", Type: "middleware.Middleware"},
+			{Name: "repository", Type: "repository.Repository"},
+		},
+		Routes: []Route{
+			{
+				Source: "api",
+				Messages: []MessageRoute{
+					{
+						Message:   "*pb.CreateRequestProto",
+						Response:  "(*pb.CreateResponseProto, error)",
+						Receivers: []string{"middleware", "repository"},
+					},
+					{
+						Message:   "*pb.NotifyEventProto",
+						Response:  "error",
+						Receivers: []string{"middleware", "repository"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestGenerateResponseAndResponselessSignatures(t *testing.T) {
+	spec := newTestSpec()
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("Expected valid spec, got: %v", err)
+	}
+
+	code, err := NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	expected := []string{
+		// Sendable methods return the declared response
+		"SendCreateRequestFromApi(ctx context.Context, message *pb.CreateRequestProto) (*pb.CreateResponseProto, error)",
+		"SendNotifyEventFromApi(ctx context.Context, message *pb.NotifyEventProto) error",
+		// Terminal receiver returns the declared response
+		"HandleCreateRequest(ctx context.Context, message *pb.CreateRequestProto) (*pb.CreateResponseProto, error)",
+		"HandleNotifyEvent(ctx context.Context, message *pb.NotifyEventProto) error",
+		// Intermediate receiver only returns an error
+		"HandleCreateRequest(ctx context.Context, message *pb.CreateRequestProto) error",
+	}
+	for _, signature := range expected {
+		if !strings.Contains(string(code), signature) {
+			t.Errorf("Generated code missing signature %q:\n%s", signature, code)
+		}
+	}
+}
+
+func TestGenerateOmittedResponseIsResponseless(t *testing.T) {
+	spec := newTestSpec()
+	spec.Routes[0].Messages[1].Response = ""
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("Expected a route without response to be valid, got: %v", err)
+	}
+
+	code, err := NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	for _, signature := range []string{
+		"SendNotifyEventFromApi(ctx context.Context, message *pb.NotifyEventProto) error",
+		"HandleNotifyEvent(ctx context.Context, message *pb.NotifyEventProto) error",
+	} {
+		if !strings.Contains(string(code), signature) {
+			t.Errorf("Generated code missing signature %q:\n%s", signature, code)
+		}
+	}
+}
+
+func TestValidateIntermediateReceiverIsResponseless(t *testing.T) {
+	spec := newTestSpec()
+	// middleware is an intermediate receiver of CreateRequest, so it can't also be its terminal receiver
+	spec.Routes = append(spec.Routes, Route{
+		Source: "repository",
+		Messages: []MessageRoute{
+			{
+				Message:   "*pb.CreateRequestProto",
+				Response:  "(*pb.CreateResponseProto, error)",
+				Receivers: []string{"middleware"},
+			},
+		},
+	})
+
+	err := spec.Validate()
+	if err == nil {
+		t.Fatal("Expected validation error for intermediate receiver with response, got nil")
+	}
+	if !strings.Contains(err.Error(), "must be response-less") {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+}
+
+func TestGeneratePackagePerHandler(t *testing.T) {
+	spec := newTestSpec()
+	spec.PackagePerHandler = true
+	spec.Imports = []string{
+		`pb "example.com/proto/v1"`,
+		`unused "example.com/unused/v1"`,
+	}
+
+	dir := t.TempDir()
+	if err := NewGenerator(spec).WriteToFile(dir); err != nil {
+		t.Fatalf("Failed to write packages: %v", err)
+	}
+
+	expected := map[string][]string{
+		"api":        {"type ApiSendable interface", "type ApiInterface interface"},
+		"middleware": {"type MiddlewareInterface interface", "HandleCreateRequest(ctx context.Context, message *pb.CreateRequestProto) error"},
+		"repository": {"type RepositoryInterface interface", "HandleCreateRequest(ctx context.Context, message *pb.CreateRequestProto) (*pb.CreateResponseProto, error)"},
+	}
+	for pkg, contents := range expected {
+		file := filepath.Join(dir, pkg, pkg+".go")
+		code, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Expected file for handler package %s: %v", pkg, err)
+		}
+
+		parsed, err := parser.ParseFile(token.NewFileSet(), file, code, parser.ImportsOnly)
+		if err != nil {
+			t.Fatalf("Generated %s does not parse: %v", file, err)
+		}
+		if parsed.Name.Name != pkg {
+			t.Errorf("Expected package %s in %s, got %s", pkg, file, parsed.Name.Name)
+		}
+		for _, imp := range parsed.Imports {
+			if imp.Path.Value == `"example.com/unused/v1"` {
+				t.Errorf("Package %s imports unused package %s", pkg, imp.Path.Value)
+			}
+		}
+
+		for _, content := range contents {
+			if !strings.Contains(string(code), content) {
+				t.Errorf("Package %s missing %q:\n%s", pkg, content, code)
+			}
+		}
+		// Each package only declares its own handler's types
+		for other := range expected {
+			if other != pkg && strings.Contains(string(code), "type "+strings.Title(other)) {
+				t.Errorf("Package %s declares types of handler %s:\n%s", pkg, other, code)
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read output directory: %v", err)
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d handler packages, got %d", len(expected), len(entries))
+	}
+}
+
+func TestGenerateSplitMatchesGoldenFiles(t *testing.T) {
+	spec := newTestSpec()
+	spec.Split = true
+	spec.Imports = []string{
+		`pb "example.com/proto/v1"`,
+		`unused "example.com/unused/v1"`,
+	}
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("Expected valid spec, got: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := NewGenerator(spec).WriteToFile(dir); err != nil {
+		t.Fatalf("Failed to write split files: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read output directory: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{"api.go", "middleware.go", "repository.go", SplitTypesFile}
+	if !slices.Equal(names, want) {
+		t.Fatalf("Expected files %v, got %v", want, names)
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range names {
+		code, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+
+		golden := filepath.Join("testdata", "split", name+".golden")
+		if *update {
+			if err := os.WriteFile(golden, code, 0644); err != nil {
+				t.Fatalf("Failed to update %s: %v", golden, err)
+			}
+		}
+		expected, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", golden, err)
+		}
+		if string(code) != string(expected) {
+			t.Errorf("%s does not match %s:\n%s", name, golden, code)
+		}
+
+		file, err := parser.ParseFile(fset, name, code, 0)
+		if err != nil {
+			t.Fatalf("Generated %s does not parse: %v", name, err)
+		}
+		files = append(files, file)
+	}
+
+	// The files compile together as one package, each importing only what it uses
+	conf := types.Config{Importer: fakeImporter{
+		"context":              fakePackage("context", "context", "Context"),
+		"example.com/proto/v1": fakePackage("example.com/proto/v1", "pb", "CreateRequestProto", "CreateResponseProto", "NotifyEventProto"),
+	}}
+	if _, err := conf.Check("interfaces", fset, files, nil); err != nil {
+		t.Fatalf("Split output does not compile: %v", err)
+	}
+}
+
+func TestValidateSplitExcludesPackagePerHandler(t *testing.T) {
+	spec := newTestSpec()
+	spec.Split = true
+	spec.PackagePerHandler = true
+	if err := spec.Validate(); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Fatalf("Expected split and package_per_handler to be rejected together, got: %v", err)
+	}
+
+	spec = newTestSpec()
+	spec.Split = true
+	spec.Handlers = append(spec.Handlers, Handler{Name: "Types", Type: "types.Types"})
+	if err := spec.Validate(); err == nil || !strings.Contains(err.Error(), "clashes") {
+		t.Fatalf("Expected a handler named like %s to be rejected, got: %v", SplitTypesFile, err)
+	}
+}
+
+// fakeImporter resolves imports to fake packages, so type checking needs no compiled dependencies
+type fakeImporter map[string]*types.Package
+
+func (f fakeImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := f[path]; ok {
+		return pkg, nil
+	}
+	return nil, fmt.Errorf("unexpected import %q, known: %v", path, slices.Sorted(maps.Keys(f)))
+}
+
+// fakePackage declares a package with an empty struct type for each of names
+func fakePackage(path, name string, names ...string) *types.Package {
+	pkg := types.NewPackage(path, name)
+	for _, name := range names {
+		obj := types.NewTypeName(token.NoPos, pkg, name, nil)
+		types.NewNamed(obj, types.NewStruct(nil, nil), nil)
+		pkg.Scope().Insert(obj)
+	}
+	pkg.MarkComplete()
+	return pkg
+}
+
+func TestGenerateGenericHandler(t *testing.T) {
+	// api is shaped like ConfigurationApi[T], with a store returning T to it
+	spec := &InterfaceSpec{
+		Package: "interfaces",
+		Imports: []string{`pb "example.com/proto/v1"`},
+		Handlers: []Handler{
+			{Name: "api", Type: "api.ConfigurationApi[T]", TypeParams: []TypeParam{{Name: "T", Constraint: "pb.Message"}}},
+			{Name: "store", Type: "store.Store[T]", TypeParams: []TypeParam{{Name: "T", Constraint: "pb.Message"}}},
+			{Name: "audit", Type: "audit.Audit"},
+		},
+		Routes: []Route{
+			{
+				Source: "api",
+				Messages: []MessageRoute{
+					{Message: "*pb.CreateRequestProto", Response: "(T, error)", Receivers: []string{"store"}},
+					{Message: "*pb.NotifyEventProto", Response: "error", Receivers: []string{"audit"}},
+				},
+			},
+			{
+				Source: "store",
+				Messages: []MessageRoute{
+					{Message: "*pb.AuditEventProto", Response: "error", Receivers: []string{"audit"}},
+				},
+			},
+		},
+	}
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("Expected valid spec, got: %v", err)
+	}
+
+	code, err := NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	expected := []string{
+		"type ApiSendable[T pb.Message] interface",
+		"SendCreateRequestFromApi(ctx context.Context, message *pb.CreateRequestProto) (T, error)",
+		"type ApiInterface[T pb.Message] interface",
+		"type StoreSendable[T pb.Message] interface",
+		"type StoreInterface[T pb.Message] interface",
+		"HandleCreateRequest(ctx context.Context, message *pb.CreateRequestProto, next StoreSendable[T]) (T, error)",
+		// Handlers without type parameters stay as they are
+		"type AuditInterface interface",
+	}
+	for _, signature := range expected {
+		if !strings.Contains(string(code), signature) {
+			t.Errorf("Generated code missing %q:\n%s", signature, code)
+		}
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "interfaces.go", code, 0)
+	if err != nil {
+		t.Fatalf("Generated code does not parse: %v", err)
+	}
+	pb := fakePackage("example.com/proto/v1", "pb", "CreateRequestProto", "NotifyEventProto", "AuditEventProto")
+	message := types.NewTypeName(token.NoPos, pb, "Message", nil)
+	types.NewNamed(message, types.NewInterfaceType(nil, nil).Complete(), nil)
+	pb.Scope().Insert(message)
+	conf := types.Config{Importer: fakeImporter{
+		"context":              fakePackage("context", "context", "Context"),
+		"example.com/proto/v1": pb,
+	}}
+	if _, err := conf.Check("interfaces", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("Generated code does not compile: %v\n%s", err, code)
+	}
+}
+
+func TestValidateTypeParams(t *testing.T) {
+	spec := newTestSpec()
+	spec.Handlers[0].TypeParams = []TypeParam{{Name: "T"}}
+	spec.Routes[0].Messages[0].Response = "(T, error)"
+
+	// The receivers of a route returning T must declare T as well
+	err := spec.Validate()
+	if err == nil || !strings.Contains(err.Error(), "does not declare type parameter 'T'") {
+		t.Fatalf("Expected an undeclared type parameter error, got: %v", err)
+	}
+
+	// A type of another package named like the parameter is not a use of it
+	spec.Routes[0].Messages[0].Response = "(*pb.T, error)"
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("Expected pb.T not to refer to the type parameter, got: %v", err)
+	}
+
+	spec.Handlers[0].TypeParams = []TypeParam{{Name: "T"}, {Name: "T"}}
+	if err := spec.Validate(); err == nil || !strings.Contains(err.Error(), "duplicate type parameter") {
+		t.Fatalf("Expected a duplicate type parameter error, got: %v", err)
+	}
+}
+
+func TestGenerateCatchAllReceivesAllMessages(t *testing.T) {
+	spec := newTestSpec()
+	spec.Imports = []string{`pb "example.com/proto/v1"`}
+	spec.Handlers = append(spec.Handlers, Handler{Name: "audit", Type: "audit.Audit"}, Handler{Name: "tracer", Type: "tracer.Tracer"})
+	spec.Routes = append(spec.Routes,
+		Route{
+			Source: "middleware",
+			Messages: []MessageRoute{
+				{Message: "*pb.CreateRequestProto", Response: "(*pb.CreateResponseProto, error)", Receivers: []string{"repository"}},
+				{Message: "*pb.LookupRequestProto", Response: "(*pb.LookupResponseProto, error)", Receivers: []string{"repository"}},
+			},
+			CatchAll: []string{"tracer"},
+		},
+		Route{
+			Source: "audit",
+			Messages: []MessageRoute{
+				{Message: "*pb.AuditEventProto", Response: "error", Receivers: []string{"repository"}},
+			},
+		},
+	)
+	spec.CatchAll = []string{"audit"}
+
+	if err := spec.ExpandCatchAll(); err != nil {
+		t.Fatalf("Failed to expand catch-all receivers: %v", err)
+	}
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("Expected valid spec, got: %v", err)
+	}
+	code, err := NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "interfaces.go", code, 0)
+	if err != nil {
+		t.Fatalf("Generated code does not parse: %v", err)
+	}
+	conf := types.Config{Importer: fakeImporter{
+		"context": fakePackage("context", "context", "Context"),
+		"example.com/proto/v1": fakePackage("example.com/proto/v1", "pb",
+			"CreateRequestProto", "CreateResponseProto", "NotifyEventProto", "LookupRequestProto", "LookupResponseProto", "AuditEventProto"),
+	}}
+	pkg, err := conf.Check("interfaces", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatalf("Generated code does not compile: %v\n%s", err, code)
+	}
+
+	// The global catch-all gets every message it doesn't send itself, once, as an intermediate receiver
+	// The route's catch-all only gets the messages of its route
+	expected := map[string][]string{
+		"AuditInterface":  {"HandleCreateRequest", "HandleLookupRequest", "HandleNotifyEvent"},
+		"TracerInterface": {"HandleCreateRequest", "HandleLookupRequest"},
+	}
+	for name, want := range expected {
+		iface := pkg.Scope().Lookup(name).Type().Underlying().(*types.Interface)
+		var got []string
+		for i := range iface.NumMethods() {
+			method := iface.Method(i)
+			got = append(got, method.Name())
+			results := method.Type().(*types.Signature).Results()
+			if results.Len() != 1 || results.At(0).Type().String() != "error" {
+				t.Errorf("Expected %s.%s to only return an error, got %s", name, method.Name(), results)
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("Expected %s to have methods %v, got %v", name, want, got)
+		}
+	}
+
+	// Expanding again leaves the routes as they are
+	if err := spec.ExpandCatchAll(); err != nil {
+		t.Fatalf("Failed to expand catch-all receivers again: %v", err)
+	}
+	if got := spec.Routes[1].Messages[0].Receivers; !slices.Equal(got, []string{"audit", "tracer", "repository"}) {
+		t.Fatalf("Expected the catch-all receivers before the explicit ones once, got %v", got)
+	}
+}
+
+func TestExpandCatchAllUnknownHandler(t *testing.T) {
+	spec := newTestSpec()
+	spec.CatchAll = []string{"missing"}
+	if err := spec.ExpandCatchAll(); err == nil || !strings.Contains(err.Error(), "unknown handler 'missing'") {
+		t.Fatalf("Expected an unknown handler error, got: %v", err)
+	}
+}
+
+func TestImportName(t *testing.T) {
+	for imp, want := range map[string]string{
+		`configpb "github.com/example/proto/configuration/v1"`: "configpb",
+		`"github.com/example/golang/middleware/audit"`:         "audit",
+	} {
+		if got := importName(imp); got != want {
+			t.Errorf("importName(%s) = %q, want %q", imp, got, want)
+		}
+	}
+}
@@ -10,10 +10,12 @@ func main() {
 	var (
 		specFile   string
 		outputFile string
+		split      bool
 	)
 
 	flag.StringVar(&specFile, "spec", "", "Path to the YAML specification file")
-	flag.StringVar(&outputFile, "output", "", "Path to the output Go file")
+	flag.StringVar(&outputFile, "output", "", "Path to the output Go file, or output directory with interfaces.package_per_handler or split")
+	flag.BoolVar(&split, "split", false, "Emit one file per handler plus "+SplitTypesFile+" into the -output directory, like interfaces.split")
 	flag.Parse()
 
 	if specFile == "" || outputFile == "" {
@@ -28,6 +30,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The flag can only turn split output on; validate again since it excludes package_per_handler
+	if split {
+		spec.Split = true
+		if err := spec.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading spec: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Validate that package is set
 	if spec.Package == "" {
 		fmt.Fprintf(os.Stderr, "Error: package name is required in YAML (interfaces.package)\n")
@@ -3,42 +3,47 @@ package main
 import (
 	"fmt"
 	"os"
+	"regexp"
 
+	"github.com/berendjan/golang-bazel-starter/golang/tools/codegen/spec"
 	"gopkg.in/yaml.v3"
 )
 
 // InterfaceConfig defines the interface-specific configuration
 type InterfaceConfig struct {
-	Package string   `yaml:"package"`
-	Imports []string `yaml:"imports,omitempty"`
+	Package           string   `yaml:"package"`
+	Imports           []string `yaml:"imports,omitempty"`
+	PackagePerHandler bool     `yaml:"package_per_handler,omitempty"` // Emit one sub-package per handler
+	Split             bool     `yaml:"split,omitempty"`               // Emit one file per handler plus types.go
 }
 
 // InterfaceSpec defines the YAML specification structure for interface generation
 type InterfaceSpec struct {
-	InterfaceConfig InterfaceConfig `yaml:"interfaces"`
-	Package         string          `yaml:"package,omitempty"` // Deprecated, for backwards compatibility
-	Imports         []string        `yaml:"imports,omitempty"` // Deprecated, for backwards compatibility
-	Handlers        []Handler       `yaml:"handlers"`
-	Routes          []Route         `yaml:"routes"`
+	InterfaceConfig   InterfaceConfig `yaml:"interfaces"`
+	Package           string          `yaml:"package,omitempty"` // Deprecated, for backwards compatibility
+	Imports           []string        `yaml:"imports,omitempty"` // Deprecated, for backwards compatibility
+	PackagePerHandler bool            `yaml:"-"`                 // Set from interfaces.package_per_handler
+	Split             bool            `yaml:"-"`                 // Set from interfaces.split or the -split flag
+	Handlers          []Handler       `yaml:"handlers"`
+	Routes            []Route         `yaml:"routes"`
+	CatchAll          []string        `yaml:"catch_all,omitempty"` // Receivers of every message of every route
 }
 
-// Handler defines a handler with its name and type
-type Handler struct {
-	Name string `yaml:"name"`
-	Type string `yaml:"type"`
-}
+// Handler, TypeParam, Route and MessageRoute are the routing spec shared with messenger-gen
+type (
+	Handler      = spec.Handler
+	TypeParam    = spec.TypeParam
+	Route        = spec.Route
+	MessageRoute = spec.MessageRoute
+)
 
-// Route defines routing for a source with multiple messages
-type Route struct {
-	Source   string          `yaml:"source"`
-	Messages []MessageRoute  `yaml:"messages"`
-}
+// typeParamName matches a valid type parameter name
+var typeParamName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 
-// MessageRoute defines a specific message routing configuration
-type MessageRoute struct {
-	Message   string   `yaml:"message"`
-	Response  string   `yaml:"response,omitempty"`
-	Receivers []string `yaml:"receivers"`
+// mentionsTypeParam reports whether the Go type expression typ refers to the type parameter name,
+// e.g. "(T, error)" and "[]*Page[T]" do, "pb.T" does not
+func mentionsTypeParam(typ, name string) bool {
+	return regexp.MustCompile(`(^|[^.\w])` + regexp.QuoteMeta(name) + `\b`).MatchString(typ)
 }
 
 // LoadSpec loads and validates an interface specification from YAML
@@ -60,7 +65,12 @@ func LoadSpec(filepath string) (*InterfaceSpec, error) {
 	if len(spec.InterfaceConfig.Imports) > 0 {
 		spec.Imports = spec.InterfaceConfig.Imports
 	}
+	spec.PackagePerHandler = spec.InterfaceConfig.PackagePerHandler
+	spec.Split = spec.InterfaceConfig.Split
 
+	if err := spec.ExpandCatchAll(); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
 	if err := spec.Validate(); err != nil {
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
@@ -71,57 +81,57 @@ func LoadSpec(filepath string) (*InterfaceSpec, error) {
 // Validate checks if the spec is valid
 func (s *InterfaceSpec) Validate() error {
 	// Package can be set via CLI flag, so don't require it in YAML
-	if len(s.Handlers) == 0 {
-		return fmt.Errorf("at least one handler is required")
+	if err := spec.Validate(s.Handlers, s.Routes); err != nil {
+		return err
 	}
-	if len(s.Routes) == 0 {
-		return fmt.Errorf("at least one route is required")
+
+	if s.Split && s.PackagePerHandler {
+		return fmt.Errorf("split and package_per_handler are mutually exclusive")
 	}
 
 	// Validate handlers
 	for i, h := range s.Handlers {
-		if h.Name == "" {
-			return fmt.Errorf("handler %d: name is required", i)
+		if s.Split && HandlerFile(h.Name) == SplitTypesFile {
+			return fmt.Errorf("handler %d: name '%s' clashes with the shared %s of split output", i, h.Name, SplitTypesFile)
 		}
-		if h.Type == "" {
-			return fmt.Errorf("handler %d: type is required", i)
+		seen := make(map[string]bool)
+		for j, p := range h.TypeParams {
+			if !typeParamName.MatchString(p.Name) {
+				return fmt.Errorf("handler %d, type parameter %d: invalid name '%s'", i, j, p.Name)
+			}
+			if seen[p.Name] {
+				return fmt.Errorf("handler %d: duplicate type parameter '%s'", i, p.Name)
+			}
+			seen[p.Name] = true
 		}
 	}
 
-	// Build a map of valid handler names for validation
-	handlerNames := make(map[string]bool)
+	return s.validateTypeParams()
+}
+
+// validateTypeParams checks that every handler on a route whose types refer to a type parameter declares it,
+// since the route's types appear in the interfaces of its source and all its receivers
+func (s *InterfaceSpec) validateTypeParams() error {
+	declared := make(map[string]map[string]bool) // handler -> type parameter -> declared
+	var params []string
 	for _, h := range s.Handlers {
-		handlerNames[h.Name] = true
+		declared[h.Name] = make(map[string]bool)
+		for _, p := range h.TypeParams {
+			declared[h.Name][p.Name] = true
+			params = append(params, p.Name)
+		}
 	}
 
-	// Validate routes
 	for i, r := range s.Routes {
-		if r.Source == "" {
-			return fmt.Errorf("route %d: source is required", i)
-		}
-
-		// Validate source handler exists
-		if !handlerNames[r.Source] {
-			return fmt.Errorf("route %d: unknown handler '%s' in source (available handlers: %v)", i, r.Source, getHandlerNamesList(s.Handlers))
-		}
-
-		if len(r.Messages) == 0 {
-			return fmt.Errorf("route %d: at least one message is required for source %s", i, r.Source)
-		}
-
-		// Validate each message route
 		for j, m := range r.Messages {
-			if m.Message == "" {
-				return fmt.Errorf("route %d, message %d: message type is required", i, j)
-			}
-			if len(m.Receivers) == 0 {
-				return fmt.Errorf("route %d, message %d: at least one receiver is required", i, j)
-			}
-
-			// Validate receiver handlers exist
-			for k, receiver := range m.Receivers {
-				if !handlerNames[receiver] {
-					return fmt.Errorf("route %d, message %d, receiver %d: unknown handler '%s' (available handlers: %v)", i, j, k, receiver, getHandlerNamesList(s.Handlers))
+			for _, param := range params {
+				if !mentionsTypeParam(m.Message, param) && !mentionsTypeParam(m.Response, param) {
+					continue
+				}
+				for _, handler := range append([]string{r.Source}, m.Receivers...) {
+					if !declared[handler][param] {
+						return fmt.Errorf("route %d, message %d: handler '%s' does not declare type parameter '%s' used by %s", i, j, handler, param, m.Message)
+					}
 				}
 			}
 		}
@@ -130,11 +140,7 @@ func (s *InterfaceSpec) Validate() error {
 	return nil
 }
 
-// getHandlerNamesList returns a list of handler names for error messages
-func getHandlerNamesList(handlers []Handler) []string {
-	names := make([]string, len(handlers))
-	for i, h := range handlers {
-		names[i] = h.Name
-	}
-	return names
+// ExpandCatchAll adds the catch-all receivers to the receivers of every message they cover, see spec.ExpandCatchAll
+func (s *InterfaceSpec) ExpandCatchAll() error {
+	return spec.ExpandCatchAll(s.Handlers, s.Routes, s.CatchAll)
 }
@@ -5,7 +5,9 @@ const fileTemplate = `// Code generated by interface-gen. DO NOT EDIT.
 package {{.Spec.Package}}
 
 import (
+{{- if .UsesContext}}
 	"context"
+{{- end}}
 {{- range .Spec.Imports}}
 	{{.}}
 {{- end}}
@@ -15,29 +17,29 @@ import (
 {{- $hasSendable := $.HasSendableMessages $handler.Name}}
 {{- if $hasSendable}}
 // {{$handler.Name | title}}Sendable defines the interface for messages that {{$handler.Name}} can send
-type {{$handler.Name | title}}Sendable interface {
+type {{$handler.Name | title}}Sendable{{$.TypeParams $handler.Name}} interface {
 {{- range $route := $.RoutesForHandler $handler.Name}}
 {{- range $msg := $route.Messages}}
-	Send{{$msg.Message | baseName}}From{{$handler.Name | title}}(ctx context.Context, message {{$msg.Message}}) {{$msg.Response}}
+	Send{{$msg.Message | baseName}}From{{$handler.Name | title}}(ctx context.Context, message {{$msg.Message}}) {{$msg.Returns}}
 {{- end}}
 {{- end}}
 }
 {{- end}}
 
 // {{$handler.Name | title}}Interface defines the interface for handling messages sent to {{$handler.Name}}
-type {{$handler.Name | title}}Interface interface {
+type {{$handler.Name | title}}Interface{{$.TypeParams $handler.Name}} interface {
 {{- range $route := $.RoutesReceivedBy $handler.Name}}
 {{- range $msg := $route.Messages}}
 {{- $isLast := $.IsLastReceiver $handler.Name $route.Source $msg.Message}}
 {{- if $hasSendable}}
 {{- if $isLast}}
-	Handle{{$msg.Message | baseName}}(ctx context.Context, message {{$msg.Message}}, next {{$handler.Name | title}}Sendable) {{$msg.Response}}
+	Handle{{$msg.Message | baseName}}(ctx context.Context, message {{$msg.Message}}, next {{$handler.Name | title}}Sendable{{$.TypeArgs $handler.Name}}) {{$msg.Returns}}
 {{- else}}
-	Handle{{$msg.Message | baseName}}(ctx context.Context, message {{$msg.Message}}, next {{$handler.Name | title}}Sendable) error
+	Handle{{$msg.Message | baseName}}(ctx context.Context, message {{$msg.Message}}, next {{$handler.Name | title}}Sendable{{$.TypeArgs $handler.Name}}) error
 {{- end}}
 {{- else}}
 {{- if $isLast}}
-	Handle{{$msg.Message | baseName}}(ctx context.Context, message {{$msg.Message}}) {{$msg.Response}}
+	Handle{{$msg.Message | baseName}}(ctx context.Context, message {{$msg.Message}}) {{$msg.Returns}}
 {{- else}}
 	Handle{{$msg.Message | baseName}}(ctx context.Context, message {{$msg.Message}}) error
 {{- end}}
@@ -48,3 +50,17 @@ type {{$handler.Name | title}}Interface interface {
 
 {{end}}
 `
+
+// typesTemplate renders the file of a split package shared by the per-handler files
+const typesTemplate = `// Code generated by interface-gen. DO NOT EDIT.
+
+// Package {{.Spec.Package}} declares the interfaces of the handlers below, each in its own file
+package {{.Spec.Package}}
+
+// Names of the handlers in the routing spec
+const (
+{{- range .Spec.Handlers}}
+	{{.Name | title}}HandlerName = "{{.Name}}"
+{{- end}}
+)
+`
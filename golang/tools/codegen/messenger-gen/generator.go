@@ -24,6 +24,11 @@ func (g *Generator) Spec() *MessengerSpec {
 	return g.spec
 }
 
+// Decorated returns true if Send methods wrap an undecorated route method, i.e. logging or tracing is enabled
+func (g *Generator) Decorated() bool {
+	return g.spec.Logging || g.spec.Tracing
+}
+
 // RoutesForHandler returns all routes where the given handler is the source
 func (g *Generator) RoutesForHandler(handlerName string) []Route {
 	var routes []Route
@@ -82,11 +87,41 @@ func (g *Generator) GetHandlerPackages() map[string]bool {
 	return packages
 }
 
+// ClientRoutes returns the routes of the client source that are served as RPCs, in spec order
+func (g *Generator) ClientRoutes() []MessageRoute {
+	var routes []MessageRoute
+	for _, route := range g.RoutesForHandler(g.spec.ClientConfig.Source) {
+		for _, msg := range route.Messages {
+			if msg.RPC != "" {
+				routes = append(routes, msg)
+			}
+		}
+	}
+	return routes
+}
+
 // Generate produces the Go source code
 func (g *Generator) Generate() ([]byte, error) {
+	return g.render("messenger", fileTemplate)
+}
+
+// GenerateClient produces the Go source code of the typed gRPC client
+func (g *Generator) GenerateClient() ([]byte, error) {
+	return g.render("client", clientTemplate)
+}
+
+// render executes text with the Generator as context and formats the result
+func (g *Generator) render(name, text string) ([]byte, error) {
 	// Create template with custom functions
-	tmpl, err := template.New("messenger").Funcs(template.FuncMap{
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
 		"title": strings.Title,
+		"untitle": func(s string) string {
+			// Lower the first letter, e.g. "SendFooFromApi" -> "sendFooFromApi"
+			if s == "" {
+				return s
+			}
+			return strings.ToLower(s[:1]) + s[1:]
+		},
 		"sub": func(a, b int) int {
 			return a - b
 		},
@@ -101,7 +136,7 @@ func (g *Generator) Generate() ([]byte, error) {
 			}
 			return s
 		},
-	}).Parse(fileTemplate)
+	}).Parse(text)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -129,7 +164,20 @@ func (g *Generator) WriteToFile(filepath string) error {
 	if err != nil {
 		return err
 	}
+	return writeFile(filepath, code)
+}
+
+// WriteClientToFile generates the client and writes it to the specified file
+func (g *Generator) WriteClientToFile(filepath string) error {
+	code, err := g.GenerateClient()
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath, code)
+}
 
+// writeFile writes generated code to filepath
+func writeFile(filepath string, code []byte) error {
 	if err := os.WriteFile(filepath, code, 0644); err != nil {
 		return fmt.Errorf("failed to write output file: %w", err)
 	}
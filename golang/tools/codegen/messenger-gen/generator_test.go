@@ -0,0 +1,509 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update rewrites the golden files in testdata from the generator's output
+var update = flag.Bool("update", false, "update golden files")
+
+// newTestSpec returns a spec with one route returning a response and one response-less route
+func newTestSpec() *MessengerSpec {
+	return &MessengerSpec{
+		Package:       "messenger",
+		MessengerName: "TestMessenger",
+		Handlers: []Handler{
+			{Name: "api", Type: "api.Api"},
+			{Name: "middleware", Type: "middleware.Middleware"},
+			{Name: "repository", Type: "repository.Repository"},
+		},
+		Routes: []Route{
+			{
+				Source: "api",
+				Messages: []MessageRoute{
+					{
+						Message:   "*pb.CreateRequestProto",
+						Response:  "(*pb.CreateResponseProto, error)",
+						Receivers: []string{"middleware", "repository"},
+					},
+					{
+						Message:   "*pb.NotifyEventProto",
+						Response:  "error",
+						Receivers: []string{"middleware", "repository"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestGenerateResponseAndResponselessRoutes(t *testing.T) {
+	spec := newTestSpec()
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("Expected valid spec, got: %v", err)
+	}
+
+	code, err := NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	expected := []string{
+		"func (m *TestMessenger) SendCreateRequestFromApi(ctx context.Context, message *pb.CreateRequestProto) (*pb.CreateResponseProto, error) {\n" +
+			"\tif err := m.middleware.HandleCreateRequest(ctx, message); err != nil {\n" +
+			"\t\treturn nil, err\n" +
+			"\t}\n" +
+			"\treturn m.repository.HandleCreateRequest(ctx, message)\n}",
+		"func (m *TestMessenger) SendNotifyEventFromApi(ctx context.Context, message *pb.NotifyEventProto) error {\n" +
+			"\tif err := m.middleware.HandleNotifyEvent(ctx, message); err != nil {\n" +
+			"\t\treturn err\n" +
+			"\t}\n" +
+			"\treturn m.repository.HandleNotifyEvent(ctx, message)\n}",
+	}
+	for _, method := range expected {
+		if !strings.Contains(string(code), method) {
+			t.Errorf("Generated code missing method:\n%s\n\ngot:\n%s", method, code)
+		}
+	}
+}
+
+func TestGenerateOmittedResponseIsResponseless(t *testing.T) {
+	spec := newTestSpec()
+	spec.Routes[0].Messages[1].Response = ""
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("Expected a route without response to be valid, got: %v", err)
+	}
+
+	code, err := NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	method := "func (m *TestMessenger) SendNotifyEventFromApi(ctx context.Context, message *pb.NotifyEventProto) error {\n" +
+		"\tif err := m.middleware.HandleNotifyEvent(ctx, message); err != nil {\n" +
+		"\t\treturn err\n" +
+		"\t}\n" +
+		"\treturn m.repository.HandleNotifyEvent(ctx, message)\n}"
+	if !strings.Contains(string(code), method) {
+		t.Errorf("Generated code missing method:\n%s\n\ngot:\n%s", method, code)
+	}
+}
+
+func TestValidateIntermediateReceiverIsResponseless(t *testing.T) {
+	spec := newTestSpec()
+	// middleware is an intermediate receiver of CreateRequest, so it can't also be its terminal receiver
+	spec.Routes = append(spec.Routes, Route{
+		Source: "repository",
+		Messages: []MessageRoute{
+			{
+				Message:   "*pb.CreateRequestProto",
+				Response:  "(*pb.CreateResponseProto, error)",
+				Receivers: []string{"middleware"},
+			},
+		},
+	})
+
+	err := spec.Validate()
+	if err == nil {
+		t.Fatal("Expected validation error for intermediate receiver with response, got nil")
+	}
+	if !strings.Contains(err.Error(), "must be response-less") {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+}
+
+func TestGenerateCatchAllReceivesAllMessages(t *testing.T) {
+	spec := newTestSpec()
+	spec.Handlers = append(spec.Handlers, Handler{Name: "audit", Type: "audit.Audit"})
+	spec.Routes = append(spec.Routes,
+		Route{
+			Source: "middleware",
+			Messages: []MessageRoute{
+				{Message: "*pb.LookupRequestProto", Response: "(*pb.LookupResponseProto, error)", Receivers: []string{"repository"}},
+			},
+		},
+		Route{
+			Source: "audit",
+			Messages: []MessageRoute{
+				{Message: "*pb.AuditEventProto", Response: "error", Receivers: []string{"repository"}},
+			},
+		},
+	)
+	spec.CatchAll = []string{"audit"}
+
+	if err := spec.ExpandCatchAll(); err != nil {
+		t.Fatalf("Failed to expand catch-all receivers: %v", err)
+	}
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("Expected valid spec, got: %v", err)
+	}
+	code, err := NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	// Every declared message reaches audit before its explicit receivers, except the one audit sends itself
+	for _, method := range []string{"SendCreateRequestFromApi", "SendNotifyEventFromApi", "SendLookupRequestFromMiddleware"} {
+		body := methodBody(t, string(code), method)
+		call := strings.Index(body, "m.audit.Handle")
+		if call < 0 || call > strings.Index(body, "m.repository.Handle") {
+			t.Errorf("Expected %s to call audit before the repository:\n%s", method, body)
+		}
+	}
+	if body := methodBody(t, string(code), "SendAuditEventFromAudit"); strings.Contains(body, "m.audit.") {
+		t.Errorf("Expected messages sent by audit not to be routed back to it:\n%s", body)
+	}
+	if !strings.Contains(string(code), "audit geninterfaces.AuditInterface") {
+		t.Errorf("Expected the messenger to hold the catch-all receiver:\n%s", code)
+	}
+}
+
+// methodBody returns the source of the messenger method name, failing t if code lacks it
+func methodBody(t *testing.T, code, name string) string {
+	t.Helper()
+	start := strings.Index(code, ") "+name+"(")
+	if start < 0 {
+		t.Fatalf("Generated code missing method %s:\n%s", name, code)
+	}
+	end := strings.Index(code[start:], "\n}\n")
+	return code[start : start+end]
+}
+
+func TestValidateRejectsGenericHandlers(t *testing.T) {
+	spec := newTestSpec()
+	spec.Handlers[0].TypeParams = []TypeParam{{Name: "T", Constraint: "proto.Message"}}
+
+	err := spec.Validate()
+	if err == nil || !strings.Contains(err.Error(), "declares type parameters") {
+		t.Fatalf("Expected generic handlers to be rejected, got: %v", err)
+	}
+}
+
+func TestGenerateLoggingDecorator(t *testing.T) {
+	spec := newTestSpec()
+
+	code, err := NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+	if strings.Contains(string(code), "logRoute") {
+		t.Fatalf("Expected no logging decorator when logging is disabled, got:\n%s", code)
+	}
+
+	spec.Logging = true
+	code, err = NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	expected := []string{
+		"\"log/slog\"",
+		"\"time\"",
+		"func logRoute(ctx context.Context, route string) func(err error) {",
+		"func (m *TestMessenger) SendCreateRequestFromApi(ctx context.Context, message *pb.CreateRequestProto) (*pb.CreateResponseProto, error) {\n" +
+			"\tdone := logRoute(ctx, \"SendCreateRequestFromApi\")\n" +
+			"\tresp, err := m.sendCreateRequestFromApi(ctx, message)\n" +
+			"\tdone(err)\n" +
+			"\treturn resp, err\n}",
+		"func (m *TestMessenger) SendNotifyEventFromApi(ctx context.Context, message *pb.NotifyEventProto) error {\n" +
+			"\tdone := logRoute(ctx, \"SendNotifyEventFromApi\")\n" +
+			"\terr := m.sendNotifyEventFromApi(ctx, message)\n" +
+			"\tdone(err)\n" +
+			"\treturn err\n}",
+		"func (m *TestMessenger) sendCreateRequestFromApi(ctx context.Context, message *pb.CreateRequestProto) (*pb.CreateResponseProto, error) {\n" +
+			"\tif err := m.middleware.HandleCreateRequest(ctx, message); err != nil {\n" +
+			"\t\treturn nil, err\n" +
+			"\t}\n" +
+			"\treturn m.repository.HandleCreateRequest(ctx, message)\n}",
+	}
+	for _, snippet := range expected {
+		if !strings.Contains(string(code), snippet) {
+			t.Errorf("Generated code missing:\n%s\n\ngot:\n%s", snippet, code)
+		}
+	}
+}
+
+func TestGenerateTracingDecorator(t *testing.T) {
+	spec := newTestSpec()
+
+	code, err := NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+	if strings.Contains(string(code), "startRouteSpan") {
+		t.Fatalf("Expected no tracing decorator when tracing is disabled, got:\n%s", code)
+	}
+
+	spec.Tracing = true
+	code, err = NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	expected := []string{
+		"\"go.opentelemetry.io/otel\"",
+		"otelcodes \"go.opentelemetry.io/otel/codes\"",
+		"\"go.opentelemetry.io/otel/trace\"",
+		"func startRouteSpan(ctx context.Context, name string) (context.Context, trace.Span) {",
+		"func endRouteSpan(span trace.Span, err error) {",
+		"func (m *TestMessenger) SendCreateRequestFromApi(ctx context.Context, message *pb.CreateRequestProto) (*pb.CreateResponseProto, error) {\n" +
+			"\tctx, span := startRouteSpan(ctx, \"api/CreateRequest\")\n" +
+			"\tresp, err := m.sendCreateRequestFromApi(ctx, message)\n" +
+			"\tendRouteSpan(span, err)\n" +
+			"\treturn resp, err\n}",
+		"func (m *TestMessenger) SendNotifyEventFromApi(ctx context.Context, message *pb.NotifyEventProto) error {\n" +
+			"\tctx, span := startRouteSpan(ctx, \"api/NotifyEvent\")\n" +
+			"\terr := m.sendNotifyEventFromApi(ctx, message)\n" +
+			"\tendRouteSpan(span, err)\n" +
+			"\treturn err\n}",
+	}
+	for _, snippet := range expected {
+		if !strings.Contains(string(code), snippet) {
+			t.Errorf("Generated code missing:\n%s\n\ngot:\n%s", snippet, code)
+		}
+	}
+	if strings.Contains(string(code), "logRoute") {
+		t.Fatalf("Expected tracing alone not to generate the logging decorator, got:\n%s", code)
+	}
+
+	// With logging as well, the span wraps the logged route so its logs carry the span context
+	spec.Logging = true
+	code, err = NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+	combined := "\tctx, span := startRouteSpan(ctx, \"api/CreateRequest\")\n" +
+		"\tdone := logRoute(ctx, \"SendCreateRequestFromApi\")\n" +
+		"\tresp, err := m.sendCreateRequestFromApi(ctx, message)\n" +
+		"\tdone(err)\n" +
+		"\tendRouteSpan(span, err)\n"
+	if !strings.Contains(string(code), combined) {
+		t.Errorf("Generated code missing:\n%s\n\ngot:\n%s", combined, code)
+	}
+}
+
+// newClientTestSpec returns the test spec with a client for the routes of api, one converting its RPC request
+func newClientTestSpec() *MessengerSpec {
+	spec := newTestSpec()
+	spec.ClientConfig = ClientConfig{
+		Package:          "client",
+		ClientName:       "TestClient",
+		Source:           "api",
+		ServiceClient:    "svc.ServiceClient",
+		NewServiceClient: "svc.NewServiceClient",
+		Imports: []string{
+			`pb "example.com/proto/v1"`,
+			`svc "example.com/service/v1"`,
+		},
+	}
+	spec.Routes[0].Messages[0].RPC = "Create"
+	spec.Routes[0].Messages[0].RPCRequest = "*pb.CreateRpcRequestProto"
+	spec.Routes[0].Messages = append(spec.Routes[0].Messages, MessageRoute{
+		Message:   "*pb.GetRequestProto",
+		Response:  "(*pb.CreateResponseProto, error)",
+		Receivers: []string{"repository"},
+		RPC:       "Get",
+	})
+	return spec
+}
+
+// clientTestSources are stubs of the packages the generated client imports, type checked from source
+var clientTestSources = map[string]string{
+	"context":    `package context; type Context interface{}`,
+	"crypto/tls": `package tls; type Config struct{}`,
+	"fmt":        `package fmt; func Errorf(format string, a ...any) error { return nil }`,
+	"strings":    `package strings; func HasPrefix(s, prefix string) bool { return false }`,
+	"google.golang.org/grpc": `package grpc
+		type ClientConn struct{}
+		func (*ClientConn) Close() error { return nil }
+		type DialOption interface{}
+		type CallOption interface{}
+		func NewClient(target string, opts ...DialOption) (*ClientConn, error) { return nil, nil }
+		func WithTransportCredentials(creds interface{ Info() string }) DialOption { return nil }`,
+	"google.golang.org/grpc/credentials": `package credentials
+		import "crypto/tls"
+		type TransportCredentials interface{ Info() string }
+		func NewTLS(c *tls.Config) TransportCredentials { return nil }`,
+	"google.golang.org/grpc/credentials/insecure": `package insecure
+		import "google.golang.org/grpc/credentials"
+		func NewCredentials() credentials.TransportCredentials { return nil }`,
+	"example.com/proto/v1": `package pb
+		type CreateRpcRequestProto struct{}
+		type CreateResponseProto struct{}
+		type GetRequestProto struct{}`,
+	"example.com/service/v1": `package svc
+		import (
+			"context"
+			"google.golang.org/grpc"
+			pb "example.com/proto/v1"
+		)
+		type ServiceClient interface {
+			Create(ctx context.Context, in *pb.CreateRpcRequestProto, opts ...grpc.CallOption) (*pb.CreateResponseProto, error)
+			Get(ctx context.Context, in *pb.GetRequestProto, opts ...grpc.CallOption) (*pb.CreateResponseProto, error)
+		}
+		func NewServiceClient(cc *grpc.ClientConn) ServiceClient { return nil }`,
+}
+
+// sourceImporter type checks the imported packages from clientTestSources, so type checking needs no compiled dependencies
+type sourceImporter struct {
+	fset     *token.FileSet
+	packages map[string]*types.Package
+}
+
+func (s *sourceImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := s.packages[path]; ok {
+		return pkg, nil
+	}
+	src, ok := clientTestSources[path]
+	if !ok {
+		return nil, fmt.Errorf("unexpected import %q", path)
+	}
+	file, err := parser.ParseFile(s.fset, path, src, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stub of %s: %w", path, err)
+	}
+	conf := types.Config{Importer: s}
+	pkg, err := conf.Check(path, s.fset, []*ast.File{file}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check stub of %s: %w", path, err)
+	}
+	s.packages[path] = pkg
+	return pkg, nil
+}
+
+func TestGenerateObserver(t *testing.T) {
+	spec := newTestSpec()
+
+	code, err := NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+	if strings.Contains(string(code), "observe") {
+		t.Fatalf("Expected no observer when it is disabled, got:\n%s", code)
+	}
+
+	spec.Observer = true
+	code, err = NewGenerator(spec).Generate()
+	if err != nil {
+		t.Fatalf("Failed to generate code: %v", err)
+	}
+
+	expected := []string{
+		"\tobserver   func(ctx context.Context, source, receiver string)\n",
+		"func (m *TestMessenger) WithObserver(fn func(ctx context.Context, source, receiver string)) *TestMessenger {",
+		// Every receiver is reported just before it handles the message
+		"func (m *TestMessenger) SendCreateRequestFromApi(ctx context.Context, message *pb.CreateRequestProto) (*pb.CreateResponseProto, error) {\n" +
+			"\tm.observe(ctx, \"api\", \"middleware\")\n" +
+			"\tif err := m.middleware.HandleCreateRequest(ctx, message); err != nil {\n" +
+			"\t\treturn nil, err\n" +
+			"\t}\n" +
+			"\tm.observe(ctx, \"api\", \"repository\")\n" +
+			"\treturn m.repository.HandleCreateRequest(ctx, message)\n}",
+	}
+	for _, snippet := range expected {
+		if !strings.Contains(string(code), snippet) {
+			t.Errorf("Generated code missing:\n%s\n\ngot:\n%s", snippet, code)
+		}
+	}
+
+	// The observer's field can't be shared with a handler
+	spec.Handlers[0].Name = ObserverField
+	spec.Routes[0].Source = ObserverField
+	if err := spec.Validate(); err == nil || !strings.Contains(err.Error(), "clashes with the messenger's observer field") {
+		t.Fatalf("Expected a handler named %s to be rejected, got: %v", ObserverField, err)
+	}
+}
+
+func TestGenerateClientMatchesGoldenFile(t *testing.T) {
+	spec := newClientTestSpec()
+	if err := spec.ValidateClient(); err != nil {
+		t.Fatalf("Expected valid client spec, got: %v", err)
+	}
+
+	code, err := NewGenerator(spec).GenerateClient()
+	if err != nil {
+		t.Fatalf("Failed to generate client: %v\n%s", err, code)
+	}
+
+	golden := filepath.Join("testdata", "client", "client.go.golden")
+	if *update {
+		if err := os.WriteFile(golden, code, 0644); err != nil {
+			t.Fatalf("Failed to update %s: %v", golden, err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", golden, err)
+	}
+	if string(code) != string(expected) {
+		t.Errorf("Generated client does not match %s:\n%s", golden, code)
+	}
+
+	// The response-less route has no RPC, so it gets no method
+	if strings.Contains(string(code), "NotifyEvent") {
+		t.Errorf("Expected no method for the route without an rpc:\n%s", code)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "client.go", code, 0)
+	if err != nil {
+		t.Fatalf("Generated client does not parse: %v", err)
+	}
+	conf := types.Config{Importer: &sourceImporter{fset: fset, packages: make(map[string]*types.Package)}}
+	if _, err := conf.Check("client", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("Generated client does not compile: %v", err)
+	}
+}
+
+func TestValidateClient(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*MessengerSpec)
+		want   string
+	}{
+		{"valid", func(*MessengerSpec) {}, ""},
+		{"missing name", func(s *MessengerSpec) { s.ClientConfig.ClientName = "" }, "client.client_name"},
+		{"unknown source", func(s *MessengerSpec) { s.ClientConfig.Source = "gateway" }, "unknown handler 'gateway'"},
+		{"no rpc", func(s *MessengerSpec) {
+			for i := range s.Routes[0].Messages {
+				s.Routes[0].Messages[i].RPC = ""
+			}
+		}, "sets an rpc"},
+		{"response-less rpc", func(s *MessengerSpec) { s.Routes[0].Messages[1].RPC = "Notify" }, "response-less"},
+		{"duplicate rpc", func(s *MessengerSpec) { s.Routes[0].Messages[2].RPC = "Create" }, "duplicate rpc 'Create'"},
+		{"rpc on another source", func(s *MessengerSpec) {
+			s.Routes = append(s.Routes, Route{Source: "middleware", Messages: []MessageRoute{{
+				Message:   "*pb.GetRequestProto",
+				Response:  "(*pb.CreateResponseProto, error)",
+				Receivers: []string{"repository"},
+				RPC:       "List",
+			}}})
+		}, "only routes from client.source"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := newClientTestSpec()
+			tt.modify(spec)
+			err := spec.ValidateClient()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Expected valid client spec, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Expected an error containing %q, got: %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -10,10 +10,12 @@ func main() {
 	var (
 		specFile   string
 		outputFile string
+		client     bool
 	)
 
 	flag.StringVar(&specFile, "spec", "", "Path to the YAML specification file")
 	flag.StringVar(&outputFile, "output", "", "Path to the output Go file")
+	flag.BoolVar(&client, "client", false, "Emit the typed gRPC client of client.source instead of the messenger")
 	flag.Parse()
 
 	if specFile == "" || outputFile == "" {
@@ -28,6 +30,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	if client {
+		if err := spec.ValidateClient(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading spec: %v\n", err)
+			os.Exit(1)
+		}
+		if err := NewGenerator(spec).WriteClientToFile(outputFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating code: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Successfully generated %s from %s\n", outputFile, specFile)
+		return
+	}
+
 	// Validate required fields
 	if spec.Package == "" {
 		fmt.Fprintf(os.Stderr, "Error: package name is required in YAML (messenger.package)\n")
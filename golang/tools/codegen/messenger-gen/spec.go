@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/berendjan/golang-bazel-starter/golang/tools/codegen/spec"
 	"gopkg.in/yaml.v3" // This will be resolved by go mod tidy && bazel mod tidy
 )
 
@@ -12,36 +13,46 @@ type MessengerConfig struct {
 	Package       string   `yaml:"package"`
 	MessengerName string   `yaml:"messenger_name"`
 	Imports       []string `yaml:"imports,omitempty"`
+	Logging       bool     `yaml:"logging,omitempty"`  // Log entry, exit and elapsed time of every route
+	Tracing       bool     `yaml:"tracing,omitempty"`  // Wrap every route in an OpenTelemetry span
+	Observer      bool     `yaml:"observer,omitempty"` // Report every receiver to a function set with WithObserver
+}
+
+// ClientConfig defines the configuration of the client generated with -client
+type ClientConfig struct {
+	Package          string   `yaml:"package"`
+	ClientName       string   `yaml:"client_name"`
+	Source           string   `yaml:"source"`             // Handler serving the gRPC service; its routes with an rpc become client methods
+	ServiceClient    string   `yaml:"service_client"`     // Generated gRPC client interface, e.g. gw.ConfigurationClient
+	NewServiceClient string   `yaml:"new_service_client"` // Its constructor, e.g. gw.NewConfigurationClient
+	Imports          []string `yaml:"imports,omitempty"`
 }
 
 // MessengerSpec defines the YAML specification structure
 type MessengerSpec struct {
 	MessengerConfig MessengerConfig `yaml:"messenger"`
-	Package         string          `yaml:"package,omitempty"`         // Deprecated, for backwards compatibility
+	ClientConfig    ClientConfig    `yaml:"client,omitempty"`
+	Package         string          `yaml:"package,omitempty"`        // Deprecated, for backwards compatibility
 	MessengerName   string          `yaml:"messenger_name,omitempty"` // Deprecated, for backwards compatibility
-	Imports         []string        `yaml:"imports,omitempty"`         // Deprecated, for backwards compatibility
+	Imports         []string        `yaml:"imports,omitempty"`        // Deprecated, for backwards compatibility
+	Logging         bool            `yaml:"-"`                        // Set from messenger.logging
+	Tracing         bool            `yaml:"-"`                        // Set from messenger.tracing
+	Observer        bool            `yaml:"-"`                        // Set from messenger.observer
 	Handlers        []Handler       `yaml:"handlers"`
 	Routes          []Route         `yaml:"routes"`
+	CatchAll        []string        `yaml:"catch_all,omitempty"` // Receivers of every message of every route
 }
 
-// Handler defines a handler with its name and type
-type Handler struct {
-	Name string `yaml:"name"`
-	Type string `yaml:"type"`
-}
-
-// Route defines routing for a source with multiple messages
-type Route struct {
-	Source   string          `yaml:"source"`
-	Messages []MessageRoute  `yaml:"messages"`
-}
+// Handler, TypeParam, Route and MessageRoute are the routing spec shared with interface-gen
+type (
+	Handler      = spec.Handler
+	TypeParam    = spec.TypeParam
+	Route        = spec.Route
+	MessageRoute = spec.MessageRoute
+)
 
-// MessageRoute defines a specific message routing configuration
-type MessageRoute struct {
-	Message   string   `yaml:"message"`
-	Response  string   `yaml:"response,omitempty"` // Optional, includes error tuple like "(Type, error)"
-	Receivers []string `yaml:"receivers"`
-}
+// ObserverField is the messenger field holding the function set with WithObserver
+const ObserverField = "observer"
 
 // LoadSpec loads and validates a messenger specification from YAML
 func LoadSpec(filepath string) (*MessengerSpec, error) {
@@ -65,7 +76,13 @@ func LoadSpec(filepath string) (*MessengerSpec, error) {
 	if len(spec.MessengerConfig.Imports) > 0 {
 		spec.Imports = spec.MessengerConfig.Imports
 	}
+	spec.Logging = spec.MessengerConfig.Logging
+	spec.Tracing = spec.MessengerConfig.Tracing
+	spec.Observer = spec.MessengerConfig.Observer
 
+	if err := spec.ExpandCatchAll(); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
 	if err := spec.Validate(); err != nil {
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
@@ -76,70 +93,70 @@ func LoadSpec(filepath string) (*MessengerSpec, error) {
 // Validate checks if the spec is valid
 func (s *MessengerSpec) Validate() error {
 	// Package and messenger name can be set via CLI flags, so don't require them in YAML
-	if len(s.Handlers) == 0 {
-		return fmt.Errorf("at least one handler is required")
-	}
-	if len(s.Routes) == 0 {
-		return fmt.Errorf("at least one route is required")
+	if err := spec.Validate(s.Handlers, s.Routes); err != nil {
+		return err
 	}
 
 	// Validate handlers
 	for i, h := range s.Handlers {
-		if h.Name == "" {
-			return fmt.Errorf("handler %d: name is required", i)
+		// The messenger holds every handler's interface, which it could only do for instantiated generic handlers
+		if len(h.TypeParams) > 0 {
+			return fmt.Errorf("handler %d: '%s' declares type parameters, which the messenger does not support", i, h.Name)
 		}
-		if h.Type == "" {
-			return fmt.Errorf("handler %d: type is required", i)
+		if s.Observer && h.Name == ObserverField {
+			return fmt.Errorf("handler %d: name '%s' clashes with the messenger's observer field", i, h.Name)
 		}
 	}
 
-	// Build a map of valid handler names for validation
-	handlerNames := make(map[string]bool)
+	return nil
+}
+
+// ValidateClient checks the client configuration and the rpc of the routes, which only -client requires
+func (s *MessengerSpec) ValidateClient() error {
+	c := s.ClientConfig
+	switch {
+	case c.Package == "":
+		return fmt.Errorf("client package is required (client.package)")
+	case c.ClientName == "":
+		return fmt.Errorf("client name is required (client.client_name)")
+	case c.ServiceClient == "" || c.NewServiceClient == "":
+		return fmt.Errorf("gRPC client type and constructor are required (client.service_client, client.new_service_client)")
+	}
+
+	found := false
 	for _, h := range s.Handlers {
-		handlerNames[h.Name] = true
+		found = found || h.Name == c.Source
+	}
+	if !found {
+		return fmt.Errorf("unknown handler '%s' in client.source (available handlers: %v)", c.Source, spec.HandlerNames(s.Handlers))
 	}
 
-	// Validate routes
+	rpcs := make(map[string]bool)
 	for i, r := range s.Routes {
-		if r.Source == "" {
-			return fmt.Errorf("route %d: source is required", i)
-		}
-
-		// Validate source handler exists
-		if !handlerNames[r.Source] {
-			return fmt.Errorf("route %d: unknown handler '%s' in source (available handlers: %v)", i, r.Source, getHandlerNamesList(s.Handlers))
-		}
-
-		if len(r.Messages) == 0 {
-			return fmt.Errorf("route %d: at least one message is required for source %s", i, r.Source)
-		}
-
-		// Validate each message route
 		for j, m := range r.Messages {
-			if m.Message == "" {
-				return fmt.Errorf("route %d, message %d: message type is required", i, j)
+			if m.RPC == "" {
+				continue
 			}
-			if len(m.Receivers) == 0 {
-				return fmt.Errorf("route %d, message %d: at least one receiver is required", i, j)
+			if r.Source != c.Source {
+				return fmt.Errorf("route %d, message %d: rpc '%s' is set on a route from '%s', but only routes from client.source '%s' are served as RPCs", i, j, m.RPC, r.Source, c.Source)
 			}
-
-			// Validate receiver handlers exist
-			for k, receiver := range m.Receivers {
-				if !handlerNames[receiver] {
-					return fmt.Errorf("route %d, message %d, receiver %d: unknown handler '%s' (available handlers: %v)", i, j, k, receiver, getHandlerNamesList(s.Handlers))
-				}
+			if m.IsResponseless() {
+				return fmt.Errorf("route %d, message %d: rpc '%s' needs a response, but the route is response-less", i, j, m.RPC)
 			}
+			if rpcs[m.RPC] {
+				return fmt.Errorf("route %d, message %d: duplicate rpc '%s'", i, j, m.RPC)
+			}
+			rpcs[m.RPC] = true
 		}
 	}
+	if len(rpcs) == 0 {
+		return fmt.Errorf("no route from client.source '%s' sets an rpc", c.Source)
+	}
 
 	return nil
 }
 
-// getHandlerNamesList returns a list of handler names for error messages
-func getHandlerNamesList(handlers []Handler) []string {
-	names := make([]string, len(handlers))
-	for i, h := range handlers {
-		names[i] = h.Name
-	}
-	return names
+// ExpandCatchAll adds the catch-all receivers to the receivers of every message they cover, see spec.ExpandCatchAll
+func (s *MessengerSpec) ExpandCatchAll() error {
+	return spec.ExpandCatchAll(s.Handlers, s.Routes, s.CatchAll)
 }
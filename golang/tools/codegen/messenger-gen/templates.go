@@ -6,6 +6,15 @@ package {{.Spec.Package}}
 
 import (
 	"context"
+{{- if .Spec.Logging}}
+	"log/slog"
+	"time"
+{{- end}}
+{{- if .Spec.Tracing}}
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+{{- end}}
 {{- range .Spec.Imports}}
 	{{.}}
 {{- end}}
@@ -18,6 +27,9 @@ type {{.Spec.MessengerName}} struct {
 	{{$handler.Name}} geninterfaces.{{$handler.Name | title}}Interface
 {{- end}}
 {{- end}}
+{{- if .Spec.Observer}}
+	observer func(ctx context.Context, source, receiver string)
+{{- end}}
 }
 
 // New{{.Spec.MessengerName}} creates a new messenger with dependencies
@@ -37,21 +49,71 @@ func New{{.Spec.MessengerName}}(
 	}
 }
 
+{{- if .Spec.Observer}}
+// WithObserver sets fn to be called with the source and receiver before every receiver handles a message
+// Use it to record the path messages take, e.g. in tests; must be called before sending messages
+func (m *{{.Spec.MessengerName}}) WithObserver(fn func(ctx context.Context, source, receiver string)) *{{.Spec.MessengerName}} {
+	m.observer = fn
+	return m
+}
+
+// observe reports a receiver to the function set with WithObserver, if any
+func (m *{{.Spec.MessengerName}}) observe(ctx context.Context, source, receiver string) {
+	if m.observer != nil {
+		m.observer(ctx, source, receiver)
+	}
+}
+{{end}}
 {{range $handler := .Spec.Handlers}}
 {{- $routes := $.RoutesForHandler $handler.Name}}
 {{- if $routes}}
 {{range $route := $routes}}
 {{range $msg := $route.Messages}}
-// Send{{$msg.Message | baseName}}From{{$handler.Name | title}} sends {{$msg.Message}} from {{$handler.Name}} to receivers
-func (m *{{$.Spec.MessengerName}}) Send{{$msg.Message | baseName}}From{{$handler.Name | title}}(ctx context.Context, message {{$msg.Message}}) {{$msg.Response}} {
+{{- $method := printf "Send%sFrom%s" ($msg.Message | baseName) ($handler.Name | title)}}
+{{- if $.Decorated}}
+// {{$method}} sends {{$msg.Message}} from {{$handler.Name}} to receivers{{if $.Spec.Tracing}} in a span{{end}}{{if $.Spec.Logging}}, logging entry, exit and elapsed time{{end}}
+func (m *{{$.Spec.MessengerName}}) {{$method}}(ctx context.Context, message {{$msg.Message}}) {{$msg.Returns}} {
+{{- if $.Spec.Tracing}}
+	ctx, span := startRouteSpan(ctx, "{{$handler.Name}}/{{$msg.Message | baseName}}")
+{{- end}}
+{{- if $.Spec.Logging}}
+	done := logRoute(ctx, "{{$method}}")
+{{- end}}
+{{- if $msg.IsResponseless}}
+	err := m.{{$method | untitle}}(ctx, message)
+{{- else}}
+	resp, err := m.{{$method | untitle}}(ctx, message)
+{{- end}}
+{{- if $.Spec.Logging}}
+	done(err)
+{{- end}}
+{{- if $.Spec.Tracing}}
+	endRouteSpan(span, err)
+{{- end}}
+{{- if $msg.IsResponseless}}
+	return err
+{{- else}}
+	return resp, err
+{{- end}}
+}
+
+// {{$method | untitle}} sends {{$msg.Message}} from {{$handler.Name}} to receivers
+func (m *{{$.Spec.MessengerName}}) {{$method | untitle}}(ctx context.Context, message {{$msg.Message}}) {{$msg.Returns}} {
+{{- else}}
+// {{$method}} sends {{$msg.Message}} from {{$handler.Name}} to receivers
+func (m *{{$.Spec.MessengerName}}) {{$method}}(ctx context.Context, message {{$msg.Message}}) {{$msg.Returns}} {
+{{- end}}
 {{- range $i, $receiver := $msg.Receivers}}
 {{- $isLast := eq $i (sub (len $msg.Receivers) 1)}}
+{{- if $.Spec.Observer}}
+	m.observe(ctx, "{{$handler.Name}}", "{{$receiver}}")
+{{- end}}
 {{- if $.HasSendableMessages $receiver}}
 {{- if $isLast}}
 	return m.{{$receiver}}.Handle{{$msg.Message | baseName}}(ctx, message, m)
 {{- else}}
 	if err := m.{{$receiver}}.Handle{{$msg.Message | baseName}}(ctx, message, m); err != nil {
-		return nil, err
+		return {{if not $msg.IsResponseless}}nil, {{end}}err
 	}
 {{- end}}
 {{- else}}
@@ -59,7 +121,7 @@ func (m *{{$.Spec.MessengerName}}) Send{{$msg.Message | baseName}}From{{$handler
 	return m.{{$receiver}}.Handle{{$msg.Message | baseName}}(ctx, message)
 {{- else}}
 	if err := m.{{$receiver}}.Handle{{$msg.Message | baseName}}(ctx, message); err != nil {
-		return nil, err
+		return {{if not $msg.IsResponseless}}nil, {{end}}err
 	}
 {{- end}}
 {{- end}}
@@ -69,4 +131,102 @@ func (m *{{$.Spec.MessengerName}}) Send{{$msg.Message | baseName}}From{{$handler
 {{end}}
 {{- end}}
 {{end}}
+{{- if .Spec.Logging}}
+// logRoute logs the start of a route and returns a function that logs its end with the elapsed time
+func logRoute(ctx context.Context, route string) func(err error) {
+	start := time.Now()
+	slog.Default().LogAttrs(ctx, slog.LevelInfo, "route started", slog.String("route", route))
+	return func(err error) {
+		attrs := []slog.Attr{
+			slog.String("route", route),
+			slog.Duration("duration", time.Since(start)),
+		}
+		level := slog.LevelInfo
+		if err != nil {
+			level = slog.LevelError
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		slog.Default().LogAttrs(ctx, level, "route finished", attrs...)
+	}
+}
+{{- end}}
+{{- if .Spec.Tracing}}
+
+// startRouteSpan starts a span for one hop of a route, nested under the span of the previous hop
+func startRouteSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("{{.Spec.Package}}.{{.Spec.MessengerName}}").Start(ctx, name)
+}
+
+// endRouteSpan records the error of a hop, if any, and ends its span
+func endRouteSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
+{{- end}}
 `
+
+const clientTemplate = `// Code generated by messenger-gen -client. DO NOT EDIT.
+
+package {{.Spec.ClientConfig.Package}}
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+{{- if .Spec.ClientConfig.Imports}}
+{{end}}
+{{- range .Spec.ClientConfig.Imports}}
+	{{.}}
+{{- end}}
+)
+
+// {{.Spec.ClientConfig.ClientName}} is the generated client for the RPCs served by {{.Spec.ClientConfig.Source}}
+type {{.Spec.ClientConfig.ClientName}} struct {
+	conn   *grpc.ClientConn
+	client {{.Spec.ClientConfig.ServiceClient}}
+}
+
+// New{{.Spec.ClientConfig.ClientName}} connects to address, over TLS unless tlsConfig is nil
+// opts are added after the transport credentials, e.g. interceptors adding call metadata
+func New{{.Spec.ClientConfig.ClientName}}(address string, tlsConfig *tls.Config, opts ...grpc.DialOption) (*{{.Spec.ClientConfig.ClientName}}, error) {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, opts...)
+
+	// Use passthrough resolver for localhost to avoid slow DNS resolution
+	target := address
+	if strings.HasPrefix(target, "localhost") || strings.HasPrefix(target, "127.0.0.1") {
+		target = "passthrough:///" + target
+	}
+
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	return &{{.Spec.ClientConfig.ClientName}}{
+		conn:   conn,
+		client: {{.Spec.ClientConfig.NewServiceClient}}(conn),
+	}, nil
+}
+
+// Close closes the connection to the server
+func (c *{{.Spec.ClientConfig.ClientName}}) Close() error {
+	return c.conn.Close()
+}
+{{range $msg := .ClientRoutes}}
+// {{$msg.RPC}} calls the {{$msg.RPC}} RPC, which {{$.Spec.ClientConfig.Source}} routes as {{$msg.Message}}
+func (c *{{$.Spec.ClientConfig.ClientName}}) {{$msg.RPC}}(ctx context.Context, req {{$msg.Request}}, opts ...grpc.CallOption) {{$msg.Returns}} {
+	return c.client.{{$msg.RPC}}(ctx, req, opts...)
+}
+{{end}}`
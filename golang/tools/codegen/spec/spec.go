@@ -0,0 +1,200 @@
+// Package spec defines the routing spec shared by interface-gen and messenger-gen, and the validation both apply to it
+package spec
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Handler defines a handler with its name and type
+type Handler struct {
+	Name       string      `yaml:"name"`
+	Type       string      `yaml:"type"`
+	TypeParams []TypeParam `yaml:"type_params,omitempty"` // Makes the handler's interfaces generic, only supported by interface-gen
+}
+
+// TypeParam is a type parameter of a generic handler, usable in the types of the routes it sends or receives
+type TypeParam struct {
+	Name       string `yaml:"name"`
+	Constraint string `yaml:"constraint,omitempty"` // Defaults to any
+}
+
+// Route defines routing for a source with multiple messages
+type Route struct {
+	Source   string         `yaml:"source"`
+	Messages []MessageRoute `yaml:"messages"`
+	CatchAll []string       `yaml:"catch_all,omitempty"` // Receivers of every message from source
+}
+
+// MessageRoute defines a specific message routing configuration
+type MessageRoute struct {
+	Message    string   `yaml:"message"`
+	Response   string   `yaml:"response,omitempty"` // "(Type, error)", or "error" or omitted for response-less routes
+	Receivers  []string `yaml:"receivers"`
+	RPC        string   `yaml:"rpc,omitempty"`         // Unary RPC serving the route, generates a client method with messenger-gen -client
+	RPCRequest string   `yaml:"rpc_request,omitempty"` // Request type of the RPC when the source converts it to message
+}
+
+// IsResponseless returns true if the route only reports an error (fire-and-forget)
+func (m MessageRoute) IsResponseless() bool {
+	response := strings.TrimSpace(m.Response)
+	return response == "" || response == "error"
+}
+
+// Returns returns the result type of the route's Send and Handle methods, "error" for response-less routes
+func (m MessageRoute) Returns() string {
+	if m.IsResponseless() {
+		return "error"
+	}
+	return m.Response
+}
+
+// Request returns the request type of the route's RPC, which defaults to its message
+func (m MessageRoute) Request() string {
+	if m.RPCRequest != "" {
+		return m.RPCRequest
+	}
+	return m.Message
+}
+
+// Validate checks the handlers and routes, leaving checks specific to a generator to its caller
+func Validate(handlers []Handler, routes []Route) error {
+	if len(handlers) == 0 {
+		return fmt.Errorf("at least one handler is required")
+	}
+	if len(routes) == 0 {
+		return fmt.Errorf("at least one route is required")
+	}
+
+	// Validate handlers
+	handlerNames := make(map[string]bool)
+	for i, h := range handlers {
+		if h.Name == "" {
+			return fmt.Errorf("handler %d: name is required", i)
+		}
+		if h.Type == "" {
+			return fmt.Errorf("handler %d: type is required", i)
+		}
+		handlerNames[h.Name] = true
+	}
+
+	// Validate routes
+	for i, r := range routes {
+		if r.Source == "" {
+			return fmt.Errorf("route %d: source is required", i)
+		}
+
+		// Validate source handler exists
+		if !handlerNames[r.Source] {
+			return fmt.Errorf("route %d: unknown handler '%s' in source (available handlers: %v)", i, r.Source, HandlerNames(handlers))
+		}
+
+		if len(r.Messages) == 0 {
+			return fmt.Errorf("route %d: at least one message is required for source %s", i, r.Source)
+		}
+
+		// Validate each message route
+		for j, m := range r.Messages {
+			if m.Message == "" {
+				return fmt.Errorf("route %d, message %d: message type is required", i, j)
+			}
+			if len(m.Receivers) == 0 {
+				return fmt.Errorf("route %d, message %d: at least one receiver is required", i, j)
+			}
+
+			// Validate receiver handlers exist
+			for k, receiver := range m.Receivers {
+				if !handlerNames[receiver] {
+					return fmt.Errorf("route %d, message %d, receiver %d: unknown handler '%s' (available handlers: %v)", i, j, k, receiver, HandlerNames(handlers))
+				}
+			}
+		}
+	}
+
+	return validateIntermediateReceivers(routes)
+}
+
+// validateIntermediateReceivers checks that handlers receiving a message as an intermediate
+// are not also terminal receivers of that message with a response, since both positions
+// generate the same Handle method and intermediate receivers only return an error
+func validateIntermediateReceivers(routes []Route) error {
+	intermediates := make(map[string]map[string]bool) // handler -> message -> receives as intermediate
+	for _, r := range routes {
+		for _, m := range r.Messages {
+			for _, receiver := range m.Receivers[:len(m.Receivers)-1] {
+				if intermediates[receiver] == nil {
+					intermediates[receiver] = make(map[string]bool)
+				}
+				intermediates[receiver][m.Message] = true
+			}
+		}
+	}
+
+	for i, r := range routes {
+		for j, m := range r.Messages {
+			terminal := m.Receivers[len(m.Receivers)-1]
+			if intermediates[terminal][m.Message] && !m.IsResponseless() {
+				return fmt.Errorf("route %d, message %d: handler '%s' is an intermediate receiver of %s elsewhere and must be response-less here (got response %q)", i, j, terminal, m.Message, m.Response)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ExpandCatchAll adds the catch-all receivers to the front of the receivers of every message they cover,
+// so they see the message before the explicit receivers and, like any intermediate receiver, can stop it with an error.
+// A global catch-all receiver gets a message on every hop of its route. Routes sent by a catch-all receiver
+// itself are not routed back to it, and messages already listing it as a receiver are left as they are
+func ExpandCatchAll(handlers []Handler, routes []Route, catchAll []string) error {
+	handlerNames := make(map[string]bool)
+	for _, h := range handlers {
+		handlerNames[h.Name] = true
+	}
+	for i, name := range catchAll {
+		if !handlerNames[name] {
+			return fmt.Errorf("catch_all %d: unknown handler '%s' (available handlers: %v)", i, name, HandlerNames(handlers))
+		}
+	}
+
+	for i := range routes {
+		r := &routes[i]
+		for k, name := range r.CatchAll {
+			if !handlerNames[name] {
+				return fmt.Errorf("route %d, catch_all %d: unknown handler '%s' (available handlers: %v)", i, k, name, HandlerNames(handlers))
+			}
+		}
+
+		var routeCatchAll []string
+		for _, name := range append(append([]string{}, catchAll...), r.CatchAll...) {
+			if name != r.Source && !slices.Contains(routeCatchAll, name) {
+				routeCatchAll = append(routeCatchAll, name)
+			}
+		}
+		for j := range r.Messages {
+			m := &r.Messages[j]
+			if len(m.Receivers) == 0 {
+				continue // Left for Validate to report
+			}
+			var receivers []string
+			for _, name := range routeCatchAll {
+				if !slices.Contains(m.Receivers, name) {
+					receivers = append(receivers, name)
+				}
+			}
+			m.Receivers = append(receivers, m.Receivers...)
+		}
+	}
+
+	return nil
+}
+
+// HandlerNames returns a list of handler names for error messages
+func HandlerNames(handlers []Handler) []string {
+	names := make([]string, len(handlers))
+	for i, h := range handlers {
+		names[i] = h.Name
+	}
+	return names
+}
@@ -0,0 +1,77 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+)
+
+// newTestRoutes returns handlers and a route through an intermediate and a terminal receiver
+func newTestRoutes() ([]Handler, []Route) {
+	handlers := []Handler{
+		{Name: "api", Type: "api.Api"},
+		{Name: "middleware", Type: "middleware.Middleware"},
+		{Name: "repository", Type: "repository.Repository"},
+	}
+	routes := []Route{{
+		Source: "api",
+		Messages: []MessageRoute{{
+			Message:   "*pb.CreateRequestProto",
+			Response:  "(*pb.CreateResponseProto, error)",
+			Receivers: []string{"middleware", "repository"},
+		}},
+	}}
+	return handlers, routes
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(routes []Route) []Route
+		want   string
+	}{
+		{"valid", func(routes []Route) []Route { return routes }, ""},
+		{"omitted response", func(routes []Route) []Route {
+			routes[0].Messages[0].Response = ""
+			return routes
+		}, ""},
+		{"unknown receiver", func(routes []Route) []Route {
+			routes[0].Messages[0].Receivers = []string{"missing"}
+			return routes
+		}, "unknown handler 'missing'"},
+		{"intermediate receiver with response", func(routes []Route) []Route {
+			return append(routes, Route{Source: "repository", Messages: []MessageRoute{{
+				Message:   "*pb.CreateRequestProto",
+				Response:  "(*pb.CreateResponseProto, error)",
+				Receivers: []string{"middleware"},
+			}}})
+		}, "must be response-less"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers, routes := newTestRoutes()
+			err := Validate(handlers, tt.modify(routes))
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Expected valid routes, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Expected an error containing %q, got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestOmittedResponseReturnsError(t *testing.T) {
+	for _, response := range []string{"", "error", " error "} {
+		m := MessageRoute{Response: response}
+		if !m.IsResponseless() || m.Returns() != "error" {
+			t.Errorf("Expected response %q to be response-less returning error, got %t and %q", response, m.IsResponseless(), m.Returns())
+		}
+	}
+	m := MessageRoute{Response: "(*pb.CreateResponseProto, error)"}
+	if m.IsResponseless() || m.Returns() != m.Response {
+		t.Errorf("Expected the declared response to be returned, got %q", m.Returns())
+	}
+}